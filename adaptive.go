@@ -0,0 +1,96 @@
+package gos
+
+import (
+	"sort"
+)
+
+// WithAdaptiveObjsPerSlab makes the capacity of new slabs grow with the
+// pool. The first slab of a pool has the number of objects per slab given
+// by WithObjsPerSlab, each further slab has twice the capacity of the
+// previous one up to the given maximum. Small pools waste little memory,
+// while large pools need fewer slabs and fewer calls to map memory
+func WithAdaptiveObjsPerSlab(maxObjsPerSlab uint) Option {
+	return func(o *ObjectStore) {
+		o.maxObjsPerSlab = maxObjsPerSlab
+	}
+}
+
+// setMaxObjsPerSlab makes the pool grow the capacity of its new slabs
+// up to the given number of objects per slab, see WithAdaptiveObjsPerSlab
+func (s *slabPool) setMaxObjsPerSlab(maxObjsPerSlab uint) {
+	if maxObjsPerSlab <= s.objsPerSlab {
+		return
+	}
+
+	s.maxObjsPerSlab = maxObjsPerSlab
+	for capacity := s.objsPerSlab * 2; capacity < maxObjsPerSlab; capacity *= 2 {
+		s.addCapacity(capacity)
+	}
+	s.addCapacity(maxObjsPerSlab)
+}
+
+// nextSlabCapacity returns the number of objects per slab of the next
+// slab of the pool, it doubles with each slab that the pool has
+// The caller must hold the pool lock
+func (s *slabPool) nextSlabCapacity() uint {
+	return s.slabCapacityAt(len(s.slabs))
+}
+
+// slabCapacityAt returns the number of objects per slab of a new slab
+// when the pool has the given number of slabs, see nextSlabCapacity
+func (s *slabPool) slabCapacityAt(slabs int) uint {
+	capacity := s.objsPerSlab
+	for i := 0; i < slabs && capacity < s.maxObjsPerSlab; i++ {
+		capacity *= 2
+	}
+	if s.maxObjsPerSlab > 0 && capacity > s.maxObjsPerSlab {
+		capacity = s.maxObjsPerSlab
+	}
+	return capacity
+}
+
+// addCapacity adds the given number of objects per slab to the capacities
+// which the slabs of the pool may have
+// The caller must hold the pools lock of the store for writing, because
+// snapshots rely on the capacities while they only hold it for reading
+func (s *slabPool) addCapacity(capacity uint) {
+	idx := sort.Search(len(s.capacities), func(i int) bool { return s.capacities[i] >= capacity })
+	if idx < len(s.capacities) && s.capacities[idx] == capacity {
+		return
+	}
+	s.capacities = append(s.capacities, 0)
+	copy(s.capacities[idx+1:], s.capacities[idx:])
+	s.capacities[idx] = capacity
+}
+
+// hasCapacity returns true if the slabs of the pool may have the given
+// number of objects per slab
+// The caller must hold the pools lock of the store
+func (s *slabPool) hasCapacity(capacity uint) bool {
+	idx := sort.Search(len(s.capacities), func(i int) bool { return s.capacities[i] >= capacity })
+	return idx < len(s.capacities) && s.capacities[idx] == capacity
+}
+
+// acquireSlabPoolWithCapacity is like acquireSlabPool, but the returned
+// pool accepts slabs with the given number of objects per slab. A pool
+// which doesn't exist yet gets it as its number of objects per slab
+func (o *ObjectStore) acquireSlabPoolWithCapacity(size uint8, capacity uint) *slabPool {
+	o.poolsLock.RLock()
+	pool, ok := o.slabPools[size]
+	for !ok || !pool.hasCapacity(capacity) {
+		o.poolsLock.RUnlock()
+
+		o.poolsLock.Lock()
+		if pool, ok = o.slabPools[size]; !ok {
+			pool = o.newSlabPool(size, capacity)
+			o.slabPools[size] = pool
+			o.lockFreePools[size].Store(pool)
+		}
+		pool.addCapacity(capacity)
+		o.poolsLock.Unlock()
+
+		o.poolsLock.RLock()
+		pool, ok = o.slabPools[size]
+	}
+	return pool
+}
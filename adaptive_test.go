@@ -0,0 +1,59 @@
+package gos
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdaptiveObjsPerSlab(t *testing.T) {
+	Convey("When adding objects to a store with adaptive objects per slab", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4), WithAdaptiveObjsPerSlab(16))
+		var objs []ObjAddr
+		for i := 0; i < 60; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("the capacity of the slabs should double up to the maximum", func() {
+			infos := os.Slabs()
+			capacities := make([]uint, 0, len(infos))
+			for _, info := range infos {
+				capacities = append(capacities, info.Capacity)
+				So(info.TotalBytes, ShouldEqual, slabSize(3, info.Capacity))
+			}
+			sort.Slice(capacities, func(i, j int) bool { return capacities[i] < capacities[j] })
+			So(capacities, ShouldResemble, []uint{4, 8, 16, 16, 16})
+		})
+
+		Convey("the memory statistics should account for each slab's capacity", func() {
+			stats := os.MemStats()
+			So(stats.Slabs, ShouldEqual, 5)
+			So(stats.LiveObjects, ShouldEqual, 60)
+			So(stats.FreeSlots, ShouldEqual, 0)
+			So(stats.MappedBytes, ShouldEqual, slabSize(3, 4)+slabSize(3, 8)+3*slabSize(3, 16))
+		})
+
+		Convey("a snapshot should restore the slabs with their capacities", func() {
+			var buf bytes.Buffer
+			_, err := os.WriteTo(&buf)
+			So(err, ShouldBeNil)
+
+			dst := NewObjectStore(WithObjsPerSlab(4))
+			table, err := dst.Restore(&buf)
+			So(err, ShouldBeNil)
+			So(len(dst.Slabs()), ShouldEqual, 5)
+			for i, obj := range objs {
+				newObj, ok := table.Translate(obj)
+				So(ok, ShouldBeTrue)
+				data, err := dst.Get(newObj)
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []byte{byte(i), 1, 2})
+			}
+			So(dst.CheckIntegrity(), ShouldBeNil)
+		})
+	})
+}
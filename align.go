@@ -0,0 +1,111 @@
+package gos
+
+import (
+	"math/bits"
+	"sync"
+	"unsafe"
+)
+
+// WithAlignedSlabs aligns every slab to a power of two boundary which is at
+// least its total size, so the slab of an object address can be found by
+// masking the address instead of a binary search over all slabs. The
+// alignment rounds the size of each slab up to a power of two, so slabs
+// whose size is a power of two, like with WithSlabSize, waste the least
+// memory. Twice the size of a slab gets reserved, but the pages outside of
+// the aligned part are never touched. It only affects the default backend
+// and can't be combined with WithHugePages, with WithCanaries the slabs are
+// not aligned anymore
+func WithAlignedSlabs() Option {
+	return func(o *ObjectStore) {
+		o.alignedSlabs = true
+	}
+}
+
+// slabAlignment returns the smallest power of two which is at least the
+// given size and the page size
+func slabAlignment(size int) int {
+	if size <= systemPageSize {
+		return systemPageSize
+	}
+	return 1 << bits.Len(uint(size-1))
+}
+
+// alignmentOf returns the alignment of the slab at the given address if it
+// is aligned to the power of two of its total length, otherwise 0
+func alignmentOf(sAddr SlabAddr) uintptr {
+	align := uintptr(1) << bits.Len(uint(slabFromSlabAddr(sAddr).getTotalLength()-1))
+	if sAddr&(align-1) != 0 {
+		return 0
+	}
+	return align
+}
+
+// alignedBackend wraps a backend and maps memory areas which are aligned to
+// the power of two of their size. Areas of up to a page are aligned anyway
+type alignedBackend struct {
+	Backend
+
+	lock sync.Mutex
+
+	// mappings maps the address of each aligned memory area to the whole
+	// mapping that it has been cut out of
+	mappings map[uintptr][]byte
+}
+
+// newAlignedBackend wraps the given backend with an alignedBackend
+func newAlignedBackend(backend Backend) Backend {
+	return &alignedBackend{Backend: backend, mappings: make(map[uintptr][]byte)}
+}
+
+// Map maps a memory area of twice the alignment of the given size and
+// returns the aligned part of it
+func (a *alignedBackend) Map(size int) ([]byte, error) {
+	align := slabAlignment(size)
+	if align == systemPageSize {
+		return a.Backend.Map(size)
+	}
+
+	mapping, err := a.Backend.Map(2 * align)
+	if err != nil {
+		return nil, err
+	}
+	addr := uintptr(unsafe.Pointer(&mapping[0]))
+	start := int((addr+uintptr(align)-1)&^uintptr(align-1) - addr)
+
+	a.lock.Lock()
+	a.mappings[addr+uintptr(start)] = mapping
+	a.lock.Unlock()
+
+	return mapping[start : start+size : start+size], nil
+}
+
+// Unmap unmaps the whole mapping of the given aligned memory area
+func (a *alignedBackend) Unmap(data []byte) error {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+
+	a.lock.Lock()
+	mapping, ok := a.mappings[addr]
+	delete(a.mappings, addr)
+	a.lock.Unlock()
+
+	if !ok {
+		return a.Backend.Unmap(data)
+	}
+	return a.Backend.Unmap(mapping)
+}
+
+// maskedSlab finds the slab of the given object address by masking it with
+// each of the given alignments, isSlab must return whether a slab starts at
+// the masked address
+// On success it returns the slab address and true
+// On failure it returns 0 and false
+func maskedSlab(obj ObjAddr, alignments uintptr, isSlab func(SlabAddr) bool) (SlabAddr, bool) {
+	for ; alignments != 0; alignments &= alignments - 1 {
+		align := alignments & -alignments
+		sAddr := obj &^ (align - 1)
+		if isSlab(sAddr) && obj < sAddr+slabFromSlabAddr(sAddr).getTotalLength() {
+			return sAddr, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,51 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAlignedSlabs(t *testing.T) {
+	Convey("When adding objects to a store with aligned slabs", t, func() {
+		os := NewObjectStore(WithAlignedSlabs(), WithSlabSize(3*systemPageSize))
+		backend, ok := os.backend.(*alignedBackend)
+		So(ok, ShouldBeTrue)
+
+		var objs []ObjAddr
+		for i := 0; i < 3*systemPageSize/8; i++ {
+			obj, err := os.Add([]byte{byte(i), byte(i >> 8), 1, 2, 3, 4, 5, 6})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(len(os.lookupTable), ShouldBeGreaterThan, 1)
+
+		Convey("each slab should be aligned to the power of two of its size", func() {
+			for _, slabAddr := range os.lookupTable {
+				So(slabAddr%uintptr(4*systemPageSize), ShouldEqual, 0)
+				So(alignmentOf(slabAddr), ShouldEqual, 4*systemPageSize)
+			}
+		})
+
+		Convey("the slab of each object should be found by masking its address", func() {
+			pool, _ := os.getSlabPool(8)
+			for _, obj := range objs {
+				_, owner, ok := os.Owner(obj)
+				So(ok, ShouldBeTrue)
+				slabAddr, ok := maskedSlab(obj, os.slabAlignments, os.isSlab)
+				So(ok, ShouldBeTrue)
+				So(slabAddr, ShouldEqual, owner)
+				So(pool.findSlab(obj), ShouldEqual, slabFromSlabAddr(owner))
+				So(os.Contains(obj), ShouldBeTrue)
+			}
+		})
+
+		Convey("deleting all objects should unmap the whole mappings", func() {
+			for _, obj := range objs {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(len(backend.mappings), ShouldEqual, 0)
+			So(len(os.lookupTable), ShouldEqual, 0)
+		})
+	})
+}
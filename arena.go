@@ -0,0 +1,188 @@
+package gos
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// errArenaUnsupported is returned by reserveArena on platforms which can't
+// reserve address space without allocating memory
+var errArenaUnsupported = errors.New("ObjectStore: arenas are not supported on this platform")
+
+// WithArena makes each pool reserve one contiguous memory area of the given
+// size and carve its slabs out of it. Creating a slab then is a pointer bump
+// instead of a syscall, and the slab of an object address is found by
+// arithmetic instead of a binary search. The pages of the area only get
+// allocated when they are used and the pages of deleted slabs get released,
+// but the address space stays reserved for the lifetime of the store.
+// Slabs which are larger than those of the pool's first slab, like with
+// WithAdaptiveObjsPerSlab, and slabs that don't fit into the arena anymore
+// are mapped by the default backend. Arenas are only supported on Unix and
+// only used with the default backend, WithHugePages, WithMlock,
+// WithNUMABinding and WithCanaries disable them
+func WithArena(reserveBytes int) Option {
+	return func(o *ObjectStore) {
+		o.arenaBytes = reserveBytes
+	}
+}
+
+// arena is a Backend which carves memory areas of up to stride bytes out of
+// a reserved memory area. Larger areas and areas that don't fit anymore get
+// mapped by the wrapped backend
+type arena struct {
+	Backend
+
+	// objSize is the object size of the pool that the arena belongs to
+	objSize uint8
+
+	// data is the reserved memory area, it is divided into chunks of
+	// stride bytes which start at page boundaries
+	data   []byte
+	base   uintptr
+	stride int
+
+	lock sync.Mutex
+
+	// next is the index of the first chunk that has never been used,
+	// free is a stack of the indexes of chunks that have been unmapped
+	next int
+	free []int
+
+	// live tells whether each chunk is in use, it is read without holding
+	// the lock when resolving object addresses
+	live []atomic.Bool
+}
+
+// newArena reserves a memory area of the given size for slabs of the given
+// object size and objects per slab, the wrapped backend maps the slabs
+// that don't fit into it
+// On success it returns the arena and nil
+// On failure the second returned value is the error
+func newArena(backend Backend, reserveBytes int, objSize uint8, objsPerSlab uint) (*arena, error) {
+	pageSize := systemPageSize
+	stride := (slabSize(objSize, objsPerSlab) + pageSize - 1) / pageSize * pageSize
+	chunks := reserveBytes / stride
+	if chunks < 1 {
+		return nil, fmt.Errorf("ObjectStore: arena of %d bytes is smaller than a slab of %d bytes", reserveBytes, stride)
+	}
+
+	data, err := reserveArena(chunks * stride)
+	if err != nil {
+		return nil, err
+	}
+
+	return &arena{
+		Backend: backend,
+		objSize: objSize,
+		data:    data,
+		base:    uintptr(unsafe.Pointer(&data[0])),
+		stride:  stride,
+		live:    make([]atomic.Bool, chunks),
+	}, nil
+}
+
+// Map returns a chunk of the arena if the given size fits into one, if it
+// doesn't or if the arena is full the wrapped backend maps the memory area
+func (a *arena) Map(size int) ([]byte, error) {
+	if size > a.stride {
+		return a.Backend.Map(size)
+	}
+
+	a.lock.Lock()
+	idx := -1
+	if len(a.free) > 0 {
+		idx = a.free[len(a.free)-1]
+		a.free = a.free[:len(a.free)-1]
+	} else if a.next < len(a.live) {
+		idx = a.next
+		a.next++
+	}
+	a.lock.Unlock()
+
+	if idx < 0 {
+		return a.Backend.Map(size)
+	}
+
+	a.live[idx].Store(true)
+	start := idx * a.stride
+	return a.data[start : start+size : start+size], nil
+}
+
+// Unmap releases the pages of the given chunk, so it reads as zeros when
+// it gets reused. Memory areas which are not in the arena get unmapped by
+// the wrapped backend
+func (a *arena) Unmap(data []byte) error {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	if addr < a.base || addr >= a.base+uintptr(len(a.data)) {
+		return a.Backend.Unmap(data)
+	}
+
+	idx := int(addr-a.base) / a.stride
+	if !a.live[idx].Load() || addr != a.base+uintptr(idx*a.stride) {
+		return fmt.Errorf("memory area at %#x is not a chunk of the arena: %w", addr, ErrNotFound)
+	}
+
+	start := idx * a.stride
+	discardMemory(a.data[start : start+a.stride])
+	a.live[idx].Store(false)
+
+	a.lock.Lock()
+	a.free = append(a.free, idx)
+	a.lock.Unlock()
+
+	return nil
+}
+
+// slabOf returns the address of the slab which contains the given object
+// address, if it is in a chunk of the arena that is in use
+// The second returned value is false if it isn't
+func (a *arena) slabOf(obj ObjAddr) (SlabAddr, bool) {
+	if obj < a.base || obj >= a.base+uintptr(len(a.data)) {
+		return 0, false
+	}
+	idx := (obj - a.base) / uintptr(a.stride)
+	if !a.live[idx].Load() {
+		return 0, false
+	}
+	return a.base + idx*uintptr(a.stride), true
+}
+
+// arenaFor returns the arena of the pool with the given object size, it
+// gets created with the given objects per slab if it doesn't exist yet. It
+// returns nil if the store doesn't use arenas with its backend, or if the
+// arena couldn't be reserved
+// The caller must hold the pools lock for writing
+func (o *ObjectStore) arenaFor(size uint8, objsPerSlab uint) *arena {
+	if o.arenaBytes <= 0 {
+		return nil
+	}
+	if o.backend != defaultBackend {
+		return nil
+	}
+
+	o.lookupLock.RLock()
+	for _, a := range o.arenas {
+		if a.objSize == size {
+			o.lookupLock.RUnlock()
+			return a
+		}
+	}
+	o.lookupLock.RUnlock()
+
+	a, err := newArena(o.backend, o.arenaBytes, size, objsPerSlab)
+	if err != nil {
+		if o.logger != nil {
+			o.logger.Warn("gos: failed to reserve arena", "objSize", size, "error", err)
+		}
+		return nil
+	}
+
+	o.lookupLock.Lock()
+	o.arenas = append(o.arenas, a)
+	o.lookupLock.Unlock()
+
+	return a
+}
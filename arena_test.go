@@ -0,0 +1,91 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestArena(t *testing.T) {
+	Convey("When adding objects to a store with arenas", t, func() {
+		os := NewObjectStore(WithArena(1<<20), WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 12; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(len(os.arenas), ShouldEqual, 1)
+		a := os.arenas[0]
+
+		Convey("the slabs should be consecutive chunks of the arena", func() {
+			for _, info := range os.Slabs() {
+				So(info.Addr, ShouldBeGreaterThanOrEqualTo, a.base)
+				So((info.Addr-a.base)%uintptr(a.stride), ShouldEqual, 0)
+				So((info.Addr-a.base)/uintptr(a.stride), ShouldBeLessThan, 3)
+			}
+		})
+
+		Convey("the slab of each object should be found by arithmetic", func() {
+			for i, obj := range objs {
+				_, slabAddr, ok := os.Owner(obj)
+				So(ok, ShouldBeTrue)
+				arenaSlab, ok := a.slabOf(obj)
+				So(ok, ShouldBeTrue)
+				So(arenaSlab, ShouldEqual, slabAddr)
+
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []byte{byte(i), 1, 2})
+			}
+		})
+
+		Convey("the chunk of a deleted slab should be reused with zeroed memory", func() {
+			_, slabAddr, _ := os.Owner(objs[0])
+			for _, obj := range objs {
+				if _, owner, _ := os.Owner(obj); owner == slabAddr {
+					So(os.Delete(obj), ShouldBeNil)
+				}
+			}
+			_, ok := a.slabOf(slabAddr)
+			So(ok, ShouldBeFalse)
+
+			obj, err := os.Add([]byte("xyz"))
+			So(err, ShouldBeNil)
+			_, newSlabAddr, _ := os.Owner(obj)
+			So(newSlabAddr, ShouldEqual, slabAddr)
+			So(os.CheckIntegrity(), ShouldBeNil)
+		})
+	})
+
+	Convey("When the slabs of a pool outgrow the chunks of its arena", t, func() {
+		os := NewObjectStore(WithArena(1<<22), WithObjsPerSlab(4), WithAdaptiveObjsPerSlab(1<<16))
+		var objs []ObjAddr
+		for i := 0; i < systemPageSize; i++ {
+			obj, err := os.Add([]byte{byte(i), byte(i >> 8), 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("only the larger slabs should be mapped outside of the arena", func() {
+			a := os.arenas[0]
+			var outside int
+			for _, obj := range objs {
+				_, slabAddr, _ := os.Owner(obj)
+				info, _ := os.SlabInfo(slabAddr)
+				_, ok := a.slabOf(obj)
+				So(ok, ShouldEqual, info.TotalBytes <= uint64(a.stride))
+				if !ok {
+					outside++
+				}
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(data[2], ShouldEqual, 2)
+			}
+			So(outside, ShouldBeGreaterThan, 0)
+		})
+	})
+}
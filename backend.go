@@ -0,0 +1,100 @@
+package gos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Backend allocates and releases the memory that is used by slabs
+// The memory returned by a Backend must not be managed by the Go GC
+type Backend interface {
+	// Map returns a zeroed, writable memory area of the given size
+	Map(size int) ([]byte, error)
+
+	// Unmap releases a memory area which has previously been returned by Map
+	Unmap(data []byte) error
+}
+
+// GuardPageBackend is a Backend that surrounds each memory area with
+// inaccessible guard pages. The memory area gets placed at the end of its
+// pages, so accessing memory beyond its end faults immediately instead of
+// corrupting a neighboring slab. Each memory area takes at least three
+// pages, so this is meant for debugging
+type GuardPageBackend struct{}
+
+// guardedLayout returns the length of the readable part of a guarded
+// mapping for a memory area of the given size, and the offset of the
+// memory area inside the whole mapping
+func guardedLayout(size int) (int, int) {
+	pageSize := systemPageSize
+	dataLen := (size + pageSize - 1) / pageSize * pageSize
+	return dataLen, pageSize + dataLen - size
+}
+
+// sliceAt returns a byte slice of the given size which refers to the
+// memory at addr
+func sliceAt(addr uintptr, size int) []byte {
+	var data []byte
+	dataHeader := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	dataHeader.Data = addr
+	dataHeader.Len = size
+	dataHeader.Cap = size
+	return data
+}
+
+// canaryPattern is combined with the address of a memory area
+// to get the value of its canary words
+const canaryPattern = 0x9E3779B97F4A7C15
+
+// canarySize is the size of a canary word
+const canarySize = 8
+
+// canaryBackend wraps a Backend and surrounds each memory area with canary
+// words, which can be verified by checkCanaries to detect stray writes
+type canaryBackend struct {
+	Backend
+}
+
+// Map obtains a memory area of the given size plus two canary words from
+// the wrapped backend, and writes the canaries before and after the area
+func (c canaryBackend) Map(size int) ([]byte, error) {
+	mapping, err := c.Backend.Map(size + 2*canarySize)
+	if err != nil {
+		return nil, err
+	}
+
+	data := mapping[canarySize : canarySize+size : canarySize+size]
+	canary := canaryPattern ^ uint64(objAddrFromObj(data))
+	binary.LittleEndian.PutUint64(mapping[:canarySize], canary)
+	binary.LittleEndian.PutUint64(mapping[canarySize+size:], canary)
+
+	return data, nil
+}
+
+// Unmap returns the given memory area together with its canaries to the
+// wrapped backend
+func (c canaryBackend) Unmap(data []byte) error {
+	var mapping []byte
+	mappingHeader := (*reflect.SliceHeader)(unsafe.Pointer(&mapping))
+	mappingHeader.Data = uintptr(unsafe.Pointer(&data[0])) - canarySize
+	mappingHeader.Len = len(data) + 2*canarySize
+	mappingHeader.Cap = mappingHeader.Len
+
+	return c.Backend.Unmap(mapping)
+}
+
+// checkCanaries verifies the canary words around the memory area at the
+// given address, which must have been obtained from a canaryBackend
+// On success it returns nil, otherwise it returns an error
+func checkCanaries(addr uintptr, size uintptr) error {
+	canary := canaryPattern ^ uint64(addr)
+	if binary.LittleEndian.Uint64(objFromObjAddr(addr-canarySize, canarySize)) != canary {
+		return fmt.Errorf("canary before the memory area has been overwritten")
+	}
+	if binary.LittleEndian.Uint64(objFromObjAddr(addr+size, canarySize)) != canary {
+		return fmt.Errorf("canary after the memory area has been overwritten")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+//go:build !unix && !windows
+// +build !unix,!windows
+
+package gos
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// MmapBackend is an alias of HeapBackend on platforms without mmap, so code
+// which selects the default backend explicitly builds on all platforms
+type MmapBackend = HeapBackend
+
+// errGuardPagesUnsupported is returned by GuardPageBackend on platforms
+// which can't protect memory
+var errGuardPagesUnsupported = errors.New("guard pages are not supported on this platform")
+
+// Map always fails on this platform
+func (GuardPageBackend) Map(size int) ([]byte, error) {
+	return nil, errGuardPagesUnsupported
+}
+
+// Unmap always fails on this platform
+func (GuardPageBackend) Unmap(data []byte) error {
+	return errGuardPagesUnsupported
+}
+
+// defaultBackend is the backend that is used if none has been specified
+var defaultBackend Backend = NewHeapBackend()
+
+// reserveArena always fails on this platform, so the pools map their
+// slabs with the default backend
+func reserveArena(size int) ([]byte, error) {
+	return nil, errArenaUnsupported
+}
+
+// mapFileReadOnly reads the first size bytes of the file into memory,
+// because files can't be mapped on this platform
+func mapFileReadOnly(file *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unmapFile releases the memory which has been returned by mapFileReadOnly
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package gos
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// faults calls fn and reports whether it caused a memory fault
+func faults(fn func()) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		faulted = recover() != nil
+	}()
+	fn()
+	return false
+}
+
+func TestGuardPageBackend(t *testing.T) {
+	Convey("When mapping memory with the guard page backend", t, func() {
+		backend := GuardPageBackend{}
+		data, err := backend.Map(100)
+		So(err, ShouldBeNil)
+		So(len(data), ShouldEqual, 100)
+		So(cap(data), ShouldEqual, 100)
+
+		Convey("the whole memory area should be writable", func() {
+			for i := range data {
+				data[i] = byte(i)
+			}
+			So(data[99], ShouldEqual, 99)
+			So(backend.Unmap(data), ShouldBeNil)
+		})
+
+		Convey("accessing the byte after the memory area should fault", func() {
+			beyond := objFromObjAddr(objAddrFromObj(data)+100, 1)
+			So(faults(func() { beyond[0] = 1 }), ShouldBeTrue)
+			So(backend.Unmap(data), ShouldBeNil)
+		})
+	})
+
+	Convey("When using guard pages in a store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(3), WithGuardPages())
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def", "ghi", "jkl"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("objects should be retrievable and deletable", func() {
+			obj, err := os.Get(objs[3])
+			So(err, ShouldBeNil)
+			So(string(obj), ShouldEqual, "jkl")
+			for _, obj := range objs {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(len(os.lookupTable), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestHeapBackend(t *testing.T) {
+	Convey("When using the heap backend in a store", t, func() {
+		backend := NewHeapBackend()
+		os := NewObjectStore(WithObjsPerSlab(2), WithBackend(backend))
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def", "ghi"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(len(backend.areas), ShouldEqual, 2)
+
+		Convey("objects should survive garbage collections", func() {
+			runtime.GC()
+			obj, found := os.Search([]byte("def"))
+			So(found, ShouldBeTrue)
+			So(obj, ShouldEqual, objs[1])
+			data, err := os.Get(objs[2])
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ghi")
+		})
+
+		Convey("deleting all objects of a slab should release its memory", func() {
+			So(os.Delete(objs[2]), ShouldBeNil)
+			So(len(backend.areas), ShouldEqual, 1)
+		})
+
+		Convey("unmapping unknown memory should fail", func() {
+			So(backend.Unmap(make([]byte, 8)), ShouldNotBeNil)
+		})
+	})
+}
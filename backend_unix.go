@@ -0,0 +1,85 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// MmapBackend is the default Backend, it uses anonymous private mmaps
+type MmapBackend struct{}
+
+// Map creates a new anonymous mapping of the given size
+func (MmapBackend) Map(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+// Unmap unmaps the given memory area
+func (MmapBackend) Unmap(data []byte) error {
+	if bulk, err := unmapBulk(data); bulk {
+		return err
+	}
+	if grown, err := unmapGrown(data); grown {
+		return err
+	}
+	return syscall.Munmap(data)
+}
+
+// reserveArena creates an anonymous mapping of the given size for an arena,
+// its pages only get allocated when they get accessed
+func reserveArena(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE|mapNoReserve)
+}
+
+// Map creates an anonymous mapping with a guard page before and after
+// the memory area of the given size
+func (GuardPageBackend) Map(size int) ([]byte, error) {
+	pageSize := systemPageSize
+	dataLen, offset := guardedLayout(size)
+
+	mapping, err := syscall.Mmap(-1, 0, dataLen+2*pageSize, syscall.PROT_NONE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mprotect(mapping[pageSize:pageSize+dataLen], syscall.PROT_READ|syscall.PROT_WRITE)
+	if err != nil {
+		syscall.Munmap(mapping)
+		return nil, err
+	}
+
+	return mapping[offset : offset+size : offset+size], nil
+}
+
+// Unmap unmaps the given memory area together with its guard pages
+func (GuardPageBackend) Unmap(data []byte) error {
+	pageSize := systemPageSize
+	dataLen, offset := guardedLayout(len(data))
+
+	// rebuild the byte slice of the whole mapping, as it has been
+	// returned by syscall.Mmap
+	var mapping []byte
+	mappingHeader := (*reflect.SliceHeader)(unsafe.Pointer(&mapping))
+	mappingHeader.Data = uintptr(unsafe.Pointer(&data[0])) - uintptr(offset)
+	mappingHeader.Len = dataLen + 2*pageSize
+	mappingHeader.Cap = mappingHeader.Len
+
+	return syscall.Munmap(mapping)
+}
+
+// defaultBackend is the backend that is used if none has been specified
+var defaultBackend Backend = MmapBackend{}
+
+// mapFileReadOnly maps the first size bytes of the file read-only
+func mapFileReadOnly(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile unmaps a mapping which has been created by mapFileReadOnly
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,127 @@
+//go:build windows
+// +build windows
+
+package gos
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// the allocation and protection flags of VirtualAlloc, which are missing in
+// the syscall package
+const (
+	memCommit  = 0x1000
+	memReserve = 0x2000
+	memRelease = 0x8000
+
+	pageNoAccess  = 0x01
+	pageReadWrite = 0x04
+)
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procVirtualAlloc   = kernel32.NewProc("VirtualAlloc")
+	procVirtualFree    = kernel32.NewProc("VirtualFree")
+	procVirtualProtect = kernel32.NewProc("VirtualProtect")
+)
+
+// VirtualAllocBackend is the default Backend on Windows, it commits private
+// memory with VirtualAlloc
+type VirtualAllocBackend struct{}
+
+// MmapBackend is an alias of VirtualAllocBackend on Windows, so code which
+// selects the default backend explicitly builds on all platforms
+type MmapBackend = VirtualAllocBackend
+
+// Map reserves and commits a new memory area of the given size
+func (VirtualAllocBackend) Map(size int) ([]byte, error) {
+	addr, err := virtualAlloc(size, pageReadWrite)
+	if err != nil {
+		return nil, err
+	}
+	return sliceAt(addr, size), nil
+}
+
+// Unmap releases the given memory area
+func (VirtualAllocBackend) Unmap(data []byte) error {
+	return virtualFree(uintptr(unsafe.Pointer(&data[0])))
+}
+
+// Map reserves a memory area of the given size with an inaccessible guard
+// page before and after it
+func (GuardPageBackend) Map(size int) ([]byte, error) {
+	pageSize := systemPageSize
+	dataLen, offset := guardedLayout(size)
+
+	addr, err := virtualAlloc(dataLen+2*pageSize, pageNoAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldProtect uint32
+	ok, _, err := procVirtualProtect.Call(addr+uintptr(pageSize), uintptr(dataLen), pageReadWrite, uintptr(unsafe.Pointer(&oldProtect)))
+	if ok == 0 {
+		virtualFree(addr)
+		return nil, err
+	}
+
+	return sliceAt(addr+uintptr(offset), size), nil
+}
+
+// Unmap releases the given memory area together with its guard pages
+func (GuardPageBackend) Unmap(data []byte) error {
+	_, offset := guardedLayout(len(data))
+	return virtualFree(uintptr(unsafe.Pointer(&data[0])) - uintptr(offset))
+}
+
+// defaultBackend is the backend that is used if none has been specified
+var defaultBackend Backend = VirtualAllocBackend{}
+
+// virtualAlloc reserves and commits memory of the given size with the
+// given page protection, the memory is zeroed
+func virtualAlloc(size int, protect uintptr) (uintptr, error) {
+	addr, _, err := procVirtualAlloc.Call(0, uintptr(size), memReserve|memCommit, protect)
+	if addr == 0 {
+		return 0, err
+	}
+	return addr, nil
+}
+
+// virtualFree releases the whole memory area which has been reserved by
+// virtualAlloc at the given address
+func virtualFree(addr uintptr) error {
+	ok, _, err := procVirtualFree.Call(addr, 0, memRelease)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// reserveArena always fails on this platform, so the pools map their
+// slabs with the default backend
+func reserveArena(size int) ([]byte, error) {
+	return nil, errArenaUnsupported
+}
+
+// mapFileReadOnly maps the first size bytes of the file read-only
+func mapFileReadOnly(file *os.File, size int) ([]byte, error) {
+	mapping, err := syscall.CreateFileMapping(syscall.Handle(file.Fd()), nil, syscall.PAGE_READONLY, 0, uint32(size), nil)
+	if err != nil {
+		return nil, err
+	}
+	// the view keeps the mapping alive after its handle has been closed
+	defer syscall.CloseHandle(mapping)
+
+	addr, err := syscall.MapViewOfFile(mapping, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, err
+	}
+	return sliceAt(addr, size), nil
+}
+
+// unmapFile unmaps a mapping which has been created by mapFileReadOnly
+func unmapFile(data []byte) error {
+	return syscall.UnmapViewOfFile(uintptr(unsafe.Pointer(&data[0])))
+}
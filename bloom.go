@@ -0,0 +1,74 @@
+package gos
+
+// bloomBitsPerObj is the number of counters per object slot in a bloom filter
+const bloomBitsPerObj = 10
+
+// bloomHashCount is the number of hash functions used by a bloom filter
+const bloomHashCount = 4
+
+// bloomFilter is a counting bloom filter, which makes it possible to
+// remove values again when objects get deleted from a slab
+// it is not safe for concurrent use, the owning slab pool's lock
+// protects it
+type bloomFilter struct {
+	counters []uint8
+}
+
+// newBloomFilter creates a bloom filter sized for the given number of objects
+func newBloomFilter(objsPerSlab uint) *bloomFilter {
+	return &bloomFilter{counters: make([]uint8, objsPerSlab*bloomBitsPerObj+1)}
+}
+
+// bloomHash returns the 64bit FNV-1a hash of the given data, the positions
+// in the filter are derived from it
+func bloomHash(data []byte) uint64 {
+	hash := uint64(14695981039346656037)
+	for _, b := range data {
+		hash ^= uint64(b)
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+// positions calls fn with each counter position of the given hash
+// it uses double hashing to derive bloomHashCount positions from one hash
+func (b *bloomFilter) positions(hash uint64, fn func(pos uint64)) {
+	h1 := hash & 0xffffffff
+	h2 := hash>>32 | 1
+	m := uint64(len(b.counters))
+	for i := uint64(0); i < bloomHashCount; i++ {
+		fn((h1 + i*h2) % m)
+	}
+}
+
+// add adds the value with the given hash to the filter
+func (b *bloomFilter) add(hash uint64) {
+	b.positions(hash, func(pos uint64) {
+		// a saturated counter can never be decremented again,
+		// otherwise we could get false negatives
+		if b.counters[pos] < 255 {
+			b.counters[pos]++
+		}
+	})
+}
+
+// remove removes the value with the given hash from the filter
+func (b *bloomFilter) remove(hash uint64) {
+	b.positions(hash, func(pos uint64) {
+		if b.counters[pos] > 0 && b.counters[pos] < 255 {
+			b.counters[pos]--
+		}
+	})
+}
+
+// mayContain returns false if the value with the given hash is definitely
+// not in the filter, if it returns true the value might be in the filter
+func (b *bloomFilter) mayContain(hash uint64) bool {
+	found := true
+	b.positions(hash, func(pos uint64) {
+		if b.counters[pos] == 0 {
+			found = false
+		}
+	})
+	return found
+}
@@ -0,0 +1,42 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBloomFilter(t *testing.T) {
+	Convey("When adding values to a bloom filter", t, func() {
+		filter := newBloomFilter(100)
+		for i := 0; i < 100; i++ {
+			filter.add(bloomHash([]byte(fmt.Sprintf("%05d", i))))
+		}
+
+		Convey("it should report all of them as possibly contained", func() {
+			for i := 0; i < 100; i++ {
+				So(filter.mayContain(bloomHash([]byte(fmt.Sprintf("%05d", i)))), ShouldBeTrue)
+			}
+		})
+
+		Convey("most values that haven't been added should be rejected", func() {
+			falsePositives := 0
+			for i := 100; i < 1100; i++ {
+				if filter.mayContain(bloomHash([]byte(fmt.Sprintf("%05d", i)))) {
+					falsePositives++
+				}
+			}
+			So(falsePositives, ShouldBeLessThan, 50)
+		})
+
+		Convey("after removing all values nothing should be contained anymore", func() {
+			for i := 0; i < 100; i++ {
+				filter.remove(bloomHash([]byte(fmt.Sprintf("%05d", i))))
+			}
+			for i := 0; i < 100; i++ {
+				So(filter.mayContain(bloomHash([]byte(fmt.Sprintf("%05d", i)))), ShouldBeFalse)
+			}
+		})
+	})
+}
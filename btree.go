@@ -0,0 +1,630 @@
+package gos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// btreeDegree is the minimum degree of a BTreeIndex, each node except the
+// root has between btreeDegree-1 and 2*btreeDegree-1 entries
+const btreeDegree = 5
+
+// btreeMaxEntries is the maximum number of entries of a node
+const btreeMaxEntries = 2*btreeDegree - 1
+
+// btreeChildren is the offset of the child addresses in a node
+const btreeChildren = 2 + btreeMaxEntries*16
+
+// btreeNodeSize is the size of a node object of a BTreeIndex. A node starts
+// with a leaf flag and its number of entries, followed by the entries and
+// the address of each child node. An entry consists of the address of a
+// copy of its key and the address of its object
+const btreeNodeSize = btreeChildren + (btreeMaxEntries+1)*8
+
+// btreeNode is the memory of a node object of a BTreeIndex
+type btreeNode []byte
+
+// leaf returns true if the node has no children
+func (n btreeNode) leaf() bool {
+	return n[0] == 1
+}
+
+// count returns the number of entries of the node
+func (n btreeNode) count() int {
+	return int(n[1])
+}
+
+// setCount sets the number of entries of the node
+func (n btreeNode) setCount(count int) {
+	n[1] = uint8(count)
+}
+
+// keyAddr returns the address of the key copy of the entry at the given
+// index, 0 refers to an empty key
+func (n btreeNode) keyAddr(i int) ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(n[2+i*16:]))
+}
+
+// obj returns the object address of the entry at the given index
+func (n btreeNode) obj(i int) ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(n[2+i*16+8:]))
+}
+
+// setEntry sets the key copy address and the object address of the entry
+// at the given index
+func (n btreeNode) setEntry(i int, keyAddr, obj ObjAddr) {
+	binary.LittleEndian.PutUint64(n[2+i*16:], uint64(keyAddr))
+	binary.LittleEndian.PutUint64(n[2+i*16+8:], uint64(obj))
+}
+
+// entry returns the memory of the entry at the given index
+func (n btreeNode) entry(i int) []byte {
+	return n[2+i*16 : 2+i*16+16]
+}
+
+// entries returns the memory of the entries from the given index on
+func (n btreeNode) entries(from int) []byte {
+	return n[2+from*16 : btreeChildren]
+}
+
+// child returns the address of the child node at the given index
+func (n btreeNode) child(i int) ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(n[btreeChildren+i*8:]))
+}
+
+// setChild sets the address of the child node at the given index
+func (n btreeNode) setChild(i int, child ObjAddr) {
+	binary.LittleEndian.PutUint64(n[btreeChildren+i*8:], uint64(child))
+}
+
+// children returns the memory of the child addresses from the given index on
+func (n btreeNode) children(from int) []byte {
+	return n[btreeChildren+from*8 : btreeNodeSize]
+}
+
+// BTreeIndex is an ordered index over the objects of an ObjectStore, the
+// objects get ordered by the keys which a caller supplied function
+// extracts from them, objects with equal keys are ordered by their
+// addresses. The nodes and copies of the keys are objects of a separate
+// ObjectStore, so large indexes don't live on the Go heap. The index
+// follows the objects which get moved by compactions of the indexed store,
+// but objects must be deleted from the index before they get deleted from
+// the store. A BTreeIndex is safe for concurrent use
+type BTreeIndex struct {
+	objects *ObjectStore
+	key     func(obj []byte) []byte
+	nodes   *ObjectStore
+
+	// lock protects the nodes
+	lock  sync.RWMutex
+	root  ObjAddr
+	count int
+
+	removeListener func()
+}
+
+// NewBTreeIndex creates an empty index over the objects of the given store,
+// key returns the key of an object, it must always return the same key for
+// the same object data and keys must not be longer than 255 bytes. The
+// nodes get stored in an ObjectStore created with the given options
+// On success it returns the index and nil
+// On failure the second returned value is the error
+func NewBTreeIndex(objects *ObjectStore, key func(obj []byte) []byte, opts ...Option) (*BTreeIndex, error) {
+	t, err := newBTreeIndex(objects, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	t.removeListener = objects.AddRelocationListener(t.relocate)
+
+	return t, nil
+}
+
+// newBTreeIndex creates an empty index like NewBTreeIndex, but the index
+// doesn't follow the objects which get moved by compactions, its owner
+// must update the moved entries
+// On success it returns the index and nil
+// On failure the second returned value is the error
+func newBTreeIndex(objects *ObjectStore, key func(obj []byte) []byte, opts ...Option) (*BTreeIndex, error) {
+	t := &BTreeIndex{
+		objects: objects,
+		key:     key,
+		nodes:   NewObjectStore(opts...),
+	}
+
+	root, err := t.newNode(true)
+	if err != nil {
+		return nil, err
+	}
+	t.root = root
+
+	return t, nil
+}
+
+// newNode adds an empty node to the node store
+// On success it returns the address of the node and nil
+// On failure the second returned value is the error
+func (t *BTreeIndex) newNode(leaf bool) (ObjAddr, error) {
+	addr, data, err := t.nodes.AddReserve(btreeNodeSize)
+	if err != nil {
+		return 0, err
+	}
+	if leaf {
+		data[0] = 1
+	}
+	return addr, nil
+}
+
+// node returns the memory of the node at the given address
+func (t *BTreeIndex) node(addr ObjAddr) btreeNode {
+	return btreeNode(sliceAt(addr, btreeNodeSize))
+}
+
+// keyAt returns the key copy of the entry at the given index of the given
+// node
+func (t *BTreeIndex) keyAt(n btreeNode, i int) []byte {
+	if n.keyAddr(i) == 0 {
+		return nil
+	}
+	key, _ := t.nodes.Get(n.keyAddr(i))
+	return key
+}
+
+// keyOf returns the key of the indexed object at the given address
+// On success it returns the key and nil
+// On failure the second returned value is the error
+func (t *BTreeIndex) keyOf(obj ObjAddr) ([]byte, error) {
+	data, err := t.objects.GetChecked(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	key := t.key(data)
+	if len(key) > 255 {
+		return nil, fmt.Errorf("BTreeIndex: size of key (%d) is outside limits (0-%d): %w", len(key), 255, ErrInvalidSize)
+	}
+
+	return key, nil
+}
+
+// compare compares the given key and object address with the entry at the
+// given index of the given node, entries are ordered by their keys first
+// and by their object addresses second
+func (t *BTreeIndex) compare(key []byte, obj ObjAddr, n btreeNode, i int) int {
+	if c := bytes.Compare(key, t.keyAt(n, i)); c != 0 {
+		return c
+	}
+	switch entry := n.obj(i); {
+	case obj < entry:
+		return -1
+	case obj > entry:
+		return 1
+	}
+	return 0
+}
+
+// Insert adds the object at the given address to the index
+// On success it returns nil, otherwise it returns an error
+func (t *BTreeIndex) Insert(obj ObjAddr) error {
+	key, err := t.keyOf(obj)
+	if err != nil {
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.insert(key, obj)
+}
+
+// insert implements Insert, full nodes get split on the way down so the
+// entry can always be added to a leaf
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) insert(key []byte, obj ObjAddr) error {
+	if t.node(t.root).count() == btreeMaxEntries {
+		root, err := t.newNode(false)
+		if err != nil {
+			return err
+		}
+		t.node(root).setChild(0, t.root)
+		if err := t.split(root, 0); err != nil {
+			t.nodes.Delete(root)
+			return err
+		}
+		t.root = root
+	}
+
+	var keyAddr ObjAddr
+	if len(key) > 0 {
+		var err error
+		if keyAddr, err = t.nodes.Add(key); err != nil {
+			return err
+		}
+	}
+
+	addr := t.root
+	for {
+		n := t.node(addr)
+		i := n.count() - 1
+		for i >= 0 && t.compare(key, obj, n, i) < 0 {
+			i--
+		}
+		i++
+
+		if n.leaf() {
+			copy(n.entries(i+1), n.entries(i))
+			n.setEntry(i, keyAddr, obj)
+			n.setCount(n.count() + 1)
+			t.count++
+			return nil
+		}
+
+		if t.node(n.child(i)).count() == btreeMaxEntries {
+			if err := t.split(addr, i); err != nil {
+				if keyAddr != 0 {
+					t.nodes.Delete(keyAddr)
+				}
+				return err
+			}
+			if t.compare(key, obj, n, i) > 0 {
+				i++
+			}
+		}
+		addr = n.child(i)
+	}
+}
+
+// split splits the full child at the given index of the given node into
+// two nodes, its median entry moves up into the given node
+// On success it returns nil, otherwise it returns an error and the nodes
+// are unchanged
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) split(parentAddr ObjAddr, i int) error {
+	parent := t.node(parentAddr)
+	left := t.node(parent.child(i))
+
+	rightAddr, err := t.newNode(left.leaf())
+	if err != nil {
+		return err
+	}
+	right := t.node(rightAddr)
+
+	copy(right.entries(0), left.entries(btreeDegree))
+	if !left.leaf() {
+		copy(right.children(0), left.children(btreeDegree))
+	}
+	right.setCount(btreeDegree - 1)
+	left.setCount(btreeDegree - 1)
+
+	copy(parent.children(i+2), parent.children(i + 1)[:(parent.count()-i)*8])
+	parent.setChild(i+1, rightAddr)
+	copy(parent.entries(i+1), parent.entries(i))
+	copy(parent.entry(i), left.entry(btreeDegree-1))
+	parent.setCount(parent.count() + 1)
+
+	return nil
+}
+
+// Delete removes the object at the given address from the index, the
+// object must still be in the indexed store
+// On success it returns true if the object has been removed or false if
+// it wasn't part of the index, and nil
+// On failure the second returned value is the error
+func (t *BTreeIndex) Delete(obj ObjAddr) (bool, error) {
+	key, err := t.keyOf(obj)
+	if err != nil {
+		return false, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.delete(key, obj), nil
+}
+
+// delete implements Delete, it removes the entry and deletes its key copy
+// It returns true if the entry has been removed
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) delete(key []byte, obj ObjAddr) bool {
+	keyAddr, deleted := t.deleteFrom(t.root, key, obj)
+
+	root := t.node(t.root)
+	if root.count() == 0 && !root.leaf() {
+		oldRoot := t.root
+		t.root = root.child(0)
+		t.nodes.Delete(oldRoot)
+	}
+	if !deleted {
+		return false
+	}
+
+	if keyAddr != 0 {
+		t.nodes.Delete(keyAddr)
+	}
+	t.count--
+
+	return true
+}
+
+// deleteFrom removes the entry of the given key and object address from
+// the subtree of the given node, nodes with the minimum number of entries
+// get filled up on the way down so an entry can always be removed
+// It returns the address of the key copy of the removed entry and true if
+// the entry has been removed
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) deleteFrom(addr ObjAddr, key []byte, obj ObjAddr) (ObjAddr, bool) {
+	var removed ObjAddr
+	found := false
+
+	for {
+		n := t.node(addr)
+		i := 0
+		c := 1
+		for i < n.count() {
+			if c = t.compare(key, obj, n, i); c <= 0 {
+				break
+			}
+			i++
+		}
+
+		if i < n.count() && c == 0 {
+			// once the entry got replaced by its predecessor or successor,
+			// that one gets removed from its old position, but the key copy
+			// of the replaced entry is the one to delete
+			if !found {
+				removed, found = n.keyAddr(i), true
+			}
+			if n.leaf() {
+				copy(n.entries(i), n.entries(i+1))
+				n.setCount(n.count() - 1)
+				return removed, true
+			}
+
+			left, right := t.node(n.child(i)), t.node(n.child(i+1))
+			switch {
+			case left.count() >= btreeDegree:
+				pred, idx := t.last(n.child(i))
+				copy(n.entry(i), pred.entry(idx))
+				addr, key, obj = n.child(i), t.keyAt(pred, idx), pred.obj(idx)
+			case right.count() >= btreeDegree:
+				succ := t.first(n.child(i + 1))
+				copy(n.entry(i), succ.entry(0))
+				addr, key, obj = n.child(i+1), t.keyAt(succ, 0), succ.obj(0)
+			default:
+				t.merge(addr, i)
+				addr = n.child(i)
+			}
+			continue
+		}
+
+		if n.leaf() {
+			return removed, found
+		}
+
+		// make sure that the child has more than the minimum number of
+		// entries before descending into it
+		if t.node(n.child(i)).count() < btreeDegree {
+			switch {
+			case i > 0 && t.node(n.child(i-1)).count() >= btreeDegree:
+				t.rotateRight(addr, i-1)
+			case i < n.count() && t.node(n.child(i+1)).count() >= btreeDegree:
+				t.rotateLeft(addr, i)
+			case i < n.count():
+				t.merge(addr, i)
+			default:
+				t.merge(addr, i-1)
+				i--
+			}
+		}
+		addr = n.child(i)
+	}
+}
+
+// first returns the leftmost leaf of the subtree of the given node
+// The caller must hold the index lock
+func (t *BTreeIndex) first(addr ObjAddr) btreeNode {
+	n := t.node(addr)
+	for !n.leaf() {
+		n = t.node(n.child(0))
+	}
+	return n
+}
+
+// last returns the rightmost leaf of the subtree of the given node and the
+// index of its last entry
+// The caller must hold the index lock
+func (t *BTreeIndex) last(addr ObjAddr) (btreeNode, int) {
+	n := t.node(addr)
+	for !n.leaf() {
+		n = t.node(n.child(n.count()))
+	}
+	return n, n.count() - 1
+}
+
+// merge merges the child at the given index + 1 of the given node and the
+// entry at the given index into the child at the given index
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) merge(parentAddr ObjAddr, i int) {
+	parent := t.node(parentAddr)
+	left := t.node(parent.child(i))
+	rightAddr := parent.child(i + 1)
+	right := t.node(rightAddr)
+
+	copy(left.entry(left.count()), parent.entry(i))
+	copy(left.entries(left.count()+1), right.entries(0)[:right.count()*16])
+	if !left.leaf() {
+		copy(left.children(left.count()+1), right.children(0)[:(right.count()+1)*8])
+	}
+	left.setCount(left.count() + 1 + right.count())
+
+	copy(parent.entries(i), parent.entries(i+1))
+	copy(parent.children(i+1), parent.children(i+2))
+	parent.setCount(parent.count() - 1)
+
+	t.nodes.Delete(rightAddr)
+}
+
+// rotateRight moves the entry at the given index of the given node into
+// the child at the given index + 1, and the last entry of the child at the
+// given index up into the node
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) rotateRight(parentAddr ObjAddr, i int) {
+	parent := t.node(parentAddr)
+	left, right := t.node(parent.child(i)), t.node(parent.child(i+1))
+
+	copy(right.entries(1), right.entries(0))
+	copy(right.entry(0), parent.entry(i))
+	if !right.leaf() {
+		copy(right.children(1), right.children(0)[:(right.count()+1)*8])
+		right.setChild(0, left.child(left.count()))
+	}
+	right.setCount(right.count() + 1)
+
+	copy(parent.entry(i), left.entry(left.count()-1))
+	left.setCount(left.count() - 1)
+}
+
+// rotateLeft moves the entry at the given index of the given node into the
+// child at the given index, and the first entry of the child at the given
+// index + 1 up into the node
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) rotateLeft(parentAddr ObjAddr, i int) {
+	parent := t.node(parentAddr)
+	left, right := t.node(parent.child(i)), t.node(parent.child(i+1))
+
+	copy(left.entry(left.count()), parent.entry(i))
+	if !left.leaf() {
+		left.setChild(left.count()+1, right.child(0))
+		copy(right.children(0), right.children(1))
+	}
+	left.setCount(left.count() + 1)
+
+	copy(parent.entry(i), right.entry(0))
+	copy(right.entries(0), right.entries(1))
+	right.setCount(right.count() - 1)
+}
+
+// Range calls fn with the key and the address of each object whose key is
+// at least from and less than to in ascending order, until fn returns
+// false. A nil from or to leaves the range unbounded on that side. The key
+// is only valid during the call and fn must not modify the index
+func (t *BTreeIndex) Range(from, to []byte, fn func(key []byte, obj ObjAddr) bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	t.rangeFrom(t.root, from, to, fn)
+}
+
+// rangeFrom implements Range for the subtree of the given node
+// It returns false if the iteration has been stopped
+// The caller must hold the index lock
+func (t *BTreeIndex) rangeFrom(addr ObjAddr, from, to []byte, fn func(key []byte, obj ObjAddr) bool) bool {
+	n := t.node(addr)
+	for i := 0; i < n.count(); i++ {
+		key := t.keyAt(n, i)
+
+		// the entries of the child before the entry aren't greater than it
+		if from != nil && bytes.Compare(key, from) < 0 {
+			continue
+		}
+		if !n.leaf() && !t.rangeFrom(n.child(i), from, to, fn) {
+			return false
+		}
+		if to != nil && bytes.Compare(key, to) >= 0 {
+			return false
+		}
+		if !fn(key, n.obj(i)) {
+			return false
+		}
+	}
+
+	if !n.leaf() {
+		return t.rangeFrom(n.child(n.count()), from, to, fn)
+	}
+	return true
+}
+
+// Find returns the address of the first object with the given key
+// When found it returns the object address and true, otherwise the second
+// returned value is false
+func (t *BTreeIndex) Find(key []byte) (ObjAddr, bool) {
+	var found ObjAddr
+	t.Range(key, nil, func(k []byte, obj ObjAddr) bool {
+		if bytes.Equal(k, key) {
+			found = obj
+		}
+		return false
+	})
+
+	return found, found != 0
+}
+
+// Len returns the number of objects in the index
+func (t *BTreeIndex) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.count
+}
+
+// MemStats returns the memory usage statistics of the store of the nodes
+// and key copies
+func (t *BTreeIndex) MemStats() StoreMemStats {
+	return t.nodes.MemStats()
+}
+
+// relocate updates the entry of an object which has been moved by a
+// compaction, it is registered as a relocation listener of the indexed
+// store. Entries get compared by their key copies, so the entries of
+// other moved objects which haven't been updated yet don't interfere
+func (t *BTreeIndex) relocate(oldAddr, newAddr ObjAddr) {
+	key, err := t.keyOf(newAddr)
+	if err != nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.delete(key, oldAddr) {
+		return
+	}
+	if err := t.insert(key, newAddr); err != nil && t.objects.logger != nil {
+		t.objects.logger.Warn("gos: failed to update moved object in btree index", "obj", newAddr, "error", err)
+	}
+}
+
+// Close deletes all nodes and key copies of the index and stops following
+// the objects which get moved by compactions, the index must not be used
+// anymore afterwards
+func (t *BTreeIndex) Close() {
+	if t.removeListener != nil {
+		t.removeListener()
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.deleteNodes(t.root)
+	t.root = 0
+	t.count = 0
+}
+
+// deleteNodes deletes the node at the given address, its key copies and
+// all its children
+// The caller must hold the index lock for writing
+func (t *BTreeIndex) deleteNodes(addr ObjAddr) {
+	n := t.node(addr)
+	for i := 0; i < n.count(); i++ {
+		if n.keyAddr(i) != 0 {
+			t.nodes.Delete(n.keyAddr(i))
+		}
+	}
+	if !n.leaf() {
+		for i := 0; i <= n.count(); i++ {
+			t.deleteNodes(n.child(i))
+		}
+	}
+	t.nodes.Delete(addr)
+}
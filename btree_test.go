@@ -0,0 +1,126 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// collectKeys returns the keys of the objects which Range visits
+func collectKeys(t *BTreeIndex, from, to []byte) []string {
+	var keys []string
+	t.Range(from, to, func(key []byte, obj ObjAddr) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	return keys
+}
+
+func TestBTreeIndex(t *testing.T) {
+	Convey("When indexing many objects by a key prefix", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(16))
+		idx, err := NewBTreeIndex(os, func(obj []byte) []byte { return obj[:4] })
+		So(err, ShouldBeNil)
+
+		objs := make(map[int]ObjAddr)
+		for i := 0; i < 1000; i++ {
+			k := (i * 7919) % 1000
+			obj, err := os.Add([]byte(fmt.Sprintf("%04d-payload", k)))
+			So(err, ShouldBeNil)
+			So(idx.Insert(obj), ShouldBeNil)
+			objs[k] = obj
+		}
+
+		Convey("Range should visit all objects in order of their keys", func() {
+			So(idx.Len(), ShouldEqual, 1000)
+			keys := collectKeys(idx, nil, nil)
+			So(len(keys), ShouldEqual, 1000)
+			for i, key := range keys {
+				So(key, ShouldEqual, fmt.Sprintf("%04d", i))
+			}
+		})
+
+		Convey("Range should respect its bounds", func() {
+			keys := collectKeys(idx, []byte("0100"), []byte("0110"))
+			So(len(keys), ShouldEqual, 10)
+			So(keys[0], ShouldEqual, "0100")
+			So(keys[9], ShouldEqual, "0109")
+			So(len(collectKeys(idx, []byte("0990"), nil)), ShouldEqual, 10)
+			So(len(collectKeys(idx, nil, []byte("0010"))), ShouldEqual, 10)
+		})
+
+		Convey("Find should return the object of a key", func() {
+			obj, ok := idx.Find([]byte("0500"))
+			So(ok, ShouldBeTrue)
+			So(obj, ShouldEqual, objs[500])
+			_, ok = idx.Find([]byte("5000"))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("objects with equal keys should all be indexed", func() {
+			dup, err := os.Add([]byte("0500-another"))
+			So(err, ShouldBeNil)
+			So(idx.Insert(dup), ShouldBeNil)
+			count := 0
+			idx.Range([]byte("0500"), []byte("0501"), func(key []byte, obj ObjAddr) bool {
+				So(obj == dup || obj == objs[500], ShouldBeTrue)
+				count++
+				return true
+			})
+			So(count, ShouldEqual, 2)
+		})
+
+		Convey("deleted objects should not be visited anymore", func() {
+			for k := 0; k < 1000; k += 2 {
+				deleted, err := idx.Delete(objs[k])
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeTrue)
+			}
+			deleted, err := idx.Delete(objs[0])
+			So(err, ShouldBeNil)
+			So(deleted, ShouldBeFalse)
+			So(idx.Len(), ShouldEqual, 500)
+
+			keys := collectKeys(idx, nil, nil)
+			So(len(keys), ShouldEqual, 500)
+			for i, key := range keys {
+				So(key, ShouldEqual, fmt.Sprintf("%04d", i*2+1))
+			}
+
+			for k := 1; k < 1000; k += 2 {
+				deleted, err := idx.Delete(objs[k])
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeTrue)
+			}
+			So(idx.Len(), ShouldEqual, 0)
+			So(idx.MemStats().LiveObjects, ShouldEqual, 1)
+		})
+
+		Convey("objects moved by a compaction should be followed", func() {
+			for k := 0; k < 1000; k++ {
+				if k%5 != 0 {
+					_, err := idx.Delete(objs[k])
+					So(err, ShouldBeNil)
+					So(os.Delete(objs[k]), ShouldBeNil)
+				}
+			}
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldBeGreaterThan, 0)
+
+			So(idx.Len(), ShouldEqual, 200)
+			idx.Range(nil, nil, func(key []byte, obj ObjAddr) bool {
+				data, err := os.GetChecked(obj)
+				So(err, ShouldBeNil)
+				So(string(data[:4]), ShouldEqual, string(key))
+				return true
+			})
+		})
+
+		Convey("closing the index should delete all of its nodes", func() {
+			idx.Close()
+			So(idx.MemStats().LiveObjects, ShouldEqual, 0)
+		})
+	})
+}
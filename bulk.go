@@ -0,0 +1,61 @@
+package gos
+
+// bulkMapper can be implemented by a Backend which can map several memory
+// areas with a single mapping. Each memory area still gets unmapped on its
+// own, the backend needs to track which mapping it belongs to
+type bulkMapper interface {
+	mapBulk(sizes []int) ([][]byte, error)
+}
+
+// addSlabsBulk adds the slabs which are required for n more free slots
+// with a single call to the backend, if more than one slab is required and
+// the backend implements bulkMapper
+// It returns the addresses of the added slabs. If it returns none, the
+// caller needs to add the slabs one by one
+// The caller must hold the pool lock for writing
+func (s *slabPool) addSlabsBulk(n uint) []SlabAddr {
+	mapper, ok := s.backend.(bulkMapper)
+	if !ok {
+		return nil
+	}
+
+	// the capacities are the same which addSlab would give the slabs
+	var capacities []uint
+	var sizes []int
+	var total uint64
+	for free := uint(0); free < n; {
+		capacity := s.slabCapacityAt(len(s.slabs) + len(capacities))
+		size := growableSlabSize(s.objSize, capacity, s.reservedCapacity(capacity))
+		capacities = append(capacities, capacity)
+		sizes = append(sizes, size)
+		total += uint64(size)
+		free += capacity
+	}
+	if len(capacities) < 2 {
+		return nil
+	}
+
+	// if the slabs don't fit into the memory limit together, adding them
+	// one by one reports the error
+	if !s.mem.reserve(total) {
+		return nil
+	}
+
+	areas, err := mapper.mapBulk(sizes)
+	if err != nil {
+		s.mem.release(total)
+		if s.logger != nil {
+			s.logger.Warn("gos: failed to map slabs in bulk", "objSize", s.objSize, "slabs", len(sizes), "error", err)
+		}
+		return nil
+	}
+
+	newSlabs := make([]SlabAddr, len(areas))
+	for i, area := range areas {
+		sl := initGrowableSlab(area, s.objSize, capacities[i], s.reservedCapacity(capacities[i]))
+		s.setupSlab(sl)
+		newSlabs[i] = sl.addr()
+	}
+
+	return newSlabs
+}
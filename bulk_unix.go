@@ -0,0 +1,95 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// errBulkArea is returned when growing a memory area which is part of a
+// bulk mapping, because the memory after it belongs to the next area
+var errBulkArea = errors.New("memory area is part of a bulk mapping")
+
+// bulkMapping is a mapping which has been split into several memory areas
+// by MmapBackend.mapBulk, live is the number of them that are still in use
+type bulkMapping struct {
+	mapping []byte
+	live    int
+}
+
+// bulkMappings maps the address of each memory area which has been mapped
+// by MmapBackend.mapBulk to the mapping it is part of
+var bulkMappings = struct {
+	sync.Mutex
+	m map[uintptr]*bulkMapping
+}{m: make(map[uintptr]*bulkMapping)}
+
+// mapBulk maps memory areas of the given sizes with a single mapping, each
+// of them starts at a page boundary
+func (MmapBackend) mapBulk(sizes []int) ([][]byte, error) {
+	var total int
+	for _, size := range sizes {
+		total += pageRound(size)
+	}
+
+	mapping, err := syscall.Mmap(-1, 0, total, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+
+	bulk := &bulkMapping{mapping: mapping, live: len(sizes)}
+	areas := make([][]byte, len(sizes))
+	var offset int
+
+	bulkMappings.Lock()
+	defer bulkMappings.Unlock()
+
+	for i, size := range sizes {
+		areas[i] = mapping[offset : offset+size : offset+size]
+		bulkMappings.m[uintptr(unsafe.Pointer(&areas[i][0]))] = bulk
+		offset += pageRound(size)
+	}
+
+	return areas, nil
+}
+
+// isBulkArea returns true if the memory area at the given address has
+// been mapped by MmapBackend.mapBulk
+func isBulkArea(addr uintptr) bool {
+	bulkMappings.Lock()
+	defer bulkMappings.Unlock()
+
+	_, ok := bulkMappings.m[addr]
+	return ok
+}
+
+// unmapBulk releases the given memory area if it has been mapped by
+// MmapBackend.mapBulk. The whole mapping gets unmapped together with its
+// last memory area that is in use, the pages of the others only get
+// released
+// It returns false if the memory area isn't part of a bulk mapping
+func unmapBulk(data []byte) (bool, error) {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+
+	bulkMappings.Lock()
+	bulk, ok := bulkMappings.m[addr]
+	var live int
+	if ok {
+		delete(bulkMappings.m, addr)
+		bulk.live--
+		live = bulk.live
+	}
+	bulkMappings.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if live > 0 {
+		return true, releaseMemory(sliceAt(addr, pageRound(len(data))))
+	}
+	return true, syscall.Munmap(bulk.mapping)
+}
@@ -0,0 +1,71 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMapBulk(t *testing.T) {
+	Convey("When mapping several memory areas at once", t, func() {
+		backend := MmapBackend{}
+		pageSize := systemPageSize
+		areas, err := backend.mapBulk([]int{100, pageSize + 1, 10})
+		So(err, ShouldBeNil)
+		So(len(areas), ShouldEqual, 3)
+
+		Convey("the areas should start at consecutive page boundaries", func() {
+			So(len(areas[1]), ShouldEqual, pageSize+1)
+			So(objAddrFromObj(areas[1])-objAddrFromObj(areas[0]), ShouldEqual, pageSize)
+			So(objAddrFromObj(areas[2])-objAddrFromObj(areas[1]), ShouldEqual, 2*pageSize)
+			for _, area := range areas {
+				area[len(area)-1] = 1
+				So(backend.Unmap(area), ShouldBeNil)
+			}
+		})
+
+		Convey("the mapping should only be unmapped with its last area", func() {
+			So(backend.Unmap(areas[1]), ShouldBeNil)
+			So(backend.Unmap(areas[0]), ShouldBeNil)
+			areas[2][0] = 1
+			So(areas[2][0], ShouldEqual, 1)
+			So(backend.Unmap(areas[2]), ShouldBeNil)
+			So(faults(func() { areas[2][0] = 2 }), ShouldBeTrue)
+			So(isBulkArea(objAddrFromObj(areas[2])), ShouldBeFalse)
+		})
+	})
+
+	Convey("When reserving slots which need several slabs", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		So(os.Reserve(3, 20), ShouldBeNil)
+
+		Convey("the slabs should be mapped together", func() {
+			slabs := os.Slabs()
+			So(len(slabs), ShouldEqual, 5)
+			for i := 1; i < len(slabs); i++ {
+				So(slabs[i].Addr-slabs[i-1].Addr, ShouldEqual, pageRound(slabSize(3, 4)))
+				So(isBulkArea(slabs[i].Addr), ShouldBeTrue)
+			}
+		})
+
+		Convey("the objects added to the slabs should be usable", func() {
+			var objs []ObjAddr
+			for i := 0; i < 20; i++ {
+				obj, err := os.Add([]byte{byte(i), 1, 2})
+				So(err, ShouldBeNil)
+				objs = append(objs, obj)
+			}
+			So(len(os.Slabs()), ShouldEqual, 5)
+			for i, obj := range objs {
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(data[0], ShouldEqual, byte(i))
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(len(os.Slabs()), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package gos
+
+import (
+	"sync"
+)
+
+// ChangeKind identifies the kind of a Change
+type ChangeKind int
+
+const (
+	// ObjAdded means that an object has been added
+	ObjAdded ChangeKind = iota
+	// ObjDeleted means that an object has been deleted
+	ObjDeleted
+)
+
+// Change describes an added or deleted object, it gets sent to the
+// channels returned by Subscribe
+type Change struct {
+	Kind ChangeKind
+	Obj  ObjAddr
+	Size uint8
+	// Payload is a copy of the object's data, it is only set if the
+	// subscription asked for it. Objects added by AddReserve have no
+	// payload because their data gets written after the change is sent
+	Payload []byte
+}
+
+// subscription is a channel which has been returned by Subscribe
+type subscription struct {
+	ch      chan Change
+	done    chan struct{}
+	payload bool
+}
+
+// Subscribe returns a channel which receives a Change for every object that
+// gets added to or deleted from the store, so replication, cache invalidation
+// or audit logging can be built on top of the store. If withPayload is true,
+// the changes contain a copy of the object data.
+// Changes get sent synchronously by the goroutine which made them, so once
+// the buffer of the channel is full, adds and deletes block until the
+// subscriber has received the pending changes. The changes of concurrent
+// operations may arrive in any order
+// It returns the channel and a function which cancels the subscription
+// and closes the channel, it may be called multiple times
+func (o *ObjectStore) Subscribe(buffer int, withPayload bool) (<-chan Change, func()) {
+	sub := &subscription{
+		ch:      make(chan Change, buffer),
+		done:    make(chan struct{}),
+		payload: withPayload,
+	}
+
+	o.subsLock.Lock()
+	if o.subs == nil {
+		o.subs = make(map[*subscription]struct{})
+	}
+	o.subs[sub] = struct{}{}
+	o.subsLock.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			// done unblocks any pending send before we wait for the lock
+			close(sub.done)
+			o.subsLock.Lock()
+			delete(o.subs, sub)
+			o.subsLock.Unlock()
+			close(sub.ch)
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+// hasSubscribers returns whether there are subscriptions, the second
+// returned value is true if at least one of them wants payloads
+func (o *ObjectStore) hasSubscribers() (bool, bool) {
+	o.subsLock.RLock()
+	defer o.subsLock.RUnlock()
+
+	for sub := range o.subs {
+		if sub.payload {
+			return true, true
+		}
+	}
+	return len(o.subs) > 0, false
+}
+
+// publishChange sends a Change to all subscriptions, payload is only
+// passed on to the subscriptions which asked for it
+func (o *ObjectStore) publishChange(kind ChangeKind, obj ObjAddr, size uint8, payload []byte) {
+	o.subsLock.RLock()
+	defer o.subsLock.RUnlock()
+
+	for sub := range o.subs {
+		change := Change{Kind: kind, Obj: obj, Size: size}
+		if sub.payload {
+			change.Payload = payload
+		}
+		select {
+		case sub.ch <- change:
+		case <-sub.done:
+		}
+	}
+}
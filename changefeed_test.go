@@ -0,0 +1,56 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChangeFeed(t *testing.T) {
+	Convey("When subscribing to the changes of a store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		withPayload, cancelWithPayload := os.Subscribe(10, true)
+		withoutPayload, cancelWithoutPayload := os.Subscribe(10, false)
+
+		obj, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		objs, err := os.AddBatched([][]byte{[]byte("de"), []byte("fgh")})
+		So(err, ShouldBeNil)
+		So(os.Delete(obj), ShouldBeNil)
+
+		Convey("the subscribers should receive all changes in order", func() {
+			expected := []Change{
+				{Kind: ObjAdded, Obj: obj, Size: 3, Payload: []byte("abc")},
+				{Kind: ObjAdded, Obj: objs[0], Size: 2, Payload: []byte("de")},
+				{Kind: ObjAdded, Obj: objs[1], Size: 3, Payload: []byte("fgh")},
+				{Kind: ObjDeleted, Obj: obj, Size: 3, Payload: []byte("abc")},
+			}
+			received := make([]Change, 0, len(expected))
+			for range expected {
+				received = append(received, <-withPayload)
+			}
+			// the sizes of a batch get added in random order
+			if received[1].Size != 2 {
+				received[1], received[2] = received[2], received[1]
+			}
+			So(received, ShouldResemble, expected)
+
+			change := <-withoutPayload
+			So(change.Obj, ShouldEqual, obj)
+			So(change.Payload, ShouldBeNil)
+		})
+
+		Convey("cancelling a subscription should close its channel", func() {
+			cancelWithPayload()
+			cancelWithoutPayload()
+			count := 0
+			for range withoutPayload {
+				count++
+			}
+			So(count, ShouldEqual, 4)
+
+			_, err := os.Add([]byte("ijk"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
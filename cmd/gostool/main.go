@@ -0,0 +1,261 @@
+// Command gostool inspects snapshot files and the directories of file
+// backed stores, which have been written by go-generic-object-store.
+//
+// Usage:
+//
+//	gostool [-key hex] [-wal] <command> <path> [arguments]
+//
+// The commands are:
+//
+//	pools              list the pools with their object sizes and slab counts
+//	slabs              list the slabs with their ids and usage
+//	dump [id...]       write a hexdump of the slabs with the given ids, or
+//	                   of all the slabs
+//	verify             verify the checksums of a snapshot and the integrity
+//	                   of the loaded slabs
+//	grep [-x] pattern  list the objects which contain the pattern, which is
+//	                   hex encoded if -x is given
+//	stats              print memory and fragmentation statistics
+//
+// The path is either a snapshot file, which gets restored into memory, or
+// the directory of a file backed store, which gets opened with
+// OpenFileStore. Slabs are identified by their ids, because their addresses
+// change every time they get loaded
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	gos "github.com/replay/go-generic-object-store"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "gostool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned if the command line is invalid
+var errUsage = errors.New("usage: gostool [-key hex] [-wal] pools|slabs|dump|verify|grep|stats <path> [arguments]")
+
+// run executes the command line args and writes the output to stdout
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("gostool", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	key := flags.String("key", "", "hex encoded AES key of encrypted snapshots")
+	wal := flags.Bool("wal", false, "replay the write-ahead log of a file backed store")
+	if err := flags.Parse(args); err != nil || flags.NArg() < 2 {
+		return errUsage
+	}
+
+	var opts []gos.Option
+	if *key != "" {
+		aead, err := newCipher(*key)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, gos.WithSnapshotCipher(aead))
+	}
+	if *wal {
+		opts = append(opts, gos.WithWriteAheadLog(false))
+	}
+
+	command, path, rest := flags.Arg(0), flags.Arg(1), flags.Args()[2:]
+	o, closeStore, err := open(path, opts)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	switch command {
+	case "pools":
+		return pools(o, w)
+	case "slabs":
+		return slabs(o, w)
+	case "dump":
+		return dump(o, w, rest)
+	case "verify":
+		return verify(o, w)
+	case "grep":
+		return grep(o, w, rest)
+	case "stats":
+		return stats(o, w)
+	default:
+		return errUsage
+	}
+}
+
+// newCipher creates an AES-GCM AEAD from the hex encoded key
+func newCipher(key string) (cipher.AEAD, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// open loads the snapshot file or the file backed store at path
+// On success it returns the store, a function which closes it and nil
+// On failure the third returned value is the error
+func open(path string, opts []gos.Option) (*gos.ObjectStore, func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.IsDir() {
+		o, backend, err := gos.OpenFileStore(path, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, func() { backend.Close() }, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	o := gos.NewObjectStore(opts...)
+	if _, err := o.Restore(file); err != nil {
+		return nil, nil, err
+	}
+	return o, func() {}, nil
+}
+
+// pools lists the pools of the store
+func pools(o *gos.ObjectStore, w io.Writer) error {
+	fmt.Fprintln(w, "OBJSIZE\tOBJSPERSLAB\tSLABS\tOBJECTS\tFREE")
+	for _, pool := range o.MemStats().Pools {
+		var objsPerSlab uint64
+		if pool.Slabs > 0 {
+			objsPerSlab = (pool.LiveObjects + pool.FreeSlots) / pool.Slabs
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\n", pool.ObjSize, objsPerSlab, pool.Slabs, pool.LiveObjects, pool.FreeSlots)
+	}
+	return nil
+}
+
+// slabs lists the slabs of the store
+func slabs(o *gos.ObjectStore, w io.Writer) error {
+	fmt.Fprintln(w, "ID\tOBJSIZE\tCAPACITY\tOBJECTS\tFREE\tBYTES")
+	for _, info := range o.Slabs() {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\n", info.ID, info.ObjSize, info.Capacity, info.LiveObjects, info.FreeSlots, info.TotalBytes)
+	}
+	return nil
+}
+
+// dump writes a hexdump of the slabs with the given ids, or of all slabs
+// if there are no ids
+func dump(o *gos.ObjectStore, w io.Writer, ids []string) error {
+	infos := o.Slabs()
+	byID := make(map[uint32]gos.SlabAddr, len(infos))
+	for _, info := range infos {
+		byID[info.ID] = info.Addr
+	}
+
+	var addrs []gos.SlabAddr
+	if len(ids) == 0 {
+		for _, info := range infos {
+			addrs = append(addrs, info.Addr)
+		}
+	}
+	for _, arg := range ids {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid slab id %q: %w", arg, err)
+		}
+		addr, ok := byID[uint32(id)]
+		if !ok {
+			return fmt.Errorf("there is no slab with id %d", id)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	for _, addr := range addrs {
+		if err := o.DumpSlab(addr, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify checks the integrity of the store, the checksums of snapshots have
+// already been verified when they were restored
+func verify(o *gos.ObjectStore, w io.Writer) error {
+	if err := o.CheckIntegrity(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "ok: %d slabs\n", len(o.Slabs()))
+	return nil
+}
+
+// grep lists the objects which contain the pattern
+func grep(o *gos.ObjectStore, w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("grep", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	isHex := flags.Bool("x", false, "the pattern is hex encoded")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	pattern := []byte(flags.Arg(0))
+	if *isHex {
+		var err error
+		if pattern, err = hex.DecodeString(flags.Arg(0)); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	fmt.Fprintln(w, "SLAB\tOFFSET\tOBJECT")
+	for _, info := range o.Slabs() {
+		objs, err := o.SlabObjects(info.Addr)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			data, err := o.Get(obj)
+			if err != nil {
+				return err
+			}
+			if !bytes.Contains(data, pattern) {
+				continue
+			}
+			fmt.Fprintf(w, "%d\t%d\t%q\n", info.ID, obj-info.Addr, data)
+		}
+	}
+	return nil
+}
+
+// stats prints the memory usage and the fragmentation of the store
+func stats(o *gos.ObjectStore, w io.Writer) error {
+	mem := o.MemStats()
+	fmt.Fprintf(w, "slabs\t%d\n", mem.Slabs)
+	fmt.Fprintf(w, "mapped bytes\t%d\n", mem.MappedBytes)
+	fmt.Fprintf(w, "objects\t%d\n", mem.LiveObjects)
+	fmt.Fprintf(w, "free slots\t%d\n", mem.FreeSlots)
+	fmt.Fprintf(w, "padding bytes\t%d\n", mem.PaddingBytes)
+	for _, frag := range o.Fragmentation(0) {
+		fmt.Fprintf(w, "fragmentation of object size %d\t%.2f\n", frag.ObjSize, frag.Ratio)
+	}
+	return nil
+}
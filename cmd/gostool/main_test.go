@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gos "github.com/replay/go-generic-object-store"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGostool(t *testing.T) {
+	Convey("When inspecting a snapshot file", t, func() {
+		o := gos.NewObjectStore(gos.WithObjsPerSlab(4))
+		for _, value := range []string{"abc", "bcd", "cde", "hello"} {
+			_, err := o.Add([]byte(value))
+			So(err, ShouldBeNil)
+		}
+
+		path := filepath.Join(t.TempDir(), "snapshot")
+		file, err := os.Create(path)
+		So(err, ShouldBeNil)
+		_, err = o.WriteTo(file)
+		So(err, ShouldBeNil)
+		So(file.Close(), ShouldBeNil)
+
+		var out bytes.Buffer
+		Convey("pools should list both pools", func() {
+			So(run([]string{"pools", path}, &out), ShouldBeNil)
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			So(len(lines), ShouldEqual, 3)
+			So(strings.Fields(lines[1]), ShouldResemble, []string{"3", "4", "1", "3", "1"})
+		})
+
+		Convey("grep should find the objects which contain the pattern", func() {
+			So(run([]string{"grep", path, "bc"}, &out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, `"abc"`)
+			So(out.String(), ShouldContainSubstring, `"bcd"`)
+			So(out.String(), ShouldNotContainSubstring, `"cde"`)
+
+			out.Reset()
+			So(run([]string{"grep", path, "-x", "6c6c"}, &out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, `"hello"`)
+		})
+
+		Convey("dump should write the slab with the given id", func() {
+			So(run([]string{"dump", path, "1"}, &out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "objSize=3")
+			So(run([]string{"dump", path, "100"}, &out), ShouldNotBeNil)
+		})
+
+		Convey("verify should fail for a corrupted snapshot", func() {
+			So(run([]string{"verify", path}, &out), ShouldBeNil)
+
+			data, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			data[len(data)-5] ^= 0xff
+			So(os.WriteFile(path, data, 0o600), ShouldBeNil)
+			So(run([]string{"verify", path}, &out), ShouldNotBeNil)
+		})
+
+		Convey("an unknown command should fail", func() {
+			So(run([]string{"unknown", path}, &out), ShouldEqual, errUsage)
+		})
+	})
+}
@@ -0,0 +1,203 @@
+package gos
+
+import (
+	"sort"
+)
+
+// deletedSlab is a slab which has been deleted by a compaction, totalBytes
+// has been read from the slab before it got unmapped
+type deletedSlab struct {
+	addr       SlabAddr
+	totalBytes uint64
+}
+
+// Compact moves the objects of the sparsest slabs of the pool into the free
+// slots of its densest slabs and unmaps the slabs which have been emptied.
+// Only as many of the sparsest slabs get evacuated as can be emptied
+// completely, so no object gets moved without freeing its slab. Empty,
+// full and sealed slabs are left alone, slabs with pinned objects only
+// receive objects, see Pin. Pools aren't compacted while an IterSnapshot
+// is in progress. The old addresses of the moved objects become invalid
+// On success it returns the new address of each moved object by its old
+// address and nil
+// On failure the returned map contains the objects which have been moved
+// before the error, the second returned value is the error
+func (s *slabPool) Compact() (map[ObjAddr]ObjAddr, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	moved, _, err := s.compact(0)
+	return moved, err
+}
+
+// compact implements Compact, it evacuates at most maxSlabs slabs unless
+// maxSlabs is 0. Additionally it returns the slabs which have been deleted
+// The caller must hold the pool lock for writing
+func (s *slabPool) compact(maxSlabs int) (map[ObjAddr]ObjAddr, []deletedSlab, error) {
+	// moving objects would make them invisible to IterSnapshot
+	if s.snapshotIters.Load() > 0 {
+		return nil, nil, nil
+	}
+
+	// sort the partially used slabs from the sparsest to the densest one,
+	// slabs with pinned objects can't be evacuated so they go last
+	candidates := append([]*slabState(nil), s.lists[partialSlabs]...)
+	live := func(st *slabState) uint { return st.capacity - st.freeCount() }
+	sort.Slice(candidates, func(i, j int) bool {
+		if (candidates[i].pinned > 0) != (candidates[j].pinned > 0) {
+			return candidates[j].pinned > 0
+		}
+		if live(candidates[i]) != live(candidates[j]) {
+			return live(candidates[i]) < live(candidates[j])
+		}
+		return candidates[i].slab.addr() < candidates[j].slab.addr()
+	})
+
+	var free uint
+	for _, st := range candidates {
+		free += st.freeCount()
+	}
+
+	// the sparsest slabs get evacuated as long as their objects fit into
+	// the free slots of the remaining slabs
+	var sources, moving uint
+	for ; sources < uint(len(candidates)) && (maxSlabs == 0 || sources < uint(maxSlabs)); sources++ {
+		st := candidates[sources]
+		if st.pinned > 0 || moving+live(st) > free-st.freeCount() {
+			break
+		}
+		moving += live(st)
+		free -= st.freeCount()
+	}
+
+	moved := make(map[ObjAddr]ObjAddr, moving)
+	var deleted []deletedSlab
+	dst := len(candidates) - 1
+	for _, src := range candidates[:sources] {
+		slabAddr := src.slab.addr()
+		totalBytes := uint64(src.slab.getTotalLength())
+
+		var slabDeleted bool
+		for idx, ok := src.slab.nextObj(0); ok; idx, ok = src.slab.nextObj(idx + 1) {
+			for candidates[dst].freeCount() == 0 {
+				dst--
+			}
+
+			oldAddr := src.slab.getObjAddrByIdx(idx)
+			newAddr, srcDeleted, err := s.moveObj(src, idx, candidates[dst])
+			if err != nil {
+				return moved, deleted, err
+			}
+			moved[oldAddr] = newAddr
+
+			// the memory of a deleted slab must not be accessed anymore
+			if slabDeleted = srcDeleted; slabDeleted {
+				break
+			}
+		}
+
+		// slabs which have been emptied get unmapped even if empty slabs
+		// get kept otherwise
+		if !slabDeleted {
+			if _, err := s.deleteSlab(slabAddr); err != nil {
+				return moved, deleted, err
+			}
+		}
+		deleted = append(deleted, deletedSlab{addr: slabAddr, totalBytes: totalBytes})
+
+		if s.logger != nil {
+			s.logger.Debug("gos: slab compacted", "objSize", s.objSize, "slab", slabAddr)
+		}
+	}
+
+	return moved, deleted, nil
+}
+
+// moveObj moves the object in the slot at the given index of the source
+// slab into a free slot of the destination slab
+// On success it returns the new address of the object, whether the source
+// slab has been deleted because it has become empty, and nil
+// On failure the third returned value is the error
+// The caller must hold the pool lock for writing
+func (s *slabPool) moveObj(src *slabState, idx uint, dst *slabState) (ObjAddr, bool, error) {
+	obj := s.objByIdx(src.slab, idx)
+	slot := dst.popFree()
+
+	if s.wal != nil {
+		record := s.wal.newRecord()
+		s.wal.add(record, dst.slab, slot, obj, s.varLen)
+		if err := s.wal.commit(record); err != nil {
+			s.releaseSlot(dst, slot)
+			return 0, false, err
+		}
+		defer s.wal.applied()
+	}
+
+	newAddr, err := s.storeObj(dst, slot, obj)
+	if err != nil {
+		return 0, false, err
+	}
+	oldAddr := src.slab.getObjAddrByIdx(idx)
+	s.moveRefs(oldAddr, newAddr)
+	if s.objMoved != nil {
+		s.objMoved(src.slab, idx, src.gens[idx], dst.slab, slot, dst.gens[slot])
+	}
+
+	deleted, err := s.deleteFromSlab(oldAddr, src.slab.addr())
+	if err != nil {
+		return 0, false, err
+	}
+
+	return newAddr, deleted, nil
+}
+
+// Compact compacts each slab pool like the Compact method of the pools
+// does, and removes the unmapped slabs from the lookup table. The slab
+// listeners get notified about the deleted slabs and the relocation
+// listeners about the moved objects, but subscribers of the change feed
+// don't get notified about them. Handles of the moved objects keep
+// resolving to them, see Resolve
+// On success it returns the new address of each moved object by its old
+// address and nil
+// On failure the returned map contains the objects which have been moved
+// before the error, the second returned value is the error
+func (o *ObjectStore) Compact() (map[ObjAddr]ObjAddr, error) {
+	o.poolsLock.RLock()
+	pools := make([]*slabPool, 0, len(o.slabPools))
+	for _, pool := range o.slabPools {
+		pools = append(pools, pool)
+	}
+	o.poolsLock.RUnlock()
+
+	moved := make(map[ObjAddr]ObjAddr)
+	for _, pool := range pools {
+		poolMoved, err := o.compactPool(pool, 0)
+		for oldAddr, newAddr := range poolMoved {
+			moved[oldAddr] = newAddr
+		}
+		if err != nil {
+			return moved, err
+		}
+	}
+
+	return moved, nil
+}
+
+// compactPool compacts the given pool of the store, evacuating at most
+// maxSlabs slabs unless maxSlabs is 0, and removes the unmapped slabs from
+// the lookup table
+// It returns the moved objects like Compact
+func (o *ObjectStore) compactPool(pool *slabPool, maxSlabs int) (map[ObjAddr]ObjAddr, error) {
+	pool.lock.Lock()
+	moved, deleted, err := pool.compact(maxSlabs)
+	pool.lock.Unlock()
+
+	// the pool has removed the deleted slabs from the lookup table
+	for _, d := range deleted {
+		o.slabDeleted(pool.objSize, d.addr, d.totalBytes)
+	}
+	o.indexesRelocated(moved)
+	o.objsRelocated(moved)
+
+	return moved, err
+}
@@ -0,0 +1,168 @@
+package gos
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompact(t *testing.T) {
+	Convey("When compacting a store with sparsely used slabs", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var events []SlabEvent
+		os.AddSlabListener(func(event SlabEvent) {
+			events = append(events, event)
+		})
+
+		objs := make(map[ObjAddr]byte)
+		var order []ObjAddr
+		for i := 0; i < 16; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs[obj] = byte(i)
+			order = append(order, obj)
+		}
+
+		// leave 1, 1, 2 and 4 objects in the four slabs
+		for _, i := range []int{1, 2, 3, 5, 6, 7, 10, 11} {
+			So(os.Delete(order[i]), ShouldBeNil)
+			delete(objs, order[i])
+		}
+		So(len(os.Slabs()), ShouldEqual, 4)
+		events = nil
+
+		moved, err := os.Compact()
+		So(err, ShouldBeNil)
+
+		Convey("the sparsest slabs should have been evacuated into the denser ones", func() {
+			So(len(moved), ShouldEqual, 2)
+			So(len(os.Slabs()), ShouldEqual, 2)
+			So(len(events), ShouldEqual, 2)
+			So(events[0].Kind, ShouldEqual, SlabDeleted)
+			So(os.CheckIntegrity(), ShouldBeNil)
+
+			for obj, value := range objs {
+				if newObj, ok := moved[obj]; ok {
+					obj = newObj
+				}
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []byte{value, 1, 2})
+				found, ok := os.Search([]byte{value, 1, 2})
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, obj)
+			}
+		})
+
+		Convey("compacting again should not move anything", func() {
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 0)
+			So(len(os.Slabs()), ShouldEqual, 2)
+		})
+	})
+
+	Convey("When compacting a pool whose objects don't fit into fewer slabs", t, func() {
+		pool := NewSlabPool(3, 4)
+		var objs []ObjAddr
+		for i := 0; i < 8; i++ {
+			obj, _, err := pool.add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		_, err := pool.deleteObj(objs[0])
+		So(err, ShouldBeNil)
+		_, err = pool.deleteObj(objs[4])
+		So(err, ShouldBeNil)
+
+		Convey("nothing should be moved", func() {
+			moved, err := pool.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 0)
+			So(len(pool.slabs), ShouldEqual, 2)
+		})
+	})
+
+	Convey("When compacting a pool which keeps empty slabs", t, func() {
+		pool := NewSlabPool(3, 4)
+		pool.setReclaimEmptySlabs(false)
+		var objs []ObjAddr
+		for i := 0; i < 8; i++ {
+			obj, _, err := pool.add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		for _, i := range []int{0, 1, 2, 4} {
+			_, err := pool.deleteObj(objs[i])
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the evacuated slab should be unmapped anyway", func() {
+			moved, err := pool.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 1)
+			So(len(pool.slabs), ShouldEqual, 1)
+			newObj, ok := moved[objs[3]]
+			So(ok, ShouldBeTrue)
+			So(pool.get(newObj), ShouldResemble, []byte{3, 1, 2})
+		})
+	})
+}
+
+func TestCompactor(t *testing.T) {
+	Convey("When a background compactor runs on a fragmented store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 16; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		for i := range objs {
+			if i%4 != 0 {
+				So(os.Delete(objs[i]), ShouldBeNil)
+			}
+		}
+
+		runs := make(chan map[ObjAddr]ObjAddr, 10)
+		c := os.StartCompactor(CompactorOptions{
+			Interval:       time.Millisecond,
+			MaxSlabsPerRun: 1,
+			OnCompact:      func(moved map[ObjAddr]ObjAddr) { runs <- moved },
+		})
+
+		Convey("it should evacuate one slab per run until the pool is dense", func() {
+			for i := 0; i < 3; i++ {
+				moved := <-runs
+				So(len(moved), ShouldEqual, 1)
+			}
+			So(len(os.Slabs()), ShouldEqual, 1)
+
+			// the pool isn't fragmented anymore, so nothing gets moved
+			time.Sleep(10 * time.Millisecond)
+			c.Stop()
+			So(len(runs), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When the fragmentation is below the threshold", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 8; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(os.Delete(objs[0]), ShouldBeNil)
+		So(os.Delete(objs[4]), ShouldBeNil)
+		So(os.Delete(objs[5]), ShouldBeNil)
+
+		Convey("the compactor should leave the pool alone", func() {
+			c := os.StartCompactor(CompactorOptions{Interval: time.Millisecond})
+			time.Sleep(10 * time.Millisecond)
+			c.Stop()
+			So(len(os.Slabs()), ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package gos
+
+import (
+	"time"
+)
+
+// CompactorOptions configures a background compactor, see StartCompactor
+type CompactorOptions struct {
+	// Interval is the time between two compaction runs, it defaults to a
+	// minute
+	Interval time.Duration
+
+	// MinFragmentation is the fragmentation ratio of a pool, see
+	// PoolFragmentation, from which on the pool gets compacted. It
+	// defaults to 0.5
+	MinFragmentation float64
+
+	// MaxSlabsPerRun is the maximum number of slabs which get evacuated
+	// per pool and run, it limits the time for which a run holds the lock
+	// of a pool. 0 means no limit
+	MaxSlabsPerRun int
+
+	// OnCompact gets called with the new address of each moved object by
+	// its old address after every run which has moved objects. It is
+	// called from the compactor's goroutine
+	OnCompact func(moved map[ObjAddr]ObjAddr)
+}
+
+// Compactor compacts the pools of a store in the background, it is
+// returned by StartCompactor
+type Compactor struct {
+	store *ObjectStore
+	opts  CompactorOptions
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// StartCompactor starts a goroutine which periodically compacts the pools
+// of the store whose fragmentation has reached the configured threshold,
+// see Compact. This keeps stores with delete heavy workloads from holding
+// on to sparsely used slabs. Objects get moved while other goroutines may
+// still use their old addresses, so the callers must either not keep
+// object addresses across runs or update them in OnCompact before they
+// use them again. Errors get logged if the store has a logger
+// The returned Compactor must be stopped with Stop
+func (o *ObjectStore) StartCompactor(opts CompactorOptions) *Compactor {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.MinFragmentation <= 0 {
+		opts.MinFragmentation = 0.5
+	}
+
+	c := &Compactor{
+		store: o,
+		opts:  opts,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go c.loop()
+
+	return c
+}
+
+// Stop stops the compactor and waits until a run which is in progress has
+// finished. It must be called exactly once
+func (c *Compactor) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+// loop runs the compaction once per interval until the compactor gets
+// stopped
+func (c *Compactor) loop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.run()
+		}
+	}
+}
+
+// run compacts each pool whose fragmentation has reached the threshold
+func (c *Compactor) run() {
+	o := c.store
+
+	o.poolsLock.RLock()
+	pools := make([]*slabPool, 0, len(o.slabPools))
+	for _, pool := range o.slabPools {
+		pools = append(pools, pool)
+	}
+	o.poolsLock.RUnlock()
+
+	moved := make(map[ObjAddr]ObjAddr)
+	for _, pool := range pools {
+		if pool.fragmentation(0).Ratio < c.opts.MinFragmentation {
+			continue
+		}
+
+		poolMoved, err := o.compactPool(pool, c.opts.MaxSlabsPerRun)
+		for oldAddr, newAddr := range poolMoved {
+			moved[oldAddr] = newAddr
+		}
+		if err != nil && o.logger != nil {
+			o.logger.Warn("gos: failed to compact pool", "objSize", pool.objSize, "error", err)
+		}
+	}
+
+	if len(moved) > 0 && c.opts.OnCompact != nil {
+		c.opts.OnCompact(moved)
+	}
+}
@@ -0,0 +1,103 @@
+package gos
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// debugPool is the state of a slab pool as it is rendered by DebugHandler
+type debugPool struct {
+	MemStats      PoolMemStats
+	Fragmentation float64
+	Slabs         []debugSlab
+}
+
+// debugSlab is the state of a slab as it is rendered by DebugHandler,
+// Occupancy contains a '#' for each used slot and a '.' for each free one
+type debugSlab struct {
+	SlabInfo
+	Occupancy string
+}
+
+// debugSlabs returns the debugSlab of each slab in the pool,
+// sorted by ascending address
+func (s *slabPool) debugSlabs() []debugSlab {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	slabs := make([]debugSlab, 0, len(s.slabs))
+	for i := len(s.slabs) - 1; i >= 0; i-- {
+		sl := s.slabs[i]
+		var occupancy strings.Builder
+		bitSet := sl.bitSet()
+		for idx := uint(0); idx < s.states[sl].capacity; idx++ {
+			if bitSet.Test(idx) {
+				occupancy.WriteByte('#')
+			} else {
+				occupancy.WriteByte('.')
+			}
+		}
+		slabs = append(slabs, debugSlab{SlabInfo: s.slabInfoOf(s.states[sl]), Occupancy: occupancy.String()})
+	}
+	return slabs
+}
+
+// debugPools returns the debugPool of each pool, sorted by object size
+func (o *ObjectStore) debugPools() []debugPool {
+	o.poolsLock.RLock()
+	defer o.poolsLock.RUnlock()
+
+	pools := make([]debugPool, 0, len(o.slabPools))
+	for _, pool := range o.slabPools {
+		pools = append(pools, debugPool{
+			MemStats:      pool.detailedMemStats(),
+			Fragmentation: pool.fragmentation(0).Ratio,
+			Slabs:         pool.debugSlabs(),
+		})
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].MemStats.ObjSize < pools[j].MemStats.ObjSize })
+	return pools
+}
+
+var debugTemplate = template.Must(template.New("gos").Parse(`<!DOCTYPE html>
+<html>
+<head><title>object store</title></head>
+<body>
+<h1>object store</h1>
+{{range .}}
+<h2>pool of {{.MemStats.ObjSize}} byte objects</h2>
+<p>{{.MemStats.Slabs}} slabs, {{.MemStats.MappedBytes}} bytes mapped, {{.MemStats.LiveObjects}} objects, {{.MemStats.FreeSlots}} free slots, fragmentation {{printf "%.3f" .Fragmentation}}</p>
+<table>
+<tr><th>address</th><th>objects</th><th>free</th><th>created</th><th>occupancy</th></tr>
+{{range .Slabs}}<tr><td>{{printf "0x%x" .Addr}}</td><td>{{.LiveObjects}}</td><td>{{.FreeSlots}}</td><td>{{.Created.Format "2006-01-02 15:04:05"}}</td><td><code>{{.Occupancy}}</code></td></tr>
+{{end}}</table>
+{{else}}
+<p>the store is empty</p>
+{{end}}
+</body>
+</html>
+`))
+
+// DebugHandler returns an http.Handler which renders the pools of the store
+// with their memory statistics and fragmentation, and each slab with a map
+// of its used slots. By default it renders HTML, if the request has the
+// query parameter format=json it renders JSON. Like net/http/pprof it is
+// meant to be registered on a debug path, for example
+// http.Handle("/debug/gos", store.DebugHandler())
+func (o *ObjectStore) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools := o.debugPools()
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(pools)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		debugTemplate.Execute(w, pools)
+	})
+}
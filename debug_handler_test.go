@@ -0,0 +1,43 @@
+package gos
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDebugHandler(t *testing.T) {
+	Convey("When inspecting a store via the debug handler", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def", "ghi"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(os.Delete(objs[1]), ShouldBeNil)
+		handler := os.DebugHandler()
+
+		Convey("the JSON output should contain the occupancy of the slabs", func() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/gos?format=json", nil))
+			So(rec.Header().Get("Content-Type"), ShouldEqual, "application/json")
+
+			var pools []debugPool
+			So(json.Unmarshal(rec.Body.Bytes(), &pools), ShouldBeNil)
+			So(len(pools), ShouldEqual, 1)
+			So(pools[0].MemStats.LiveObjects, ShouldEqual, 2)
+			So(len(pools[0].Slabs), ShouldEqual, 1)
+			So(pools[0].Slabs[0].Occupancy, ShouldEqual, "#.#.")
+		})
+
+		Convey("the HTML output should contain the occupancy of the slabs", func() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/gos", nil))
+			So(rec.Body.String(), ShouldContainSubstring, "pool of 3 byte objects")
+			So(rec.Body.String(), ShouldContainSubstring, "<code>#.#.</code>")
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// writeDebugPool writes the human-readable layout of the given pool to buf
+func writeDebugPool(buf *bytes.Buffer, pool debugPool) {
+	stats := pool.MemStats
+	fmt.Fprintf(buf, "pool objSize=%d slabs=%d objects=%d freeSlots=%d mappedBytes=%d fragmentation=%.3f\n",
+		stats.ObjSize, stats.Slabs, stats.LiveObjects, stats.FreeSlots, stats.MappedBytes, pool.Fragmentation)
+	for _, sl := range pool.Slabs {
+		fmt.Fprintf(buf, "  slab 0x%x used=%d/%d [%s]\n", sl.Addr, sl.LiveObjects, sl.Capacity, sl.Occupancy)
+	}
+}
+
+// DumpTo writes a human-readable layout of the pool to w, it lists the
+// slabs sorted by address with a map of their used slots
+// On success it returns nil, otherwise it returns the error of w
+func (s *slabPool) DumpTo(w io.Writer) error {
+	var buf bytes.Buffer
+	writeDebugPool(&buf, debugPool{
+		MemStats:      s.detailedMemStats(),
+		Fragmentation: s.fragmentation(0).Ratio,
+		Slabs:         s.debugSlabs(),
+	})
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// String returns the layout of the pool as it is written by DumpTo
+func (s *slabPool) String() string {
+	var buf bytes.Buffer
+	s.DumpTo(&buf)
+	return buf.String()
+}
+
+// DumpTo writes a human-readable layout of the store to w, it lists the
+// pools sorted by object size, their slabs sorted by address with a map
+// of their used slots and the totals of the whole store. This helps to
+// find out where the memory of a store goes
+// On success it returns nil, otherwise it returns the error of w
+func (o *ObjectStore) DumpTo(w io.Writer) error {
+	var buf bytes.Buffer
+	var total PoolMemStats
+	for _, pool := range o.debugPools() {
+		writeDebugPool(&buf, pool)
+		total.Slabs += pool.MemStats.Slabs
+		total.LiveObjects += pool.MemStats.LiveObjects
+		total.FreeSlots += pool.MemStats.FreeSlots
+		total.MappedBytes += pool.MemStats.MappedBytes
+	}
+	fmt.Fprintf(&buf, "total slabs=%d objects=%d freeSlots=%d mappedBytes=%d\n",
+		total.Slabs, total.LiveObjects, total.FreeSlots, total.MappedBytes)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// String returns the layout of the store as it is written by DumpTo
+func (o *ObjectStore) String() string {
+	var buf bytes.Buffer
+	o.DumpTo(&buf)
+	return buf.String()
+}
+
+// DumpSlab writes an annotated hexdump of the slab at the given address to
+// w. It shows the slab header, the BitSet of used slots and the content of
+// each object slot, so corrupted objects can be inspected
+// On success it returns nil
+// On failure, if there is no slab at the given address or writing to w
+// fails, it returns an error
+func (o *ObjectStore) DumpSlab(slabAddr SlabAddr, w io.Writer) error {
+	objSize, known := o.slabObjSize(slabAddr)
+	if !known {
+		return fmt.Errorf("ObjectStore: DumpSlab failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	sl := slabFromSlabAddr(slabAddr)
+	pool, ok := o.getSlabPool(objSize)
+	if !ok {
+		return fmt.Errorf("ObjectStore: DumpSlab failed to find pool of slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if _, ok := pool.states[sl]; !ok {
+		return fmt.Errorf("ObjectStore: DumpSlab failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	var buf bytes.Buffer
+	data := sl.memory()
+	dataOffset := int(sl.getDataOffset())
+
+	fmt.Fprintf(&buf, "slab 0x%x objSize=%d objsPerSlab=%d totalLength=%d\n", slabAddr, sl.objSize, sl.objsPerSlab(), len(data))
+	fmt.Fprintf(&buf, "objSize:\n")
+	writeHexdump(&buf, data[:1], 0)
+	fmt.Fprintf(&buf, "BitSet:\n")
+	writeHexdump(&buf, data[1:1+sizeOfBitSet], 1)
+	fmt.Fprintf(&buf, "BitSet data:\n")
+	writeHexdump(&buf, data[1+sizeOfBitSet:dataOffset], 1+int(sizeOfBitSet))
+
+	bitSet := sl.bitSet()
+	for i := uint(0); i < sl.objsPerSlab(); i++ {
+		state := "free"
+		if bitSet.Test(i) {
+			state = "used"
+		}
+		offset := int(sl.getObjOffset(i))
+		fmt.Fprintf(&buf, "slot %d (%s):\n", i, state)
+		writeHexdump(&buf, data[offset:offset+int(sl.objSize)], offset)
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeHexdump writes data to buf as lines of 16 bytes, each line starts
+// with the offset of its first byte and ends with the printable characters
+func writeHexdump(buf *bytes.Buffer, data []byte, offset int) {
+	for start := 0; start < len(data); start += 16 {
+		end := start + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		line := data[start:end]
+
+		fmt.Fprintf(buf, "  %08x  % -47x  |", offset+start, line)
+		for _, b := range line {
+			if b >= 0x20 && b < 0x7f {
+				buf.WriteByte(b)
+			} else {
+				buf.WriteByte('.')
+			}
+		}
+		buf.WriteString("|\n")
+	}
+}
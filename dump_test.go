@@ -0,0 +1,79 @@
+package gos
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDumpTo(t *testing.T) {
+	Convey("When dumping a store with two pools", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		for i := 0; i < 5; i++ {
+			_, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+		}
+		_, err := os.Add([]byte("abcd"))
+		So(err, ShouldBeNil)
+
+		Convey("the layout should list the pools, the slabs and the totals", func() {
+			lines := strings.Split(strings.TrimSpace(os.String()), "\n")
+			So(len(lines), ShouldEqual, 6)
+			So(lines[0], ShouldStartWith, "pool objSize=3 slabs=2 objects=5 freeSlots=3")
+			// the slabs are sorted by address, which depends on the kernel
+			slabs := []string{lines[1][strings.Index(lines[1], "used="):], lines[2][strings.Index(lines[2], "used="):]}
+			So(slabs, ShouldContain, "used=4/4 [####]")
+			So(slabs, ShouldContain, "used=1/4 [#...]")
+			So(lines[3], ShouldStartWith, "pool objSize=4 slabs=1 objects=1 freeSlots=3")
+			So(lines[5], ShouldStartWith, "total slabs=3 objects=6 freeSlots=6")
+		})
+
+		Convey("the layout of a single pool should only contain its slabs", func() {
+			pool, ok := os.getSlabPool(4)
+			So(ok, ShouldBeTrue)
+			lines := strings.Split(strings.TrimSpace(pool.String()), "\n")
+			So(len(lines), ShouldEqual, 2)
+			So(lines[1], ShouldEndWith, "used=1/4 [#...]")
+		})
+	})
+}
+
+func TestDumpSlab(t *testing.T) {
+	Convey("When dumping a slab", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(3))
+		obj, err := os.Add([]byte("hello"))
+		So(err, ShouldBeNil)
+		_, err = os.Add([]byte("world"))
+		So(err, ShouldBeNil)
+		So(os.Delete(obj), ShouldBeNil)
+		slabAddr := os.lookupTable[0]
+
+		Convey("the hexdump should annotate the header and the slots", func() {
+			var b strings.Builder
+			So(os.DumpSlab(slabAddr, &b), ShouldBeNil)
+			dump := b.String()
+			So(dump, ShouldStartWith, fmt.Sprintf("slab 0x%x objSize=5 objsPerSlab=3", slabAddr))
+			So(dump, ShouldContainSubstring, "objSize:\n  00000000  05")
+			// the BitSet struct differs in size between architectures,
+			// its data is a single word which follows it
+			bitSetData := 1 + sizeOfBitSet
+			dataOffset := bitSetData + 8
+			So(dump, ShouldContainSubstring, fmt.Sprintf("BitSet data:\n  %08x  02 00 00 00 00 00 00 00", bitSetData))
+			// debug builds poison the freed slot
+			freed := "68 65 6c 6c 6f"
+			if poisonFreedSlots {
+				freed = strings.TrimSpace(strings.Repeat(fmt.Sprintf("%02x ", poisonByte), 5))
+			}
+			So(dump, ShouldContainSubstring, fmt.Sprintf("slot 0 (free):\n  %08x  %s", dataOffset, freed))
+			So(dump, ShouldContainSubstring, fmt.Sprintf("slot 1 (used):\n  %08x  77 6f 72 6c 64", dataOffset+5))
+			So(dump, ShouldContainSubstring, "|world|")
+		})
+
+		Convey("dumping an unknown slab should fail", func() {
+			var b strings.Builder
+			So(os.DumpSlab(obj, &b), ShouldNotBeNil)
+		})
+	})
+}
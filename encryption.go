@@ -0,0 +1,228 @@
+package gos
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedMagic is written at the start of every encrypted snapshot
+var encryptedMagic = [4]byte{'G', 'O', 'S', 'E'}
+
+// encryptedVersion is the version of the encrypted snapshot format
+//
+// An encrypted snapshot wraps a snapshot or an incremental snapshot. It is
+// split into chunks, so it can be written and read as a stream. All
+// integers are little endian:
+//
+//	magic        [4]byte "GOSE"
+//	version      uint16
+//	nonce        the nonce size of the AEAD, random bytes
+//	for each chunk:
+//	  final      uint8, 1 for the last chunk
+//	  length     uint32, the length of the sealed chunk
+//	  sealed     up to encryptedChunkSize bytes sealed by the AEAD
+//
+// The nonce of each chunk is the nonce of the header with the chunk's
+// index xor'ed into its last 8 bytes. The header and the final flag are
+// the additional data of each chunk, so chunks can't be reordered and the
+// snapshot can't be truncated at a chunk boundary without being noticed
+const encryptedVersion = 1
+
+// encryptedChunkSize is the maximum plaintext size of a chunk
+const encryptedChunkSize = 64 << 10
+
+// encryptingWriter encrypts everything that is written to it and writes
+// it to the underlying writer as an encrypted snapshot
+type encryptingWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+	chunk  uint64
+	buf    []byte
+}
+
+// newEncryptingWriter writes the header of an encrypted snapshot to w
+// On success it returns the writer and nil, the writer must be closed to
+// write the final chunk
+// On failure the second returned value is the error
+func newEncryptingWriter(w io.Writer, aead cipher.AEAD) (*encryptingWriter, error) {
+	if aead.NonceSize() < 8 {
+		return nil, fmt.Errorf("ObjectStore: the nonce size of the cipher must be at least 8 bytes: %w", ErrInvalidSize)
+	}
+
+	header := make([]byte, 6+aead.NonceSize())
+	copy(header, encryptedMagic[:])
+	binary.LittleEndian.PutUint16(header[4:], encryptedVersion)
+	if _, err := io.ReadFull(rand.Reader, header[6:]); err != nil {
+		return nil, fmt.Errorf("ObjectStore: Failed to generate a nonce: %w", err)
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptingWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		nonce:  make([]byte, aead.NonceSize()),
+		buf:    make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(e.buf) == encryptedChunkSize {
+			if err := e.writeChunk(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encryptedChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the final chunk, it doesn't close the underlying writer
+func (e *encryptingWriter) Close() error {
+	return e.writeChunk(true)
+}
+
+// writeChunk seals the buffered data and writes it as the next chunk
+func (e *encryptingWriter) writeChunk(final bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.nonce, e.header[6:], e.chunk), e.buf, chunkAD(e.header, final))
+	e.chunk++
+	e.buf = e.buf[:0]
+
+	var chunkHeader [5]byte
+	if final {
+		chunkHeader[0] = 1
+	}
+	binary.LittleEndian.PutUint32(chunkHeader[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(chunkHeader[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptingReader reads an encrypted snapshot from the underlying reader
+// and returns the decrypted snapshot
+type decryptingReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+	chunk  uint64
+	buf    []byte
+	final  bool
+}
+
+// newDecryptingReader reads the header of an encrypted snapshot from r
+// On success it returns the reader and nil
+// On failure the second returned value is the error
+func newDecryptingReader(r io.Reader, aead cipher.AEAD) (*decryptingReader, error) {
+	header := make([]byte, 6+aead.NonceSize())
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read the encryption header: %w", err)
+	}
+	if [4]byte(header[:4]) != encryptedMagic {
+		return nil, fmt.Errorf("failed because the data is not an encrypted snapshot")
+	}
+	if version := binary.LittleEndian.Uint16(header[4:]); version != encryptedVersion {
+		return nil, fmt.Errorf("failed because encryption version %d is not supported", version)
+	}
+
+	return &decryptingReader{r: r, aead: aead, header: header, nonce: make([]byte, aead.NonceSize())}, nil
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.final {
+			return 0, io.EOF
+		}
+		if err := d.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// readChunk reads and opens the next chunk
+func (d *decryptingReader) readChunk() error {
+	var chunkHeader [5]byte
+	if _, err := io.ReadFull(d.r, chunkHeader[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			// the final chunk is missing
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	length := binary.LittleEndian.Uint32(chunkHeader[1:])
+	if length > encryptedChunkSize+uint32(d.aead.Overhead()) {
+		return fmt.Errorf("encrypted chunk %d is too large: %w", d.chunk, ErrInvalidSize)
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	d.final = chunkHeader[0] == 1
+	buf, err := d.aead.Open(sealed[:0], chunkNonce(d.nonce, d.header[6:], d.chunk), sealed, chunkAD(d.header, d.final))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt chunk %d: %w", d.chunk, err)
+	}
+	d.chunk++
+	d.buf = buf
+	return nil
+}
+
+// snapshotReader returns a buffered reader for a snapshot which gets read
+// from r. If the store has a snapshot cipher the snapshot gets decrypted,
+// in that case unencrypted snapshots are refused
+// On success it returns the reader and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) snapshotReader(r io.Reader) (*bufio.Reader, error) {
+	if o.snapshotCipher == nil {
+		return bufio.NewReader(r), nil
+	}
+
+	dr, err := newDecryptingReader(r, o.snapshotCipher)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(dr), nil
+}
+
+// chunkNonce writes the nonce of the chunk with the given index to nonce
+// and returns it
+func chunkNonce(nonce, base []byte, chunk uint64) []byte {
+	copy(nonce, base)
+	tail := nonce[len(nonce)-8:]
+	binary.LittleEndian.PutUint64(tail, binary.LittleEndian.Uint64(tail)^chunk)
+	return nonce
+}
+
+// chunkAD returns the additional data of a chunk
+func chunkAD(header []byte, final bool) []byte {
+	ad := make([]byte, len(header)+1)
+	copy(ad, header)
+	if final {
+		ad[len(header)] = 1
+	}
+	return ad
+}
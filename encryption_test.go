@@ -0,0 +1,70 @@
+package gos
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestCipher(key byte) cipher.AEAD {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{key}, 32))
+	So(err, ShouldBeNil)
+	aead, err := cipher.NewGCM(block)
+	So(err, ShouldBeNil)
+	return aead
+}
+
+func TestEncryptedSnapshots(t *testing.T) {
+	Convey("When writing a snapshot of a store with a snapshot cipher", t, func() {
+		// enough objects for several chunks
+		src := NewObjectStore(WithSnapshotCipher(newTestCipher(1)), WithObjsPerSlab(1000))
+		var objs []ObjAddr
+		for i := 0; i < 5000; i++ {
+			obj, err := src.Add([]byte{byte(i), byte(i >> 8), 'x', 'y', 'z', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		var buf bytes.Buffer
+		n, err := src.WriteTo(&buf)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, buf.Len())
+
+		Convey("the object data should not be readable", func() {
+			So(bytes.Contains(buf.Bytes(), []byte("xyz")), ShouldBeFalse)
+		})
+
+		Convey("it should be restored by a store with the same cipher", func() {
+			dst := NewObjectStore(WithSnapshotCipher(newTestCipher(1)))
+			table, err := dst.Restore(bytes.NewReader(buf.Bytes()))
+			So(err, ShouldBeNil)
+
+			for i, obj := range objs {
+				restored, ok := table.Translate(obj)
+				So(ok, ShouldBeTrue)
+				data, err := dst.Get(restored)
+				So(err, ShouldBeNil)
+				So(data[:2], ShouldResemble, []byte{byte(i), byte(i >> 8)})
+			}
+		})
+
+		Convey("it should be refused with another cipher or without one", func() {
+			_, err := NewObjectStore(WithSnapshotCipher(newTestCipher(2))).Restore(bytes.NewReader(buf.Bytes()))
+			So(err, ShouldNotBeNil)
+			_, err = NewObjectStore().Restore(bytes.NewReader(buf.Bytes()))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("truncating it at a chunk boundary should be noticed", func() {
+			// the first chunk has the maximum size
+			end := 6 + 12 + 5 + encryptedChunkSize + 16
+			dst := NewObjectStore(WithSnapshotCipher(newTestCipher(1)))
+			_, err := dst.Restore(bytes.NewReader(buf.Bytes()[:end]))
+			So(err, ShouldNotBeNil)
+			So(len(dst.Slabs()), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,134 @@
+package gos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors returned by the ObjectStore wrap one of these errors, callers
+// can use errors.Is to find out why an operation has failed
+var (
+	// ErrNotFound means that there is no pool or slab for the given
+	// object size or slab address
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidAddr means that an object address doesn't refer to an
+	// object in the store
+	ErrInvalidAddr = errors.New("invalid object address")
+
+	// ErrInvalidSize means that an object or buffer has a size which
+	// is not supported by the operation
+	ErrInvalidSize = errors.New("invalid size")
+
+	// ErrSlabFull means that an object couldn't be added to a slab
+	// because it has no free slot
+	ErrSlabFull = errors.New("slab is full")
+
+	// ErrMemoryLimit means that adding a slab would exceed the memory
+	// limit which has been set with WithMaxMemory
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+
+	// ErrMmapFailed means that the backend failed to map or unmap slab
+	// memory, the error of the backend is wrapped as well
+	ErrMmapFailed = errors.New("mmap failed")
+
+	// ErrDoubleFree is wrapped by the errors that get returned when
+	// deleting an object whose slot is not in use
+	ErrDoubleFree = errors.New("double free")
+
+	// ErrStaleHandle is returned when resolving a handle to an object
+	// that has been deleted
+	ErrStaleHandle = errors.New("handle refers to a deleted object")
+
+	// ErrChecksumMismatch means that the data of a slab in a snapshot
+	// doesn't match its checksum, because the snapshot is corrupted
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrLayoutMismatch means that the slabs of a snapshot have a different
+	// layout than the slabs that the store creates for them, so the object
+	// addresses of the snapshot would be wrong after restoring it
+	ErrLayoutMismatch = errors.New("slab layout mismatch")
+
+	// ErrSealed means that an object couldn't be deleted or changed,
+	// because its slab has been sealed by SealSlab
+	ErrSealed = errors.New("slab is sealed")
+
+	// ErrMemlockLimit means that locking the memory of a new slab with
+	// WithMlock would exceed RLIMIT_MEMLOCK
+	ErrMemlockLimit = errors.New("memlock limit exceeded")
+
+	// ErrOutOfRange means that an index doesn't refer to an element of
+	// a container like Vector
+	ErrOutOfRange = errors.New("index out of range")
+
+	// ErrRingFull means that a record couldn't be pushed into a Ring
+	// because all of its slots are in use
+	ErrRingFull = errors.New("ring is full")
+
+	// ErrCycle means that following the links of objects has led back to
+	// an object which had been visited before
+	ErrCycle = errors.New("links form a cycle")
+)
+
+// AddrError gets returned by the checked accessors if an object address
+// doesn't refer to a used object slot, it unwraps to ErrInvalidAddr
+type AddrError struct {
+	Obj    ObjAddr
+	Slab   SlabAddr
+	Reason string
+
+	// unused is true if the address refers to the slot at index slot,
+	// which is not in use. freed is true if the slot has been used by
+	// an object that got deleted
+	unused bool
+	freed  bool
+	slot   uint
+}
+
+func (e *AddrError) Error() string {
+	if e.Slab == 0 {
+		return fmt.Sprintf("ObjectStore: invalid object address %d: %s", e.Obj, e.Reason)
+	}
+	return fmt.Sprintf("ObjectStore: invalid object address %d in slab %d: %s", e.Obj, e.Slab, e.Reason)
+}
+
+// Unwrap returns ErrInvalidAddr
+func (e *AddrError) Unwrap() error {
+	return ErrInvalidAddr
+}
+
+// DoubleFreeError gets returned when deleting an object whose slot is
+// not in use, it unwraps to ErrDoubleFree
+type DoubleFreeError struct {
+	Slab SlabAddr
+	Slot uint
+}
+
+func (e *DoubleFreeError) Error() string {
+	return fmt.Sprintf("%s of slot %d in slab %d", ErrDoubleFree, e.Slot, e.Slab)
+}
+
+// Unwrap returns ErrDoubleFree
+func (e *DoubleFreeError) Unwrap() error {
+	return ErrDoubleFree
+}
+
+// ChecksumError gets returned when a slab in a snapshot doesn't match its
+// checksum, it identifies the corrupted slab by its address and id at the
+// time the snapshot was written. It unwraps to ErrChecksumMismatch
+type ChecksumError struct {
+	Slab     uint64
+	SlabID   uint32
+	ObjSize  uint8
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("slab %#x with id %d in the pool with object size %d is corrupted, its checksum is %#x instead of %#x", e.Slab, e.SlabID, e.ObjSize, e.Actual, e.Expected)
+}
+
+// Unwrap returns ErrChecksumMismatch
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
@@ -0,0 +1,62 @@
+package gos
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// errBackend is returned by every failingBackend operation
+var errBackend = errors.New("backend failure")
+
+// failingBackend fails to map any memory
+type failingBackend struct{}
+
+func (failingBackend) Map(size int) ([]byte, error) {
+	return nil, errBackend
+}
+
+func (failingBackend) Unmap(data []byte) error {
+	return errBackend
+}
+
+func TestSentinelErrors(t *testing.T) {
+	Convey("When operations on the store fail", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2), WithMaxMemory(uint64(slabSize(3, 2))))
+		obj, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		Convey("the errors should wrap the according sentinel errors", func() {
+			_, err := os.Add(nil)
+			So(errors.Is(err, ErrInvalidSize), ShouldBeTrue)
+
+			_, err = os.FragStatsByObjSize(10)
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+
+			_, err = os.Get(1)
+			So(errors.Is(err, ErrInvalidAddr), ShouldBeTrue)
+
+			_, err = os.GetChecked(obj + 1)
+			So(errors.Is(err, ErrInvalidAddr), ShouldBeTrue)
+
+			_, err = os.Add([]byte("def"))
+			So(err, ShouldBeNil)
+			_, err = os.Add([]byte("ghi"))
+			So(errors.Is(err, ErrMemoryLimit), ShouldBeTrue)
+
+			So(os.Delete(obj), ShouldBeNil)
+			So(errors.Is(os.Delete(obj), ErrDoubleFree), ShouldBeTrue)
+		})
+	})
+
+	Convey("When the backend fails to map memory", t, func() {
+		os := NewObjectStore(WithBackend(failingBackend{}))
+		_, err := os.Add([]byte("abc"))
+
+		Convey("the error should wrap ErrMmapFailed and the backend's error", func() {
+			So(errors.Is(err, ErrMmapFailed), ShouldBeTrue)
+			So(errors.Is(err, errBackend), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,19 @@
+package gos
+
+import (
+	"expvar"
+)
+
+// PublishExpvar publishes the statistics of the store via the expvar package,
+// so they show up at /debug/vars. The variables are named by appending
+// ".mem_stats" and ".fragmentation" to the given prefix, they are computed
+// whenever they get read. Like expvar.Publish it panics if one of the names
+// has already been published
+func (o *ObjectStore) PublishExpvar(prefix string) {
+	expvar.Publish(prefix+".mem_stats", expvar.Func(func() interface{} {
+		return o.MemStats()
+	}))
+	expvar.Publish(prefix+".fragmentation", expvar.Func(func() interface{} {
+		return o.Fragmentation(0)
+	}))
+}
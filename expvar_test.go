@@ -0,0 +1,35 @@
+package gos
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	Convey("When publishing the statistics of a store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		// expvar names can only be published once per process
+		prefix := fmt.Sprintf("gos_test_%d", time.Now().UnixNano())
+		os.PublishExpvar(prefix)
+
+		_, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		Convey("the published variables should reflect the current state", func() {
+			var stats StoreMemStats
+			So(json.Unmarshal([]byte(expvar.Get(prefix+".mem_stats").String()), &stats), ShouldBeNil)
+			So(stats.LiveObjects, ShouldEqual, 1)
+			So(stats.Slabs, ShouldEqual, 1)
+
+			var frag []PoolFragmentation
+			So(json.Unmarshal([]byte(expvar.Get(prefix+".fragmentation").String()), &frag), ShouldBeNil)
+			So(len(frag), ShouldEqual, 1)
+			So(frag[0].Ratio, ShouldAlmostEqual, 0.75)
+		})
+	})
+}
@@ -0,0 +1,412 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// slabFilePrefix and slabFileSuffix surround the number of each slab file
+const (
+	slabFilePrefix = "slab-"
+	slabFileSuffix = ".gos"
+)
+
+// FileBackend is a Backend that maps each memory area from its own file in
+// a directory, using shared mappings. The OS writes the slab memory back to
+// the files, so a store that has been created with OpenFileStore can be
+// reopened after a restart of the process
+type FileBackend struct {
+	dir string
+
+	lock sync.Mutex
+
+	// files maps the address of each memory area to its file
+	files map[uintptr]slabFile
+
+	// next is the number that is used in the name of the next slab file
+	next uint32
+
+	// wal is the write-ahead log of the store, it may be nil
+	wal *writeAheadLog
+}
+
+// slabFile is a file which contains a slab, the number in its name
+// plus 1 is used as the id of the slab
+type slabFile struct {
+	path string
+	num  uint32
+}
+
+// slabFilePath returns the path of the slab file with the given number
+func (f *FileBackend) slabFilePath(num uint32) string {
+	return filepath.Join(f.dir, slabFilePrefix+strconv.FormatUint(uint64(num), 10)+slabFileSuffix)
+}
+
+// NewFileBackend creates a FileBackend which keeps its files in the given
+// directory, the directory gets created if it doesn't exist yet
+// On success it returns the backend and nil
+// On failure the second returned value is the error
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileBackend{dir: dir, files: make(map[uintptr]slabFile)}, nil
+}
+
+// Map creates a new slab file of the given size and maps it
+func (f *FileBackend) Map(size int) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	num := f.next
+	path := f.slabFilePath(num)
+	f.next++
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := file.Truncate(int64(size)); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	f.files[uintptr(unsafe.Pointer(&data[0]))] = slabFile{path: path, num: num}
+	return data, nil
+}
+
+// Unmap unmaps the given memory area and removes its slab file
+func (f *FileBackend) Unmap(data []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	if err := syscall.Munmap(data); err != nil {
+		return err
+	}
+
+	file := f.files[addr]
+	delete(f.files, addr)
+	return os.Remove(file.path)
+}
+
+// slabID implements slabIDer, the slab id is derived from the number
+// of the slab file, so it stays the same when the store gets reopened
+func (f *FileBackend) slabID(addr SlabAddr) (uint32, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	file, ok := f.files[addr]
+	return file.num + 1, ok
+}
+
+// Sync flushes the memory of all mapped slab files to disk, if the store
+// has a write-ahead log it gets truncated afterwards
+// On success it returns nil, otherwise the error of the first failed flush
+func (f *FileBackend) Sync() error {
+	if f.wal != nil {
+		return f.wal.checkpoint(f.sync)
+	}
+	return f.sync()
+}
+
+// sync flushes the memory of all mapped slab files to disk
+func (f *FileBackend) sync() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for addr, file := range f.files {
+		if err := msync(addr, file.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close unmaps all slab files without removing them and closes the
+// write-ahead log. The store which uses this backend must not be used
+// anymore after it has been closed
+// On success it returns nil, otherwise the error of the first failed unmap
+func (f *FileBackend) Close() error {
+	if f.wal != nil {
+		if err := f.wal.close(); err != nil {
+			return err
+		}
+		f.wal = nil
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for addr, file := range f.files {
+		data, err := fileMapping(addr, file.path)
+		if err == nil {
+			err = syscall.Munmap(data)
+		}
+		if err != nil {
+			return err
+		}
+		delete(f.files, addr)
+	}
+
+	return nil
+}
+
+// fileMapping returns the byte slice of the mapping at the given address,
+// the length of the mapping is the size of its file
+func fileMapping(addr uintptr, path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	dataHeader := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	dataHeader.Data = addr
+	dataHeader.Len = int(info.Size())
+	dataHeader.Cap = dataHeader.Len
+	return data, nil
+}
+
+// msync flushes the mapping at the given address to its file
+func msync(addr uintptr, path string) error {
+	data, err := fileMapping(addr, path)
+	if err != nil {
+		return err
+	}
+
+	return msyncMemory(data)
+}
+
+// load maps all the slab files which exist in the directory of the backend
+// On success it returns the mapped slabs and nil
+// On failure the second returned value is the error, all the files that
+// have been mapped by load are unmapped again
+func (f *FileBackend) load() ([]*slab, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var slabs []*slab
+	fail := func(err error) ([]*slab, error) {
+		for _, sl := range slabs {
+			syscall.Munmap(sl.memory())
+			delete(f.files, uintptr(sl.addr()))
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, slabFilePrefix) || !strings.HasSuffix(name, slabFileSuffix) {
+			continue
+		}
+		num, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, slabFilePrefix), slabFileSuffix), 10, 32)
+		if err != nil || num == math.MaxUint32 {
+			continue
+		}
+		if uint32(num) >= f.next {
+			f.next = uint32(num) + 1
+		}
+
+		path := filepath.Join(f.dir, name)
+		sl, err := mapSlabFile(path)
+		if err == errUninitializedSlab {
+			os.Remove(path)
+			continue
+		}
+		if err != nil {
+			return fail(fmt.Errorf("ObjectStore: Failed to load slab file %s: %w", path, err))
+		}
+		f.files[uintptr(sl.addr())] = slabFile{path: path, num: uint32(num)}
+		slabs = append(slabs, sl)
+	}
+
+	sort.Slice(slabs, func(i, j int) bool { return slabs[i].addr() < slabs[j].addr() })
+
+	return slabs, nil
+}
+
+// errUninitializedSlab is returned by mapSlabFile if the header of the
+// slab hasn't been written, because the process has crashed right after
+// the file has been created. Such a slab can't contain any objects
+var errUninitializedSlab = errors.New("slab header has not been initialized")
+
+// mapFile maps the whole file at the given path
+func mapFile(path string) ([]byte, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < int64(1+sizeOfBitSet) {
+		return nil, fmt.Errorf("file is too small to contain a slab: %w", ErrInvalidSize)
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMmapFailed, err)
+	}
+	return data, nil
+}
+
+// mapSlabFile maps the slab file at the given path, verifies its header
+// and points the slab's BitSet at its new location
+// On success it returns the slab and nil
+// On failure the second returned value is the error
+func mapSlabFile(path string) (*slab, error) {
+	data, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sl, err := relocateSlab(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+	return sl, nil
+}
+
+// relocateSlab verifies the header of a slab which has been mapped at a
+// new address and points the slab's BitSet at its new location
+// On success it returns the slab and nil
+// On failure the second returned value is the error
+func relocateSlab(data []byte) (*slab, error) {
+	sl := (*slab)(unsafe.Pointer(&data[0]))
+	objsPerSlab := sl.bitSet().Len()
+	if sl.objSize == 0 && objsPerSlab == 0 {
+		return nil, errUninitializedSlab
+	}
+	if sl.objSize == 0 || objsPerSlab == 0 || slabSize(sl.objSize, objsPerSlab) != len(data) {
+		return nil, fmt.Errorf("slab header doesn't match the file size of %d bytes: %w", len(data), ErrInvalidSize)
+	}
+
+	// the BitSet's data slice still points to where the slab has been
+	// mapped before, so it needs to be redirected to the new mapping
+	words := int((objsPerSlab + 63) / 64)
+	bitSetDataSlice := (*reflect.SliceHeader)(unsafe.Pointer(&data[1+offsetOfBitSetData]))
+	bitSetDataSlice.Data = uintptr(unsafe.Pointer(&data[1+int(sizeOfBitSet)]))
+	bitSetDataSlice.Len = words
+	bitSetDataSlice.Cap = words
+
+	return sl, nil
+}
+
+// OpenFileStore opens the object store which persists its slabs in the
+// given directory, see FileBackend. The slabs which exist in the directory
+// get mapped again, so their objects are available at new addresses.
+// Empty slabs are kept when the store gets reopened, even if empty slabs
+// are reclaimed otherwise
+// The file backend replaces any backend that is set by the options, and
+// WithCanaries, WithMlock and WithNUMABinding can't be used. If
+// WithWriteAheadLog is used, the log gets replayed before the slabs are
+// loaded
+// On success it returns the store, its backend and nil
+// On failure the third returned value is the error
+func OpenFileStore(dir string, opts ...Option) (*ObjectStore, *FileBackend, error) {
+	backend, err := NewFileBackend(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ObjectStore: Failed to open %s: %w", dir, err)
+	}
+
+	o := NewObjectStore(append(opts, WithBackend(backend))...)
+	if o.backend != Backend(backend) {
+		return nil, nil, fmt.Errorf("ObjectStore: The file backend can't be combined with canaries, mlock or NUMA binding")
+	}
+
+	if o.writeAheadLog {
+		o.wal, err = openWriteAheadLog(backend, o.syncWriteAheadLog)
+		if err != nil {
+			return nil, nil, err
+		}
+		backend.wal = o.wal
+	}
+
+	slabs, err := backend.load()
+	if err != nil {
+		backend.Close()
+		return nil, nil, err
+	}
+
+	for i, sl := range slabs {
+		if err := o.adoptSlab(sl); err != nil {
+			// the slabs that have been adopted already are part of the store
+			// now, it's simpler to unmap all of them through the backend
+			backend.Close()
+			return nil, nil, fmt.Errorf("ObjectStore: Failed to load slab %d of %s: %w", i, dir, err)
+		}
+	}
+
+	return o, backend, nil
+}
+
+// adoptSlab adds a slab which has been mapped from a file to the store
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) adoptSlab(sl *slab) error {
+	pool := o.acquireSlabPoolWithCapacity(sl.objSize, sl.objsPerSlab())
+	defer o.poolsLock.RUnlock()
+
+	if err := pool.adoptSlab(sl); err != nil {
+		return err
+	}
+	o.lookupTableInsert(sl.addr())
+	o.slabCreated(sl.objSize, sl.addr())
+
+	return nil
+}
+
+// adoptSlab adds a slab which has been mapped from a file to the pool
+// On success it returns nil, otherwise it returns an error
+func (s *slabPool) adoptSlab(sl *slab) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	capacity := sl.objsPerSlab()
+	if idx, ok := sl.nextObj(capacity); ok {
+		return fmt.Errorf("BitSet has slot %d set, but the slab only has %d slots", idx, capacity)
+	}
+
+	size := uint64(slabSize(s.objSize, capacity))
+	if !s.mem.reserve(size) {
+		return fmt.Errorf("slab would exceed the memory limit of %d bytes: %w", s.mem.max, ErrMemoryLimit)
+	}
+
+	_, st := s.insertSlab(sl)
+	s.addToList(st, emptySlabs)
+	s.rebuildState(st)
+
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build !unix
+// +build !unix
+
+package gos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errFileBackendUnsupported is returned by OpenFileStore and NewFileBackend
+// on platforms which can't map files into memory the way FileBackend needs
+var errFileBackendUnsupported = errors.New("ObjectStore: file backed stores are not supported on this platform")
+
+// FileBackend maps slabs from files on Unix, it can't be created on this
+// platform
+type FileBackend struct{}
+
+// NewFileBackend always fails on this platform
+func NewFileBackend(dir string) (*FileBackend, error) {
+	return nil, errFileBackendUnsupported
+}
+
+// Map always fails on this platform
+func (f *FileBackend) Map(size int) ([]byte, error) {
+	return nil, errFileBackendUnsupported
+}
+
+// Unmap always fails on this platform
+func (f *FileBackend) Unmap(data []byte) error {
+	return errFileBackendUnsupported
+}
+
+// Sync always fails on this platform
+func (f *FileBackend) Sync() error {
+	return errFileBackendUnsupported
+}
+
+// Close always fails on this platform
+func (f *FileBackend) Close() error {
+	return errFileBackendUnsupported
+}
+
+// OpenFileStore always fails on this platform
+func OpenFileStore(dir string, opts ...Option) (*ObjectStore, *FileBackend, error) {
+	return nil, nil, fmt.Errorf("%w: %s", errFileBackendUnsupported, dir)
+}
+
+// writeAheadLog is only created by OpenFileStore, so the slab pools of
+// this platform never have one
+type writeAheadLog struct{}
+
+// walRecord is a record of a writeAheadLog
+type walRecord struct{}
+
+func (w *writeAheadLog) newRecord() *walRecord                                         { return nil }
+func (w *writeAheadLog) add(r *walRecord, sl *slab, idx uint, obj []byte, varLen bool) {}
+func (w *writeAheadLog) delete(r *walRecord, sl *slab, idx uint)                       {}
+func (w *writeAheadLog) commit(r *walRecord) error                                     { return errFileBackendUnsupported }
+func (w *writeAheadLog) applied()                                                      {}
@@ -0,0 +1,121 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileStore(t *testing.T) {
+	Convey("When using a file backed store", t, func() {
+		dir := t.TempDir()
+		os1, backend, err := OpenFileStore(dir, WithObjsPerSlab(4))
+		So(err, ShouldBeNil)
+
+		values := []string{"ab", "cd", "ef", "gh", "ij", "xyz"}
+		var objs []ObjAddr
+		for _, value := range values {
+			obj, err := os1.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(os1.Delete(objs[1]), ShouldBeNil)
+
+		files, err := filepath.Glob(filepath.Join(dir, "slab-*.gos"))
+		So(err, ShouldBeNil)
+		So(len(files), ShouldEqual, 3)
+		So(backend.Sync(), ShouldBeNil)
+
+		Convey("the objects should be there after reopening the store", func() {
+			So(backend.Close(), ShouldBeNil)
+
+			os2, backend2, err := OpenFileStore(dir)
+			So(err, ShouldBeNil)
+			defer backend2.Close()
+			So(os2.CheckIntegrity(), ShouldBeNil)
+
+			for i, value := range values {
+				obj, found := os2.Search([]byte(value))
+				So(found, ShouldEqual, i != 1)
+				if found {
+					data, err := os2.Get(obj)
+					So(err, ShouldBeNil)
+					So(string(data), ShouldEqual, value)
+				}
+			}
+
+			pool, ok := os2.getSlabPool(2)
+			So(ok, ShouldBeTrue)
+			So(pool.objsPerSlab, ShouldEqual, 4)
+
+			Convey("new slabs should not overwrite the existing files", func() {
+				for i := 0; i < 8; i++ {
+					_, err := os2.Add([]byte("zz"))
+					So(err, ShouldBeNil)
+				}
+				obj, found := os2.Search([]byte("gh"))
+				So(found, ShouldBeTrue)
+				data, err := os2.Get(obj)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "gh")
+			})
+		})
+
+		Convey("deleting the last object of a slab should remove its file", func() {
+			So(os1.Delete(objs[5]), ShouldBeNil)
+			files, err := filepath.Glob(filepath.Join(dir, "slab-*.gos"))
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, 2)
+			So(backend.Close(), ShouldBeNil)
+		})
+	})
+
+	Convey("When opening a directory with an invalid slab file", t, func() {
+		dir := t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "slab-0.gos"), []byte("not a slab"), 0o600), ShouldBeNil)
+
+		_, _, err := OpenFileStore(dir)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("When combining the file backend with canaries", t, func() {
+		_, _, err := OpenFileStore(t.TempDir(), WithCanaries())
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestRelativeFileStore(t *testing.T) {
+	Convey("When reopening a file backed store", t, func() {
+		dir := t.TempDir()
+		os1, backend, err := OpenFileStore(dir, WithObjsPerSlab(2))
+		So(err, ShouldBeNil)
+		var rels []RelAddr
+		for _, value := range []string{"ab", "cd", "ef"} {
+			obj, err := os1.Add([]byte(value))
+			So(err, ShouldBeNil)
+			rel, err := os1.Relative(obj)
+			So(err, ShouldBeNil)
+			rels = append(rels, rel)
+		}
+		So(backend.Close(), ShouldBeNil)
+
+		os2, backend, err := OpenFileStore(dir)
+		So(err, ShouldBeNil)
+		defer backend.Close()
+
+		Convey("the relative addresses should still be valid", func() {
+			for i, value := range []string{"ab", "cd", "ef"} {
+				obj, err := os2.Absolute(rels[i])
+				So(err, ShouldBeNil)
+				data, err := os2.Get(obj)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, value)
+			}
+		})
+	})
+}
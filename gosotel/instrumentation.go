@@ -0,0 +1,91 @@
+//go:build gosotel
+// +build gosotel
+
+// Package gosotel records the operations of an ObjectStore as OpenTelemetry
+// metrics. It depends on go.opentelemetry.io/otel, which is not vendored by
+// this repository, so the package is only built with the gosotel tag.
+// Its tests are run by scripts/test_tagged.sh gosotel
+package gosotel
+
+import (
+	"context"
+	"time"
+
+	gos "github.com/replay/go-generic-object-store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instrumentation implements gos.Instrumentation by recording counters and
+// a search latency histogram with the given meter. It gets passed to the
+// store with gos.WithMetrics
+type Instrumentation struct {
+	adds          metric.Int64Counter
+	deletes       metric.Int64Counter
+	slabsCreated  metric.Int64Counter
+	slabsDeleted  metric.Int64Counter
+	searchLatency metric.Float64Histogram
+}
+
+var _ gos.Instrumentation = (*Instrumentation)(nil)
+
+// New creates the instruments with the given meter
+// On success it returns the Instrumentation and nil
+// On failure the second returned value is the error
+func New(meter metric.Meter) (*Instrumentation, error) {
+	var i Instrumentation
+	var err error
+
+	if i.adds, err = meter.Int64Counter("gos.objects.added", metric.WithDescription("Number of added objects")); err != nil {
+		return nil, err
+	}
+	if i.deletes, err = meter.Int64Counter("gos.objects.deleted", metric.WithDescription("Number of deleted objects")); err != nil {
+		return nil, err
+	}
+	if i.slabsCreated, err = meter.Int64Counter("gos.slabs.created", metric.WithDescription("Number of created slabs")); err != nil {
+		return nil, err
+	}
+	if i.slabsDeleted, err = meter.Int64Counter("gos.slabs.deleted", metric.WithDescription("Number of deleted slabs")); err != nil {
+		return nil, err
+	}
+	i.searchLatency, err = meter.Float64Histogram("gos.search.duration", metric.WithDescription("Latency of searches"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// OnAdd implements gos.Metrics
+func (i *Instrumentation) OnAdd(objSize uint8) {
+	i.adds.Add(context.Background(), 1, objSizeAttr(objSize))
+}
+
+// OnDelete implements gos.Metrics
+func (i *Instrumentation) OnDelete(objSize uint8) {
+	i.deletes.Add(context.Background(), 1, objSizeAttr(objSize))
+}
+
+// OnSlabCreated implements gos.Metrics
+func (i *Instrumentation) OnSlabCreated(objSize uint8, _ gos.SlabAddr) {
+	i.slabsCreated.Add(context.Background(), 1, objSizeAttr(objSize))
+}
+
+// OnSlabDeleted implements gos.Metrics
+func (i *Instrumentation) OnSlabDeleted(objSize uint8, _ gos.SlabAddr) {
+	i.slabsDeleted.Add(context.Background(), 1, objSizeAttr(objSize))
+}
+
+// OnSearch implements gos.SearchMetrics
+func (i *Instrumentation) OnSearch(objSize uint8, found bool, latency time.Duration) {
+	i.searchLatency.Record(context.Background(), latency.Seconds(), metric.WithAttributes(
+		attribute.Int("gos.obj_size", int(objSize)),
+		attribute.Bool("gos.found", found),
+	))
+}
+
+// objSizeAttr returns the option which sets the attribute of the pool's object size
+func objSizeAttr(objSize uint8) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.Int("gos.obj_size", int(objSize)))
+}
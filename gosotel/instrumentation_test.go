@@ -0,0 +1,51 @@
+//go:build gosotel
+// +build gosotel
+
+package gosotel
+
+import (
+	"context"
+	"testing"
+
+	gos "github.com/replay/go-generic-object-store"
+
+	. "github.com/smartystreets/goconvey/convey"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInstrumentation(t *testing.T) {
+	Convey("When recording the operations of a store", t, func() {
+		reader := sdkmetric.NewManualReader()
+		provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		instrumentation, err := New(provider.Meter("gos"))
+		So(err, ShouldBeNil)
+
+		store := gos.NewObjectStore(gos.WithObjsPerSlab(2), gos.WithMetrics(instrumentation))
+		for _, value := range []string{"abc", "def", "ghi"} {
+			_, err := store.Add([]byte(value))
+			So(err, ShouldBeNil)
+		}
+		store.Search([]byte("def"))
+
+		Convey("the instruments should have recorded them", func() {
+			var rm metricdata.ResourceMetrics
+			So(reader.Collect(context.Background(), &rm), ShouldBeNil)
+
+			values := make(map[string]int64)
+			for _, m := range rm.ScopeMetrics[0].Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Sum[int64]:
+					values[m.Name] = data.DataPoints[0].Value
+				case metricdata.Histogram[float64]:
+					values[m.Name] = int64(data.DataPoints[0].Count)
+				}
+			}
+			So(values, ShouldResemble, map[string]int64{
+				"gos.objects.added":   3,
+				"gos.slabs.created":   2,
+				"gos.search.duration": 1,
+			})
+		})
+	})
+}
@@ -0,0 +1,149 @@
+//go:build gosprom
+// +build gosprom
+
+// Package gosprom exports the statistics of an ObjectStore to Prometheus.
+// It depends on github.com/prometheus/client_golang, which is not vendored
+// by this repository, so the package is only built with the gosprom tag.
+// Its tests are run by scripts/test_tagged.sh gosprom
+package gosprom
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	gos "github.com/replay/go-generic-object-store"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector implements prometheus.Collector for an ObjectStore. It also
+// implements gos.Metrics and gos.SearchMetrics, the store must be created
+// with gos.WithMetrics(collector) for the slab churn and the search
+// latencies to be counted
+type Collector struct {
+	lock  sync.RWMutex
+	store *gos.ObjectStore
+
+	mappedBytes   *prometheus.Desc
+	objects       *prometheus.Desc
+	freeSlots     *prometheus.Desc
+	slabs         *prometheus.Desc
+	fragmentation *prometheus.Desc
+
+	adds          *prometheus.CounterVec
+	deletes       *prometheus.CounterVec
+	slabsCreated  *prometheus.CounterVec
+	slabsDeleted  *prometheus.CounterVec
+	searchLatency *prometheus.HistogramVec
+}
+
+// NewCollector creates a Collector, all its metrics get the label store
+// set to the given store name
+func NewCollector(storeName string) *Collector {
+	labels := prometheus.Labels{"store": storeName}
+	poolLabels := []string{"obj_size"}
+
+	return &Collector{
+		mappedBytes:   prometheus.NewDesc("gos_mapped_bytes", "Memory used by the slabs of a pool.", poolLabels, labels),
+		objects:       prometheus.NewDesc("gos_objects", "Number of objects in a pool.", poolLabels, labels),
+		freeSlots:     prometheus.NewDesc("gos_free_slots", "Number of unused object slots in a pool.", poolLabels, labels),
+		slabs:         prometheus.NewDesc("gos_slabs", "Number of slabs in a pool.", poolLabels, labels),
+		fragmentation: prometheus.NewDesc("gos_fragmentation_ratio", "Free slots in non-empty slabs divided by all slots of a pool.", poolLabels, labels),
+
+		adds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gos_objects_added_total", Help: "Number of added objects.", ConstLabels: labels,
+		}, poolLabels),
+		deletes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gos_objects_deleted_total", Help: "Number of deleted objects.", ConstLabels: labels,
+		}, poolLabels),
+		slabsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gos_slabs_created_total", Help: "Number of created slabs.", ConstLabels: labels,
+		}, poolLabels),
+		slabsDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gos_slabs_deleted_total", Help: "Number of deleted slabs.", ConstLabels: labels,
+		}, poolLabels),
+		searchLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "gos_search_duration_seconds", Help: "Latency of searches.", ConstLabels: labels,
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10),
+		}, []string{"obj_size", "found"}),
+	}
+}
+
+// SetStore sets the store whose memory statistics get collected
+func (c *Collector) SetStore(store *gos.ObjectStore) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.store = store
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.mappedBytes
+	ch <- c.objects
+	ch <- c.freeSlots
+	ch <- c.slabs
+	ch <- c.fragmentation
+	c.adds.Describe(ch)
+	c.deletes.Describe(ch)
+	c.slabsCreated.Describe(ch)
+	c.slabsDeleted.Describe(ch)
+	c.searchLatency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	store := c.store
+	c.lock.RUnlock()
+
+	if store != nil {
+		for _, pool := range store.MemStats().Pools {
+			size := objSizeLabel(pool.ObjSize)
+			ch <- prometheus.MustNewConstMetric(c.mappedBytes, prometheus.GaugeValue, float64(pool.MappedBytes), size)
+			ch <- prometheus.MustNewConstMetric(c.objects, prometheus.GaugeValue, float64(pool.LiveObjects), size)
+			ch <- prometheus.MustNewConstMetric(c.freeSlots, prometheus.GaugeValue, float64(pool.FreeSlots), size)
+			ch <- prometheus.MustNewConstMetric(c.slabs, prometheus.GaugeValue, float64(pool.Slabs), size)
+		}
+		for _, pool := range store.Fragmentation(0) {
+			ch <- prometheus.MustNewConstMetric(c.fragmentation, prometheus.GaugeValue, pool.Ratio, objSizeLabel(pool.ObjSize))
+		}
+	}
+
+	c.adds.Collect(ch)
+	c.deletes.Collect(ch)
+	c.slabsCreated.Collect(ch)
+	c.slabsDeleted.Collect(ch)
+	c.searchLatency.Collect(ch)
+}
+
+// OnAdd implements gos.Metrics
+func (c *Collector) OnAdd(objSize uint8) {
+	c.adds.WithLabelValues(objSizeLabel(objSize)).Inc()
+}
+
+// OnDelete implements gos.Metrics
+func (c *Collector) OnDelete(objSize uint8) {
+	c.deletes.WithLabelValues(objSizeLabel(objSize)).Inc()
+}
+
+// OnSlabCreated implements gos.Metrics
+func (c *Collector) OnSlabCreated(objSize uint8, _ gos.SlabAddr) {
+	c.slabsCreated.WithLabelValues(objSizeLabel(objSize)).Inc()
+}
+
+// OnSlabDeleted implements gos.Metrics
+func (c *Collector) OnSlabDeleted(objSize uint8, _ gos.SlabAddr) {
+	c.slabsDeleted.WithLabelValues(objSizeLabel(objSize)).Inc()
+}
+
+// OnSearch implements gos.SearchMetrics
+func (c *Collector) OnSearch(objSize uint8, found bool, latency time.Duration) {
+	c.searchLatency.WithLabelValues(objSizeLabel(objSize), strconv.FormatBool(found)).Observe(latency.Seconds())
+}
+
+// objSizeLabel returns the value of the obj_size label of a pool
+func objSizeLabel(objSize uint8) string {
+	return strconv.Itoa(int(objSize))
+}
@@ -0,0 +1,34 @@
+//go:build gosprom
+// +build gosprom
+
+package gosprom
+
+import (
+	"testing"
+
+	gos "github.com/replay/go-generic-object-store"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollector(t *testing.T) {
+	Convey("When collecting the metrics of a store", t, func() {
+		collector := NewCollector("test")
+		store := gos.NewObjectStore(gos.WithObjsPerSlab(2), gos.WithMetrics(collector))
+		collector.SetStore(store)
+
+		for _, value := range []string{"abc", "def", "ghi"} {
+			_, err := store.Add([]byte(value))
+			So(err, ShouldBeNil)
+		}
+		store.Search([]byte("def"))
+
+		Convey("the metrics should reflect the state of the store", func() {
+			So(testutil.ToFloat64(collector.slabsCreated), ShouldEqual, 2)
+			So(testutil.ToFloat64(collector.adds), ShouldEqual, 3)
+			So(testutil.CollectAndCount(collector, "gos_objects"), ShouldEqual, 1)
+			So(testutil.CollectAndCount(collector, "gos_search_duration_seconds"), ShouldEqual, 1)
+		})
+	})
+}
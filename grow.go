@@ -0,0 +1,119 @@
+package gos
+
+// WithSlabGrowth makes a pool grow one of its full slabs in place when it
+// runs out of free slots, instead of creating another slab. The capacity of
+// the slab gets doubled up to the given number of objects per slab, if the
+// address space after the slab is free. This keeps the number of slabs and
+// the changes to the lookup table low for steadily growing pools. Growing
+// requires a backend that can extend its mappings without moving them,
+// like the default backend on Linux which uses mremap, with other backends
+// the option has no effect. The BitSet of each slab reserves room for the
+// maximum, which moves the objects within the slab if the maximum needs
+// more BitSet words than the initial capacity. Restore and ApplyIncremental
+// fail with ErrLayoutMismatch if the slabs of a snapshot have been laid out
+// for another maximum. Slab listeners don't get notified when a slab grows
+func WithSlabGrowth(maxObjsPerSlab uint) Option {
+	return func(o *ObjectStore) {
+		o.growObjsPerSlab = maxObjsPerSlab
+	}
+}
+
+// slabGrower can be implemented by a Backend which can extend a memory
+// area that it has mapped without moving it
+type slabGrower interface {
+	// grow extends the given memory area to the given size and returns
+	// it, it fails if the address space after the area is in use
+	grow(data []byte, size int) ([]byte, error)
+}
+
+// setGrowObjsPerSlab makes the pool grow its slabs in place up to the
+// given number of objects per slab if its backend supports it, see
+// WithSlabGrowth
+func (s *slabPool) setGrowObjsPerSlab(maxObjsPerSlab uint) {
+	if _, ok := s.backend.(slabGrower); !ok || maxObjsPerSlab <= s.objsPerSlab {
+		return
+	}
+
+	s.growObjsPerSlab = maxObjsPerSlab
+	for capacity := s.objsPerSlab * 2; capacity < maxObjsPerSlab; capacity *= 2 {
+		s.addCapacity(capacity)
+	}
+	s.addCapacity(maxObjsPerSlab)
+}
+
+// reservedCapacity returns the number of objects that the BitSet of a new
+// slab with the given capacity has room for
+func (s *slabPool) reservedCapacity(capacity uint) uint {
+	if s.growObjsPerSlab > capacity {
+		return s.growObjsPerSlab
+	}
+	return capacity
+}
+
+// growSlab doubles the capacity of a full slab in place, so it has free
+// slots again. The slabs get tried from the highest address down, slabs
+// which have failed to grow before are skipped
+// It returns the state of the grown slab, or nil if no slab could grow
+// The caller must hold the pool lock for writing
+func (s *slabPool) growSlab() *slabState {
+	if s.growObjsPerSlab == 0 {
+		return nil
+	}
+	grower, ok := s.backend.(slabGrower)
+	if !ok {
+		return nil
+	}
+
+	for _, sl := range s.slabs {
+		st := s.states[sl]
+		if st.sealed || st.stuck || st.list != fullSlabs {
+			continue
+		}
+		capacity := st.capacity * 2
+		if capacity > s.growObjsPerSlab {
+			capacity = s.growObjsPerSlab
+		}
+		if !sl.canGrow(capacity) || !s.hasCapacity(capacity) {
+			continue
+		}
+
+		added := uint64(s.objSize) * uint64(capacity-st.capacity)
+		if !s.mem.reserve(added) {
+			return nil
+		}
+		if _, err := grower.grow(sl.memory(), int(uint64(sl.getTotalLength())+added)); err != nil {
+			s.mem.release(added)
+			st.stuck = true
+			if s.logger != nil {
+				s.logger.Debug("gos: failed to grow slab", "objSize", s.objSize, "slab", sl.addr(), "error", err)
+			}
+			continue
+		}
+
+		sl.grow(capacity)
+		st.gens = append(st.gens, make([]uint32, capacity-st.capacity)...)
+		st.capacity = capacity
+		st.dirty.Store(true)
+		s.updateList(st)
+
+		if s.logger != nil {
+			s.logger.Debug("gos: slab grown", "objSize", s.objSize, "slab", sl.addr(), "objsPerSlab", capacity)
+		}
+		if s.slabGrown != nil {
+			s.slabGrown(sl.addr())
+		}
+
+		return st
+	}
+
+	return nil
+}
+
+// slabGrown adds the pages of a slab which has grown in place to the slab
+// index
+func (o *ObjectStore) slabGrown(sAddr SlabAddr) {
+	o.lookupLock.Lock()
+	defer o.lookupLock.Unlock()
+
+	o.slabIndex.insert(sAddr, slabFromSlabAddr(sAddr).getTotalLength())
+}
@@ -0,0 +1,150 @@
+package gos
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// growingHeapBackend is a HeapBackend which allocates limit bytes for each
+// memory area, so the memory areas can grow up to that size
+type growingHeapBackend struct {
+	*HeapBackend
+	limit int
+}
+
+func (b growingHeapBackend) Map(size int) ([]byte, error) {
+	data, err := b.HeapBackend.Map(b.limit)
+	if err != nil {
+		return nil, err
+	}
+	return data[:size:size], nil
+}
+
+func (b growingHeapBackend) grow(data []byte, size int) ([]byte, error) {
+	if size > b.limit {
+		return nil, ErrInvalidSize
+	}
+	return sliceAt(objAddrFromObj(data), size), nil
+}
+
+func TestSlabGrowth(t *testing.T) {
+	Convey("When adding objects to a store whose slabs can grow", t, func() {
+		backend := growingHeapBackend{HeapBackend: NewHeapBackend(), limit: growableSlabSize(8, 32, 32)}
+		os := NewObjectStore(WithBackend(backend), WithObjsPerSlab(8), WithSlabGrowth(32))
+		var objs []ObjAddr
+		for i := 0; i < 32; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2, 3, 4, 5, 6, 7})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("the first slab should have grown instead of adding slabs", func() {
+			slabs := os.Slabs()
+			So(len(slabs), ShouldEqual, 1)
+			So(slabs[0].Capacity, ShouldEqual, 32)
+			So(slabs[0].LiveObjects, ShouldEqual, 32)
+			So(slabs[0].TotalBytes, ShouldEqual, backend.limit)
+			So(os.CheckIntegrity(), ShouldBeNil)
+
+			for i, obj := range objs {
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(data[0], ShouldEqual, byte(i))
+				sAddr, ok := os.slabIndex.lookup(obj)
+				So(ok, ShouldBeTrue)
+				So(sAddr, ShouldEqual, slabs[0].Addr)
+			}
+		})
+
+		Convey("a slab should be added once the slab has reached the maximum", func() {
+			_, err := os.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+			So(err, ShouldBeNil)
+			So(len(os.Slabs()), ShouldEqual, 2)
+		})
+
+		Convey("a snapshot should restore the grown slab", func() {
+			var buf bytes.Buffer
+			_, err := os.WriteTo(&buf)
+			So(err, ShouldBeNil)
+
+			dst := NewObjectStore(WithBackend(growingHeapBackend{HeapBackend: NewHeapBackend(), limit: backend.limit}), WithObjsPerSlab(8), WithSlabGrowth(32))
+			table, err := dst.Restore(&buf)
+			So(err, ShouldBeNil)
+			for i, obj := range objs {
+				newObj, ok := table.Translate(obj)
+				So(ok, ShouldBeTrue)
+				data, err := dst.Get(newObj)
+				So(err, ShouldBeNil)
+				So(data[0], ShouldEqual, byte(i))
+			}
+		})
+
+		Convey("deleting all objects should delete the grown slab", func() {
+			for _, obj := range objs {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(len(os.Slabs()), ShouldEqual, 0)
+			So(len(backend.areas), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When a slab can't grow", t, func() {
+		backend := growingHeapBackend{HeapBackend: NewHeapBackend(), limit: growableSlabSize(8, 8, 32)}
+		os := NewObjectStore(WithBackend(backend), WithObjsPerSlab(8), WithSlabGrowth(32))
+		for i := 0; i < 17; i++ {
+			_, err := os.Add([]byte{byte(i), 1, 2, 3, 4, 5, 6, 7})
+			So(err, ShouldBeNil)
+		}
+
+		Convey("slabs should be added and the slab should not be tried again", func() {
+			slabs := os.Slabs()
+			So(len(slabs), ShouldEqual, 3)
+			pool, _ := os.getSlabPool(8)
+			var stuck int
+			for _, sl := range pool.slabs {
+				So(pool.states[sl].capacity, ShouldEqual, 8)
+				if pool.states[sl].stuck {
+					stuck++
+				}
+			}
+			So(stuck, ShouldEqual, 2)
+		})
+	})
+	Convey("When restoring a snapshot of slabs that have room to grow beyond 64 objects", t, func() {
+		backend := growingHeapBackend{HeapBackend: NewHeapBackend(), limit: growableSlabSize(8, 128, 128)}
+		src := NewObjectStore(WithBackend(backend), WithObjsPerSlab(8), WithSlabGrowth(128))
+		obj, err := src.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+		So(err, ShouldBeNil)
+
+		var incremental, full bytes.Buffer
+		_, err = src.WriteIncremental(&incremental)
+		So(err, ShouldBeNil)
+		_, err = src.WriteTo(&full)
+		So(err, ShouldBeNil)
+
+		Convey("a store with the same maximum should restore it", func() {
+			dst := NewObjectStore(WithBackend(growingHeapBackend{HeapBackend: NewHeapBackend(), limit: backend.limit}), WithObjsPerSlab(8), WithSlabGrowth(128))
+			table, err := dst.Restore(&full)
+			So(err, ShouldBeNil)
+			newObj, ok := table.Translate(obj)
+			So(ok, ShouldBeTrue)
+			data, err := dst.Get(newObj)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+		})
+
+		Convey("a store without slab growth should reject it", func() {
+			dst := NewObjectStore(WithObjsPerSlab(8))
+			_, err := dst.Restore(&full)
+			So(errors.Is(err, ErrLayoutMismatch), ShouldBeTrue)
+			So(len(dst.Slabs()), ShouldEqual, 0)
+
+			_, err = dst.ApplyIncremental(&incremental)
+			So(errors.Is(err, ErrLayoutMismatch), ShouldBeTrue)
+			So(len(dst.Slabs()), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package gos
+
+// ObjHandle is an opaque reference to an object in an ObjectStore, it can be
+// used instead of an ObjAddr if objects may get deleted while references to
+// them are still around.
+// A handle doesn't contain a memory address, it identifies the slab by an id,
+// the object slot by its index and the slot's use by a generation. The
+// generation of a slot gets bumped whenever its object gets deleted, so
+// handles to deleted objects are detected even if the slot has been reused.
+// Because the slab gets resolved by its id, a handle stays valid if the
+// memory of the slab gets relocated
+type ObjHandle struct {
+	slab uint32
+	slot uint32
+	gen  uint32
+}
+
+// Handle returns a handle which refers to the object at the given address
+// On success it returns the handle and nil
+// On failure the second returned value is an *AddrError
+func (o *ObjectStore) Handle(obj ObjAddr) (ObjHandle, error) {
+	var h ObjHandle
+	err := o.withCheckedObj(obj, func(pool *slabPool, slabAddr SlabAddr) error {
+		id, ok := o.slabID(slabAddr)
+		if !ok {
+			return &AddrError{Obj: obj, Slab: slabAddr, Reason: "slab has no id"}
+		}
+
+		sl := slabFromSlabAddr(slabAddr)
+		idx := sl.getObjIdx(obj)
+		h = ObjHandle{slab: id, slot: uint32(idx), gen: pool.states[sl].gens[idx]}
+		return nil
+	})
+
+	return h, err
+}
+
+// Resolve returns the current address of the object that the handle refers
+// to, it follows the object if it has been moved by a compaction
+// On success it returns the object address and nil
+// On failure it returns 0 and ErrStaleHandle
+func (o *ObjectStore) Resolve(h ObjHandle) (ObjAddr, error) {
+	var followed []ObjHandle
+	for {
+		obj, err := o.resolve(h)
+		if err != ErrStaleHandle {
+			return obj, err
+		}
+
+		o.lookupLock.RLock()
+		next, ok := o.handleForwards[h]
+		o.lookupLock.RUnlock()
+		if !ok {
+			break
+		}
+		followed = append(followed, h)
+		h = next
+	}
+
+	// the object has been deleted after it got moved, the slots of its
+	// forwards never get used by it again
+	if len(followed) > 0 {
+		o.lookupLock.Lock()
+		for _, f := range followed {
+			delete(o.handleForwards, f)
+		}
+		o.lookupLock.Unlock()
+	}
+
+	return 0, ErrStaleHandle
+}
+
+// resolve returns the address of the object that the handle refers to,
+// without following forwards
+// On success it returns the object address and nil
+// On failure it returns 0 and ErrStaleHandle
+func (o *ObjectStore) resolve(h ObjHandle) (ObjAddr, error) {
+	// the header of the slab only gets read while holding the lookup lock,
+	// so the slab can't get unmapped meanwhile
+	var objSize uint8
+	o.lookupLock.RLock()
+	slabAddr, ok := o.slabsByID[h.slab]
+	if ok {
+		objSize = slabFromSlabAddr(slabAddr).objSize
+	}
+	o.lookupLock.RUnlock()
+	if !ok {
+		return 0, ErrStaleHandle
+	}
+
+	pool, ok := o.getSlabPool(objSize)
+	if !ok {
+		return 0, ErrStaleHandle
+	}
+
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	// the pool unlinks its slabs while holding its lock, so if the slab
+	// still has the same id it can't get unmapped until the lock is released
+	if id, ok := o.slabID(slabAddr); !ok || id != h.slab {
+		return 0, ErrStaleHandle
+	}
+
+	sl := slabFromSlabAddr(slabAddr)
+	st, ok := pool.states[sl]
+	if !ok || uint(h.slot) >= st.capacity || st.gens[h.slot] != h.gen || !sl.bitSet().Test(uint(h.slot)) {
+		return 0, ErrStaleHandle
+	}
+
+	return sl.getObjAddrByIdx(uint(h.slot)), nil
+}
+
+// objMoved records a forward from the handle of an object which gets moved
+// by a compaction to the handle of its new slot. The pool calls it while it
+// holds its lock, before the old slot gets freed
+func (o *ObjectStore) objMoved(from *slab, fromIdx uint, fromGen uint32, to *slab, toIdx uint, toGen uint32) {
+	o.lookupLock.Lock()
+	defer o.lookupLock.Unlock()
+
+	fromID, ok := o.slabIDs[from.addr()]
+	if !ok {
+		return
+	}
+	toID, ok := o.slabIDs[to.addr()]
+	if !ok {
+		return
+	}
+	o.handleForwards[ObjHandle{slab: fromID, slot: uint32(fromIdx), gen: fromGen}] = ObjHandle{slab: toID, slot: uint32(toIdx), gen: toGen}
+}
@@ -0,0 +1,165 @@
+package gos
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHandles(t *testing.T) {
+	Convey("When adding objects to the store with empty slabs being kept", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4), WithReclaimEmptySlabs(false))
+		obj1, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("def"))
+		So(err, ShouldBeNil)
+
+		h1, err := os.Handle(obj1)
+		So(err, ShouldBeNil)
+		h2, err := os.Handle(obj2)
+		So(err, ShouldBeNil)
+
+		Convey("the handles should resolve to the objects", func() {
+			addr, err := os.Resolve(h1)
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, obj1)
+			addr, err = os.Resolve(h2)
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, obj2)
+		})
+
+		Convey("a handle to a deleted object should be stale, even if its slot got reused", func() {
+			So(os.Delete(obj1), ShouldBeNil)
+			_, err := os.Resolve(h1)
+			So(err, ShouldEqual, ErrStaleHandle)
+
+			obj3, err := os.Add([]byte("ghi"))
+			So(err, ShouldBeNil)
+			So(obj3, ShouldEqual, obj1)
+			_, err = os.Resolve(h1)
+			So(err, ShouldEqual, ErrStaleHandle)
+
+			h3, err := os.Handle(obj3)
+			So(err, ShouldBeNil)
+			So(h3, ShouldNotResemble, h1)
+			addr, err := os.Resolve(h3)
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, obj3)
+		})
+
+		Convey("the zero handle and handles of invalid addresses should be rejected", func() {
+			_, err := os.Resolve(ObjHandle{})
+			So(err, ShouldEqual, ErrStaleHandle)
+			_, err = os.Handle(obj1 + 1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestResolvingHandlesConcurrently(t *testing.T) {
+	Convey("When handles get resolved while their slabs get deleted", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(1))
+
+		// every add maps a slab and every delete unmaps it, while the
+		// resolvers keep resolving the handle of the most recent object
+		wg := sync.WaitGroup{}
+		errs := make(chan error, 8)
+		for g := 1; g <= 4; g++ {
+			var latest atomic.Value
+			var done atomic.Bool
+			wg.Add(2)
+			go func(size int) {
+				defer wg.Done()
+				defer done.Store(true)
+				value := make([]byte, size)
+				for i := 0; i < 2000; i++ {
+					obj, err := os.Add(value)
+					if err != nil {
+						errs <- err
+						return
+					}
+					h, err := os.Handle(obj)
+					if err != nil {
+						errs <- err
+						return
+					}
+					latest.Store(h)
+					if err := os.Delete(obj); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(g)
+			go func() {
+				defer wg.Done()
+				for !done.Load() {
+					h, ok := latest.Load().(ObjHandle)
+					if !ok {
+						continue
+					}
+					if _, err := os.Resolve(h); err != nil && err != ErrStaleHandle {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			So(err, ShouldBeNil)
+		}
+	})
+}
+
+func TestHandlesAfterCompact(t *testing.T) {
+	Convey("When compacting a store whose objects are referred to by handles", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var order []ObjAddr
+		for i := 0; i < 16; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			order = append(order, obj)
+		}
+
+		// leave 1, 1, 2 and 4 objects in the four slabs
+		handles := make(map[ObjAddr]ObjHandle)
+		for i, obj := range order {
+			switch i {
+			case 1, 2, 3, 5, 6, 7, 10, 11:
+				So(os.Delete(obj), ShouldBeNil)
+			default:
+				h, err := os.Handle(obj)
+				So(err, ShouldBeNil)
+				handles[obj] = h
+			}
+		}
+
+		moved, err := os.Compact()
+		So(err, ShouldBeNil)
+		So(len(moved), ShouldEqual, 2)
+
+		Convey("the handles should resolve to the new addresses of the moved objects", func() {
+			for obj, h := range handles {
+				expected := obj
+				if newObj, ok := moved[obj]; ok {
+					expected = newObj
+				}
+				addr, err := os.Resolve(h)
+				So(err, ShouldBeNil)
+				So(addr, ShouldEqual, expected)
+			}
+		})
+
+		Convey("the handle of a moved object should be stale once it has been deleted", func() {
+			for oldObj, newObj := range moved {
+				So(os.Delete(newObj), ShouldBeNil)
+				_, err := os.Resolve(handles[oldObj])
+				So(err, ShouldEqual, ErrStaleHandle)
+			}
+			So(os.handleForwards, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,157 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// hashIndex maps the hashes of the objects of a pool to the addresses of
+// the objects with that hash, so objects can be found by their value
+// without searching through the slabs
+// it is not safe for concurrent use, the owning slab pool's lock
+// protects it
+type hashIndex map[uint64][]ObjAddr
+
+// add adds the object at the given address with the given hash
+func (h hashIndex) add(hash uint64, obj ObjAddr) {
+	h[hash] = append(h[hash], obj)
+}
+
+// remove removes the object at the given address with the given hash
+func (h hashIndex) remove(hash uint64, obj ObjAddr) {
+	objs := h[hash]
+	for i := range objs {
+		if objs[i] != obj {
+			continue
+		}
+		if len(objs) == 1 {
+			delete(h, hash)
+			return
+		}
+		objs[i] = objs[len(objs)-1]
+		h[hash] = objs[:len(objs)-1]
+		return
+	}
+}
+
+// enableHashIndex creates the hash index of the pool from the objects of
+// all its slabs and keeps it updated on every add and delete from then on
+func (s *slabPool) enableHashIndex() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.buildHashIndex()
+}
+
+// buildHashIndex implements enableHashIndex, it does nothing if the pool
+// already has a hash index
+// The caller must hold the pool lock for writing
+func (s *slabPool) buildHashIndex() {
+	if s.hashIndex != nil {
+		return
+	}
+
+	s.hashIndex = make(hashIndex)
+	for _, sl := range s.slabs {
+		s.indexSlab(sl)
+	}
+}
+
+// indexSlab adds the objects of the given slab to the hash index
+// The caller must hold the pool lock for writing
+func (s *slabPool) indexSlab(sl *slab) {
+	for idx, ok := sl.nextObj(0); ok; idx, ok = sl.nextObj(idx + 1) {
+		s.hashIndex.add(bloomHash(s.objByIdx(sl, idx)), sl.getObjAddrByIdx(idx))
+	}
+}
+
+// unindexSlab removes the objects of the given slab from the hash index
+// The caller must hold the pool lock for writing
+func (s *slabPool) unindexSlab(sl *slab) {
+	for idx, ok := sl.nextObj(0); ok; idx, ok = sl.nextObj(idx + 1) {
+		s.hashIndex.remove(bloomHash(s.objByIdx(sl, idx)), sl.getObjAddrByIdx(idx))
+	}
+}
+
+// lookup returns the address of an object which is equal to the given one
+// by looking it up in the hash index
+// When found it returns the object address and true, otherwise the second
+// returned value is false
+// The caller must hold the pool lock and the pool must have a hash index
+func (s *slabPool) lookup(searching []byte) (ObjAddr, bool) {
+	for _, obj := range s.hashIndex[bloomHash(searching)] {
+		if bytes.Equal(s.get(obj), searching) {
+			return obj, true
+		}
+	}
+	return 0, false
+}
+
+// addOrGet adds the given object to the pool unless the pool already
+// contains an equal object. If the pool has no hash index yet it gets
+// built first, see enableHashIndex
+// The first returned value is the address of the added or existing object
+// The second value is the slab address if the call created a new slab
+// The third value is true if the object already existed
+// The fourth value is nil if there was no error, otherwise it is the error
+func (s *slabPool) addOrGet(obj []byte) (ObjAddr, SlabAddr, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.buildHashIndex()
+	if objAddr, ok := s.lookup(obj); ok {
+		return objAddr, 0, true, nil
+	}
+
+	objAddr, slabAddr, err := s.addLocked(obj)
+	return objAddr, slabAddr, false, err
+}
+
+// AddOrGet adds the given object like Add does, unless the store already
+// contains an identical object, in which case the address of the existing
+// object gets returned instead of storing a duplicate. This is meant for
+// interning strings or labels. The existing objects get found through the
+// hash index of the pool, which gets built by the first AddOrGet on a pool
+// unless it has been enabled with WithHashIndex, and which then gets
+// updated on every add and delete of the pool. AddReserve is not supported
+// by pools with a hash index
+// On success it returns the object address, true if the object already
+// existed, and nil
+// On failure the third returned value is the error
+func (o *ObjectStore) AddOrGet(obj []byte) (ObjAddr, bool, error) {
+	// we only deal with objects up to a size of 255
+	if len(obj) == 0 || len(obj) > 255 {
+		return 0, false, fmt.Errorf("ObjectStore: AddOrGet failed because size of object (%d) is outside limits (1-%d): %w", len(obj), 255, ErrInvalidSize)
+	}
+
+	size := uint8(len(obj))
+	pool := o.acquireSlabPool(size)
+	oAddr, sAddr, existed, err := pool.addOrGet(obj)
+	o.poolsLock.RUnlock()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if existed {
+		return oAddr, true, nil
+	}
+
+	if sAddr != 0 {
+		o.slabCreated(size, sAddr)
+	}
+	if o.metrics != nil {
+		o.metrics.OnAdd(size)
+	}
+	o.indexAdded(oAddr, obj)
+	if subscribed, payload := o.hasSubscribers(); subscribed {
+		if payload {
+			payload := make([]byte, len(obj))
+			copy(payload, obj)
+			o.publishChange(ObjAdded, oAddr, size, payload)
+		} else {
+			o.publishChange(ObjAdded, oAddr, size, nil)
+		}
+	}
+
+	return oAddr, false, nil
+}
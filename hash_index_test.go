@@ -0,0 +1,113 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHashIndex(t *testing.T) {
+	Convey("When objects with colliding hashes get added to a hash index", t, func() {
+		index := make(hashIndex)
+		index.add(1, 100)
+		index.add(1, 200)
+		index.add(2, 300)
+
+		Convey("removing one of them should keep the other", func() {
+			index.remove(1, 100)
+			So(index[1], ShouldResemble, []ObjAddr{200})
+			index.remove(1, 200)
+			_, ok := index[1]
+			So(ok, ShouldBeFalse)
+			So(index[2], ShouldResemble, []ObjAddr{300})
+		})
+	})
+}
+
+func TestAddOrGet(t *testing.T) {
+	Convey("When interning values with AddOrGet", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		objs := make(map[string]ObjAddr)
+		for i := 0; i < 10; i++ {
+			value := fmt.Sprintf("label%02d", i)
+			obj, existed, err := os.AddOrGet([]byte(value))
+			So(err, ShouldBeNil)
+			So(existed, ShouldBeFalse)
+			objs[value] = obj
+		}
+
+		Convey("adding them again should return the existing objects", func() {
+			for value, obj := range objs {
+				found, existed, err := os.AddOrGet([]byte(value))
+				So(err, ShouldBeNil)
+				So(existed, ShouldBeTrue)
+				So(found, ShouldEqual, obj)
+			}
+			So(len(os.Slabs()), ShouldEqual, 3)
+		})
+
+		Convey("deleted objects should be added again", func() {
+			So(os.Delete(objs["label03"]), ShouldBeNil)
+			obj, existed, err := os.AddOrGet([]byte("label03"))
+			So(err, ShouldBeNil)
+			So(existed, ShouldBeFalse)
+			data, err := os.Get(obj)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "label03")
+		})
+
+		Convey("objects moved by a compaction should be found at their new address", func() {
+			for _, i := range []int{0, 1, 2, 4, 5, 6} {
+				So(os.Delete(objs[fmt.Sprintf("label%02d", i)]), ShouldBeNil)
+			}
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldBeGreaterThan, 0)
+
+			for _, i := range []int{3, 7, 8, 9} {
+				value := fmt.Sprintf("label%02d", i)
+				obj := objs[value]
+				if newObj, ok := moved[obj]; ok {
+					obj = newObj
+				}
+				found, existed, err := os.AddOrGet([]byte(value))
+				So(err, ShouldBeNil)
+				So(existed, ShouldBeTrue)
+				So(found, ShouldEqual, obj)
+			}
+		})
+
+		Convey("reserving slots in the pool should fail", func() {
+			_, _, err := os.AddReserve(7)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("an empty object should be rejected", func() {
+			_, _, err := os.AddOrGet(nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("When the hash index is enabled for a store with objects", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4), WithHashIndex())
+		var objs []ObjAddr
+		for i := 0; i < 10; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("searches should find the objects through the index", func() {
+			pool, _ := os.getSlabPool(3)
+			So(len(pool.hashIndex), ShouldEqual, 10)
+			for i, obj := range objs {
+				found, ok := os.Search([]byte{byte(i), 1, 2})
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, obj)
+			}
+			_, ok := os.Search([]byte{100, 1, 2})
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
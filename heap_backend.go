@@ -0,0 +1,59 @@
+package gos
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// HeapBackend is a Backend which allocates the memory areas on the Go heap,
+// it is the default on platforms without mmap like js/wasm and plan9
+// The GC doesn't move heap objects, so the memory areas keep their
+// addresses. HeapBackend holds a reference to each of them until it gets
+// unmapped, because the store only refers to its memory areas by address.
+// The memory areas count towards the heap size of the GC, so they make it
+// collect less often than with the other backends
+type HeapBackend struct {
+	lock sync.Mutex
+
+	// areas maps the address of each memory area to the words which
+	// contain it
+	areas map[uintptr][]uint64
+}
+
+// NewHeapBackend creates a HeapBackend
+func NewHeapBackend() *HeapBackend {
+	return &HeapBackend{areas: make(map[uintptr][]uint64)}
+}
+
+// Map allocates a new zeroed memory area of the given size, it is
+// allocated as words to get the alignment which the slab header needs
+func (b *HeapBackend) Map(size int) ([]byte, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("memory area size %d: %w", size, ErrInvalidSize)
+	}
+
+	words := make([]uint64, (size+7)/8)
+	addr := uintptr(unsafe.Pointer(&words[0]))
+
+	b.lock.Lock()
+	b.areas[addr] = words
+	b.lock.Unlock()
+
+	return sliceAt(addr, size), nil
+}
+
+// Unmap drops the reference to the given memory area, so the GC can
+// collect it
+func (b *HeapBackend) Unmap(data []byte) error {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.areas[addr]; !ok {
+		return fmt.Errorf("memory area at %#x has not been mapped by this backend: %w", addr, ErrNotFound)
+	}
+	delete(b.areas, addr)
+	return nil
+}
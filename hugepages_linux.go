@@ -0,0 +1,102 @@
+//go:build linux && !arm
+// +build linux,!arm
+
+package gos
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// hugePageSize is the size of the default huge pages, which MAP_HUGETLB
+// maps. It is 2MB on x86-64 and on arm64 with 4K pages, but 512MB on
+// arm64 with 64K pages
+var hugePageSize = detectHugePageSize()
+
+// detectHugePageSize reads the size of the default huge pages from
+// /proc/meminfo, if that fails it returns 2MB
+func detectHugePageSize() int {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 2 << 20
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// the line looks like "Hugepagesize:       2048 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "Hugepagesize:" && fields[2] == "kB" {
+			if kb, err := strconv.Atoi(fields[1]); err == nil && kb > 0 {
+				return kb << 10
+			}
+		}
+	}
+	return 2 << 20
+}
+
+// hugePageBackend wraps MmapBackend and maps the memory areas of at least
+// one huge page with MAP_HUGETLB. If no huge pages are available, it falls
+// back to the wrapped backend
+type hugePageBackend struct {
+	Backend
+
+	lock sync.Mutex
+
+	// mappings maps the address of each memory area that is backed by
+	// huge pages to its whole mapping, which is rounded up to huge pages
+	mappings map[uintptr][]byte
+}
+
+// newHugePageBackend wraps the given backend with a hugePageBackend, if it
+// is the MmapBackend. Other backends get returned unchanged, because their
+// memory must not be replaced by anonymous mappings
+func newHugePageBackend(backend Backend) Backend {
+	if _, ok := backend.(MmapBackend); !ok {
+		return backend
+	}
+	return &hugePageBackend{Backend: backend, mappings: make(map[uintptr][]byte)}
+}
+
+// Map maps a memory area of the given size from huge pages if it is at
+// least one huge page large, otherwise or if that fails it uses the
+// wrapped backend
+func (h *hugePageBackend) Map(size int) ([]byte, error) {
+	if size < hugePageSize {
+		return h.Backend.Map(size)
+	}
+
+	length := (size + hugePageSize - 1) / hugePageSize * hugePageSize
+	mapping, err := syscall.Mmap(-1, 0, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE|syscall.MAP_HUGETLB)
+	if err != nil {
+		// the huge page pool is empty or exhausted
+		return h.Backend.Map(size)
+	}
+
+	h.lock.Lock()
+	h.mappings[uintptr(unsafe.Pointer(&mapping[0]))] = mapping
+	h.lock.Unlock()
+
+	return mapping[:size:size], nil
+}
+
+// Unmap unmaps the given memory area, with the wrapped backend unless it
+// is backed by huge pages
+func (h *hugePageBackend) Unmap(data []byte) error {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+
+	h.lock.Lock()
+	mapping, ok := h.mappings[addr]
+	delete(h.mappings, addr)
+	h.lock.Unlock()
+
+	if !ok {
+		return h.Backend.Unmap(data)
+	}
+	return syscall.Munmap(mapping)
+}
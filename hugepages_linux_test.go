@@ -0,0 +1,50 @@
+//go:build linux && !arm
+// +build linux,!arm
+
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHugePages(t *testing.T) {
+	Convey("When using huge pages in a store with large slabs", t, func() {
+		os := NewObjectStore(WithHugePages(), WithObjsPerSlab(uint(hugePageSize/4)))
+		backend, ok := os.backend.(*hugePageBackend)
+		So(ok, ShouldBeTrue)
+
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		small, err := os.Add([]byte("a"))
+		So(err, ShouldBeNil)
+
+		Convey("objects should be retrievable whether huge pages are available or not", func() {
+			data, err := os.Get(objs[1])
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "def")
+			data, err = os.Get(small)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "a")
+		})
+
+		Convey("deleting all objects should unmap the slabs", func() {
+			for _, obj := range append(objs, small) {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(len(backend.mappings), ShouldEqual, 0)
+			So(len(os.lookupTable), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When combining huge pages with another backend", t, func() {
+		backend := NewHeapBackend()
+		os := NewObjectStore(WithHugePages(), WithBackend(backend))
+		So(os.backend, ShouldEqual, backend)
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux || arm
+// +build !linux arm
+
+package gos
+
+// newHugePageBackend returns the given backend unchanged, because huge
+// pages are not supported on this platform
+func newHugePageBackend(backend Backend) Backend {
+	return backend
+}
@@ -0,0 +1,311 @@
+package gos
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// incrementalMagic is written at the start of every incremental snapshot
+var incrementalMagic = [4]byte{'G', 'O', 'S', 'I'}
+
+// An incremental snapshot is laid out like a snapshot of the current
+// version, but it starts with incrementalMagic and each slab has a dirty
+// flag after its id:
+//
+//	    address    uint64, the address of the slab when it was written
+//	    id         uint32, the id of the slab
+//	    dirty      uint8, 1 if the slab has changed since the last snapshot
+//	    bitset     only if the slab is dirty
+//	    objects    only if the slab is dirty
+//	    checksum   uint32, CRC32 of all the above fields of the slab
+//
+// So an incremental snapshot contains a manifest of all the slabs of the
+// store, but only the data of the slabs which have changed
+
+// WriteIncremental writes an incremental snapshot to w, which only contains
+// the data of the slabs which have changed since the last snapshot has been
+// written by WriteTo or WriteIncremental. The unchanged slabs are only
+// listed, so ApplyIncremental can delete the slabs which have been deleted.
+// A chain of a snapshot and incremental snapshots can be restored by
+// restoring the snapshot into an empty store and then applying the
+// incremental snapshots in the order in which they have been written
+// On success it returns the number of written bytes and nil
+// On failure the second returned value is the error of w, in that case the
+// slabs are still considered as changed by the next snapshot
+func (o *ObjectStore) WriteIncremental(w io.Writer) (int64, error) {
+	return o.writeSnapshot(w, incrementalMagic, true)
+}
+
+// incrementalPool is a pool of an incremental snapshot
+type incrementalPool struct {
+	objSize     uint8
+	objsPerSlab uint
+	layout      slabLayout
+	slabs       []incrementalSlab
+}
+
+// incrementalSlab is a slab of an incremental snapshot, words and data
+// are only set if the slab is dirty
+type incrementalSlab struct {
+	addr  uintptr
+	id    uint32
+	dirty bool
+	words []uint64
+	data  []byte
+}
+
+// ApplyIncremental applies an incremental snapshot which has been written
+// by WriteIncremental to the store. The store must have been restored from
+// the snapshot that the incremental snapshot is based on, because the slabs
+// get identified by their ids. Afterwards the store contains exactly the
+// slabs which are listed by the incremental snapshot, the changed slabs
+// get overwritten and the others are left alone
+// On success it returns the relocation table of all the listed slabs and nil
+// On failure the second returned value is the error. If the incremental
+// snapshot couldn't be read or doesn't match the store, the store is left
+// unchanged. Otherwise it has been applied partially
+func (o *ObjectStore) ApplyIncremental(r io.Reader) (*RelocationTable, error) {
+	br, err := o.snapshotReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+	}
+	pools, err := readIncremental(br, !o.skipChecksums)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkIncremental(pools); err != nil {
+		return nil, err
+	}
+
+	table := &RelocationTable{}
+	listed := make(map[uint32]struct{})
+	for _, ip := range pools {
+		reloc, err := o.applyIncrementalPool(ip, listed)
+		table.relocations = append(table.relocations, reloc...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the slabs which aren't listed have been deleted
+	o.lookupLock.RLock()
+	var deleted []SlabAddr
+	for id, slabAddr := range o.slabsByID {
+		if _, ok := listed[id]; !ok {
+			deleted = append(deleted, slabAddr)
+		}
+	}
+	o.lookupLock.RUnlock()
+	for _, slabAddr := range deleted {
+		size := slabFromSlabAddr(slabAddr).objSize
+		totalBytes := uint64(slabFromSlabAddr(slabAddr).getTotalLength())
+		o.deleteRestoredSlab(slabAddr)
+		o.slabDeleted(size, slabAddr, totalBytes)
+	}
+
+	sort.Slice(table.relocations, func(i, j int) bool { return table.relocations[i].oldStart < table.relocations[j].oldStart })
+	return table, nil
+}
+
+// readIncremental reads a whole incremental snapshot from br, the checksums
+// of the slabs only get verified if verify is true
+// On success it returns its pools and nil
+// On failure the second returned value is the error
+func readIncremental(br *bufio.Reader, verify bool) ([]incrementalPool, error) {
+	d, err := newSnapshotDecoder(br, incrementalMagic)
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+	}
+	d.verify = verify
+	if !d.hasSlabIDs() {
+		return nil, fmt.Errorf("ObjectStore: ApplyIncremental failed because snapshot version %d has no slab ids", d.version)
+	}
+
+	var pools []incrementalPool
+	for i := uint32(0); i < d.poolCount; i++ {
+		objSize, objsPerSlab, slabCount, err := d.readPoolHeader()
+		if err != nil {
+			return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+		}
+		layout, err := d.readPoolLayout(objsPerSlab)
+		if err != nil {
+			return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+		}
+
+		ip := incrementalPool{objSize: objSize, objsPerSlab: objsPerSlab, layout: layout}
+		for j := uint32(0); j < slabCount; j++ {
+			addr, id, err := d.readSlabHeader()
+			if err != nil {
+				return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+			}
+			dirty, err := d.readDirty()
+			if err != nil {
+				return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+			}
+
+			is := incrementalSlab{addr: uintptr(addr), id: id, dirty: dirty}
+			if is.dirty {
+				is.words = make([]uint64, (ip.objsPerSlab+63)/64)
+				is.data = make([]byte, int(ip.objSize)*int(ip.objsPerSlab))
+				err = d.readWords(is.words)
+				if err == nil {
+					err = d.readData(is.data)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+				}
+			}
+			if err := d.verifySlab(addr, id, objSize); err != nil {
+				return nil, fmt.Errorf("ObjectStore: ApplyIncremental %w", err)
+			}
+			ip.slabs = append(ip.slabs, is)
+		}
+		pools = append(pools, ip)
+	}
+
+	return pools, nil
+}
+
+// checkIncremental verifies that the incremental snapshot can be applied
+// to the store, the slabs that haven't changed must exist in the store and
+// the existing slabs and pools must have the same layout
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) checkIncremental(pools []incrementalPool) error {
+	for _, ip := range pools {
+		o.lookupLock.RLock()
+		for _, is := range ip.slabs {
+			slabAddr, ok := o.slabsByID[is.id]
+			if !ok && !is.dirty {
+				o.lookupLock.RUnlock()
+				return fmt.Errorf("ObjectStore: ApplyIncremental failed because the unchanged slab %d doesn't exist: %w", is.id, ErrNotFound)
+			}
+			if ok && slabFromSlabAddr(slabAddr).objSize != ip.objSize {
+				o.lookupLock.RUnlock()
+				return fmt.Errorf("ObjectStore: ApplyIncremental failed because slab %d has object size %d instead of %d", is.id, slabFromSlabAddr(slabAddr).objSize, ip.objSize)
+			}
+			if ok && slabFromSlabAddr(slabAddr).objsPerSlab() != ip.objsPerSlab {
+				o.lookupLock.RUnlock()
+				return fmt.Errorf("ObjectStore: ApplyIncremental failed because slab %d has %d objects per slab instead of %d", is.id, slabFromSlabAddr(slabAddr).objsPerSlab(), ip.objsPerSlab)
+			}
+			if ok {
+				if err := ip.layout.check(slabFromSlabAddr(slabAddr)); err != nil {
+					o.lookupLock.RUnlock()
+					return fmt.Errorf("ObjectStore: ApplyIncremental of slab %d %w", is.id, err)
+				}
+			}
+		}
+		o.lookupLock.RUnlock()
+	}
+
+	return nil
+}
+
+// applyIncrementalPool applies the slabs of a pool of an incremental
+// snapshot and adds their ids to listed
+// It returns the relocations of the applied slabs, and an error if a slab
+// couldn't be created
+func (o *ObjectStore) applyIncrementalPool(ip incrementalPool, listed map[uint32]struct{}) ([]relocation, error) {
+	// pools which don't exist yet get the layout of the snapshot
+	pool := o.acquireSlabPoolWithCapacity(ip.objSize, ip.objsPerSlab)
+	defer o.poolsLock.RUnlock()
+
+	var relocations []relocation
+	for _, is := range ip.slabs {
+		reloc := relocation{
+			oldStart: is.addr,
+			oldID:    is.id,
+		}
+
+		o.lookupLock.RLock()
+		slabAddr, exists := o.slabsByID[is.id]
+		o.lookupLock.RUnlock()
+
+		var err error
+		switch {
+		case exists && is.dirty:
+			err = pool.overwriteSlab(slabFromSlabAddr(slabAddr), is.words, is.data)
+			reloc.newStart, reloc.newID = slabAddr, is.id
+		case exists:
+			reloc.newStart, reloc.newID = slabAddr, is.id
+		default:
+			slabAddr, err = pool.restoreSlabData(is.words, is.data, ip.objsPerSlab, ip.layout)
+			if err == nil {
+				reloc.newStart = slabAddr
+				reloc.newID = o.assignSlabID(slabAddr, is.id)
+				o.slabCreated(ip.objSize, slabAddr)
+			}
+		}
+		if err != nil {
+			return relocations, fmt.Errorf("ObjectStore: ApplyIncremental failed to apply slab %d: %w", is.id, err)
+		}
+		reloc.oldEnd = is.addr + slabFromSlabAddr(reloc.newStart).getTotalLength()
+
+		listed[reloc.newID] = struct{}{}
+		relocations = append(relocations, reloc)
+	}
+
+	return relocations, nil
+}
+
+// overwriteSlab replaces the BitSet and the objects of an existing slab.
+// The generations of all slots get bumped, so handles which refer to the
+// replaced objects become stale
+// On success it returns nil, otherwise it returns an error
+func (s *slabPool) overwriteSlab(sl *slab, words []uint64, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	st, ok := s.states[sl]
+	if !ok {
+		return fmt.Errorf("slab is not in the pool: %w", ErrNotFound)
+	}
+	if st.sealed {
+		return fmt.Errorf("slab can't be overwritten: %w", ErrSealed)
+	}
+
+	if s.bloomFilters != nil {
+		s.bloomFilters[sl] = newBloomFilter(st.capacity)
+	}
+	if s.hashIndex != nil {
+		s.unindexSlab(sl)
+	}
+	copy(sl.memory()[sl.getDataOffset():], data)
+	copy(sl.bitSet().Bytes(), words)
+	for i := range st.gens {
+		st.gens[i]++
+	}
+	st.dirty.Store(true)
+
+	return s.rebuildState(st)
+}
+
+// restoreSlabData adds a new slab with the given capacity to the pool and
+// fills it with the given BitSet words and object data, the new slab must
+// have the given layout
+// On success it returns the address of the new slab and nil
+// On failure the second returned value is the error
+func (s *slabPool) restoreSlabData(words []uint64, data []byte, capacity uint, layout slabLayout) (SlabAddr, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idx, err := s.addSlabWithCapacity(capacity)
+	if err != nil {
+		return 0, err
+	}
+	sl := s.slabs[idx]
+
+	if err := layout.check(sl); err != nil {
+		s.deleteSlab(sl.addr())
+		return 0, err
+	}
+	copy(sl.memory()[sl.getDataOffset():], data)
+	copy(sl.bitSet().Bytes(), words)
+	if err := s.rebuildState(s.states[sl]); err != nil {
+		s.deleteSlab(sl.addr())
+		return 0, err
+	}
+
+	return sl.addr(), nil
+}
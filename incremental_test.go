@@ -0,0 +1,84 @@
+package gos
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIncrementalSnapshots(t *testing.T) {
+	Convey("When writing incremental snapshots", t, func() {
+		src := NewObjectStore(WithObjsPerSlab(2))
+		var objs []ObjAddr
+		for _, value := range []string{"aa", "bb", "cc", "dd", "ee", "ff"} {
+			obj, err := src.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		var full bytes.Buffer
+		_, err := src.WriteTo(&full)
+		So(err, ShouldBeNil)
+
+		dst := NewObjectStore()
+		_, err = dst.Restore(&full)
+		So(err, ShouldBeNil)
+
+		Convey("an incremental snapshot without changes should only list the slabs", func() {
+			var buf bytes.Buffer
+			n, err := src.WriteIncremental(&buf)
+			So(err, ShouldBeNil)
+			// header, one pool and three slabs without data
+			So(n, ShouldEqual, 12+17+3*(13+4))
+		})
+
+		Convey("applying an incremental snapshot should reproduce the changes", func() {
+			// change the slab of "cc" and "dd", delete the slab of "ee" and
+			// "ff" and add a new slab, the slab of "aa" and "bb" stays the same
+			So(src.Delete(objs[2]), ShouldBeNil)
+			So(src.Delete(objs[4]), ShouldBeNil)
+			So(src.Delete(objs[5]), ShouldBeNil)
+			_, err := src.Add([]byte("gg"))
+			So(err, ShouldBeNil)
+			_, err = src.Add([]byte("hh"))
+			So(err, ShouldBeNil)
+			_, err = src.Add([]byte("xyz"))
+			So(err, ShouldBeNil)
+
+			var buf bytes.Buffer
+			_, err = src.WriteIncremental(&buf)
+			So(err, ShouldBeNil)
+
+			table, err := dst.ApplyIncremental(&buf)
+			So(err, ShouldBeNil)
+			So(len(dst.Slabs()), ShouldEqual, len(src.Slabs()))
+
+			for _, value := range []string{"aa", "bb", "dd", "gg", "hh", "xyz"} {
+				srcObj, found := src.Search([]byte(value))
+				So(found, ShouldBeTrue)
+				obj, ok := table.Translate(srcObj)
+				So(ok, ShouldBeTrue)
+				data, err := dst.Get(obj)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, value)
+			}
+			for _, value := range []string{"cc", "ee", "ff"} {
+				_, found := dst.Search([]byte(value))
+				So(found, ShouldBeFalse)
+			}
+		})
+
+		Convey("an incremental snapshot should not be applied to an unrelated store", func() {
+			var buf bytes.Buffer
+			_, err := src.WriteIncremental(&buf)
+			So(err, ShouldBeNil)
+
+			other := NewObjectStore()
+			_, err = other.ApplyIncremental(&buf)
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+			So(len(other.Slabs()), ShouldEqual, 0)
+		})
+	})
+}
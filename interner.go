@@ -0,0 +1,253 @@
+package gos
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StringHandle refers to a string which has been interned by an Interner,
+// it stays valid when the string gets moved by a compaction of the store
+// until the string gets released. The zero value refers to no string
+type StringHandle uint32
+
+// InternerStats describes the strings of an Interner and how much memory
+// their deduplication has saved
+type InternerStats struct {
+	// Strings is the number of unique strings
+	Strings uint64
+
+	// References is the number of references to the strings, each Intern
+	// adds one and each Release removes one
+	References uint64
+
+	// StoredBytes is the number of bytes of the unique strings
+	StoredBytes uint64
+
+	// SavedBytes is the number of bytes which haven't been stored because
+	// an identical string was interned already
+	SavedBytes uint64
+}
+
+// Interner stores unique strings off-heap in an ObjectStore and returns a
+// stable handle for each of them. Interning a string which has been
+// interned before returns the handle of the existing string and adds a
+// reference to it, the string gets deleted once each reference has been
+// released. Strings must be between 1 and 255 bytes long
+type Interner struct {
+	store *ObjectStore
+
+	// lock protects the fields below, Intern and Release hold it for
+	// writing so a string can't get deleted while it gets interned again
+	lock sync.RWMutex
+
+	// addrs contains the address of each string by its handle minus one,
+	// handles maps the addresses back to the handles
+	addrs   []ObjAddr
+	handles map[ObjAddr]StringHandle
+
+	// sizes contains the length of each string by its handle minus one
+	sizes []uint8
+
+	// free contains the handles of released strings for reuse
+	free []StringHandle
+
+	stats InternerStats
+
+	removeListener func()
+}
+
+// NewInterner creates an Interner whose strings get stored in an
+// ObjectStore created with the given options, the store always has a hash
+// index, see WithHashIndex
+func NewInterner(opts ...Option) *Interner {
+	store := NewObjectStore(append(opts, WithHashIndex())...)
+
+	in := &Interner{
+		store:   store,
+		handles: make(map[ObjAddr]StringHandle),
+	}
+	in.removeListener = store.AddRelocationListener(in.relocate)
+
+	return in
+}
+
+// Store returns the ObjectStore of the interner, it can be used to compact
+// or inspect the store. Objects must only be added and deleted through
+// the Interner
+func (in *Interner) Store() *ObjectStore {
+	return in.store
+}
+
+// Close stops the interner from following the objects which get moved by
+// compactions, the interner must not be used anymore afterwards
+func (in *Interner) Close() {
+	in.removeListener()
+}
+
+// Intern stores the given string unless it has been interned before, and
+// adds a reference to it
+// On success it returns the handle of the string and nil
+// On failure the second returned value is the error
+func (in *Interner) Intern(s string) (StringHandle, error) {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	return in.intern(s)
+}
+
+// InternBatch interns each of the given strings like Intern does
+// On success it returns the handles of the strings in the same order as
+// the given strings
+// On failure it returns an error and none of the strings have been interned
+func (in *Interner) InternBatch(strs []string) ([]StringHandle, error) {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	handles := make([]StringHandle, 0, len(strs))
+	for i, s := range strs {
+		h, err := in.intern(s)
+		if err != nil {
+			// roll back the strings that have already been interned
+			for _, h := range handles {
+				in.release(h)
+			}
+			return nil, fmt.Errorf("Interner: InternBatch failed to intern string %d: %w", i, err)
+		}
+		handles = append(handles, h)
+	}
+
+	return handles, nil
+}
+
+// intern implements Intern
+// The caller must hold the interner lock for writing
+func (in *Interner) intern(s string) (StringHandle, error) {
+	obj, existed, err := in.store.AddOrGet([]byte(s))
+	if err != nil {
+		return 0, err
+	}
+
+	if existed {
+		if _, err := in.store.AddRef(obj); err != nil {
+			return 0, err
+		}
+		in.stats.References++
+		in.stats.SavedBytes += uint64(len(s))
+		return in.handles[obj], nil
+	}
+
+	var h StringHandle
+	if n := len(in.free); n > 0 {
+		h = in.free[n-1]
+		in.free = in.free[:n-1]
+		in.addrs[h-1] = obj
+		in.sizes[h-1] = uint8(len(s))
+	} else {
+		in.addrs = append(in.addrs, obj)
+		in.sizes = append(in.sizes, uint8(len(s)))
+		h = StringHandle(len(in.addrs))
+	}
+	in.handles[obj] = h
+
+	in.stats.Strings++
+	in.stats.References++
+	in.stats.StoredBytes += uint64(len(s))
+
+	return h, nil
+}
+
+// Lookup returns a copy of the string that the given handle refers to
+// On success it returns the string and nil
+// On failure the second returned value wraps ErrStaleHandle
+func (in *Interner) Lookup(h StringHandle) (string, error) {
+	in.lock.RLock()
+	defer in.lock.RUnlock()
+
+	obj, err := in.addr(h)
+	if err != nil {
+		return "", err
+	}
+
+	// the string gets copied while holding the pool lock, because a
+	// concurrent compaction may move it. If it has been moved before the
+	// relocation listener has updated its address, the lookup fails
+	var buf [255]byte
+	size := in.sizes[h-1]
+	if err := in.store.GetInto(obj, buf[:size]); err != nil {
+		return "", fmt.Errorf("Interner: Lookup of handle %d failed: %w: %w", h, ErrStaleHandle, err)
+	}
+
+	return string(buf[:size]), nil
+}
+
+// Release removes a reference from the string that the given handle
+// refers to, once its last reference has been released the string gets
+// deleted and the handle must not be used anymore
+// On success it returns nil, otherwise it returns an error
+func (in *Interner) Release(h StringHandle) error {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	return in.release(h)
+}
+
+// release implements Release
+// The caller must hold the interner lock for writing
+func (in *Interner) release(h StringHandle) error {
+	obj, err := in.addr(h)
+	if err != nil {
+		return err
+	}
+
+	size := in.sizes[h-1]
+	deleted, err := in.store.Release(obj)
+	if err != nil {
+		return err
+	}
+	in.stats.References--
+	if !deleted {
+		return nil
+	}
+
+	delete(in.handles, obj)
+	in.addrs[h-1] = 0
+	in.free = append(in.free, h)
+	in.stats.Strings--
+	in.stats.StoredBytes -= uint64(size)
+
+	return nil
+}
+
+// addr returns the address of the string that the given handle refers to
+// On success it returns the address and nil
+// On failure the second returned value wraps ErrStaleHandle
+// The caller must hold the interner lock
+func (in *Interner) addr(h StringHandle) (ObjAddr, error) {
+	if h == 0 || int(h) > len(in.addrs) || in.addrs[h-1] == 0 {
+		return 0, fmt.Errorf("Interner: handle %d doesn't refer to a string: %w", h, ErrStaleHandle)
+	}
+	return in.addrs[h-1], nil
+}
+
+// Stats returns the statistics of the interner
+func (in *Interner) Stats() InternerStats {
+	in.lock.RLock()
+	defer in.lock.RUnlock()
+
+	return in.stats
+}
+
+// relocate updates the address of a string which has been moved by a
+// compaction, it is registered as a relocation listener of the store
+func (in *Interner) relocate(oldAddr, newAddr ObjAddr) {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	h, ok := in.handles[oldAddr]
+	if !ok {
+		return
+	}
+	delete(in.handles, oldAddr)
+	in.handles[newAddr] = h
+	in.addrs[h-1] = newAddr
+}
@@ -0,0 +1,90 @@
+package gos
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInterner(t *testing.T) {
+	Convey("When interning strings with duplicates", t, func() {
+		in := NewInterner(WithObjsPerSlab(4))
+		defer in.Close()
+
+		var handles []StringHandle
+		for i := 0; i < 20; i++ {
+			h, err := in.Intern(fmt.Sprintf("label%02d", i%10))
+			So(err, ShouldBeNil)
+			handles = append(handles, h)
+		}
+
+		Convey("each unique string should only be stored once", func() {
+			for i := 0; i < 10; i++ {
+				So(handles[i+10], ShouldEqual, handles[i])
+				s, err := in.Lookup(handles[i])
+				So(err, ShouldBeNil)
+				So(s, ShouldEqual, fmt.Sprintf("label%02d", i))
+			}
+			So(in.Stats(), ShouldResemble, InternerStats{Strings: 10, References: 20, StoredBytes: 70, SavedBytes: 70})
+		})
+
+		Convey("a string should be deleted once every reference has been released", func() {
+			So(in.Release(handles[0]), ShouldBeNil)
+			s, err := in.Lookup(handles[0])
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "label00")
+
+			So(in.Release(handles[0]), ShouldBeNil)
+			_, err = in.Lookup(handles[0])
+			So(errors.Is(err, ErrStaleHandle), ShouldBeTrue)
+			So(in.Stats().Strings, ShouldEqual, 9)
+			So(in.Stats().StoredBytes, ShouldEqual, 63)
+
+			// the released handle gets reused
+			h, err := in.Intern("other00")
+			So(err, ShouldBeNil)
+			So(h, ShouldEqual, handles[0])
+		})
+
+		Convey("handles should stay valid when a compaction moves the strings", func() {
+			for i := 0; i < 10; i++ {
+				if i%4 != 0 {
+					So(in.Release(handles[i]), ShouldBeNil)
+					So(in.Release(handles[i]), ShouldBeNil)
+				}
+			}
+			moved, err := in.Store().Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldBeGreaterThan, 0)
+
+			for _, i := range []int{0, 4, 8} {
+				s, err := in.Lookup(handles[i])
+				So(err, ShouldBeNil)
+				So(s, ShouldEqual, fmt.Sprintf("label%02d", i))
+				h, err := in.Intern(s)
+				So(err, ShouldBeNil)
+				So(h, ShouldEqual, handles[i])
+			}
+		})
+
+		Convey("a batch with an invalid string should not intern anything", func() {
+			_, err := in.InternBatch([]string{"new", ""})
+			So(err, ShouldNotBeNil)
+			So(in.Stats().Strings, ShouldEqual, 10)
+
+			batch, err := in.InternBatch([]string{"new", "label01", "new"})
+			So(err, ShouldBeNil)
+			So(batch[0], ShouldEqual, batch[2])
+			So(batch[1], ShouldEqual, handles[1])
+			So(in.Stats().Strings, ShouldEqual, 11)
+		})
+
+		Convey("invalid handles should be rejected", func() {
+			_, err := in.Lookup(0)
+			So(errors.Is(err, ErrStaleHandle), ShouldBeTrue)
+			So(errors.Is(in.Release(100), ErrStaleHandle), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,256 @@
+package gos
+
+import (
+	"sort"
+
+	"github.com/willf/bitset"
+)
+
+// Iter calls fn with the address and the data of each object in the pool,
+// until fn returns false. The objects of each slab get visited in the
+// order of their slots. The pool stays locked during the iteration, so fn
+// must not add or delete objects of the pool, and the data is only valid
+// during the call
+func (s *slabPool) Iter(fn func(obj ObjAddr, data []byte) bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	s.iter(fn)
+}
+
+// iter implements Iter
+// It returns false if fn has stopped the iteration
+// The caller must hold the pool lock
+func (s *slabPool) iter(fn func(obj ObjAddr, data []byte) bool) bool {
+	for _, sl := range s.slabs {
+		for idx, ok := sl.nextObj(0); ok; idx, ok = sl.nextObj(idx + 1) {
+			if !fn(sl.getObjAddrByIdx(idx), s.objByIdx(sl, idx)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Iter calls fn with the address and the data of each object in the store,
+// until fn returns false, for full scans, exports or statistics. The pools
+// get visited in the order of their object sizes. Each pool stays locked
+// while its objects get visited, so fn must not add or delete objects, and
+// the data is only valid during the call
+func (o *ObjectStore) Iter(fn func(obj ObjAddr, data []byte) bool) {
+	pools := o.sortedPools()
+
+	for _, pool := range pools {
+		pool.lock.RLock()
+		more := pool.iter(fn)
+		pool.lock.RUnlock()
+		if !more {
+			return
+		}
+	}
+}
+
+// sortedPools returns the slab pools of the store sorted by object size
+func (o *ObjectStore) sortedPools() []*slabPool {
+	o.poolsLock.RLock()
+	pools := make([]*slabPool, 0, len(o.slabPools))
+	for _, pool := range o.slabPools {
+		pools = append(pools, pool)
+	}
+	o.poolsLock.RUnlock()
+
+	sort.Slice(pools, func(i, j int) bool { return pools[i].objSize < pools[j].objSize })
+	return pools
+}
+
+// slabSnapshot is the state of a slab which has been captured by
+// IterSnapshot
+type slabSnapshot struct {
+	slab  *slab
+	state *slabState
+	used  *bitset.BitSet
+	gens  []uint32
+}
+
+// snapshot captures the used slots of each slab of the pool and their
+// generations, and stops the pool from getting compacted. The caller must
+// decrement snapshotIters once it is done with the snapshots
+// The caller must hold the pool lock
+func (s *slabPool) snapshot() []slabSnapshot {
+	s.snapshotIters.Add(1)
+
+	snapshots := make([]slabSnapshot, 0, len(s.slabs))
+	for _, sl := range s.slabs {
+		st := s.states[sl]
+		snapshots = append(snapshots, slabSnapshot{
+			slab:  sl,
+			state: st,
+			used:  sl.bitSet().Clone(),
+			gens:  append([]uint32(nil), st.gens...),
+		})
+	}
+	return snapshots
+}
+
+// IterSnapshot calls fn with the address and the data of each object which
+// has been in the store when IterSnapshot got called, until fn returns
+// false. Unlike Iter it doesn't keep the pools locked while fn runs, so it
+// suits long scans while the store gets modified concurrently. The used
+// slots of all slabs get captured at once, objects which get added
+// afterwards are not visited, and objects which get deleted before they
+// are visited are skipped, even if their slots get reused meanwhile. The
+// pools don't get compacted until the iteration has ended, so no object
+// gets missed because it has been moved. The data is a copy which is only
+// valid during the call, and fn may add and delete objects
+func (o *ObjectStore) IterSnapshot(fn func(obj ObjAddr, data []byte) bool) {
+	pools := o.sortedPools()
+
+	// all pools get locked at once, so the captured states are consistent
+	// across the pools
+	for _, pool := range pools {
+		pool.lock.RLock()
+	}
+	snapshots := make([][]slabSnapshot, len(pools))
+	for i, pool := range pools {
+		snapshots[i] = pool.snapshot()
+	}
+	for _, pool := range pools {
+		pool.lock.RUnlock()
+		defer pool.snapshotIters.Add(-1)
+	}
+
+	var objs []ObjAddr
+	var data []byte
+	var ends []int
+	for i, pool := range pools {
+		for _, snap := range snapshots[i] {
+			objs, data, ends = pool.copySnapshot(snap, objs[:0], data[:0], ends[:0])
+
+			start := 0
+			for j, obj := range objs {
+				if !fn(obj, data[start:ends[j]]) {
+					return
+				}
+				start = ends[j]
+			}
+		}
+	}
+}
+
+// copySnapshot appends the addresses and the data of the objects of the
+// given slab snapshot which are still in the slab to the given slices, ends
+// receives the end of the data of each object
+// It returns the extended slices
+func (s *slabPool) copySnapshot(snap slabSnapshot, objs []ObjAddr, data []byte, ends []int) ([]ObjAddr, []byte, []int) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// the slab has been deleted, its address may have been reused
+	if s.states[snap.slab] != snap.state {
+		return objs, data, ends
+	}
+
+	used := snap.slab.bitSet()
+	for idx, ok := snap.used.NextSet(0); ok && idx < uint(len(snap.gens)); idx, ok = snap.used.NextSet(idx + 1) {
+		// the generation changes if the object has been deleted, even if
+		// another object has been added to the slot since
+		if !used.Test(idx) || snap.state.gens[idx] != snap.gens[idx] {
+			continue
+		}
+		objs = append(objs, snap.slab.getObjAddrByIdx(idx))
+		data = append(data, s.objByIdx(snap.slab, idx)...)
+		ends = append(ends, len(data))
+	}
+
+	return objs, data, ends
+}
+
+// Scan returns the addresses of up to limit objects of the store, starting
+// at the given cursor, and the cursor to pass to the next call, so huge
+// stores can be visited in bounded chunks, for example by background
+// verification jobs. A scan starts with cursor 0 and is complete once the
+// returned cursor is 0. The objects get returned in the order of their
+// addresses and no locks are held between the calls, so each object which
+// stays in the store during the whole scan gets returned exactly once,
+// unless a compaction moves it. Objects which get added or deleted during
+// the scan may or may not get returned
+func (o *ObjectStore) Scan(cursor ObjAddr, limit int) ([]ObjAddr, ObjAddr) {
+	if limit <= 0 {
+		return nil, cursor
+	}
+
+	var objs []ObjAddr
+	for from := cursor; ; {
+		sAddr, end, objSize, ok := o.scanSlab(from)
+		if !ok {
+			return objs, 0
+		}
+
+		if pool, ok := o.getSlabPool(objSize); ok {
+			objs = pool.scan(sAddr, from, objs, limit)
+			if len(objs) == limit {
+				return objs, objs[len(objs)-1] + 1
+			}
+		}
+		from = end
+	}
+}
+
+// scanSlab looks up the slab which contains the given address, or else the
+// first slab after it
+// On success it returns the address of the slab, the address where it
+// ends, its object size and true
+// On failure, if there is no such slab, the fourth returned value is false
+func (o *ObjectStore) scanSlab(addr ObjAddr) (SlabAddr, uintptr, uint8, bool) {
+	o.lookupLock.RLock()
+	defer o.lookupLock.RUnlock()
+
+	// the lookup table is sorted in descending order, idx is the last slab
+	// which starts after the address
+	idx := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] <= addr }) - 1
+	if idx+1 < len(o.lookupTable) {
+		sAddr := o.lookupTable[idx+1]
+		sl := slabFromSlabAddr(sAddr)
+		if end := sAddr + sl.getTotalLength(); addr < end {
+			return sAddr, end, sl.objSize, true
+		}
+	}
+	if idx < 0 {
+		return 0, 0, 0, false
+	}
+
+	sAddr := o.lookupTable[idx]
+	sl := slabFromSlabAddr(sAddr)
+	return sAddr, sAddr + sl.getTotalLength(), sl.objSize, true
+}
+
+// scan appends the addresses of the objects of the slab at the given
+// address which start at or after the given address to objs, until objs
+// has reached the given limit
+// It returns the extended slice
+func (s *slabPool) scan(slabAddr SlabAddr, from ObjAddr, objs []ObjAddr, limit int) []ObjAddr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// the slab may have been deleted since it has been looked up
+	sl := slabFromSlabAddr(slabAddr)
+	if _, ok := s.states[sl]; !ok {
+		return objs
+	}
+
+	var start uint
+	if from > sl.getObjAddrByIdx(0) {
+		start = sl.getObjIdx(from)
+		if sl.getObjAddrByIdx(start) < from {
+			start++
+		}
+		if start >= sl.objsPerSlab() {
+			return objs
+		}
+	}
+	for idx, ok := sl.nextObj(start); ok && idx < sl.objsPerSlab() && len(objs) < limit; idx, ok = sl.nextObj(idx + 1) {
+		objs = append(objs, sl.getObjAddrByIdx(idx))
+	}
+
+	return objs
+}
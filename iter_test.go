@@ -0,0 +1,194 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIter(t *testing.T) {
+	Convey("When iterating over the objects of a store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		objs := make(map[ObjAddr]string)
+		for i := 0; i < 30; i++ {
+			value := fmt.Sprintf("%0*d", 1+i%3, i)
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs[obj] = value
+		}
+		for obj, value := range objs {
+			if len(value) == 2 {
+				So(os.Delete(obj), ShouldBeNil)
+				delete(objs, obj)
+			}
+		}
+
+		Convey("each live object should be visited once, smaller sizes first", func() {
+			seen := make(map[ObjAddr]string)
+			lastSize := 0
+			os.Iter(func(obj ObjAddr, data []byte) bool {
+				_, ok := seen[obj]
+				So(ok, ShouldBeFalse)
+				So(len(data), ShouldBeGreaterThanOrEqualTo, lastSize)
+				lastSize = len(data)
+				seen[obj] = string(data)
+				return true
+			})
+			So(seen, ShouldResemble, objs)
+		})
+
+		Convey("the iteration should stop when fn returns false", func() {
+			visited := 0
+			os.Iter(func(ObjAddr, []byte) bool {
+				visited++
+				return visited < 5
+			})
+			So(visited, ShouldEqual, 5)
+		})
+	})
+
+	Convey("When iterating over the objects of a variable-length pool", t, func() {
+		sp := NewVarLenSlabPool(10, 4)
+		objs := make(map[ObjAddr]string)
+		for i := 0; i < 10; i++ {
+			value := fmt.Sprintf("%0*d", 1+i%5, i)
+			obj, _, err := sp.add([]byte(value))
+			So(err, ShouldBeNil)
+			objs[obj] = value
+		}
+
+		seen := make(map[ObjAddr]string)
+		sp.Iter(func(obj ObjAddr, data []byte) bool {
+			seen[obj] = string(data)
+			return true
+		})
+		So(seen, ShouldResemble, objs)
+	})
+}
+
+func TestIterSnapshot(t *testing.T) {
+	Convey("When iterating over a snapshot of a store which gets modified", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 20; i++ {
+			obj, err := os.Add([]byte{byte(i), 'a'})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		seen := make(map[ObjAddr]byte)
+		var added []ObjAddr
+		os.IterSnapshot(func(obj ObjAddr, data []byte) bool {
+			seen[obj] = data[0]
+			if len(seen) == 1 {
+				// delete the objects of the later slabs and reuse most of
+				// their slots, then ask for a compaction
+				for _, obj := range objs[10:] {
+					So(os.Delete(obj), ShouldBeNil)
+				}
+				for i := 0; i < 8; i++ {
+					obj, err := os.Add([]byte{byte(100 + i), 'b'})
+					So(err, ShouldBeNil)
+					added = append(added, obj)
+				}
+				moved, err := os.Compact()
+				So(err, ShouldBeNil)
+				So(len(moved), ShouldEqual, 0)
+			}
+			return true
+		})
+
+		Convey("only the objects which have been live throughout should be visited", func() {
+			So(len(seen), ShouldEqual, 10)
+			for i, obj := range objs[:10] {
+				So(seen[obj], ShouldEqual, byte(i))
+			}
+			for _, obj := range added {
+				_, ok := seen[obj]
+				So(ok, ShouldBeFalse)
+			}
+		})
+
+		Convey("the pools should be compacted again afterwards", func() {
+			for _, obj := range added {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(os.Delete(objs[0]), ShouldBeNil)
+			So(os.Delete(objs[1]), ShouldBeNil)
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldBeGreaterThan, 0)
+		})
+	})
+}
+
+func TestScan(t *testing.T) {
+	Convey("When scanning a store in chunks", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(8))
+		live := make(map[ObjAddr]bool)
+		for i := 0; i < 50; i++ {
+			obj, err := os.Add([]byte{byte(i), byte(i % 3)}[:1+i%2])
+			So(err, ShouldBeNil)
+			live[obj] = true
+		}
+		// leave some gaps in the slabs
+		for obj := range live {
+			if len(live) <= 40 {
+				break
+			}
+			So(os.Delete(obj), ShouldBeNil)
+			delete(live, obj)
+		}
+
+		Convey("each object should be returned once in ascending order", func() {
+			seen := make(map[ObjAddr]bool)
+			var last ObjAddr
+			calls := 0
+			for cursor := ObjAddr(0); ; {
+				var objs []ObjAddr
+				objs, cursor = os.Scan(cursor, 7)
+				calls++
+				So(len(objs), ShouldBeLessThanOrEqualTo, 7)
+				for _, obj := range objs {
+					So(obj, ShouldBeGreaterThan, last)
+					last = obj
+					seen[obj] = true
+				}
+				if cursor == 0 {
+					break
+				}
+			}
+			So(seen, ShouldResemble, live)
+			So(calls, ShouldBeBetweenOrEqual, 6, 7)
+		})
+
+		Convey("objects deleted during the scan should not hide the others", func() {
+			objs, cursor := os.Scan(0, 5)
+			So(len(objs), ShouldEqual, 5)
+			deleted := objs[4]
+			So(os.Delete(deleted), ShouldBeNil)
+			delete(live, deleted)
+
+			seen := make(map[ObjAddr]bool)
+			for _, obj := range objs {
+				seen[obj] = true
+			}
+			for cursor != 0 {
+				objs, cursor = os.Scan(cursor, 100)
+				for _, obj := range objs {
+					So(seen[obj], ShouldBeFalse)
+					seen[obj] = true
+				}
+			}
+			delete(seen, deleted)
+			So(seen, ShouldResemble, live)
+		})
+
+		Convey("an invalid limit should return nothing", func() {
+			objs, cursor := os.Scan(0, 0)
+			So(objs, ShouldBeEmpty)
+			So(cursor, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package gos
+
+import (
+	"sync"
+)
+
+// KV maps string keys to values which are objects of an ObjectStore, the
+// keys are indexed by a Go map while the values live in slabs. Unlike Map
+// the values have object addresses, so the store of a KV can be compacted,
+// snapshotted or inspected like any other store, the index follows the
+// values which get moved by compactions. Values must be between 1 and 255
+// bytes long. A KV is safe for concurrent use
+type KV struct {
+	store *ObjectStore
+
+	// lock protects the index, Put and Delete hold it for writing so a
+	// value can't get deleted while it gets read
+	lock sync.RWMutex
+
+	// addrs maps each key to the address of its value, keys maps the
+	// addresses back to the keys
+	addrs map[string]ObjAddr
+	keys  map[ObjAddr]string
+
+	removeListener func()
+}
+
+// NewKV creates an empty KV whose values get stored in an ObjectStore
+// created with the given options
+func NewKV(opts ...Option) *KV {
+	kv := &KV{
+		store: NewObjectStore(opts...),
+		addrs: make(map[string]ObjAddr),
+		keys:  make(map[ObjAddr]string),
+	}
+	kv.removeListener = kv.store.AddRelocationListener(kv.relocate)
+
+	return kv
+}
+
+// Store returns the ObjectStore of the values, it can be used to compact
+// or inspect the store. Values must only be added and deleted through the
+// KV
+func (kv *KV) Store() *ObjectStore {
+	return kv.store
+}
+
+// Put sets the value of the given key, the value gets copied into the store
+// and the previous value of the key gets deleted
+// On success it returns nil, otherwise it returns an error
+func (kv *KV) Put(key string, value []byte) error {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	obj, err := kv.store.Add(value)
+	if err != nil {
+		return err
+	}
+
+	if old, ok := kv.addrs[key]; ok {
+		delete(kv.keys, old)
+		if err := kv.store.Delete(old); err != nil {
+			kv.keys[old] = key
+			kv.store.Delete(obj)
+			return err
+		}
+	}
+	kv.addrs[key] = obj
+	kv.keys[obj] = key
+
+	return nil
+}
+
+// Get returns the value of the given key, the returned slice refers to the
+// slab memory and is only valid until the key gets updated or deleted, or
+// the value gets moved by a compaction
+// When found it returns the value and true, otherwise the second returned
+// value is false
+func (kv *KV) Get(key string) ([]byte, bool) {
+	kv.lock.RLock()
+	defer kv.lock.RUnlock()
+
+	obj, ok := kv.addrs[key]
+	if !ok {
+		return nil, false
+	}
+
+	// the address gets checked, because a concurrent compaction may have
+	// moved the value before the relocation listener has updated it
+	value, err := kv.store.GetChecked(obj)
+	return value, err == nil
+}
+
+// Addr returns the object address of the value of the given key
+// When found it returns the address and true, otherwise the second
+// returned value is false
+func (kv *KV) Addr(key string) (ObjAddr, bool) {
+	kv.lock.RLock()
+	defer kv.lock.RUnlock()
+
+	obj, ok := kv.addrs[key]
+	return obj, ok
+}
+
+// Delete deletes the given key and its value
+// It returns true if the key has been deleted, or false if it wasn't set
+func (kv *KV) Delete(key string) bool {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	obj, ok := kv.addrs[key]
+	if !ok {
+		return false
+	}
+	if err := kv.store.Delete(obj); err != nil {
+		return false
+	}
+	delete(kv.addrs, key)
+	delete(kv.keys, obj)
+
+	return true
+}
+
+// Len returns the number of keys
+func (kv *KV) Len() int {
+	kv.lock.RLock()
+	defer kv.lock.RUnlock()
+
+	return len(kv.addrs)
+}
+
+// Range calls fn with each key and its value in no particular order, until
+// fn returns false. The value is only valid during the call and fn must
+// not modify the KV
+func (kv *KV) Range(fn func(key string, value []byte) bool) {
+	kv.lock.RLock()
+	defer kv.lock.RUnlock()
+
+	for key, obj := range kv.addrs {
+		value, err := kv.store.GetChecked(obj)
+		if err != nil {
+			continue
+		}
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// relocate updates the address of a value which has been moved by a
+// compaction, it is registered as a relocation listener of the store
+func (kv *KV) relocate(oldAddr, newAddr ObjAddr) {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	key, ok := kv.keys[oldAddr]
+	if !ok {
+		return
+	}
+	delete(kv.keys, oldAddr)
+	kv.keys[newAddr] = key
+	kv.addrs[key] = newAddr
+}
+
+// Close deletes all keys and values and stops following the values which
+// get moved by compactions, the KV must not be used anymore afterwards
+// On success it returns nil, otherwise it returns an error
+func (kv *KV) Close() error {
+	kv.removeListener()
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	for key, obj := range kv.addrs {
+		if err := kv.store.Delete(obj); err != nil {
+			return err
+		}
+		delete(kv.addrs, key)
+		delete(kv.keys, obj)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKV(t *testing.T) {
+	Convey("When putting many keys into a KV", t, func() {
+		kv := NewKV(WithObjsPerSlab(16))
+		for i := 0; i < 500; i++ {
+			So(kv.Put(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i))), ShouldBeNil)
+		}
+
+		Convey("all keys should be found", func() {
+			So(kv.Len(), ShouldEqual, 500)
+			for i := 0; i < 500; i++ {
+				value, ok := kv.Get(fmt.Sprintf("key%d", i))
+				So(ok, ShouldBeTrue)
+				So(string(value), ShouldEqual, fmt.Sprintf("value%d", i))
+			}
+			_, ok := kv.Get("key500")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("putting an existing key should replace and delete its value", func() {
+			old, ok := kv.Addr("key5")
+			So(ok, ShouldBeTrue)
+			So(kv.Put("key5", []byte("replaced")), ShouldBeNil)
+			value, ok := kv.Get("key5")
+			So(ok, ShouldBeTrue)
+			So(string(value), ShouldEqual, "replaced")
+			So(kv.Len(), ShouldEqual, 500)
+			So(kv.Store().Contains(old), ShouldBeFalse)
+		})
+
+		Convey("invalid values should be rejected", func() {
+			So(kv.Put("key5", nil), ShouldNotBeNil)
+			So(kv.Put("key5", make([]byte, 256)), ShouldNotBeNil)
+			value, ok := kv.Get("key5")
+			So(ok, ShouldBeTrue)
+			So(string(value), ShouldEqual, "value5")
+		})
+
+		Convey("deleted keys should not be found anymore", func() {
+			for i := 0; i < 500; i += 2 {
+				So(kv.Delete(fmt.Sprintf("key%d", i)), ShouldBeTrue)
+			}
+			So(kv.Delete("key0"), ShouldBeFalse)
+			So(kv.Len(), ShouldEqual, 250)
+
+			seen := make(map[string]string)
+			kv.Range(func(key string, value []byte) bool {
+				seen[key] = string(value)
+				return true
+			})
+			So(len(seen), ShouldEqual, 250)
+			So(seen["key1"], ShouldEqual, "value1")
+
+			Convey("values moved by a compaction should still be found", func() {
+				moved, err := kv.Store().Compact()
+				So(err, ShouldBeNil)
+				So(len(moved), ShouldBeGreaterThan, 0)
+				for i := 1; i < 500; i += 2 {
+					value, ok := kv.Get(fmt.Sprintf("key%d", i))
+					So(ok, ShouldBeTrue)
+					So(string(value), ShouldEqual, fmt.Sprintf("value%d", i))
+				}
+			})
+		})
+
+		Convey("closing the KV should delete all values", func() {
+			So(kv.Close(), ShouldBeNil)
+			So(kv.Len(), ShouldEqual, 0)
+			So(kv.Store().MemStats().LiveObjects, ShouldEqual, 0)
+		})
+	})
+}
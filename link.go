@@ -0,0 +1,201 @@
+package gos
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// LinkSize is the number of bytes which a link takes in an object
+const LinkSize = 8
+
+// ReadLink returns the object address which is stored at the given offset
+// of the given object data, for example of a slice returned by AddReserve
+func ReadLink(obj []byte, offset int) ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(obj[offset:]))
+}
+
+// PutLink stores the given object address at the given offset of the given
+// object data, for example of a slice returned by AddReserve. The zero
+// address marks the end of a chain
+func PutLink(obj []byte, offset int, target ObjAddr) {
+	binary.LittleEndian.PutUint64(obj[offset:], uint64(target))
+}
+
+// checkLinkOffset returns an error which wraps ErrInvalidSize if an object
+// of the given size has no room for a link at the given offset
+func checkLinkOffset(obj ObjAddr, size int, offset int) error {
+	if offset < 0 || offset+LinkSize > size {
+		return fmt.Errorf("ObjectStore: object %d of size %d has no room for a link at offset %d: %w", obj, size, offset, ErrInvalidSize)
+	}
+	return nil
+}
+
+// Link returns the object address which is stored at the given offset of
+// the object at the given address, see SetLink
+// On success it returns the stored address and nil
+// On failure the second returned value is an *AddrError, or it wraps
+// ErrInvalidSize if the object has no room for a link at the offset
+func (o *ObjectStore) Link(obj ObjAddr, offset int) (ObjAddr, error) {
+	var target ObjAddr
+	err := o.withCheckedObj(obj, func(pool *slabPool, _ SlabAddr) error {
+		data := pool.get(obj)
+		if err := checkLinkOffset(obj, len(data), offset); err != nil {
+			return err
+		}
+
+		target = ReadLink(data, offset)
+		return nil
+	})
+
+	return target, err
+}
+
+// SetLink stores the target address at the given offset of the object at
+// the given address, so objects can refer to each other to build linked
+// lists, trees and graphs off the Go heap. The target must be 0 or refer to
+// an object of the store. Links don't get updated when a compaction moves
+// their targets, see UpdateLinks. Objects of pools with a bloom filter or
+// a hash index and objects of sealed slabs can't be changed
+// On success it returns nil, otherwise it returns an error, which is an
+// *AddrError if the object or the target address is invalid
+func (o *ObjectStore) SetLink(obj ObjAddr, offset int, target ObjAddr) error {
+	if target != 0 {
+		if err := o.withCheckedObj(target, func(*slabPool, SlabAddr) error { return nil }); err != nil {
+			return err
+		}
+	}
+
+	pool, sAddr, err := o.checkedPool(obj)
+	if err != nil {
+		return err
+	}
+
+	return pool.setLink(obj, sAddr, offset, target)
+}
+
+// setLink implements SetLink for the object at the given address in the
+// given slab
+// On success it returns nil, otherwise it returns an error
+func (s *slabPool) setLink(obj ObjAddr, slabAddr SlabAddr, offset int, target ObjAddr) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.checkObjAddr(obj, slabAddr); err != nil {
+		return err
+	}
+
+	data := s.get(obj)
+	if err := checkLinkOffset(obj, len(data), offset); err != nil {
+		return err
+	}
+	if s.bloomFilters != nil || s.hashIndex != nil {
+		return fmt.Errorf("SetLink: objects of pools with bloom filters or a hash index can't be changed")
+	}
+
+	st := s.states[slabFromSlabAddr(slabAddr)]
+	if st.sealed {
+		return fmt.Errorf("SetLink: slab %d is sealed: %w", slabAddr, ErrSealed)
+	}
+
+	if s.wal != nil {
+		// the changed object gets logged like an add of the slot
+		changed := make([]byte, len(data))
+		copy(changed, data)
+		PutLink(changed, offset, target)
+
+		record := s.wal.newRecord()
+		s.wal.add(record, st.slab, st.slab.getObjIdx(obj), changed, s.varLen)
+		if err := s.wal.commit(record); err != nil {
+			return err
+		}
+		defer s.wal.applied()
+	}
+
+	PutLink(data, offset, target)
+	st.dirty.Store(true)
+
+	return nil
+}
+
+// FollowLinks calls fn with the start address and then with each address
+// which is linked at the given offset of the previous object, until it
+// reaches a zero link or fn returns false. If the links form a cycle, fn
+// may get called with some objects of the cycle more than once before the
+// cycle gets detected
+// On success it returns nil
+// On failure it returns an error which wraps ErrCycle if the chain leads
+// back to an object which has been visited before, or the error of Link
+// if an object of the chain is invalid
+func (o *ObjectStore) FollowLinks(start ObjAddr, offset int, fn func(obj ObjAddr) bool) error {
+	// Brent's algorithm detects cycles without remembering the visited
+	// objects: the tortoise teleports to the hare whenever the hare has
+	// taken a power of two steps since the last teleport
+	tortoise, power, steps := start, 1, 0
+	for obj := start; obj != 0; {
+		if !fn(obj) {
+			return nil
+		}
+
+		next, err := o.Link(obj, offset)
+		if err != nil {
+			return err
+		}
+		if next == tortoise {
+			return fmt.Errorf("ObjectStore: FollowLinks found that object %d links back to object %d: %w", obj, next, ErrCycle)
+		}
+
+		steps++
+		if steps == power {
+			tortoise, power, steps = next, power*2, 0
+		}
+		obj = next
+	}
+
+	return nil
+}
+
+// UpdateLinks rewrites the links at the given offset of all objects whose
+// targets have been moved, moved maps the old to the new address of each
+// moved object like the result of Compact does. Objects which are too
+// small to hold a link at the offset are skipped. It must not be called
+// concurrently with adds or deletes
+// On success it returns the number of updated links and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) UpdateLinks(moved map[ObjAddr]ObjAddr, offset int) (int, error) {
+	if len(moved) == 0 {
+		return 0, nil
+	}
+
+	updated := 0
+	for _, info := range o.Slabs() {
+		if int(info.ObjSize) < offset+LinkSize {
+			continue
+		}
+
+		objs, err := o.SlabObjects(info.Addr)
+		if err != nil {
+			return updated, err
+		}
+		for _, obj := range objs {
+			target, err := o.Link(obj, offset)
+			if err != nil {
+				// objects of variable-length pools may be shorter
+				if errors.Is(err, ErrInvalidSize) {
+					continue
+				}
+				return updated, err
+			}
+			newTarget, ok := moved[target]
+			if !ok {
+				continue
+			}
+			if err := o.SetLink(obj, offset, newTarget); err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,104 @@
+package gos
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLinks(t *testing.T) {
+	Convey("When building a linked list of objects", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+
+		// each node consists of a value byte followed by the link to the
+		// next node
+		var nodes []ObjAddr
+		var next ObjAddr
+		for i := 9; i >= 0; i-- {
+			obj, data, err := os.AddReserve(1 + LinkSize)
+			So(err, ShouldBeNil)
+			data[0] = byte(i)
+			PutLink(data, 1, next)
+			next = obj
+			nodes = append([]ObjAddr{obj}, nodes...)
+		}
+		head := nodes[0]
+
+		Convey("FollowLinks should visit the nodes in order", func() {
+			var values []byte
+			err := os.FollowLinks(head, 1, func(obj ObjAddr) bool {
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				values = append(values, data[0])
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+			link, err := os.Link(nodes[3], 1)
+			So(err, ShouldBeNil)
+			So(link, ShouldEqual, nodes[4])
+		})
+
+		Convey("FollowLinks should detect cycles", func() {
+			So(os.SetLink(nodes[9], 1, nodes[4]), ShouldBeNil)
+			visited := 0
+			err := os.FollowLinks(head, 1, func(obj ObjAddr) bool {
+				visited++
+				return true
+			})
+			So(errors.Is(err, ErrCycle), ShouldBeTrue)
+			So(visited, ShouldBeGreaterThanOrEqualTo, 10)
+
+			So(os.SetLink(nodes[0], 1, nodes[0]), ShouldBeNil)
+			So(errors.Is(os.FollowLinks(head, 1, func(ObjAddr) bool { return true }), ErrCycle), ShouldBeTrue)
+		})
+
+		Convey("invalid offsets and targets should be rejected", func() {
+			_, err := os.Link(head, 2)
+			So(errors.Is(err, ErrInvalidSize), ShouldBeTrue)
+			So(errors.Is(os.SetLink(head, -1, 0), ErrInvalidSize), ShouldBeTrue)
+
+			So(os.SetLink(head, 1, head+1), ShouldHaveSameTypeAs, &AddrError{})
+			link, err := os.Link(head, 1)
+			So(err, ShouldBeNil)
+			So(link, ShouldEqual, nodes[1])
+		})
+
+		Convey("UpdateLinks should follow the nodes moved by a compaction", func() {
+			// unlink and delete most nodes, so the slabs become sparse
+			kept := []int{0, 4, 8, 9}
+			for i, k := range kept[:len(kept)-1] {
+				So(os.SetLink(nodes[k], 1, nodes[kept[i+1]]), ShouldBeNil)
+			}
+			for _, i := range []int{1, 2, 3, 5, 6, 7} {
+				So(os.Delete(nodes[i]), ShouldBeNil)
+			}
+
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldBeGreaterThan, 0)
+			updated, err := os.UpdateLinks(moved, 1)
+			So(err, ShouldBeNil)
+
+			// each moved node except the head is the target of one link
+			if newHead, ok := moved[head]; ok {
+				So(updated, ShouldEqual, len(moved)-1)
+				head = newHead
+			} else {
+				So(updated, ShouldEqual, len(moved))
+			}
+			So(updated, ShouldBeGreaterThan, 0)
+			var values []byte
+			err = os.FollowLinks(head, 1, func(obj ObjAddr) bool {
+				data, err := os.GetChecked(obj)
+				So(err, ShouldBeNil)
+				values = append(values, data[0])
+				return true
+			})
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []byte{0, 4, 8, 9})
+		})
+	})
+}
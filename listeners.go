@@ -0,0 +1,137 @@
+package gos
+
+// SlabEventKind identifies what happened to a slab
+type SlabEventKind int
+
+const (
+	// SlabCreated means that a slab has been created
+	SlabCreated SlabEventKind = iota
+	// SlabDeleted means that a slab has been deleted and its memory released
+	SlabDeleted
+)
+
+// SlabEvent describes the creation or the deletion of a slab, it gets
+// passed to the listeners registered with AddSlabListener
+type SlabEvent struct {
+	Kind SlabEventKind
+	// Addr is the address of the slab
+	Addr SlabAddr
+	// TotalBytes is the size of the slab's memory
+	TotalBytes uint64
+	// ObjSize is the object size of the slab's pool
+	ObjSize uint8
+}
+
+// AddSlabListener registers a callback which gets called after each
+// creation and deletion of a slab, so applications can maintain their own
+// index of address ranges or account the memory against a budget. The
+// callback gets called synchronously by the goroutine that caused the
+// event, after the store has released its locks
+// It returns a function which removes the listener again
+func (o *ObjectStore) AddSlabListener(fn func(SlabEvent)) func() {
+	o.listenersLock.Lock()
+	defer o.listenersLock.Unlock()
+
+	if o.slabListeners == nil {
+		o.slabListeners = make(map[int]func(SlabEvent))
+	}
+	id := o.nextListenerID
+	o.nextListenerID++
+	o.slabListeners[id] = fn
+
+	return func() {
+		o.listenersLock.Lock()
+		defer o.listenersLock.Unlock()
+
+		delete(o.slabListeners, id)
+	}
+}
+
+// slabCreated notifies the metrics and the listeners about a created slab
+func (o *ObjectStore) slabCreated(size uint8, slabAddr SlabAddr) {
+	if o.metrics != nil {
+		o.metrics.OnSlabCreated(size, slabAddr)
+	}
+	totalBytes := uint64(slabFromSlabAddr(slabAddr).getTotalLength())
+	o.notifySlabListeners(SlabEvent{Kind: SlabCreated, Addr: slabAddr, TotalBytes: totalBytes, ObjSize: size})
+}
+
+// slabDeleted notifies the metrics and the listeners about a deleted slab,
+// totalBytes must have been read from the slab before it got unmapped
+func (o *ObjectStore) slabDeleted(size uint8, slabAddr SlabAddr, totalBytes uint64) {
+	if o.metrics != nil {
+		o.metrics.OnSlabDeleted(size, slabAddr)
+	}
+	o.notifySlabListeners(SlabEvent{Kind: SlabDeleted, Addr: slabAddr, TotalBytes: totalBytes, ObjSize: size})
+}
+
+// notifySlabListeners calls each registered listener with the given event,
+// the listeners get called without holding the lock, so they may add or
+// remove listeners themselves
+func (o *ObjectStore) notifySlabListeners(event SlabEvent) {
+	o.listenersLock.RLock()
+	if len(o.slabListeners) == 0 {
+		o.listenersLock.RUnlock()
+		return
+	}
+	listeners := make([]func(SlabEvent), 0, len(o.slabListeners))
+	for _, fn := range o.slabListeners {
+		listeners = append(listeners, fn)
+	}
+	o.listenersLock.RUnlock()
+
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+// AddRelocationListener registers a callback which gets called with the
+// old and the new address of each object that gets moved by a compaction,
+// see Compact and StartCompactor, so applications can keep the indexes
+// which are keyed by object addresses consistent. The callback gets called
+// synchronously by the goroutine that moved the objects, after the store
+// has released its locks
+// It returns a function which removes the listener again
+func (o *ObjectStore) AddRelocationListener(fn func(oldAddr, newAddr ObjAddr)) func() {
+	o.listenersLock.Lock()
+	defer o.listenersLock.Unlock()
+
+	if o.relocationListeners == nil {
+		o.relocationListeners = make(map[int]func(ObjAddr, ObjAddr))
+	}
+	id := o.nextListenerID
+	o.nextListenerID++
+	o.relocationListeners[id] = fn
+
+	return func() {
+		o.listenersLock.Lock()
+		defer o.listenersLock.Unlock()
+
+		delete(o.relocationListeners, id)
+	}
+}
+
+// objsRelocated calls each registered relocation listener with every
+// moved object, the listeners get called without holding the lock
+func (o *ObjectStore) objsRelocated(moved map[ObjAddr]ObjAddr) {
+	if len(moved) == 0 {
+		return
+	}
+
+	o.listenersLock.RLock()
+	if len(o.relocationListeners) == 0 {
+		o.listenersLock.RUnlock()
+		return
+	}
+	listeners := make([]func(ObjAddr, ObjAddr), 0, len(o.relocationListeners))
+	for _, fn := range o.relocationListeners {
+		listeners = append(listeners, fn)
+	}
+	o.listenersLock.RUnlock()
+
+	for oldAddr, newAddr := range moved {
+		for _, fn := range listeners {
+			fn(oldAddr, newAddr)
+		}
+	}
+}
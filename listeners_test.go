@@ -0,0 +1,86 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSlabListeners(t *testing.T) {
+	Convey("When a slab listener is registered", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		var events []SlabEvent
+		remove := os.AddSlabListener(func(event SlabEvent) {
+			events = append(events, event)
+		})
+
+		var objs []ObjAddr
+		for i := 0; i < 3; i++ {
+			obj, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("it should be notified about created slabs", func() {
+			So(len(events), ShouldEqual, 2)
+			So(events[0].Kind, ShouldEqual, SlabCreated)
+			So(events[0].ObjSize, ShouldEqual, 3)
+			So(events[0].TotalBytes, ShouldEqual, slabSize(3, 2))
+			So(os.lookupTable, ShouldContain, events[1].Addr)
+
+			Convey("and about deleted slabs", func() {
+				So(os.Delete(objs[2]), ShouldBeNil)
+				So(len(events), ShouldEqual, 3)
+				So(events[2].Kind, ShouldEqual, SlabDeleted)
+				So(events[2].Addr, ShouldEqual, events[1].Addr)
+			})
+
+			Convey("but not anymore after it has been removed", func() {
+				remove()
+				So(os.Delete(objs[2]), ShouldBeNil)
+				So(len(events), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestRelocationListeners(t *testing.T) {
+	Convey("When a relocation listener is registered", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		relocated := make(map[ObjAddr]ObjAddr)
+		remove := os.AddRelocationListener(func(oldAddr, newAddr ObjAddr) {
+			relocated[oldAddr] = newAddr
+		})
+
+		var objs []ObjAddr
+		for i := 0; i < 4; i++ {
+			obj, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(os.Delete(objs[0]), ShouldBeNil)
+		So(os.Delete(objs[3]), ShouldBeNil)
+
+		Convey("it should be notified about each object moved by a compaction", func() {
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 1)
+			So(relocated, ShouldResemble, moved)
+			for oldAddr, newAddr := range relocated {
+				data, err := os.Get(newAddr)
+				So(err, ShouldBeNil)
+				So(oldAddr == objs[1] || oldAddr == objs[2], ShouldBeTrue)
+				So(string(data), ShouldEqual, map[ObjAddr]string{objs[1]: "001", objs[2]: "002"}[oldAddr])
+			}
+		})
+
+		Convey("but not anymore after it has been removed", func() {
+			remove()
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 1)
+			So(len(relocated), ShouldEqual, 0)
+		})
+	})
+}
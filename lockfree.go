@@ -0,0 +1,83 @@
+package gos
+
+import "context"
+
+// WithLockFreeReads makes Get and Search run without taking any lock in
+// the common case, so they scale with the number of readers and don't
+// have to wait for concurrent writes. The reads use the epoch based
+// reclamation of the quarantine, see WithQuarantine, so the memory of a
+// slab which gets deleted concurrently stays mapped until no read can
+// still observe it. Deleted slabs get removed from the lookup table before
+// their memory gets retired. Get only takes the lookup lock for addresses
+// on pages which are shared by several slabs. Search doesn't skip empty
+// slabs or use bloom filters, and it may miss objects which get added or
+// deleted concurrently. Every new and deleted slab copies the slab list
+// of its pool
+func WithLockFreeReads() Option {
+	return func(o *ObjectStore) {
+		o.lockFreeReads = true
+		if o.quarantine == nil {
+			o.quarantine = newQuarantine()
+		}
+	}
+}
+
+// getLockFree implements Get for stores with lock-free reads. The slab of
+// the object gets looked up and its header gets read within an epoch of
+// the quarantine, so the slab can't get unmapped meanwhile
+func (o *ObjectStore) getLockFree(obj ObjAddr) ([]byte, error) {
+	epoch := o.quarantine.enter()
+	defer o.quarantine.exit(epoch)
+
+	sAddr, ok := o.lockFreeSlabAddress(obj)
+	if !ok {
+		var err error
+		if sAddr, err = o.getSlabAddress(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	return objFromObjAddr(obj, slabFromSlabAddr(sAddr).objSize), nil
+}
+
+// lockFreeSlabAddress looks up the slab which contains the given object
+// address in the slab cache and the slab index without taking the lookup
+// lock
+// The second returned value is false if the slab has not been found, the
+// caller must then use getSlabAddress
+func (o *ObjectStore) lockFreeSlabAddress(obj ObjAddr) (SlabAddr, bool) {
+	if cached, ok := o.lastSlab.Load().(slabRange); ok && obj >= cached.start && obj < cached.end {
+		return cached.start, true
+	}
+	return o.slabIndex.lookup(obj)
+}
+
+// searchLockFree implements Search for stores with lock-free reads, it
+// searches the published slab list of the pool within an epoch of the
+// quarantine
+func (o *ObjectStore) searchLockFree(ctx context.Context, searching []byte) (ObjAddr, bool, error) {
+	epoch := o.quarantine.enter()
+	defer o.quarantine.exit(epoch)
+
+	pool := o.lockFreePools[uint8(len(searching))].Load()
+	if pool == nil {
+		return 0, false, ctx.Err()
+	}
+	slabs := pool.lockFreeSlabs.Load()
+	if slabs == nil {
+		return 0, false, ctx.Err()
+	}
+
+	return pool.searchSlabs(ctx, *slabs, searching, false)
+}
+
+// publishSlabs publishes a copy of the slab list for the lock-free
+// searches if the pool has lock-free reads
+// The caller must hold the pool lock for writing
+func (s *slabPool) publishSlabs() {
+	if !s.lockFreeReads {
+		return
+	}
+	slabs := append([]*slab(nil), s.slabs...)
+	s.lockFreeSlabs.Store(&slabs)
+}
@@ -0,0 +1,94 @@
+package gos
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLockFreeReads(t *testing.T) {
+	Convey("When reading from a store with lock-free reads", t, func() {
+		backend := &countingBackend{}
+		os := NewObjectStore(WithObjsPerSlab(2), WithBackend(backend), WithLockFreeReads())
+		var objs []ObjAddr
+		for i := 0; i < 16; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("Get and Search should find the objects", func() {
+			for i, obj := range objs {
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, []byte{byte(i), 1, 2})
+				found, ok := os.Search([]byte{byte(i), 1, 2})
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, obj)
+			}
+			_, ok := os.Search([]byte{100, 1, 2})
+			So(ok, ShouldBeFalse)
+			_, ok = os.Search([]byte{1, 2})
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("a deleted slab should be unlinked before its memory gets retired", func() {
+			guard := os.BeginRead()
+			So(os.Delete(objs[0]), ShouldBeNil)
+			So(os.Delete(objs[1]), ShouldBeNil)
+			_, ok := os.slabIndex.lookup(objs[0])
+			So(ok, ShouldBeFalse)
+			_, ok = os.Search([]byte{1, 1, 2})
+			So(ok, ShouldBeFalse)
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 8)
+			So(os.quarantine.pending(), ShouldEqual, 1)
+
+			guard.End()
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 7)
+			So(os.quarantine.pending(), ShouldEqual, 0)
+			So(os.CheckIntegrity(), ShouldBeNil)
+		})
+	})
+
+	Convey("When slabs get added and deleted while other goroutines read", t, func() {
+		backend := &countingBackend{}
+		os := NewObjectStore(WithObjsPerSlab(1), WithBackend(backend), WithLockFreeReads())
+		stable, err := os.Add([]byte("stable"))
+		So(err, ShouldBeNil)
+
+		var stop atomic.Bool
+		var failures atomic.Int64
+		wg := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for !stop.Load() {
+					if data, err := os.Get(stable); err != nil || string(data) != "stable" {
+						failures.Add(1)
+					}
+					if found, ok := os.Search([]byte("stable")); !ok || found != stable {
+						failures.Add(1)
+					}
+					os.Search([]byte("churn!"))
+				}
+			}()
+		}
+
+		for i := 0; i < 1000; i++ {
+			obj, err := os.Add([]byte("churn!"))
+			So(err, ShouldBeNil)
+			So(os.Delete(obj), ShouldBeNil)
+		}
+		stop.Store(true)
+		wg.Wait()
+
+		Convey("the reads should not fail and all deleted slabs should get unmapped", func() {
+			So(failures.Load(), ShouldEqual, 0)
+			So(os.quarantine.pending(), ShouldEqual, 0)
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 1)
+		})
+	})
+}
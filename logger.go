@@ -0,0 +1,17 @@
+//go:build go1.21
+// +build go1.21
+
+package gos
+
+import (
+	"log/slog"
+)
+
+// WithLogger sets a logger which receives debug events about the creation
+// and deletion of slabs, and warnings about failures to map or unmap slab
+// memory, slabs rejected by the memory limit and failed integrity checks
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *ObjectStore) {
+		o.logger = logger
+	}
+}
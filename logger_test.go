@@ -0,0 +1,40 @@
+//go:build go1.21
+// +build go1.21
+
+package gos
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoggerOption(t *testing.T) {
+	Convey("When logging the events of a store with a memory limit", t, func() {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		os := NewObjectStore(WithObjsPerSlab(2), WithMaxMemory(uint64(slabSize(3, 2))), WithLogger(logger))
+
+		obj, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		Convey("the slab creation should be logged", func() {
+			So(buf.String(), ShouldContainSubstring, "gos: slab created")
+		})
+
+		Convey("a rejection by the memory limit should be logged", func() {
+			_, err := os.Add([]byte("def"))
+			So(err, ShouldBeNil)
+			_, err = os.Add([]byte("ghi"))
+			So(err, ShouldNotBeNil)
+			So(buf.String(), ShouldContainSubstring, "level=WARN msg=\"gos: slab rejected by memory limit\"")
+		})
+
+		Convey("the slab deletion should be logged", func() {
+			So(os.Delete(obj), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, "gos: slab deleted")
+		})
+	})
+}
@@ -0,0 +1,74 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"syscall"
+)
+
+// pageAligned returns the part of the memory area which consists of whole
+// pages, because madvise only accepts page aligned addresses. Partial pages
+// at the start and the end may be shared with other memory areas
+func pageAligned(data []byte) []byte {
+	pageSize := uintptr(systemPageSize)
+	start := objAddrFromObj(data)
+	end := start + uintptr(len(data))
+	alignedStart := (start + pageSize - 1) &^ (pageSize - 1)
+	alignedEnd := end &^ (pageSize - 1)
+	if alignedEnd <= alignedStart {
+		return nil
+	}
+	return data[alignedStart-start : alignedEnd-start]
+}
+
+// adviseTHP advises the kernel whether to back the memory area with
+// transparent huge pages
+func adviseTHP(data []byte, mode TransparentHugePages) error {
+	advice := syscall.MADV_HUGEPAGE
+	if mode == THPDisable {
+		advice = syscall.MADV_NOHUGEPAGE
+	}
+	aligned := pageAligned(data)
+	if len(aligned) == 0 {
+		return nil
+	}
+	return syscall.Madvise(aligned, advice)
+}
+
+// madvPopulateWrite is MADV_POPULATE_WRITE, which is missing in the syscall
+// package. It requires Linux 5.14
+const madvPopulateWrite = 23
+
+// populate faults in the whole pages of the memory area for writing, with
+// a single syscall instead of a page fault per page
+func populate(data []byte) error {
+	aligned := pageAligned(data)
+	if len(aligned) == 0 {
+		return nil
+	}
+	return syscall.Madvise(aligned, madvPopulateWrite)
+}
+
+// madvFree is MADV_FREE, which is missing in the syscall package. It
+// requires Linux 4.5 and only works on private anonymous memory
+const madvFree = 8
+
+// mapNoReserve makes mmap skip reserving swap space for the mapping, so
+// large arenas can be reserved without being accounted as committed memory
+const mapNoReserve = syscall.MAP_NORESERVE
+
+// discardMemory releases the pages of the page aligned memory area, they
+// read as zeros when they get accessed again
+func discardMemory(data []byte) {
+	syscall.Madvise(data, syscall.MADV_DONTNEED)
+}
+
+// releaseMemory releases the pages of the page aligned memory area, they
+// read as zeros or keep their content when they get accessed again
+func releaseMemory(data []byte) error {
+	if err := syscall.Madvise(data, madvFree); err == nil {
+		return nil
+	}
+	return syscall.Madvise(data, syscall.MADV_DONTNEED)
+}
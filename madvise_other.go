@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package gos
+
+// adviseTHP does nothing, because transparent huge pages only exist on
+// Linux
+func adviseTHP(data []byte, mode TransparentHugePages) error {
+	return nil
+}
+
+// populate does nothing, the pages get faulted in by touching them
+func populate(data []byte) error {
+	return nil
+}
+
+// mapNoReserve is 0, because MAP_NORESERVE is specific to Linux
+const mapNoReserve = 0
+
+// discardMemory zeros the memory area, the pages stay resident
+func discardMemory(data []byte) {
+	zero(data)
+}
+
+// releaseMemory does nothing, the pages stay resident
+func releaseMemory(data []byte) error {
+	return nil
+}
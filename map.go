@@ -0,0 +1,404 @@
+package gos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// mapBucketEntries is the number of entries per bucket of a Map
+const mapBucketEntries = 8
+
+// mapBucketSize is the size of a bucket object of a Map. A bucket starts
+// with the address of its overflow bucket, followed by the top hash byte
+// of each entry and the key and value addresses of each entry
+const mapBucketSize = 8 + mapBucketEntries + mapBucketEntries*16
+
+// mapMaxLoad is the average number of entries per bucket from which on the
+// directory of a Map gets doubled
+const mapMaxLoad = 6
+
+// mapInitialBuckets is the number of buckets of a new Map
+const mapInitialBuckets = 8
+
+// mapBucket is the memory of a bucket object of a Map
+type mapBucket []byte
+
+// next returns the address of the overflow bucket, or 0 if there is none
+func (b mapBucket) next() ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(b))
+}
+
+// setNext sets the address of the overflow bucket
+func (b mapBucket) setNext(next ObjAddr) {
+	binary.LittleEndian.PutUint64(b, uint64(next))
+}
+
+// top returns the top hash byte of the entry at the given index, it is 0
+// if the entry is unused
+func (b mapBucket) top(i int) uint8 {
+	return b[8+i]
+}
+
+// entry returns the key and value addresses of the entry at the given index
+func (b mapBucket) entry(i int) (ObjAddr, ObjAddr) {
+	offset := 8 + mapBucketEntries + i*16
+	return ObjAddr(binary.LittleEndian.Uint64(b[offset:])), ObjAddr(binary.LittleEndian.Uint64(b[offset+8:]))
+}
+
+// set sets the top hash byte and the key and value addresses of the entry
+// at the given index, a top hash byte of 0 marks the entry as unused
+func (b mapBucket) set(i int, top uint8, key, value ObjAddr) {
+	offset := 8 + mapBucketEntries + i*16
+	b[8+i] = top
+	binary.LittleEndian.PutUint64(b[offset:], uint64(key))
+	binary.LittleEndian.PutUint64(b[offset+8:], uint64(value))
+}
+
+// mapTop returns the top hash byte of the given hash, which is never 0
+func mapTop(hash uint64) uint8 {
+	if top := uint8(hash >> 56); top > 0 {
+		return top
+	}
+	return 1
+}
+
+// Map is a hash map whose keys, values and buckets are objects of an
+// ObjectStore, and whose bucket directory gets mapped by the backend of
+// the store. Large maps neither live on the Go heap nor add to the work of
+// the GC. Keys and values must be between 1 and 255 bytes long. A Map is
+// safe for concurrent use
+type Map struct {
+	store *ObjectStore
+
+	// lock protects the directory and the objects of the map
+	lock sync.RWMutex
+
+	// dir contains the address of the first bucket of each bucket chain,
+	// or 0 if the chain has no bucket yet
+	dir     []byte
+	buckets uint64
+	count   int
+}
+
+// NewMap creates an empty Map whose objects get stored in an ObjectStore
+// created with the given options. The store must not have bloom filters
+// or a hash index, because the buckets get updated in place
+// On success it returns the map and nil
+// On failure the second returned value is the error
+func NewMap(opts ...Option) (*Map, error) {
+	m := &Map{store: NewObjectStore(opts...)}
+
+	dir, err := m.store.mapDirectory(mapInitialBuckets)
+	if err != nil {
+		return nil, err
+	}
+	m.dir = dir
+	m.buckets = mapInitialBuckets
+
+	return m, nil
+}
+
+// mapDirectory maps a zeroed directory with the given number of 8 byte
+// entries with the backend of the store, and accounts it against the
+// memory limit of the store. Containers like Map and Vector use it for the
+// arrays which refer to their objects
+// On success it returns the directory and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) mapDirectory(entries uint64) ([]byte, error) {
+	size := entries * 8
+	if !o.mem.reserve(size) {
+		return nil, fmt.Errorf("ObjectStore: Failed to map directory because it would exceed the memory limit of %d bytes: %w", o.mem.max, ErrMemoryLimit)
+	}
+
+	dir, err := o.backend.Map(int(size))
+	if err != nil {
+		o.mem.release(size)
+		return nil, fmt.Errorf("ObjectStore: Failed to map directory: %w: %w", ErrMmapFailed, err)
+	}
+
+	return dir, nil
+}
+
+// unmapDirectory unmaps a directory which has been mapped by mapDirectory
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) unmapDirectory(dir []byte) error {
+	if err := o.backend.Unmap(dir); err != nil {
+		return fmt.Errorf("ObjectStore: Failed to unmap directory: %w: %w", ErrMmapFailed, err)
+	}
+	o.mem.release(uint64(len(dir)))
+
+	return nil
+}
+
+// mapChain returns the address of the first bucket of the chain at the given
+// index of the given directory
+func mapChain(dir []byte, idx uint64) ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(dir[idx*8:]))
+}
+
+// bucket returns the memory of the bucket object at the given address
+func (m *Map) bucket(addr ObjAddr) mapBucket {
+	return mapBucket(sliceAt(addr, mapBucketSize))
+}
+
+// find looks up the entry of the given key
+// When found it returns the bucket and the index of the entry and true,
+// otherwise the third returned value is false
+// The caller must hold the map lock
+func (m *Map) find(key []byte, hash uint64) (mapBucket, int, bool) {
+	top := mapTop(hash)
+	for addr := mapChain(m.dir, hash&(m.buckets-1)); addr != 0; {
+		b := m.bucket(addr)
+		for i := 0; i < mapBucketEntries; i++ {
+			if b.top(i) != top {
+				continue
+			}
+			keyAddr, _ := b.entry(i)
+			if stored, err := m.store.Get(keyAddr); err == nil && bytes.Equal(stored, key) {
+				return b, i, true
+			}
+		}
+		addr = b.next()
+	}
+
+	return nil, 0, false
+}
+
+// Get returns the value of the given key, the returned slice refers to the
+// slab memory and is only valid until the key gets updated or deleted
+// When found it returns the value and true, otherwise the second returned
+// value is false
+func (m *Map) Get(key []byte) ([]byte, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	b, i, ok := m.find(key, bloomHash(key))
+	if !ok {
+		return nil, false
+	}
+
+	_, valueAddr := b.entry(i)
+	value, err := m.store.Get(valueAddr)
+	return value, err == nil
+}
+
+// Put sets the value of the given key, both get copied into the store
+// On success it returns nil, otherwise it returns an error
+func (m *Map) Put(key, value []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	hash := bloomHash(key)
+	if b, i, ok := m.find(key, hash); ok {
+		valueAddr, err := m.store.Add(value)
+		if err != nil {
+			return err
+		}
+		keyAddr, oldValueAddr := b.entry(i)
+		b.set(i, b.top(i), keyAddr, valueAddr)
+		return m.store.Delete(oldValueAddr)
+	}
+
+	if uint64(m.count) >= m.buckets*mapMaxLoad {
+		if err := m.grow(); err != nil {
+			return err
+		}
+	}
+
+	keyAddr, err := m.store.Add(key)
+	if err != nil {
+		return err
+	}
+	valueAddr, err := m.store.Add(value)
+	if err != nil {
+		m.store.Delete(keyAddr)
+		return err
+	}
+
+	if err := m.insert(m.dir, m.buckets, hash, keyAddr, valueAddr); err != nil {
+		m.store.Delete(keyAddr)
+		m.store.Delete(valueAddr)
+		return err
+	}
+	m.count++
+
+	return nil
+}
+
+// insert adds an entry for the given key and value addresses to the chain
+// of the given hash in the given directory. If all buckets of the chain
+// are used, a bucket gets added to its end
+// On success it returns nil, otherwise it returns an error
+// The caller must hold the map lock for writing
+func (m *Map) insert(dir []byte, buckets uint64, hash uint64, keyAddr, valueAddr ObjAddr) error {
+	idx := hash & (buckets - 1)
+
+	var last mapBucket
+	for addr := mapChain(dir, idx); addr != 0; addr = last.next() {
+		last = m.bucket(addr)
+		for i := 0; i < mapBucketEntries; i++ {
+			if last.top(i) == 0 {
+				last.set(i, mapTop(hash), keyAddr, valueAddr)
+				return nil
+			}
+		}
+	}
+
+	addr, data, err := m.store.AddReserve(mapBucketSize)
+	if err != nil {
+		return err
+	}
+	mapBucket(data).set(0, mapTop(hash), keyAddr, valueAddr)
+
+	if last == nil {
+		binary.LittleEndian.PutUint64(dir[idx*8:], uint64(addr))
+	} else {
+		last.setNext(addr)
+	}
+
+	return nil
+}
+
+// grow doubles the number of buckets, the entries get moved into the
+// buckets of a new directory and the old buckets get deleted
+// On success it returns nil, otherwise it returns an error and the map
+// is unchanged
+// The caller must hold the map lock for writing
+func (m *Map) grow() error {
+	buckets := m.buckets * 2
+	dir, err := m.store.mapDirectory(buckets)
+	if err != nil {
+		return err
+	}
+
+	m.forEachEntry(func(keyAddr, valueAddr ObjAddr) bool {
+		var key []byte
+		if key, err = m.store.Get(keyAddr); err == nil {
+			err = m.insert(dir, buckets, bloomHash(key), keyAddr, valueAddr)
+		}
+		return err == nil
+	})
+	if err != nil {
+		// delete the buckets of the new directory again
+		m.deleteBuckets(dir, buckets)
+		m.store.unmapDirectory(dir)
+		return err
+	}
+
+	m.deleteBuckets(m.dir, m.buckets)
+	if err := m.store.unmapDirectory(m.dir); err != nil && m.store.logger != nil {
+		m.store.logger.Warn("gos: failed to unmap map directory", "error", err)
+	}
+	m.dir = dir
+	m.buckets = buckets
+
+	return nil
+}
+
+// forEachEntry calls fn with the key and value addresses of each entry
+// until fn returns false
+// The caller must hold the map lock
+func (m *Map) forEachEntry(fn func(keyAddr, valueAddr ObjAddr) bool) {
+	for idx := uint64(0); idx < m.buckets; idx++ {
+		for addr := mapChain(m.dir, idx); addr != 0; {
+			b := m.bucket(addr)
+			for i := 0; i < mapBucketEntries; i++ {
+				if b.top(i) == 0 {
+					continue
+				}
+				if !fn(b.entry(i)) {
+					return
+				}
+			}
+			addr = b.next()
+		}
+	}
+}
+
+// deleteBuckets deletes the bucket objects of all chains of the given
+// directory, but not the objects of their entries
+// The caller must hold the map lock for writing
+func (m *Map) deleteBuckets(dir []byte, buckets uint64) {
+	for idx := uint64(0); idx < buckets; idx++ {
+		for addr := mapChain(dir, idx); addr != 0; {
+			next := m.bucket(addr).next()
+			m.store.Delete(addr)
+			addr = next
+		}
+		binary.LittleEndian.PutUint64(dir[idx*8:], 0)
+	}
+}
+
+// Delete deletes the given key and its value
+// It returns true if the key has been deleted, or false if it didn't exist
+func (m *Map) Delete(key []byte) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, i, ok := m.find(key, bloomHash(key))
+	if !ok {
+		return false
+	}
+
+	keyAddr, valueAddr := b.entry(i)
+	b.set(i, 0, 0, 0)
+	m.store.Delete(keyAddr)
+	m.store.Delete(valueAddr)
+	m.count--
+
+	return true
+}
+
+// Len returns the number of keys in the map
+func (m *Map) Len() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.count
+}
+
+// Range calls fn with each key and its value until fn returns false, the
+// order is undefined. The slices refer to the slab memory and must not be
+// retained, fn must not modify the map
+func (m *Map) Range(fn func(key, value []byte) bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	m.forEachEntry(func(keyAddr, valueAddr ObjAddr) bool {
+		key, err := m.store.Get(keyAddr)
+		if err != nil {
+			return true
+		}
+		value, err := m.store.Get(valueAddr)
+		if err != nil {
+			return true
+		}
+		return fn(key, value)
+	})
+}
+
+// MemStats returns the memory usage statistics of the store of the map,
+// the directory is not included
+func (m *Map) MemStats() StoreMemStats {
+	return m.store.MemStats()
+}
+
+// Close deletes all objects of the map and unmaps its directory, the map
+// must not be used anymore afterwards
+// On success it returns nil, otherwise it returns an error
+func (m *Map) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.forEachEntry(func(keyAddr, valueAddr ObjAddr) bool {
+		m.store.Delete(keyAddr)
+		m.store.Delete(valueAddr)
+		return true
+	})
+	m.deleteBuckets(m.dir, m.buckets)
+	m.count = 0
+
+	return m.store.unmapDirectory(m.dir)
+}
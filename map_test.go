@@ -0,0 +1,77 @@
+package gos
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMap(t *testing.T) {
+	Convey("When putting many keys into a map", t, func() {
+		m, err := NewMap(WithObjsPerSlab(64))
+		So(err, ShouldBeNil)
+		for i := 0; i < 1000; i++ {
+			So(m.Put([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i))), ShouldBeNil)
+		}
+
+		Convey("the directory should have grown and all keys should be found", func() {
+			So(m.Len(), ShouldEqual, 1000)
+			So(m.buckets, ShouldBeGreaterThan, mapInitialBuckets)
+			for i := 0; i < 1000; i++ {
+				value, ok := m.Get([]byte(fmt.Sprintf("key%d", i)))
+				So(ok, ShouldBeTrue)
+				So(string(value), ShouldEqual, fmt.Sprintf("value%d", i))
+			}
+			_, ok := m.Get([]byte("key1000"))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("putting an existing key should replace its value", func() {
+			So(m.Put([]byte("key5"), []byte("replaced")), ShouldBeNil)
+			value, ok := m.Get([]byte("key5"))
+			So(ok, ShouldBeTrue)
+			So(string(value), ShouldEqual, "replaced")
+			So(m.Len(), ShouldEqual, 1000)
+		})
+
+		Convey("deleted keys should not be found anymore", func() {
+			for i := 0; i < 1000; i += 2 {
+				So(m.Delete([]byte(fmt.Sprintf("key%d", i))), ShouldBeTrue)
+			}
+			So(m.Delete([]byte("key0")), ShouldBeFalse)
+			So(m.Len(), ShouldEqual, 500)
+
+			seen := make(map[string]string)
+			m.Range(func(key, value []byte) bool {
+				seen[string(key)] = string(value)
+				return true
+			})
+			So(len(seen), ShouldEqual, 500)
+			So(seen["key1"], ShouldEqual, "value1")
+			_, ok := seen["key0"]
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Range should stop when fn returns false", func() {
+			calls := 0
+			m.Range(func(key, value []byte) bool {
+				calls++
+				return calls < 10
+			})
+			So(calls, ShouldEqual, 10)
+		})
+
+		Convey("closing the map should release all of its slabs", func() {
+			So(m.Close(), ShouldBeNil)
+			So(m.MemStats().Slabs, ShouldEqual, 0)
+			So(m.MemStats().LiveObjects, ShouldEqual, 0)
+		})
+
+		Convey("invalid keys should be rejected", func() {
+			So(m.Put(nil, []byte("value")), ShouldNotBeNil)
+			So(m.Put(make([]byte, 256), []byte("value")), ShouldNotBeNil)
+			So(m.Len(), ShouldEqual, 1000)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// the flags of memfd_create and the file seals, which are missing in the
+// syscall package
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+
+	fAddSeals = 1033
+	fGetSeals = 1034
+
+	fSealSeal   = 0x1
+	fSealShrink = 0x2
+	fSealGrow   = 0x4
+	fSealWrite  = 0x8
+)
+
+// sysMemfdCreate is the number of the memfd_create syscall, which the
+// syscall package only defines for some architectures. It is 0 if the
+// architecture is unknown
+var sysMemfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+	"mips64":   5314,
+	"mips64le": 5314,
+}[runtime.GOARCH]
+
+// MemfdBackend is a Backend which creates each slab as an anonymous
+// memory file with memfd_create and maps it shared. Slabs can be sealed by
+// SealSlab, then their memory becomes read-only and the file gets sealed
+// against writes and size changes. The file descriptor of a sealed slab
+// can be passed to other processes, which can map it without having to
+// trust the sender not to modify it afterwards
+type MemfdBackend struct {
+	lock sync.Mutex
+
+	// fds maps the address of each memory area to its memory file
+	fds map[uintptr]int
+}
+
+// NewMemfdBackend creates a MemfdBackend
+func NewMemfdBackend() *MemfdBackend {
+	return &MemfdBackend{fds: make(map[uintptr]int)}
+}
+
+// memfdCreate creates an anonymous memory file which allows sealing
+func memfdCreate(name string) (int, error) {
+	if sysMemfdCreate == 0 {
+		return -1, syscall.ENOSYS
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(namePtr)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// Map creates a memory file of the given size and maps it
+func (b *MemfdBackend) Map(size int) ([]byte, error) {
+	fd, err := memfdCreate("gos-slab")
+	if err != nil {
+		return nil, fmt.Errorf("memfd_create failed: %w", err)
+	}
+	if err := syscall.Ftruncate(fd, int64(size)); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	data, err := syscall.Mmap(fd, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	b.lock.Lock()
+	b.fds[uintptr(unsafe.Pointer(&data[0]))] = fd
+	b.lock.Unlock()
+
+	return data, nil
+}
+
+// Unmap unmaps the given memory area and closes its memory file, the file
+// is only released once no other process has it open anymore
+func (b *MemfdBackend) Unmap(data []byte) error {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	b.lock.Lock()
+	fd, ok := b.fds[addr]
+	delete(b.fds, addr)
+	b.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("memory area at %#x has not been mapped by this backend: %w", addr, ErrNotFound)
+	}
+
+	err := syscall.Munmap(data)
+	syscall.Close(fd)
+	return err
+}
+
+// Seal implements Sealer. The shared mapping of the memory area gets
+// replaced by a private read-only one at the same address, because the
+// kernel refuses to seal files which have shared mappings that could
+// become writable. Then its memory file gets sealed against writes, size
+// changes and further seal changes
+func (b *MemfdBackend) Seal(data []byte) error {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	fd, ok := b.FD(addr)
+	if !ok {
+		return fmt.Errorf("memory area at %#x has not been mapped by this backend: %w", addr, ErrNotFound)
+	}
+
+	if err := mmapFixed(addr, len(data), syscall.PROT_READ, syscall.MAP_PRIVATE, fd); err != nil {
+		return fmt.Errorf("failed to remap the memory area read-only: %w", err)
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), fAddSeals, fSealWrite|fSealShrink|fSealGrow|fSealSeal)
+	if errno != 0 {
+		// the slab is still in use, so it must become writable again
+		mmapFixed(addr, len(data), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED, fd)
+		return fmt.Errorf("failed to seal the memory file: %w", errno)
+	}
+
+	return nil
+}
+
+// FD returns the file descriptor of the memory file of the slab at the
+// given address. It remains owned by the backend and gets closed when the
+// slab gets deleted, so it must be duplicated to keep it longer
+// The second returned value is false if there is no such slab
+func (b *MemfdBackend) FD(slabAddr SlabAddr) (int, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	fd, ok := b.fds[slabAddr]
+	return fd, ok
+}
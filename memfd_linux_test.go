@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemfdBackend(t *testing.T) {
+	Convey("When sealing a slab of a store with a memfd backend", t, func() {
+		backend := NewMemfdBackend()
+		os := NewObjectStore(WithBackend(backend), WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		_, slabAddr, _ := os.Owner(objs[0])
+		So(os.SealSlab(slabAddr), ShouldBeNil)
+
+		Convey("its objects should still be readable and searchable", func() {
+			data, err := os.Get(objs[1])
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "def")
+			obj, found := os.Search([]byte("abc"))
+			So(found, ShouldBeTrue)
+			So(obj, ShouldEqual, objs[0])
+		})
+
+		Convey("its objects should not be deletable", func() {
+			err := os.Delete(objs[0])
+			So(errors.Is(err, ErrSealed), ShouldBeTrue)
+		})
+
+		Convey("new objects should go into another slab", func() {
+			obj, err := os.Add([]byte("ghi"))
+			So(err, ShouldBeNil)
+			_, newSlabAddr, _ := os.Owner(obj)
+			So(newSlabAddr, ShouldNotEqual, slabAddr)
+		})
+
+		Convey("its memory file should be sealed against writes", func() {
+			fd, ok := backend.FD(slabAddr)
+			So(ok, ShouldBeTrue)
+			seals, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), fGetSeals, 0)
+			So(errno, ShouldEqual, 0)
+			So(seals&fSealWrite, ShouldNotEqual, 0)
+
+			_, err := syscall.Pwrite(fd, []byte("x"), 0)
+			So(err, ShouldEqual, syscall.EPERM)
+		})
+	})
+
+	Convey("When sealing a slab of a store whose backend can't seal", t, func() {
+		os := NewObjectStore()
+		obj, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		_, slabAddr, _ := os.Owner(obj)
+		So(os.SealSlab(slabAddr), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,92 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// rlimitMemlock returns the resource number of RLIMIT_MEMLOCK, which is
+// missing in the syscall package
+func rlimitMemlock() int {
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le":
+		return 9
+	}
+	return 8
+}
+
+// rlimInfinity is the value of an unlimited resource limit
+const rlimInfinity = ^uint64(0)
+
+// mlockBackend wraps a Backend and locks its memory areas into RAM
+type mlockBackend struct {
+	Backend
+
+	lock sync.Mutex
+
+	// locked is the number of bytes in the pages which have been locked
+	locked uint64
+}
+
+// newMlockBackend wraps the given backend with an mlockBackend
+func newMlockBackend(backend Backend) Backend {
+	return &mlockBackend{Backend: backend}
+}
+
+// lockedSize returns the size of the pages which contain the memory area,
+// which is what the kernel accounts against RLIMIT_MEMLOCK
+func lockedSize(data []byte) uint64 {
+	pageSize := uintptr(systemPageSize)
+	start := objAddrFromObj(data) &^ (pageSize - 1)
+	end := (objAddrFromObj(data) + uintptr(len(data)) + pageSize - 1) &^ (pageSize - 1)
+	return uint64(end - start)
+}
+
+// Map obtains a memory area of the given size from the wrapped backend and
+// locks it, unless that would exceed RLIMIT_MEMLOCK
+func (m *mlockBackend) Map(size int) ([]byte, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(rlimitMemlock(), &limit); err != nil {
+		return nil, fmt.Errorf("failed to get RLIMIT_MEMLOCK: %w", err)
+	}
+
+	data, err := m.Backend.Map(size)
+	if err != nil {
+		return nil, err
+	}
+	locked := lockedSize(data)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if limit.Cur != rlimInfinity && m.locked+locked > limit.Cur {
+		m.Backend.Unmap(data)
+		return nil, fmt.Errorf("locking %d bytes would exceed RLIMIT_MEMLOCK of %d bytes, %d bytes are locked already: %w", locked, limit.Cur, m.locked, ErrMemlockLimit)
+	}
+	if err := syscall.Mlock(data); err != nil {
+		m.Backend.Unmap(data)
+		return nil, fmt.Errorf("mlock failed: %w", err)
+	}
+	m.locked += locked
+
+	return data, nil
+}
+
+// Unmap unlocks the given memory area and releases it with the wrapped
+// backend
+func (m *mlockBackend) Unmap(data []byte) error {
+	if err := syscall.Munlock(data); err != nil {
+		return fmt.Errorf("munlock failed: %w", err)
+	}
+
+	m.lock.Lock()
+	m.locked -= lockedSize(data)
+	m.lock.Unlock()
+
+	return m.Backend.Unmap(data)
+}
@@ -0,0 +1,46 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMlock(t *testing.T) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(rlimitMemlock(), &limit); err != nil {
+		t.Fatal(err)
+	}
+	pageSize := uint64(systemPageSize)
+
+	Convey("When locking the slab memory of a store", t, func() {
+		lowered := limit
+		lowered.Cur = 4 * pageSize
+		So(syscall.Setrlimit(rlimitMemlock(), &lowered), ShouldBeNil)
+		defer syscall.Setrlimit(rlimitMemlock(), &limit)
+
+		os := NewObjectStore(WithMlock(), WithObjsPerSlab(uint(pageSize)))
+		backend := os.backend.(*mlockBackend)
+		obj, err := os.Add([]byte("a"))
+		So(err, ShouldBeNil)
+		So(backend.locked, ShouldEqual, 2*pageSize)
+
+		Convey("a slab which would exceed RLIMIT_MEMLOCK should be rejected", func() {
+			_, err := os.Add([]byte("ab"))
+			So(errors.Is(err, ErrMemlockLimit), ShouldBeTrue)
+			So(backend.locked, ShouldEqual, 2*pageSize)
+		})
+
+		Convey("deleting a slab should unlock its memory", func() {
+			So(os.Delete(obj), ShouldBeNil)
+			So(backend.locked, ShouldEqual, 0)
+			_, err := os.Add([]byte("ab"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package gos
+
+import (
+	"errors"
+)
+
+// errMlockUnsupported is returned when mapping slabs with WithMlock on
+// platforms other than Linux
+var errMlockUnsupported = errors.New("locking slab memory is only supported on Linux")
+
+// mlockBackend fails to map any memory, because locking slab memory is not
+// supported on this platform
+type mlockBackend struct {
+	Backend
+}
+
+// newMlockBackend wraps the given backend with an mlockBackend
+func newMlockBackend(backend Backend) Backend {
+	return mlockBackend{backend}
+}
+
+// Map always fails on this platform
+func (mlockBackend) Map(size int) ([]byte, error) {
+	return nil, errMlockUnsupported
+}
@@ -0,0 +1,22 @@
+//go:build unix && !linux && !darwin && !dragonfly && !freebsd
+// +build unix,!linux,!darwin,!dragonfly,!freebsd
+
+package gos
+
+import (
+	"errors"
+)
+
+// errMmanUnsupported is returned by mprotect and msyncMemory on platforms
+// whose syscall package can't call them
+var errMmanUnsupported = errors.New("ObjectStore: mprotect and msync are not supported on this platform")
+
+// mprotect always fails on this platform, so guard pages are not supported
+func mprotect(data []byte, prot int) error {
+	return errMmanUnsupported
+}
+
+// msyncMemory always fails on this platform, so FileBackend.Sync fails
+func msyncMemory(data []byte) error {
+	return errMmanUnsupported
+}
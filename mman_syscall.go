@@ -0,0 +1,28 @@
+//go:build linux || darwin || dragonfly || freebsd
+// +build linux darwin dragonfly freebsd
+
+package gos
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// mprotect changes the protection of the pages of the memory area, the
+// syscall package only wraps mprotect on some platforms
+func mprotect(data []byte, prot int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MPROTECT, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(prot))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// msyncMemory flushes the given shared mapping to its file
+func msyncMemory(data []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
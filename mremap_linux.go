@@ -0,0 +1,71 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// grownMappings maps the address of each memory area which has been grown
+// by MmapBackend to the mapping that syscall.Mmap has returned for it,
+// because syscall.Munmap only accepts the latter
+var grownMappings = struct {
+	sync.Mutex
+	m map[uintptr][]byte
+}{m: make(map[uintptr][]byte)}
+
+// grow extends the given memory area to the given size with mremap, without
+// allowing the kernel to move it
+// On success it returns the grown memory area and nil
+// On failure, if the address space after the memory area is in use, the
+// second returned value is the error
+func (MmapBackend) grow(data []byte, size int) ([]byte, error) {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	if isBulkArea(addr) {
+		return nil, errBulkArea
+	}
+	oldLen, newLen := pageRound(len(data)), pageRound(size)
+	if newLen > oldLen {
+		_, _, errno := syscall.Syscall6(syscall.SYS_MREMAP, addr, uintptr(oldLen), uintptr(newLen), 0, 0, 0)
+		if errno != 0 {
+			return nil, errno
+		}
+	}
+
+	grownMappings.Lock()
+	if _, ok := grownMappings.m[addr]; !ok {
+		grownMappings.m[addr] = data
+	}
+	grownMappings.Unlock()
+
+	return sliceAt(addr, size), nil
+}
+
+// unmapGrown unmaps the given memory area if it has been grown by
+// MmapBackend, the pages which have been added by mremap get unmapped
+// separately from the original mapping
+// It returns false if the memory area hasn't been grown
+func unmapGrown(data []byte) (bool, error) {
+	addr := uintptr(unsafe.Pointer(&data[0]))
+
+	grownMappings.Lock()
+	mapping, ok := grownMappings.m[addr]
+	delete(grownMappings.m, addr)
+	grownMappings.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	start, end := pageRound(len(mapping)), pageRound(len(data))
+	if end > start {
+		_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, addr+uintptr(start), uintptr(end-start), 0)
+		if errno != 0 {
+			return true, errno
+		}
+	}
+	return true, syscall.Munmap(mapping)
+}
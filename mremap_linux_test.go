@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMmapBackendGrow(t *testing.T) {
+	Convey("When growing a memory area of the mmap backend", t, func() {
+		backend := MmapBackend{}
+		pageSize := systemPageSize
+
+		// mappings are usually placed right below the previous one, so
+		// the upper mapping occupies the address space after the lower one
+		upper, err := backend.Map(2 * pageSize)
+		So(err, ShouldBeNil)
+		lower, err := backend.Map(pageSize)
+		So(err, ShouldBeNil)
+		if objAddrFromObj(lower)+uintptr(pageSize) != objAddrFromObj(upper) {
+			backend.Unmap(upper)
+			backend.Unmap(lower)
+			t.Skip("the kernel has not placed the mappings next to each other")
+		}
+
+		Convey("it should fail while the address space after it is in use", func() {
+			_, err := backend.grow(lower, 2*pageSize)
+			So(err, ShouldNotBeNil)
+			So(backend.Unmap(upper), ShouldBeNil)
+			So(backend.Unmap(lower), ShouldBeNil)
+		})
+
+		Convey("it should grow in place once the address space is free", func() {
+			lower[0] = 1
+			So(backend.Unmap(upper), ShouldBeNil)
+			grown, err := backend.grow(lower, 2*pageSize+100)
+			So(err, ShouldBeNil)
+			So(objAddrFromObj(grown), ShouldEqual, objAddrFromObj(lower))
+			So(len(grown), ShouldEqual, 2*pageSize+100)
+			So(grown[0], ShouldEqual, 1)
+			grown[len(grown)-1] = 2
+
+			So(backend.Unmap(grown), ShouldBeNil)
+			So(faults(func() { grown[len(grown)-1] = 3 }), ShouldBeTrue)
+			So(faults(func() { grown[0] = 3 }), ShouldBeTrue)
+		})
+
+		Convey("it should fail for areas of a bulk mapping", func() {
+			So(backend.Unmap(upper), ShouldBeNil)
+			So(backend.Unmap(lower), ShouldBeNil)
+			areas, err := backend.mapBulk([]int{pageSize, pageSize})
+			So(err, ShouldBeNil)
+			_, err = backend.grow(areas[1], 2*pageSize)
+			So(err, ShouldEqual, errBulkArea)
+			So(backend.Unmap(areas[0]), ShouldBeNil)
+			So(backend.Unmap(areas[1]), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+// +build unix,!linux
+
+package gos
+
+// unmapGrown returns false, because MmapBackend can only grow memory areas
+// on Linux
+func unmapGrown(data []byte) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,113 @@
+package gos
+
+import (
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// NUMANodeStats stores the number of slabs and their mapped bytes, which
+// have been bound to a NUMA node by WithNUMABinding. Node is -1 for the
+// slabs which couldn't be bound
+type NUMANodeStats struct {
+	Node        int
+	Slabs       uint64
+	MappedBytes uint64
+}
+
+// WithNUMABinding binds the memory of each new slab to the NUMA node of
+// the CPU that the allocating goroutine runs on, so objects get stored
+// close to the threads which add them. Goroutines can move between CPUs,
+// so callers should add objects from threads that are pinned to the CPUs
+// of one node. It is only supported on Linux, see NUMAStats
+func WithNUMABinding() Option {
+	return func(o *ObjectStore) {
+		o.numa = &numaBackend{nodes: make(map[uintptr]int)}
+	}
+}
+
+// numaBackend wraps a Backend and binds its memory areas to the NUMA node
+// of the current CPU
+type numaBackend struct {
+	Backend
+
+	lock sync.Mutex
+
+	// nodes maps the address of each memory area to the node it has
+	// been bound to, or to -1
+	nodes map[uintptr]int
+
+	// stats holds the statistics of each node which has slabs
+	stats map[int]*NUMANodeStats
+}
+
+// Map obtains a memory area of the given size from the wrapped backend and
+// binds it to the node of the current CPU, before its pages get touched
+func (n *numaBackend) Map(size int) ([]byte, error) {
+	data, err := n.Backend.Map(size)
+	if err != nil {
+		return nil, err
+	}
+
+	node := bindToCurrentNode(data)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.stats == nil {
+		n.stats = make(map[int]*NUMANodeStats)
+	}
+	stats, ok := n.stats[node]
+	if !ok {
+		stats = &NUMANodeStats{Node: node}
+		n.stats[node] = stats
+	}
+	stats.Slabs++
+	stats.MappedBytes += uint64(len(data))
+	n.nodes[uintptr(unsafe.Pointer(&data[0]))] = node
+
+	return data, nil
+}
+
+// Unmap releases the given memory area with the wrapped backend
+func (n *numaBackend) Unmap(data []byte) error {
+	if err := n.Backend.Unmap(data); err != nil {
+		return err
+	}
+
+	addr := uintptr(unsafe.Pointer(&data[0]))
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	node, ok := n.nodes[addr]
+	if !ok {
+		return nil
+	}
+	delete(n.nodes, addr)
+	stats := n.stats[node]
+	stats.Slabs--
+	stats.MappedBytes -= uint64(len(data))
+	if stats.Slabs == 0 {
+		delete(n.stats, node)
+	}
+	return nil
+}
+
+// NUMAStats returns the statistics of each NUMA node which has slabs that
+// have been bound to it by WithNUMABinding, sorted by node. It returns nil
+// if the store doesn't use WithNUMABinding
+func (o *ObjectStore) NUMAStats() []NUMANodeStats {
+	if o.numa == nil {
+		return nil
+	}
+
+	o.numa.lock.Lock()
+	defer o.numa.lock.Unlock()
+
+	stats := make([]NUMANodeStats, 0, len(o.numa.stats))
+	for _, node := range o.numa.stats {
+		stats = append(stats, *node)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Node < stats[j].Node })
+	return stats
+}
@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package gos
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// the numbers of the getcpu and mbind syscalls, which the syscall package
+// only defines for some architectures. They are 0 if the architecture is
+// unknown
+var (
+	sysGetcpu = map[string]uintptr{
+		"386":     318,
+		"amd64":   309,
+		"arm64":   168,
+		"loong64": 168,
+		"ppc64":   302,
+		"ppc64le": 302,
+		"riscv64": 168,
+		"s390x":   311,
+	}[runtime.GOARCH]
+
+	sysMbind = map[string]uintptr{
+		"386":     274,
+		"amd64":   237,
+		"arm64":   235,
+		"loong64": 235,
+		"ppc64":   259,
+		"ppc64le": 259,
+		"riscv64": 235,
+		"s390x":   268,
+	}[runtime.GOARCH]
+)
+
+// mpolBind is the MPOL_BIND memory policy of mbind
+const mpolBind = 2
+
+// currentNode returns the NUMA node of the CPU which the calling thread
+// runs on, or -1 if it can't be determined
+func currentNode() int {
+	if sysGetcpu == 0 {
+		return -1
+	}
+	var cpu, node uint32
+	_, _, errno := syscall.RawSyscall(sysGetcpu, uintptr(unsafe.Pointer(&cpu)), uintptr(unsafe.Pointer(&node)), 0)
+	if errno != 0 {
+		return -1
+	}
+	return int(node)
+}
+
+// bindToCurrentNode binds the whole pages of the memory area to the NUMA
+// node of the current CPU with mbind
+// It returns the node, or -1 if the memory couldn't be bound
+func bindToCurrentNode(data []byte) int {
+	node := currentNode()
+	if node < 0 || node >= 64 || sysMbind == 0 {
+		return -1
+	}
+
+	aligned := pageAligned(data)
+	if len(aligned) == 0 {
+		return node
+	}
+
+	nodeMask := uint64(1) << uint(node)
+	_, _, errno := syscall.Syscall6(sysMbind, objAddrFromObj(aligned), uintptr(len(aligned)), mpolBind, uintptr(unsafe.Pointer(&nodeMask)), 65, 0)
+	if errno != 0 {
+		return -1
+	}
+	return node
+}
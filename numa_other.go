@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package gos
+
+// bindToCurrentNode does nothing, because binding memory to NUMA nodes is
+// only supported on Linux
+// It always returns -1
+func bindToCurrentNode(data []byte) int {
+	return -1
+}
@@ -0,0 +1,42 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNUMABinding(t *testing.T) {
+	Convey("When binding the slabs of a store to NUMA nodes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2), WithNUMABinding())
+		var objs []ObjAddr
+		for _, value := range []string{"ab", "cd", "ef"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("the node statistics should account all slabs", func() {
+			var slabs, mapped uint64
+			for _, stats := range os.NUMAStats() {
+				slabs += stats.Slabs
+				mapped += stats.MappedBytes
+			}
+			So(slabs, ShouldEqual, 2)
+			So(mapped, ShouldEqual, os.MemStats().MappedBytes)
+		})
+
+		Convey("deleting a slab should remove it from the statistics", func() {
+			So(os.Delete(objs[2]), ShouldBeNil)
+			var slabs uint64
+			for _, stats := range os.NUMAStats() {
+				slabs += stats.Slabs
+			}
+			So(slabs, ShouldEqual, 1)
+		})
+	})
+
+	Convey("When a store doesn't bind slabs to NUMA nodes", t, func() {
+		So(NewObjectStore().NUMAStats(), ShouldBeNil)
+	})
+}
@@ -1,9 +1,14 @@
 package gos
 
 import (
+	"context"
+	"crypto/cipher"
 	"fmt"
 	"reflect"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -16,6 +21,35 @@ type MemStat struct {
 	MemUsed uint64
 }
 
+// PoolMemStats stores detailed memory usage statistics about a slab pool
+type PoolMemStats struct {
+	// ObjSize is the object size of the pool
+	ObjSize uint8
+	// Slabs is the number of slabs in the pool
+	Slabs uint64
+	// MappedBytes is the memory used by the slabs, including their headers
+	MappedBytes uint64
+	// LiveObjects is the number of objects in the pool
+	LiveObjects uint64
+	// FreeSlots is the number of unused object slots in the pool
+	FreeSlots uint64
+	// PaddingBytes is the memory which is wasted because the memory
+	// of each slab gets rounded up to whole pages
+	PaddingBytes uint64
+}
+
+// StoreMemStats stores detailed memory usage statistics about an object
+// store, analogous to runtime.MemStats. The totals are aggregated over
+// all pools, Pools contains the statistics of each pool
+type StoreMemStats struct {
+	Slabs        uint64
+	MappedBytes  uint64
+	LiveObjects  uint64
+	FreeSlots    uint64
+	PaddingBytes uint64
+	Pools        []PoolMemStats
+}
+
 // FragStat stores fragmentation insights about a slab pool
 type FragStat struct {
 	ObjSize     uint8
@@ -23,14 +57,50 @@ type FragStat struct {
 	FragPercent float32
 }
 
+// PoolFragmentation stores fragmentation metrics of a slab pool, they help
+// to decide whether the pool would benefit from a compaction
+type PoolFragmentation struct {
+	// ObjSize is the object size of the pool
+	ObjSize uint8
+	// Ratio is the number of free slots in non-empty slabs divided by
+	// the total number of slots in the pool
+	Ratio float64
+	// SparsestSlabs are the least used non-empty slabs of the pool,
+	// ordered by ascending number of live objects
+	SparsestSlabs []SlabInfo
+}
+
+// Fragmentation returns the fragmentation metrics of each pool, including
+// up to sparsest of the least used slabs per pool. The pools are sorted
+// by object size
+func (o *ObjectStore) Fragmentation(sparsest int) []PoolFragmentation {
+	var result []PoolFragmentation
+
+	o.poolsLock.RLock()
+	for _, pool := range o.slabPools {
+		result = append(result, pool.fragmentation(sparsest))
+	}
+	o.poolsLock.RUnlock()
+
+	o.lookupLock.RLock()
+	for _, frag := range result {
+		for i := range frag.SparsestSlabs {
+			frag.SparsestSlabs[i].ID = o.slabIDs[frag.SparsestSlabs[i].Addr]
+		}
+	}
+	o.lookupLock.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ObjSize < result[j].ObjSize })
+	return result
+}
+
 // FragStatsByObjSize returns the fragmentation percent of
 // the requested pool as specified by size
 func (o *ObjectStore) FragStatsByObjSize(size uint8) (float32, error) {
 	// check if pool exists
-	var pool *slabPool
-	var ok bool
-	if pool, ok = o.slabPools[size]; !ok {
-		return 0, fmt.Errorf("ObjectStore: FragStatsByObjSize failed to find pool with object size %d", size)
+	pool, ok := o.getSlabPool(size)
+	if !ok {
+		return 0, fmt.Errorf("ObjectStore: FragStatsByObjSize failed to find pool with object size %d: %w", size, ErrNotFound)
 	}
 
 	return pool.fragStats(), nil
@@ -39,6 +109,9 @@ func (o *ObjectStore) FragStatsByObjSize(size uint8) (float32, error) {
 // FragStatsPerPool returns a slice containing a FragStat for each
 // non-empty slab pool
 func (o *ObjectStore) FragStatsPerPool() (fragStats []FragStat) {
+	o.poolsLock.RLock()
+	defer o.poolsLock.RUnlock()
+
 	for _, sl := range o.slabPools {
 		fragPercent := sl.fragStats()
 		fragStats = append(fragStats, FragStat{ObjSize: sl.objSize, ObjsPerSlab: sl.objsPerSlab, FragPercent: fragPercent})
@@ -51,28 +124,130 @@ func (o *ObjectStore) FragStatsTotal() (float32, error) {
 	var total float32
 	var numPools float32
 
+	o.poolsLock.RLock()
 	for _, sl := range o.slabPools {
-		if len(sl.slabs) < 1 {
+		sl.lock.RLock()
+		slabCount := len(sl.slabs)
+		sl.lock.RUnlock()
+		if slabCount < 1 {
 			continue
 		}
 		numPools++
 		total += sl.fragStats()
 	}
+	o.poolsLock.RUnlock()
 
 	if numPools < 1 {
-		return 0, fmt.Errorf("ObjectStore: No slabs found")
+		return 0, fmt.Errorf("ObjectStore: No slabs found: %w", ErrNotFound)
 	}
 
 	return total / numPools, nil
 }
 
+// SlabInfo returns information about the slab at the given address,
+// including its number of free object slots
+// On failure, if there is no slab at the given address, it returns an error
+func (o *ObjectStore) SlabInfo(slabAddr SlabAddr) (SlabInfo, error) {
+	objSize, known := o.slabObjSize(slabAddr)
+	if !known {
+		return SlabInfo{}, fmt.Errorf("ObjectStore: SlabInfo failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	pool, ok := o.getSlabPool(objSize)
+	if !ok {
+		return SlabInfo{}, fmt.Errorf("ObjectStore: SlabInfo failed to find pool of slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	info, ok := pool.slabInfo(slabAddr)
+	if !ok {
+		return SlabInfo{}, fmt.Errorf("ObjectStore: SlabInfo failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+	info.ID, _ = o.slabID(slabAddr)
+
+	return info, nil
+}
+
+// Slabs returns the SlabInfo of every slab in the store, sorted by
+// ascending slab address
+func (o *ObjectStore) Slabs() []SlabInfo {
+	var infos []SlabInfo
+
+	o.poolsLock.RLock()
+	for _, pool := range o.slabPools {
+		infos = append(infos, pool.slabInfos()...)
+	}
+	o.poolsLock.RUnlock()
+
+	o.lookupLock.RLock()
+	for i := range infos {
+		infos[i].ID = o.slabIDs[infos[i].Addr]
+	}
+	o.lookupLock.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Addr < infos[j].Addr })
+	return infos
+}
+
+// SlabObjects returns the addresses of all the objects in the slab at the
+// given address, in the order of their slots
+// On success it returns the addresses and nil
+// On failure, if there is no slab at the given address, it returns an error
+func (o *ObjectStore) SlabObjects(slabAddr SlabAddr) ([]ObjAddr, error) {
+	objSize, known := o.slabObjSize(slabAddr)
+	if !known {
+		return nil, fmt.Errorf("ObjectStore: SlabObjects failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	sl := slabFromSlabAddr(slabAddr)
+	pool, ok := o.getSlabPool(objSize)
+	if !ok {
+		return nil, fmt.Errorf("ObjectStore: SlabObjects failed to find pool of slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if _, ok := pool.states[sl]; !ok {
+		return nil, fmt.Errorf("ObjectStore: SlabObjects failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	var objs []ObjAddr
+	bitSet := sl.bitSet()
+	for i, ok := bitSet.NextSet(0); ok && i < sl.objsPerSlab(); i, ok = bitSet.NextSet(i + 1) {
+		objs = append(objs, slabAddr+ObjAddr(sl.getObjOffset(i)))
+	}
+	return objs, nil
+}
+
+// MemStats returns detailed memory usage statistics of each pool and
+// of the whole store, the pools are sorted by object size
+func (o *ObjectStore) MemStats() StoreMemStats {
+	var stats StoreMemStats
+
+	o.poolsLock.RLock()
+	for _, pool := range o.slabPools {
+		stats.Pools = append(stats.Pools, pool.detailedMemStats())
+	}
+	o.poolsLock.RUnlock()
+
+	sort.Slice(stats.Pools, func(i, j int) bool { return stats.Pools[i].ObjSize < stats.Pools[j].ObjSize })
+	for _, pool := range stats.Pools {
+		stats.Slabs += pool.Slabs
+		stats.MappedBytes += pool.MappedBytes
+		stats.LiveObjects += pool.LiveObjects
+		stats.FreeSlots += pool.FreeSlots
+		stats.PaddingBytes += pool.PaddingBytes
+	}
+
+	return stats
+}
+
 // MemStatsByObjSize returns the size of a slab pool in bytes. It only looks at MMapped memory
 func (o *ObjectStore) MemStatsByObjSize(size uint8) (uint64, error) {
 	// check if pool exists
-	var pool *slabPool
-	var ok bool
-	if pool, ok = o.slabPools[size]; !ok {
-		return 0, fmt.Errorf("ObjectStore: MemStatsByObjSize failed to find pool with object size %d", size)
+	pool, ok := o.getSlabPool(size)
+	if !ok {
+		return 0, fmt.Errorf("ObjectStore: MemStatsByObjSize failed to find pool with object size %d: %w", size, ErrNotFound)
 	}
 
 	return pool.memStats(), nil
@@ -81,6 +256,9 @@ func (o *ObjectStore) MemStatsByObjSize(size uint8) (uint64, error) {
 // MemStatsPerPool returns a slice containing a MemStat for each
 // non-empty slab pool
 func (o *ObjectStore) MemStatsPerPool() (memStats []MemStat) {
+	o.poolsLock.RLock()
+	defer o.poolsLock.RUnlock()
+
 	for _, p := range o.slabPools {
 		memUsed := p.memStats()
 		memStats = append(memStats, MemStat{ObjSize: p.objSize, MemUsed: memUsed})
@@ -92,6 +270,9 @@ func (o *ObjectStore) MemStatsPerPool() (memStats []MemStat) {
 func (o *ObjectStore) MemStatsTotal() (uint64, error) {
 	var total uint64
 
+	o.poolsLock.RLock()
+	defer o.poolsLock.RUnlock()
+
 	for _, p := range o.slabPools {
 		total += p.memStats()
 	}
@@ -101,20 +282,239 @@ func (o *ObjectStore) MemStatsTotal() (uint64, error) {
 
 // ObjectStore contains a map of slabPools indexed by the size of the objects stored in each pool
 // It also contains a lookup table which is a slice of SlabAddr
-// lookupTable is kept sorted in descending order and updated whenever a slab is created or deleted
+// lookupTable is kept sorted in descending order and updated whenever a slab is created or deleted,
+// slabIndex maps the pages of the slabs in the lookupTable to their slab addresses
+// All methods of ObjectStore are safe for concurrent use. The locking is sharded: poolsLock only
+// protects the slabPools map, lookupTable and slabIndex are protected by lookupLock and each slab pool has its own
+// lock, so operations on objects of different sizes don't block each other
 type ObjectStore struct {
+	poolsLock   sync.RWMutex
 	slabPools   map[uint8]*slabPool
+	lookupLock  sync.RWMutex
 	lookupTable []SlabAddr
+	slabIndex   *slabIndex
 	objsPerSlab uint
+
+	// slabBytes is the size of the slabs set by WithSlabSize, if it is 0
+	// the slabs of all pools have objsPerSlab objects
+	slabBytes int
+
+	// arenaBytes is the size of the arena of each pool set by WithArena,
+	// arenas contains the arenas which have been reserved. They are kept
+	// for the lifetime of the store and protected by lookupLock
+	arenaBytes int
+	arenas     []*arena
+
+	// alignedSlabs defines whether the backend gets wrapped, so it aligns
+	// the slabs to the power of two of their size. slabAlignments has a
+	// bit set for each alignment of the slabs in the lookup table, it is
+	// protected by lookupLock
+	alignedSlabs   bool
+	slabAlignments uintptr
+
+	// slabIDs and slabsByID map the slabs in the lookup table to the ids
+	// which are used by handles, they are protected by lookupLock.
+	// Ids never get reused, so a handle can't refer to a different slab
+	// that got mapped at the same address
+	slabIDs    map[SlabAddr]uint32
+	slabsByID  map[uint32]SlabAddr
+	nextSlabID uint32
+
+	// handleForwards maps the handles of objects which have been moved by
+	// a compaction to the handles of their new slots, it is protected by
+	// lookupLock. Forwards which lead to a deleted object get dropped by
+	// Resolve
+	handleForwards map[ObjHandle]ObjHandle
+
+	// lastSlab caches the slabRange of the slab which has been looked up
+	// most recently, most workloads access the same slab repeatedly
+	lastSlab atomic.Value
+
+	// reclaimEmptySlabs is passed on to the slab pools, it defines
+	// whether empty slabs get unmapped
+	reclaimEmptySlabs bool
+
+	// quarantine is set by WithQuarantine or WithLockFreeReads, it is
+	// shared by the slab pools and tracks the reads begun with BeginRead
+	// and the lock-free reads
+	quarantine *quarantine
+
+	// lockFreeReads is set by WithLockFreeReads, lockFreePools contains
+	// the slab pools by object size for the lock-free searches. It gets
+	// updated whenever slabPools changes
+	lockFreeReads bool
+	lockFreePools [256]atomic.Pointer[slabPool]
+
+	// recycleSlabs is set by WithSlabRecycling, it is passed on to the
+	// slab pools
+	recycleSlabs int
+
+	// backend and mem are passed on to the slab pools, they are used
+	// to allocate slab memory and to enforce the memory limit
+	backend Backend
+	mem     *memAccount
+
+	// metrics gets notified about operations, it may be nil
+	metrics Metrics
+
+	// searchMetrics is metrics if it implements SearchMetrics, otherwise nil
+	searchMetrics SearchMetrics
+
+	// bloomFilters defines whether the slab pools maintain bloom filters
+	bloomFilters bool
+
+	// hashIndex defines whether the slab pools maintain a hash index
+	hashIndex bool
+
+	// detectUseAfterFree defines whether Get validates object addresses
+	detectUseAfterFree bool
+
+	// secureErase is passed on to the slab pools, it defines whether
+	// object memory gets zeroed when it is released
+	secureErase bool
+
+	// canaries defines whether the backend gets wrapped, so it
+	// surrounds the slabs with canary words
+	canaries bool
+
+	// hugePages defines whether the backend gets wrapped, so it maps
+	// large slabs from huge pages
+	hugePages bool
+
+	// mlock defines whether the backend gets wrapped, so it locks the
+	// slab memory into RAM
+	mlock bool
+
+	// maxObjsPerSlab is set by WithAdaptiveObjsPerSlab, it is passed on
+	// to the slab pools
+	maxObjsPerSlab uint
+
+	// growObjsPerSlab is set by WithSlabGrowth, it is passed on to the
+	// slab pools
+	growObjsPerSlab uint
+
+	// releaseFreePages and releaseMaxUsage are set by
+	// WithFreePageRelease, they are passed on to the slab pools
+	releaseFreePages bool
+	releaseMaxUsage  float64
+
+	// numa wraps the backend if WithNUMABinding is used, it keeps the
+	// statistics of the NUMA nodes. It may be nil
+	numa *numaBackend
+
+	// poolOptions are applied to the slab pools of the object sizes
+	// when they get created, it may be nil
+	poolOptions map[uint8][]PoolOption
+
+	// logger is passed on to the slab pools, it receives events about
+	// slabs and failures. It may be nil
+	logger eventLogger
+
+	// writeAheadLog and syncWriteAheadLog are set by WithWriteAheadLog,
+	// wal is the log which is passed on to the slab pools. It is only
+	// created by OpenFileStore, otherwise it is nil
+	writeAheadLog     bool
+	syncWriteAheadLog bool
+	wal               *writeAheadLog
+
+	// skipChecksums defines whether the slab checksums in snapshots are
+	// ignored when they get restored
+	skipChecksums bool
+
+	// snapshotCipher encrypts and decrypts the snapshots if it is set
+	snapshotCipher cipher.AEAD
+
+	// slabListeners and relocationListeners are the callbacks which have
+	// been registered with AddSlabListener and AddRelocationListener,
+	// indexed by their id
+	listenersLock       sync.RWMutex
+	slabListeners       map[int]func(SlabEvent)
+	relocationListeners map[int]func(oldAddr, newAddr ObjAddr)
+	nextListenerID      int
+
+	// subs are the subscriptions to the change feed, see Subscribe
+	subsLock sync.RWMutex
+	subs     map[*subscription]struct{}
+
+	// indexes are the secondary indexes which have been registered with
+	// AddIndex, by their name
+	indexesLock sync.RWMutex
+	indexes     map[string]*secondaryIndex
+}
+
+// NewObjectStore initializes a new object store configured by the given options
+// and returns a pointer to it
+func NewObjectStore(opts ...Option) *ObjectStore {
+	o := &ObjectStore{
+		objsPerSlab:       defaultObjsPerSlab,
+		slabPools:         make(map[uint8]*slabPool),
+		slabIndex:         newSlabIndex(),
+		slabIDs:           make(map[SlabAddr]uint32),
+		slabsByID:         make(map[uint32]SlabAddr),
+		handleForwards:    make(map[ObjHandle]ObjHandle),
+		reclaimEmptySlabs: true,
+		backend:           defaultBackend,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.alignedSlabs && o.backend == defaultBackend {
+		o.backend = newAlignedBackend(o.backend)
+	}
+	if o.hugePages {
+		o.backend = newHugePageBackend(o.backend)
+	}
+	if o.mlock {
+		o.backend = newMlockBackend(o.backend)
+	}
+	if o.numa != nil {
+		o.numa.Backend = o.backend
+		o.backend = o.numa
+	}
+	if o.canaries {
+		o.backend = canaryBackend{o.backend}
+	}
+	return o
 }
 
-// NewObjectStore initializes a new object store with the given number of objects per slab,
-// it returns the object store as a value
-func NewObjectStore(objsPerSlab uint) ObjectStore {
-	return ObjectStore{
-		objsPerSlab: objsPerSlab,
-		slabPools:   make(map[uint8]*slabPool),
+// CheckIntegrity verifies the headers of all slabs, and the canary words
+// around them if they have been enabled with WithCanaries. This helps to
+// detect stray writes of other unsafe code into the slab memory
+// On success it returns nil, otherwise it returns an error about the
+// first corrupted slab that has been found
+func (o *ObjectStore) CheckIntegrity() error {
+	o.poolsLock.RLock()
+	defer o.poolsLock.RUnlock()
+
+	for _, pool := range o.slabPools {
+		if err := pool.checkIntegrity(); err != nil {
+			if o.logger != nil {
+				o.logger.Warn("gos: integrity check failed", "error", err)
+			}
+			return err
+		}
 	}
+
+	return nil
+}
+
+// SetReclaimEmptySlabs defines whether slabs get unmapped as soon as all of
+// their objects have been deleted. By default this is enabled, if it gets
+// disabled then empty slabs are kept and reused by following adds
+func (o *ObjectStore) SetReclaimEmptySlabs(reclaim bool) {
+	o.poolsLock.Lock()
+	defer o.poolsLock.Unlock()
+
+	o.reclaimEmptySlabs = reclaim
+	for _, pool := range o.slabPools {
+		pool.setReclaimEmptySlabs(reclaim)
+	}
+}
+
+// slabRange is the memory range of a slab, start is the slab address
+type slabRange struct {
+	start SlabAddr
+	end   uintptr
 }
 
 // ObjAddr is a uintptr used for storing the addresses of objects in slabs
@@ -159,65 +559,441 @@ func (o *ObjectStore) Add(obj []byte) (ObjAddr, error) {
 
 	// we only deal with objects up to a size of 255
 	if len(obj) == 0 || len(obj) > 255 {
-		return 0, fmt.Errorf("ObjectStore: Add failed because size of object (%d) is outside limits (1-%d)", len(obj), 255)
+		return 0, fmt.Errorf("ObjectStore: Add failed because size of object (%d) is outside limits (1-%d): %w", len(obj), 255, ErrInvalidSize)
 	}
 
 	size := uint8(len(obj))
 
 	// get correct pool based on size of object
-	// if not found, create new pool for that size
-	pool, ok := o.slabPools[size]
-	if !ok {
-		o.addSlabPool(size)
-		pool = o.slabPools[size]
-	}
+	pool := o.acquireSlabPool(size)
 
 	// try to add the object to the pool
 	// there is potential for an error because this involves memory allocations
 	var err error
 	oAddr, sAddr, err = pool.add(obj)
+	o.poolsLock.RUnlock()
 	if err != nil {
 		return 0, err
 	}
 
-	// when sAddr != 0 this indicates that a new slab was created while adding the object
-	// we must update our lookup table to track the new slab
 	if sAddr != 0 {
-		// we keep the lookup table sorted in descending order and insert new entries at an appropriate position
-		insertAt := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] < sAddr })
-		o.lookupTable = append(o.lookupTable, 0)
-		copy(o.lookupTable[insertAt+1:], o.lookupTable[insertAt:])
-		o.lookupTable[insertAt] = sAddr
+		o.slabCreated(size, sAddr)
+	}
+	if o.metrics != nil {
+		o.metrics.OnAdd(size)
+	}
+	o.indexAdded(oAddr, obj)
+	if subscribed, payload := o.hasSubscribers(); subscribed {
+		if payload {
+			payload := make([]byte, len(obj))
+			copy(payload, obj)
+			o.publishChange(ObjAdded, oAddr, size, payload)
+		} else {
+			o.publishChange(ObjAdded, oAddr, size, nil)
+		}
 	}
 
 	return oAddr, nil
 }
 
-// addSlabPool adds a slab pool of the specified size to this object store
+// AddReserve allocates a slot for an object of the given size and returns
+// its address together with a writable, zeroed byte slice that refers to
+// the slot's memory. Producers can serialize objects directly into the
+// slab instead of building a byte slice that Add then copies
+// The returned slice must not be written to after the object has been
+// deleted. AddReserve is not supported if bloom filters or the hash index
+// are enabled. Secondary indexes only index the object once Reindex gets
+// called after its data has been written
+// On failure it returns an error as the third value
+func (o *ObjectStore) AddReserve(size int) (ObjAddr, []byte, error) {
+	// we only deal with objects up to a size of 255
+	if size < 1 || size > 255 {
+		return 0, nil, fmt.Errorf("ObjectStore: AddReserve failed because size of object (%d) is outside limits (1-%d): %w", size, 255, ErrInvalidSize)
+	}
+
+	pool := o.acquireSlabPool(uint8(size))
+	oAddr, obj, sAddr, err := pool.addReserve()
+	o.poolsLock.RUnlock()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if sAddr != 0 {
+		o.slabCreated(uint8(size), sAddr)
+	}
+	if o.metrics != nil {
+		o.metrics.OnAdd(uint8(size))
+	}
+	if subscribed, _ := o.hasSubscribers(); subscribed {
+		o.publishChange(ObjAdded, oAddr, uint8(size), nil)
+	}
+
+	return oAddr, obj, nil
+}
+
+// Reserve pre-creates enough slabs to add n more objects of the given size
+// without creating any further slabs, so bursts of adds don't have to pay
+// the latency of memory allocations
+// Note that empty slabs only get reclaimed when an object gets deleted from
+// them, so reserved slabs stay until they have been used
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) Reserve(size int, n uint) error {
+	// we only deal with objects up to a size of 255
+	if size < 1 || size > 255 {
+		return fmt.Errorf("ObjectStore: Reserve failed because size of object (%d) is outside limits (1-%d): %w", size, 255, ErrInvalidSize)
+	}
+
+	pool := o.acquireSlabPool(uint8(size))
+	sAddrs, err := pool.reserve(n)
+	o.poolsLock.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, sAddr := range sAddrs {
+		o.slabCreated(uint8(size), sAddr)
+	}
+
+	return nil
+}
+
+// AddBatched adds many objects at once, it groups them by size so every
+// slab pool only gets locked once and all the required slabs of a pool
+// are created in one go
+// On success it returns the addresses of the added objects in the same
+// order as the given objects
+// On failure it returns an error and none of the objects have been added
+func (o *ObjectStore) AddBatched(objs [][]byte) ([]ObjAddr, error) {
+	// group the objects by size, we only deal with objects up to a size of 255
+	bySize := make(map[uint8][]int)
+	for i, obj := range objs {
+		if len(obj) == 0 || len(obj) > 255 {
+			return nil, fmt.Errorf("ObjectStore: AddBatched failed because size of object %d (%d) is outside limits (1-%d): %w", i, len(obj), 255, ErrInvalidSize)
+		}
+		size := uint8(len(obj))
+		bySize[size] = append(bySize[size], i)
+	}
+
+	result := make([]ObjAddr, len(objs))
+	var added []ObjAddr
+	for size, idxs := range bySize {
+		batch := make([][]byte, len(idxs))
+		for j, idx := range idxs {
+			batch[j] = objs[idx]
+		}
+
+		pool := o.acquireSlabPool(size)
+		oAddrs, sAddrs, err := pool.addBatched(batch)
+		o.poolsLock.RUnlock()
+
+		if err != nil {
+			// roll back the objects of the sizes that have already been added
+			for _, oAddr := range added {
+				o.Delete(oAddr)
+			}
+			return nil, err
+		}
+
+		for j, idx := range idxs {
+			result[idx] = oAddrs[j]
+		}
+		added = append(added, oAddrs...)
+
+		for _, sAddr := range sAddrs {
+			o.slabCreated(size, sAddr)
+		}
+		if o.metrics != nil {
+			for range oAddrs {
+				o.metrics.OnAdd(size)
+			}
+		}
+		for j, oAddr := range oAddrs {
+			o.indexAdded(oAddr, batch[j])
+		}
+		if subscribed, payload := o.hasSubscribers(); subscribed {
+			for j, oAddr := range oAddrs {
+				var copied []byte
+				if payload {
+					copied = make([]byte, size)
+					copy(copied, batch[j])
+				}
+				o.publishChange(ObjAdded, oAddr, size, copied)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// acquireSlabPool returns the slab pool of the specified size, if it
+// doesn't exist yet then it gets created
+// It returns with the pools read lock held, this prevents Delete from
+// removing the pool from the map while the caller adds to it. The caller
+// must release the lock when it is done with the pool
+func (o *ObjectStore) acquireSlabPool(size uint8) *slabPool {
+	o.poolsLock.RLock()
+	pool, ok := o.slabPools[size]
+	for !ok {
+		o.poolsLock.RUnlock()
+		o.addSlabPool(size)
+		o.poolsLock.RLock()
+		pool, ok = o.slabPools[size]
+	}
+	return pool
+}
+
+// addSlabPool adds a slab pool of the specified size to this object store,
+// unless another goroutine has already added it
 func (o *ObjectStore) addSlabPool(size uint8) {
-	o.slabPools[size] = NewSlabPool(size, o.objsPerSlab)
+	o.poolsLock.Lock()
+	defer o.poolsLock.Unlock()
+
+	if _, ok := o.slabPools[size]; !ok {
+		o.slabPools[size] = o.newSlabPool(size, o.objsPerSlabFor(size))
+		o.lockFreePools[size].Store(o.slabPools[size])
+	}
+}
+
+// objsPerSlabFor returns the number of objects per slab of a new pool for
+// objects of the given size
+func (o *ObjectStore) objsPerSlabFor(size uint8) uint {
+	if o.slabBytes > 0 {
+		return objsPerSlabForSize(size, o.slabBytes)
+	}
+	return o.objsPerSlab
+}
+
+// newSlabPool creates a slab pool which is configured like this object store
+func (o *ObjectStore) newSlabPool(size uint8, objsPerSlab uint) *slabPool {
+	pool := NewSlabPool(size, objsPerSlab)
+	pool.reclaimEmptySlabs = o.reclaimEmptySlabs
+	pool.recycleSlabs = o.recycleSlabs
+	pool.quarantine = o.quarantine
+	pool.lockFreeReads = o.lockFreeReads
+	pool.slabUnlinked = o.slabUnlinked
+	pool.slabLinked = o.lookupTableInsert
+	pool.objMoved = o.objMoved
+	pool.backend = o.backend
+	if a := o.arenaFor(size, objsPerSlab); a != nil {
+		pool.backend = a
+	}
+	pool.mem = o.mem
+	pool.alignedSlabs = o.alignedSlabs
+	pool.secureErase = o.secureErase
+	pool.logger = o.logger
+	pool.wal = o.wal
+	pool.setMaxObjsPerSlab(o.maxObjsPerSlab)
+	pool.setGrowObjsPerSlab(o.growObjsPerSlab)
+	pool.slabGrown = o.slabGrown
+	if o.releaseFreePages {
+		pool.releaseFreePages = true
+		pool.releaseMaxUsage = o.releaseMaxUsage
+	}
+	if o.bloomFilters {
+		pool.enableBloomFilters()
+	}
+	if o.hashIndex {
+		pool.enableHashIndex()
+	}
+	for _, opt := range o.poolOptions[size] {
+		opt(pool)
+	}
+	return pool
+}
+
+// getSlabPool returns the slab pool of the specified size
+// the second returned value indicates whether the pool exists
+func (o *ObjectStore) getSlabPool(size uint8) (*slabPool, bool) {
+	o.poolsLock.RLock()
+	defer o.poolsLock.RUnlock()
+
+	pool, ok := o.slabPools[size]
+	return pool, ok
+}
+
+// lookupTableInsert inserts a new slab address into the lookup table. The
+// slab gets the id which is assigned by the backend if it implements
+// slabIDer, otherwise it gets a new id. The pools call it while they hold
+// their lock, see slabLinked
+func (o *ObjectStore) lookupTableInsert(sAddr SlabAddr) {
+	var id uint32
+	if ider, ok := o.backend.(slabIDer); ok {
+		id, _ = ider.slabID(sAddr)
+	}
+
+	o.lookupLock.Lock()
+	defer o.lookupLock.Unlock()
+
+	// we keep the lookup table sorted in descending order and insert new entries at an appropriate position
+	insertAt := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] < sAddr })
+	o.lookupTable = append(o.lookupTable, 0)
+	copy(o.lookupTable[insertAt+1:], o.lookupTable[insertAt:])
+	o.lookupTable[insertAt] = sAddr
+	o.slabIndex.insert(sAddr, slabFromSlabAddr(sAddr).getTotalLength())
+
+	// slab ids start at 1, so the zero ObjHandle is never valid
+	if _, used := o.slabsByID[id]; id == 0 || used {
+		o.nextSlabID++
+		id = o.nextSlabID
+	} else if id > o.nextSlabID {
+		o.nextSlabID = id
+	}
+	o.slabIDs[sAddr] = id
+	o.slabsByID[id] = sAddr
+	if o.alignedSlabs {
+		o.slabAlignments |= alignmentOf(sAddr)
+	}
+}
+
+// assignSlabID gives the slab at the given address, which is in the lookup
+// table already, the given id. It keeps its current id if that has been
+// assigned by the backend, or if the given id is 0 or in use by another slab
+// It returns the id of the slab
+func (o *ObjectStore) assignSlabID(sAddr SlabAddr, id uint32) uint32 {
+	if ider, ok := o.backend.(slabIDer); ok {
+		if _, ok := ider.slabID(sAddr); ok {
+			id = 0
+		}
+	}
+
+	o.lookupLock.Lock()
+	defer o.lookupLock.Unlock()
+
+	current := o.slabIDs[sAddr]
+	if _, used := o.slabsByID[id]; id == 0 || used {
+		return current
+	}
+
+	delete(o.slabsByID, current)
+	o.slabIDs[sAddr] = id
+	o.slabsByID[id] = sAddr
+	if id > o.nextSlabID {
+		o.nextSlabID = id
+	}
+
+	return id
+}
+
+// isSlab returns true if a slab in the lookup table starts at the given
+// address
+// The caller must hold the lookup lock
+func (o *ObjectStore) isSlab(sAddr SlabAddr) bool {
+	_, ok := o.slabIDs[sAddr]
+	return ok
+}
+
+// slabObjSize returns the object size of the slab in the lookup table which
+// starts at the given address. The header gets read while holding the
+// lookup lock, so the slab can't get unmapped meanwhile
+// The second returned value is false if there is no such slab
+func (o *ObjectStore) slabObjSize(sAddr SlabAddr) (uint8, bool) {
+	o.lookupLock.RLock()
+	defer o.lookupLock.RUnlock()
+
+	if !o.isSlab(sAddr) {
+		return 0, false
+	}
+	return slabFromSlabAddr(sAddr).objSize, true
+}
+
+// slabIDer can be implemented by a Backend which assigns persistent ids to
+// the memory areas it maps, these then get used as slab ids
+type slabIDer interface {
+	slabID(addr SlabAddr) (uint32, bool)
+}
+
+// slabID returns the id of the slab at the given address
+// The second returned value is false if there is no such slab
+func (o *ObjectStore) slabID(slabAddr SlabAddr) (uint32, bool) {
+	o.lookupLock.RLock()
+	defer o.lookupLock.RUnlock()
+
+	id, ok := o.slabIDs[slabAddr]
+	return id, ok
+}
+
+// slabUnlinked removes a slab which its pool is deleting from the lookup
+// table and releases its id. The pool calls it while it holds its lock and
+// before the memory of the slab gets unmapped or retired, so the header of
+// every slab in the lookup table can be read while holding the lookup
+// lock, and a new slab which gets mapped at the same address can't lose
+// its entries to the deleted one
+func (o *ObjectStore) slabUnlinked(slabAddr SlabAddr) {
+	o.lookupLock.Lock()
+	defer o.lookupLock.Unlock()
+
+	o.unlinkSlab(slabAddr)
+	if id, ok := o.slabIDs[slabAddr]; ok {
+		delete(o.slabsByID, id)
+		delete(o.slabIDs, slabAddr)
+	}
+}
+
+// unlinkSlab removes a slab address from the lookup table, the slab index
+// and the cache, so lookups don't find it anymore. The id of the slab
+// stays assigned
+// It returns false if the slab address isn't in the lookup table
+// The caller must hold the lookup lock for writing
+func (o *ObjectStore) unlinkSlab(slabAddr SlabAddr) bool {
+	idx := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] <= slabAddr })
+	if idx >= len(o.lookupTable) || o.lookupTable[idx] != slabAddr {
+		return false
+	}
+	copy(o.lookupTable[idx:], o.lookupTable[idx+1:])
+	o.lookupTable[len(o.lookupTable)-1] = 0
+	o.lookupTable = o.lookupTable[:len(o.lookupTable)-1]
+	o.slabIndex.remove(slabAddr)
+
+	// invalidate the cached slab if it is the deleted one
+	if cached, ok := o.lastSlab.Load().(slabRange); ok && cached.start == slabAddr {
+		o.lastSlab.Store(slabRange{})
+	}
+
+	return true
 }
 
 // Search searches for the given value in the accordingly sized slab pool
 // On success it returns the object address and true
 // On failure it returns 0 and false
 func (o *ObjectStore) Search(searching []byte) (ObjAddr, bool) {
-	var obj ObjAddr
+	obj, found, _ := o.SearchContext(context.Background(), searching)
+	return obj, found
+}
+
+// SearchContext searches for the given value like Search, but the search
+// stops promptly once the given context gets canceled or its deadline
+// gets exceeded, so slow searches through huge pools don't keep running
+// On success it returns the object address, true if it has been found,
+// and nil
+// On failure, if the context has ended before the value has been found,
+// the third returned value is the error of the context
+func (o *ObjectStore) SearchContext(ctx context.Context, searching []byte) (ObjAddr, bool, error) {
+	if o.searchMetrics != nil {
+		start := time.Now()
+		obj, found, err := o.search(ctx, searching)
+		o.searchMetrics.OnSearch(uint8(len(searching)), found, time.Since(start))
+		return obj, found, err
+	}
+
+	return o.search(ctx, searching)
+}
+
+// search implements SearchContext without the metrics
+func (o *ObjectStore) search(ctx context.Context, searching []byte) (ObjAddr, bool, error) {
+	if o.lockFreeReads {
+		return o.searchLockFree(ctx, searching)
+	}
 
 	size := uint8(len(searching))
-	pool, ok := o.slabPools[size]
+	pool, ok := o.getSlabPool(size)
 	if !ok {
 		// there is no pool for the size of the searched object,
 		// so we can directly give up
-		return 0, false
-	}
-
-	obj, success := pool.search(searching)
-	if !success {
-		return 0, false
+		return 0, false, ctx.Err()
 	}
 
-	return obj, true
+	return pool.searchContext(ctx, searching)
 }
 
 // Get retrieves a value by object address
@@ -225,6 +1001,17 @@ func (o *ObjectStore) Search(searching []byte) (ObjAddr, bool) {
 // containing the requested object data
 // On failure the second returned value is the error
 func (o *ObjectStore) Get(obj ObjAddr) ([]byte, error) {
+	if o.detectUseAfterFree {
+		result, err := o.GetChecked(obj)
+		if addrErr, ok := err.(*AddrError); ok && addrErr.freed {
+			panic(fmt.Sprintf("ObjectStore: use after free of slot %d in slab %d, object address %d", addrErr.slot, addrErr.Slab, obj))
+		}
+		return result, err
+	}
+	if o.lockFreeReads {
+		return o.getLockFree(obj)
+	}
+
 	sAddr, err := o.getSlabAddress(obj)
 	if err != nil {
 		return nil, err
@@ -234,52 +1021,358 @@ func (o *ObjectStore) Get(obj ObjAddr) ([]byte, error) {
 	return objFromObjAddr(obj, slab.objSize), nil
 }
 
+// GetInto copies the object at the given object address into dst, which
+// must be large enough to hold the object. Unlike Get it validates that
+// the address refers to a used object slot, and it copies the object
+// while holding the pool lock, so a concurrent Delete can't interfere
+// On success it returns nil, the object has been copied to the start of dst
+// On failure it returns an error
+func (o *ObjectStore) GetInto(obj ObjAddr, dst []byte) error {
+	return o.withCheckedObj(obj, func(pool *slabPool, _ SlabAddr) error {
+		src := pool.get(obj)
+		if len(dst) < len(src) {
+			return fmt.Errorf("ObjectStore: GetInto failed because the destination (%d) is smaller than the object (%d): %w", len(dst), len(src), ErrInvalidSize)
+		}
+		copy(dst, src)
+		return nil
+	})
+}
+
+// GetChecked retrieves a value by object address like Get does, but it
+// first verifies that the address points at the start of a used slot
+// inside a known slab
+// On success it returns a byte slice of appropriate length,
+// containing the requested object data
+// On failure the second returned value is an *AddrError
+func (o *ObjectStore) GetChecked(obj ObjAddr) ([]byte, error) {
+	var result []byte
+	err := o.withCheckedObj(obj, func(pool *slabPool, _ SlabAddr) error {
+		result = pool.get(obj)
+		return nil
+	})
+
+	return result, err
+}
+
+// GetString retrieves a value by object address like Get does, but it
+// returns it as a string which refers directly to the slab memory instead
+// of a copy, so read heavy interning workloads don't pay for a copy per
+// access. The string is only valid until the object gets deleted or moved
+// by a compaction, using it afterwards reads unmapped or reused memory.
+// It must not be retained beyond that, for example as a map key
+// If the address doesn't refer to a slab of the store it returns an empty
+// string, objects are never empty
+func (o *ObjectStore) GetString(obj ObjAddr) string {
+	data, err := o.Get(obj)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+
+	return unsafe.String(&data[0], len(data))
+}
+
+// withCheckedObj validates the given object address and then calls fn with
+// the pool and the address of the slab that contain the object, while
+// holding the pool's read lock
+// On success it returns the value returned by fn
+// On failure it returns an *AddrError describing why the address is invalid
+func (o *ObjectStore) withCheckedObj(obj ObjAddr, fn func(pool *slabPool, slabAddr SlabAddr) error) error {
+	pool, sAddr, err := o.checkedPool(obj)
+	if err != nil {
+		return err
+	}
+
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if err = pool.checkObjAddr(obj, sAddr); err != nil {
+		return err
+	}
+
+	return fn(pool, sAddr)
+}
+
+// checkedPool looks up the pool and the slab which contain the object at
+// the given address, the caller still has to check the address with
+// checkObjAddr while holding the pool lock
+// On success it returns the pool, the slab address and nil
+// On failure the third returned value is an *AddrError
+func (o *ObjectStore) checkedPool(obj ObjAddr) (*slabPool, SlabAddr, error) {
+	var size uint8
+	sAddr, err := o.lookupSlab(obj, func(sl *slab) { size = sl.objSize })
+	if err != nil {
+		return nil, 0, &AddrError{Obj: obj, Reason: "no slab contains the address"}
+	}
+
+	pool, ok := o.getSlabPool(size)
+	if !ok {
+		return nil, 0, &AddrError{Obj: obj, Slab: sAddr, Reason: "no pool contains the slab"}
+	}
+
+	return pool, sAddr, nil
+}
+
+// Contains returns true if the given address refers to a live object in
+// the store, it can be used to validate addresses which have been
+// persisted elsewhere before passing them to Get
+func (o *ObjectStore) Contains(obj ObjAddr) bool {
+	return o.withCheckedObj(obj, func(*slabPool, SlabAddr) error { return nil }) == nil
+}
+
+// Owner reports which slab pool and slab the given address belongs to, the
+// address may point anywhere into the slab's memory, including its header
+// On success it returns the object size of the pool, the slab address and true
+// On failure, if the address is not inside any slab, the third returned value is false
+func (o *ObjectStore) Owner(obj ObjAddr) (uint8, SlabAddr, bool) {
+	var size uint8
+	var end uintptr
+	sAddr, err := o.lookupSlab(obj, func(sl *slab) {
+		size = sl.objSize
+		end = sl.addr() + sl.getTotalLength()
+	})
+	if err != nil || obj >= end {
+		return 0, 0, false
+	}
+
+	return size, sAddr, true
+}
+
+// GetBatched retrieves many values by object address at once. The addresses
+// are processed in sorted order, so objects of the same slab get resolved
+// together while the lookup table and each slab pool only get locked and
+// traversed once
+// On success it returns a slice of byte slices in the same order as the
+// given addresses, containing the requested object data
+// On failure the second returned value is the error
+func (o *ObjectStore) GetBatched(objs []ObjAddr) ([][]byte, error) {
+	// sort the indexes by descending object address, like the lookup table
+	order := make([]int, len(objs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return objs[order[i]] > objs[order[j]] })
+
+	// group the addresses by the object size of their slabs, the headers
+	// only get read while holding the lookup lock
+	bySize := make(map[uint8][]int)
+	err := func() error {
+		o.lookupLock.RLock()
+		defer o.lookupLock.RUnlock()
+
+		// tableIdx only ever moves forward, because the addresses are sorted
+		var tableIdx int
+		var currentSlab *slab
+		for _, i := range order {
+			obj := objs[i]
+			if currentSlab == nil || obj < currentSlab.addr() {
+				remaining := o.lookupTable[tableIdx:]
+				tableIdx += sort.Search(len(remaining), func(j int) bool { return remaining[j] <= obj })
+				if tableIdx >= len(o.lookupTable) {
+					return fmt.Errorf("ObjectStore: GetBatched failed to locate size for the object address %d: %w", obj, ErrInvalidAddr)
+				}
+				currentSlab = slabFromSlabAddr(o.lookupTable[tableIdx])
+			}
+			if obj >= currentSlab.addr()+currentSlab.getTotalLength() {
+				return fmt.Errorf("ObjectStore: GetBatched failed to locate size for the object address %d: %w", obj, ErrInvalidAddr)
+			}
+			bySize[currentSlab.objSize] = append(bySize[currentSlab.objSize], i)
+		}
+		return nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, len(objs))
+	for size, idxs := range bySize {
+		pool, ok := o.getSlabPool(size)
+		if !ok {
+			return nil, fmt.Errorf("ObjectStore: GetBatched failed to find pool of the object address %d: %w", objs[idxs[0]], ErrInvalidAddr)
+		}
+
+		batch := make([]ObjAddr, len(idxs))
+		for j, i := range idxs {
+			batch[j] = objs[i]
+		}
+		pool.lock.RLock()
+		res := pool.getBatched(batch)
+		pool.lock.RUnlock()
+
+		for j, i := range idxs {
+			if res[j] == nil {
+				return nil, fmt.Errorf("ObjectStore: GetBatched failed to locate the object address %d in its slab: %w", objs[i], ErrInvalidAddr)
+			}
+			result[i] = res[j]
+		}
+	}
+
+	return result, nil
+}
+
 // Delete deletes an object by object address
+// If the slab containing the object becomes empty it gets unmapped,
+// unless this has been disabled via SetReclaimEmptySlabs
 // On success it returns nil, otherwise it returns an error message
 func (o *ObjectStore) Delete(obj ObjAddr) error {
+	_, err := o.deleteWith(obj, func(pool *slabPool, slabAddr SlabAddr) (bool, bool, error) {
+		deleted, err := pool.delete(obj, slabAddr)
+		return true, deleted, err
+	})
+	return err
+}
+
+// deleteWith implements Delete, it calls del to delete the object from its
+// pool. del returns whether the object and whether its slab have been
+// deleted, only if the object has been deleted the metrics and the change
+// feed get notified
+// On success it returns whether the object has been deleted and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) deleteWith(obj ObjAddr, del func(pool *slabPool, slabAddr SlabAddr) (bool, bool, error)) (bool, error) {
 	var err error
-	var deleted bool
+	var objDeleted, deleted bool
 	var slabAddr uintptr
 
-	slabAddr, err = o.getSlabAddress(obj)
+	// the header gets read while the slab is in the lookup table, once it
+	// has been removed from it its memory may be unmapped at any time
+	var size uint8
+	var totalBytes uint64
+	slabAddr, err = o.lookupSlab(obj, func(sl *slab) {
+		size = sl.objSize
+		totalBytes = uint64(sl.getTotalLength())
+	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	size := slabFromSlabAddr(slabAddr).objSize
-	deleted, err = o.slabPools[size].delete(obj, slabAddr)
-	if err != nil {
-		return err
+	pool, ok := o.getSlabPool(size)
+	if !ok {
+		return false, fmt.Errorf("ObjectStore: Delete failed to find pool with object size %d: %w", size, ErrNotFound)
 	}
-	if deleted {
-		// remove entry from slabPools
-		if len(o.slabPools[size].slabs) < 1 {
-			delete(o.slabPools, size)
-		}
 
-		// remove entry from lookupTable
-		idx := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] <= slabAddr })
-		ok := idx < len(o.lookupTable) && idx >= 0 && o.lookupTable[idx] == slabAddr
-		if !ok {
-			return fmt.Errorf("ObjectStore: Delete failed to remove slab from lookupTable. Index out of bounds or slab address mismatch. IDX: %d, Target Slab Address: %d, Found Slab Address: %d", idx, slabAddr, o.lookupTable[idx])
+	// the payload must be copied before the object gets deleted
+	var payload []byte
+	subscribed, withPayload := o.hasSubscribers()
+	if withPayload {
+		payload, err = o.GetChecked(obj)
+		if err == nil {
+			payload = append([]byte(nil), payload...)
 		}
-		copy(o.lookupTable[idx:], o.lookupTable[idx+1:])
-		o.lookupTable[len(o.lookupTable)-1] = 0
-		o.lookupTable = o.lookupTable[:len(o.lookupTable)-1]
 	}
 
-	return nil
+	// the object gets removed from the secondary indexes before it gets
+	// deleted, because its address may get reused right afterwards
+	unindexed := o.unindex(obj)
+
+	objDeleted, deleted, err = del(pool, slabAddr)
+	if err != nil || !objDeleted {
+		o.unindexRollback(obj, unindexed)
+		return false, err
+	}
+	if deleted {
+		// the pool has removed the slab from the lookup table already,
+		// only the pool itself may be left to remove
+		o.deleteSlabPoolIfEmpty(size)
+	}
+
+	if o.metrics != nil {
+		o.metrics.OnDelete(size)
+	}
+	if deleted {
+		o.slabDeleted(size, slabAddr, totalBytes)
+	}
+	if subscribed {
+		o.publishChange(ObjDeleted, obj, size, payload)
+	}
+
+	return true, nil
+}
+
+// deleteSlabPoolIfEmpty removes the slab pool of the given size if it has no slabs
+func (o *ObjectStore) deleteSlabPoolIfEmpty(size uint8) {
+	o.poolsLock.Lock()
+	defer o.poolsLock.Unlock()
+
+	pool, ok := o.slabPools[size]
+	if !ok {
+		return
+	}
+
+	// while we hold the pools write lock no Add can be in progress,
+	// so if the pool is empty now it is safe to remove it
+	pool.lock.RLock()
+	empty := len(pool.slabs) < 1
+	pool.lock.RUnlock()
+
+	if empty {
+		delete(o.slabPools, size)
+		o.lockFreePools[size].Store(nil)
+	}
 }
 
-// getObjectSize searches, in a descending order sorted slice, for a slab which is likely to contain
-// the object identified by the given address
+// getSlabAddress looks up the slab which contains the object identified by the given address in the
+// slab index. If the page of the address belongs to no slab or to several ones it searches the
+// descending order sorted lookup table for a slab which is likely to contain the object
 // On success it returns the slab address as SlabAddr and nil
 // On failure it returns 0 and an error
 func (o *ObjectStore) getSlabAddress(obj ObjAddr) (SlabAddr, error) {
+	return o.lookupSlab(obj, nil)
+}
+
+// lookupSlab looks up the slab which contains the given object address like
+// getSlabAddress, and calls fn with the slab unless fn is nil. fn gets
+// called while the lookup lock is held, so the slab can't get unmapped
+// meanwhile and its header can be read safely
+// On success it returns the slab address as SlabAddr and nil
+// On failure it returns 0 and an error
+func (o *ObjectStore) lookupSlab(obj ObjAddr, fn func(sl *slab)) (SlabAddr, error) {
+	o.lookupLock.RLock()
+	defer o.lookupLock.RUnlock()
+
+	sAddr, err := o.slabAddressLocked(obj)
+	if err == nil && fn != nil {
+		fn(slabFromSlabAddr(sAddr))
+	}
+	return sAddr, err
+}
+
+// slabAddressLocked implements getSlabAddress
+// The caller must hold the lookup lock
+func (o *ObjectStore) slabAddressLocked(obj ObjAddr) (SlabAddr, error) {
+
+	// check the most recently used slab first
+	if cached, ok := o.lastSlab.Load().(slabRange); ok && obj >= cached.start && obj < cached.end {
+		return cached.start, nil
+	}
+
+	for _, a := range o.arenas {
+		if sAddr, ok := a.slabOf(obj); ok {
+			return sAddr, nil
+		}
+	}
+
+	// aligned slabs are found by masking the object address
+	if sAddr, ok := maskedSlab(obj, o.slabAlignments, o.isSlab); ok {
+		return sAddr, nil
+	}
+
+	if sAddr, ok := o.slabIndex.lookup(obj); ok {
+		o.lastSlab.Store(slabRange{start: sAddr, end: sAddr + slabFromSlabAddr(sAddr).getTotalLength()})
+		return sAddr, nil
+	}
+
 	idx := sort.Search(len(o.lookupTable), func(i int) bool { return o.lookupTable[i] <= obj })
 	ok := idx < len(o.lookupTable) && idx >= 0
 	if !ok {
-		return 0, fmt.Errorf("ObjectStore: getSlabAddr failed to locate size for the object address")
+		return 0, fmt.Errorf("ObjectStore: getSlabAddr failed to locate size for the object address %d: %w", obj, ErrInvalidAddr)
 	}
-	return o.lookupTable[idx], nil
+
+	// the address may be past the end of the slab below it
+	sAddr := o.lookupTable[idx]
+	end := sAddr + slabFromSlabAddr(sAddr).getTotalLength()
+	if obj >= end {
+		return 0, fmt.Errorf("ObjectStore: getSlabAddr failed to locate size for the object address %d: %w", obj, ErrInvalidAddr)
+	}
+	o.lastSlab.Store(slabRange{start: sAddr, end: end})
+
+	return sAddr, nil
 }
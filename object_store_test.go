@@ -2,9 +2,13 @@ package gos
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
+	"math/rand"
 	"strconv"
+	"sync"
 	"testing"
+	"unsafe"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -18,7 +22,7 @@ func TestAddingGettingObjects13(t *testing.T) {
 }
 
 func testAddingGettingObjects(t *testing.T, objPerSlab, start, stop uint64) {
-	os := NewObjectStore(uint(objPerSlab))
+	os := NewObjectStore(WithObjsPerSlab(uint(objPerSlab)))
 
 	testData := make(map[string]ObjAddr)
 	for i := start; i < stop; i++ {
@@ -46,7 +50,7 @@ func testAddingGettingObjects(t *testing.T, objPerSlab, start, stop uint64) {
 func TestAddingAndDeletingObjects(t *testing.T) {
 	objectsPerSlab := uint(3)
 	expectedSlabs := uint(3)
-	os := NewObjectStore(objectsPerSlab)
+	os := NewObjectStore(WithObjsPerSlab(objectsPerSlab))
 
 	testData := make(map[string]ObjAddr)
 	for i := uint(0); i < objectsPerSlab*expectedSlabs; i++ {
@@ -80,6 +84,38 @@ func TestAddingAndDeletingObjects(t *testing.T) {
 	})
 }
 
+func TestDeletingObjectsWithoutReclaimingSlabs(t *testing.T) {
+	objectsPerSlab := uint(3)
+	os := NewObjectStore(WithObjsPerSlab(objectsPerSlab))
+	os.SetReclaimEmptySlabs(false)
+
+	var objs []ObjAddr
+	Convey("When adding and deleting objects in a store that keeps empty slabs", t, func() {
+		for i := uint(0); i < objectsPerSlab*2; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+		for _, obj := range objs {
+			So(os.Delete(obj), ShouldBeNil)
+		}
+
+		Convey("then the slabs should still exist", func() {
+			So(len(os.slabPools[5].slabs), ShouldEqual, 2)
+			So(len(os.lookupTable), ShouldEqual, 2)
+
+			Convey("and they should get reused for new objects", func() {
+				objAddr, err := os.Add([]byte("abcde"))
+				So(err, ShouldBeNil)
+				res, err := os.Get(objAddr)
+				So(err, ShouldBeNil)
+				So(string(res), ShouldEqual, "abcde")
+				So(len(os.lookupTable), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
 func TestAddingAndDeletingLargeNumberOfObjects(t *testing.T) {
 	objectsPerSlab := uint(100)
 	expectedSlabs := uint(100)
@@ -90,7 +126,7 @@ func TestAddingAndDeletingLargeNumberOfObjects(t *testing.T) {
 		testData[fmt.Sprintf("%0"+strconv.Itoa(objectSizes[i%uint(len(objectSizes))])+"d", i)] = 0
 	}
 
-	os := NewObjectStore(objectsPerSlab)
+	os := NewObjectStore(WithObjsPerSlab(objectsPerSlab))
 
 	Convey("When adding lots of test data to object store", t, func() {
 		for k := range testData {
@@ -123,6 +159,498 @@ func TestAddingAndDeletingLargeNumberOfObjects(t *testing.T) {
 	})
 }
 
+func TestConcurrentAddingGettingDeletingObjects(t *testing.T) {
+	routines := 8
+	objectsPerRoutine := 1000
+	objectSizes := []int{3, 8, 20}
+	os := NewObjectStore(WithObjsPerSlab(10))
+
+	Convey("When adding, getting and deleting objects from many go routines concurrently", t, func() {
+		wg := sync.WaitGroup{}
+		errors := make(chan error, routines)
+		wg.Add(routines)
+		for r := 0; r < routines; r++ {
+			go func(r int) {
+				defer wg.Done()
+				added := make(map[string]ObjAddr)
+				for i := 0; i < objectsPerRoutine; i++ {
+					value := fmt.Sprintf("%0"+strconv.Itoa(objectSizes[i%len(objectSizes)])+"d", r*objectsPerRoutine+i)
+					objAddr, err := os.Add([]byte(value))
+					if err != nil {
+						errors <- err
+						return
+					}
+					added[value] = objAddr
+				}
+				for value, objAddr := range added {
+					res, err := os.Get(objAddr)
+					if err != nil {
+						errors <- err
+						return
+					}
+					if string(res) != value {
+						errors <- fmt.Errorf("Expected %q, got %q", value, string(res))
+						return
+					}
+					if err = os.Delete(objAddr); err != nil {
+						errors <- err
+						return
+					}
+				}
+			}(r)
+		}
+		wg.Wait()
+		close(errors)
+
+		for err := range errors {
+			So(err, ShouldBeNil)
+		}
+
+		Convey("then all slabs should have been deleted", func() {
+			So(len(os.slabPools), ShouldEqual, 0)
+			So(len(os.lookupTable), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestConcurrentSlabReuse(t *testing.T) {
+	Convey("When slabs of different pools get deleted and mapped concurrently", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(1))
+
+		// every add maps a slab and every delete unmaps it, so the slabs
+		// of the pools keep reusing each other's addresses
+		wg := sync.WaitGroup{}
+		errs := make(chan error, 8)
+		for g := 1; g <= 8; g++ {
+			wg.Add(1)
+			go func(size int) {
+				defer wg.Done()
+				value := make([]byte, size)
+				for i := 0; i < 5000; i++ {
+					obj, err := os.Add(value)
+					if err != nil {
+						errs <- err
+						return
+					}
+					if !os.Contains(obj) {
+						errs <- fmt.Errorf("object %d of size %d is not in the store", obj, size)
+						return
+					}
+					if err := os.Delete(obj); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the lookup structures should only refer to the remaining slabs", func() {
+			So(os.lookupTable, ShouldBeEmpty)
+			So(os.slabIDs, ShouldBeEmpty)
+			So(os.slabsByID, ShouldBeEmpty)
+
+			obj, err := os.Add([]byte("abc"))
+			So(err, ShouldBeNil)
+			info := os.Slabs()
+			So(info, ShouldHaveLength, 1)
+			So(info[0].ID, ShouldNotEqual, 0)
+			So(os.Contains(obj), ShouldBeTrue)
+		})
+	})
+}
+
+func TestLastSlabCache(t *testing.T) {
+	Convey("When getting an object from the store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		obj1, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("def"))
+		So(err, ShouldBeNil)
+		_, err = os.Get(obj1)
+		So(err, ShouldBeNil)
+
+		Convey("its slab should be cached", func() {
+			cached := os.lastSlab.Load().(slabRange)
+			So(cached.start, ShouldEqual, os.lookupTable[0])
+			So(obj2, ShouldBeBetween, cached.start, cached.end)
+
+			Convey("and the cache should be invalidated when the slab gets deleted", func() {
+				So(os.Delete(obj1), ShouldBeNil)
+				So(os.Delete(obj2), ShouldBeNil)
+				So(os.lastSlab.Load().(slabRange), ShouldResemble, slabRange{})
+			})
+		})
+	})
+}
+
+func TestSlabInfo(t *testing.T) {
+	Convey("When adding 3 objects to a store with 5 objects per slab", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(5))
+		var objs []ObjAddr
+		for i := 0; i < 3; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+
+		Convey("the slab info should report 2 free slots", func() {
+			info, err := os.SlabInfo(os.lookupTable[0])
+			So(err, ShouldBeNil)
+			So(info.Created.IsZero(), ShouldBeFalse)
+			So(info, ShouldResemble, SlabInfo{
+				Addr:        os.lookupTable[0],
+				ID:          1,
+				ObjSize:     3,
+				Capacity:    5,
+				FreeSlots:   2,
+				LiveObjects: 3,
+				TotalBytes:  uint64(slabSize(3, 5)),
+				Created:     info.Created,
+			})
+
+			Convey("and 3 free slots after deleting an object", func() {
+				So(os.Delete(objs[0]), ShouldBeNil)
+				info, err := os.SlabInfo(os.lookupTable[0])
+				So(err, ShouldBeNil)
+				So(info.FreeSlots, ShouldEqual, 3)
+			})
+		})
+
+		Convey("the slab objects should be the added objects", func() {
+			slabObjs, err := os.SlabObjects(os.lookupTable[0])
+			So(err, ShouldBeNil)
+			So(slabObjs, ShouldResemble, objs)
+
+			_, err = os.SlabObjects(1)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("requesting the info of an unknown slab should fail", func() {
+			_, err := os.SlabInfo(objs[0])
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSlabs(t *testing.T) {
+	Convey("When adding objects of different sizes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		for _, value := range []string{"abc", "def", "ghi", "abcd"} {
+			_, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("Slabs should list all slabs sorted by address", func() {
+			slabs := os.Slabs()
+			So(len(slabs), ShouldEqual, 3)
+			var live uint
+			for i, info := range slabs {
+				So(info.Addr, ShouldEqual, os.lookupTable[len(slabs)-1-i])
+				So(info.TotalBytes, ShouldEqual, slabSize(info.ObjSize, 2))
+				live += info.LiveObjects
+			}
+			So(live, ShouldEqual, 4)
+		})
+	})
+}
+
+func TestMemStats(t *testing.T) {
+	Convey("When adding objects of two sizes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(10))
+		for i := 0; i < 15; i++ {
+			_, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+		}
+		for i := 0; i < 4; i++ {
+			_, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("MemStats should report the usage per pool and in total", func() {
+			stats := os.MemStats()
+			So(len(stats.Pools), ShouldEqual, 2)
+			So(stats.Pools[0], ShouldResemble, PoolMemStats{
+				ObjSize:      3,
+				Slabs:        2,
+				MappedBytes:  2 * uint64(slabSize(3, 10)),
+				LiveObjects:  15,
+				FreeSlots:    5,
+				PaddingBytes: 2 * (uint64(systemPageSize) - uint64(slabSize(3, 10))),
+			})
+			So(stats.Pools[1].Slabs, ShouldEqual, 1)
+			So(stats.Pools[1].LiveObjects, ShouldEqual, 4)
+			So(stats.Slabs, ShouldEqual, 3)
+			So(stats.LiveObjects, ShouldEqual, 19)
+			So(stats.FreeSlots, ShouldEqual, 11)
+			total, err := os.MemStatsTotal()
+			So(err, ShouldBeNil)
+			So(stats.MappedBytes, ShouldEqual, total)
+		})
+	})
+}
+
+func TestFragmentation(t *testing.T) {
+	Convey("When filling three slabs and deleting objects from two of them", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 12; i++ {
+			obj, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		for _, obj := range []ObjAddr{objs[0], objs[1], objs[2], objs[4]} {
+			So(os.Delete(obj), ShouldBeNil)
+		}
+
+		Convey("the ratio and the sparsest slabs should be reported", func() {
+			frag := os.Fragmentation(2)
+			So(len(frag), ShouldEqual, 1)
+			So(frag[0].ObjSize, ShouldEqual, 3)
+			So(frag[0].Ratio, ShouldAlmostEqual, 4.0/12.0)
+			So(len(frag[0].SparsestSlabs), ShouldEqual, 2)
+			So(frag[0].SparsestSlabs[0].LiveObjects, ShouldEqual, 1)
+			So(frag[0].SparsestSlabs[1].LiveObjects, ShouldEqual, 3)
+		})
+	})
+}
+
+func TestAddingObjectsBatchedToStore(t *testing.T) {
+	Convey("When adding a batch of objects of varying sizes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var batch [][]byte
+		for i := 0; i < 30; i++ {
+			batch = append(batch, []byte(fmt.Sprintf("%0"+strconv.Itoa(i%3+3)+"d", i)))
+		}
+		objAddrs, err := os.AddBatched(batch)
+		So(err, ShouldBeNil)
+
+		Convey("we should be able to get all of them", func() {
+			So(len(objAddrs), ShouldEqual, len(batch))
+			for i, objAddr := range objAddrs {
+				res, err := os.Get(objAddr)
+				So(err, ShouldBeNil)
+				So(string(res), ShouldEqual, string(batch[i]))
+			}
+			So(len(os.lookupTable), ShouldEqual, 9)
+		})
+	})
+
+	Convey("When adding a batch containing an invalid object", t, func() {
+		os := NewObjectStore()
+		_, err := os.AddBatched([][]byte{[]byte("abc"), {}})
+
+		Convey("it should fail without adding anything", func() {
+			So(err, ShouldNotBeNil)
+			So(len(os.lookupTable), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestGettingObjectsBatched(t *testing.T) {
+	Convey("When adding objects of varying sizes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(3))
+		var values []string
+		var objAddrs []ObjAddr
+		for i := 0; i < 50; i++ {
+			value := fmt.Sprintf("%0"+strconv.Itoa(i%4+2)+"d", i)
+			objAddr, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			values = append(values, value)
+			objAddrs = append(objAddrs, objAddr)
+		}
+
+		Convey("we should be able to get all of them in one batch", func() {
+			res, err := os.GetBatched(objAddrs)
+			So(err, ShouldBeNil)
+			So(len(res), ShouldEqual, len(values))
+			for i := range values {
+				So(string(res[i]), ShouldEqual, values[i])
+			}
+		})
+
+		Convey("the results should be in the order of unsorted and repeated addresses", func() {
+			var expected []string
+			var shuffled []ObjAddr
+			for _, i := range rand.Perm(len(objAddrs)) {
+				expected = append(expected, values[i], values[i])
+				shuffled = append(shuffled, objAddrs[i], objAddrs[i])
+			}
+			res, err := os.GetBatched(shuffled)
+			So(err, ShouldBeNil)
+			So(len(res), ShouldEqual, len(expected))
+			for i := range expected {
+				So(string(res[i]), ShouldEqual, expected[i])
+			}
+		})
+
+		Convey("getting an unknown address should fail", func() {
+			_, err := os.GetBatched([]ObjAddr{objAddrs[0], 1})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("getting an address outside of the data of a slab should fail", func() {
+			for _, info := range os.Slabs() {
+				_, err := os.GetBatched([]ObjAddr{objAddrs[0], info.Addr + ObjAddr(info.TotalBytes)})
+				So(errors.Is(err, ErrInvalidAddr), ShouldBeTrue)
+				_, err = os.GetBatched([]ObjAddr{info.Addr})
+				So(errors.Is(err, ErrInvalidAddr), ShouldBeTrue)
+			}
+		})
+	})
+}
+
+func TestAddReserve(t *testing.T) {
+	Convey("When reserving a slot and writing into it", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		objAddr, obj, err := os.AddReserve(5)
+		So(err, ShouldBeNil)
+		So(len(obj), ShouldEqual, 5)
+		So(obj, ShouldResemble, make([]byte, 5))
+		copy(obj, "abcde")
+
+		Convey("the object should be stored in the slab", func() {
+			res, err := os.Get(objAddr)
+			So(err, ShouldBeNil)
+			So(string(res), ShouldEqual, "abcde")
+			found, ok := os.Search([]byte("abcde"))
+			So(ok, ShouldBeTrue)
+			So(found, ShouldEqual, objAddr)
+		})
+
+		Convey("a reused slot should be zeroed again", func() {
+			So(os.Delete(objAddr), ShouldBeNil)
+			os.Add([]byte("xxxxx"))
+			other, err := os.Add([]byte("yyyyy"))
+			So(err, ShouldBeNil)
+			So(os.Delete(other), ShouldBeNil)
+			_, obj, err := os.AddReserve(5)
+			So(err, ShouldBeNil)
+			So(obj, ShouldResemble, make([]byte, 5))
+		})
+	})
+
+	Convey("When reserving a slot with an invalid size or bloom filters", t, func() {
+		_, _, err := NewObjectStore().AddReserve(0)
+		So(err, ShouldNotBeNil)
+		_, _, err = NewObjectStore(WithBloomFilters()).AddReserve(5)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestReserve(t *testing.T) {
+	Convey("When reserving capacity for 25 objects with 10 objects per slab", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(10))
+		So(os.Reserve(5, 25), ShouldBeNil)
+
+		Convey("3 slabs should have been created", func() {
+			So(len(os.lookupTable), ShouldEqual, 3)
+
+			Convey("and adding 25 objects should not create more slabs", func() {
+				for i := 0; i < 25; i++ {
+					objAddr, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+					So(err, ShouldBeNil)
+					res, err := os.Get(objAddr)
+					So(err, ShouldBeNil)
+					So(string(res), ShouldEqual, fmt.Sprintf("%05d", i))
+				}
+				So(len(os.lookupTable), ShouldEqual, 3)
+			})
+
+			Convey("reserving less than the free capacity should not create slabs", func() {
+				So(os.Reserve(5, 30), ShouldBeNil)
+				So(len(os.lookupTable), ShouldEqual, 3)
+			})
+		})
+	})
+
+	Convey("When reserving more than the memory limit allows", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(10), WithMaxMemory(uint64(slabSize(5, 10))))
+		err := os.Reserve(5, 11)
+
+		Convey("it should fail without creating any slabs", func() {
+			So(err, ShouldNotBeNil)
+			So(len(os.lookupTable), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestGetInto(t *testing.T) {
+	Convey("When adding objects to the store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		obj1, err := os.Add([]byte("abcde"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("fghij"))
+		So(err, ShouldBeNil)
+
+		Convey("we should be able to copy them into a buffer", func() {
+			dst := make([]byte, 10)
+			So(os.GetInto(obj2, dst), ShouldBeNil)
+			So(string(dst[:5]), ShouldEqual, "fghij")
+		})
+
+		Convey("a buffer that is too small should be rejected", func() {
+			So(os.GetInto(obj1, make([]byte, 4)), ShouldNotBeNil)
+		})
+
+		Convey("invalid addresses should be rejected", func() {
+			dst := make([]byte, 10)
+			So(os.GetInto(obj1+1, dst), ShouldNotBeNil)
+			So(os.GetInto(obj2+5, dst), ShouldNotBeNil)
+			So(os.GetInto(os.lookupTable[0], dst), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetChecked(t *testing.T) {
+	Convey("When adding objects to the store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		obj1, err := os.Add([]byte("abcde"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("fghij"))
+		So(err, ShouldBeNil)
+
+		Convey("valid addresses should return the objects", func() {
+			obj, err := os.GetChecked(obj1)
+			So(err, ShouldBeNil)
+			So(string(obj), ShouldEqual, "abcde")
+			obj, err = os.GetChecked(obj2)
+			So(err, ShouldBeNil)
+			So(string(obj), ShouldEqual, "fghij")
+		})
+
+		Convey("invalid addresses should return an AddrError", func() {
+			for _, addr := range []ObjAddr{0, obj1 + 2, obj2 + 5, os.lookupTable[0]} {
+				_, err := os.GetChecked(addr)
+				So(err, ShouldHaveSameTypeAs, &AddrError{})
+				So(err.(*AddrError).Obj, ShouldEqual, addr)
+			}
+		})
+	})
+}
+
+func TestGetString(t *testing.T) {
+	Convey("When adding objects to the store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		obj, err := os.Add([]byte("abcde"))
+		So(err, ShouldBeNil)
+
+		Convey("they should be returned as strings which refer to the slab memory", func() {
+			s := os.GetString(obj)
+			So(s, ShouldEqual, "abcde")
+			So(ObjAddr(uintptr(unsafe.Pointer(unsafe.StringData(s)))), ShouldEqual, obj)
+		})
+
+		Convey("an address outside of the store should return an empty string", func() {
+			So(os.GetString(0), ShouldEqual, "")
+		})
+	})
+}
+
 func TestMemStats63Objects(t *testing.T) {
 	objectsPerSlab := uint(63)
 	objectSize := uint8(10)
@@ -131,7 +659,7 @@ func TestMemStats63Objects(t *testing.T) {
 		[]byte("1234567890"),
 	}
 
-	os := NewObjectStore(objectsPerSlab)
+	os := NewObjectStore(WithObjsPerSlab(objectsPerSlab))
 	for _, o := range objects {
 		os.Add(o)
 	}
@@ -151,7 +679,7 @@ func TestMemStats65Objects(t *testing.T) {
 		[]byte("1234567890"),
 	}
 
-	os := NewObjectStore(objectsPerSlab)
+	os := NewObjectStore(WithObjsPerSlab(objectsPerSlab))
 	for _, o := range objects {
 		os.Add(o)
 	}
@@ -164,7 +692,7 @@ func TestMemStats65Objects(t *testing.T) {
 }
 
 func BenchmarkAddingDeleting(b *testing.B) {
-	os := NewObjectStore(100)
+	os := NewObjectStore(WithObjsPerSlab(100))
 
 	testData := make(map[string]ObjAddr)
 	for i := 0; i < 99999; i++ {
@@ -184,10 +712,43 @@ func BenchmarkAddingDeleting(b *testing.B) {
 	}
 }
 
+func BenchmarkGettingObjectsSequentially(b *testing.B) {
+	benchmarkGettingObjects(b, false)
+}
+
+func BenchmarkGettingObjectsRandomly(b *testing.B) {
+	benchmarkGettingObjects(b, true)
+}
+
+func benchmarkGettingObjects(b *testing.B, random bool) {
+	os := NewObjectStore(WithObjsPerSlab(100))
+	objCount := 100000
+	objs := make([]ObjAddr, objCount)
+	for i := range objs {
+		objs[i], _ = os.Add([]byte(fmt.Sprintf("%010d", i)))
+	}
+	order := make([]int, objCount)
+	for i := range order {
+		order[i] = i
+	}
+	if random {
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := os.Get(objs[order[n%objCount]]); err != nil {
+			b.Fatalf("Get failed: %s", err)
+		}
+	}
+}
+
 func BenchmarkSearchingForValue(b *testing.B) {
 	testValueCount := 1000000
 	testValues := make([][]byte, testValueCount)
-	os := NewObjectStore(100)
+	os := NewObjectStore(WithObjsPerSlab(100))
 
 	for i := 0; i < testValueCount; i++ {
 		testValues[i] = []byte(fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%d", i)))))
@@ -204,3 +765,95 @@ func BenchmarkSearchingForValue(b *testing.B) {
 		}
 	}
 }
+
+func TestCheckIntegrity(t *testing.T) {
+	Convey("When adding objects to a store with canaries", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4), WithCanaries())
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def", "ghi", "jkl", "mno", "pqr"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(os.CheckIntegrity(), ShouldBeNil)
+
+		Convey("objects should be deletable and the store should stay intact", func() {
+			So(os.Delete(objs[1]), ShouldBeNil)
+			So(os.CheckIntegrity(), ShouldBeNil)
+			for _, obj := range objs[4:] {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(os.CheckIntegrity(), ShouldBeNil)
+		})
+
+		Convey("a write past the end of a slab should be detected", func() {
+			sAddr, err := os.getSlabAddress(objs[0])
+			So(err, ShouldBeNil)
+			objFromObjAddr(sAddr+slabFromSlabAddr(sAddr).getTotalLength(), 1)[0] ^= 0xff
+			So(os.CheckIntegrity(), ShouldNotBeNil)
+		})
+
+		Convey("a corrupted slab header should be detected", func() {
+			sAddr, err := os.getSlabAddress(objs[4])
+			So(err, ShouldBeNil)
+			slabFromSlabAddr(sAddr).bitSet().Set(3)
+			So(os.CheckIntegrity(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestOwner(t *testing.T) {
+	Convey("When adding objects of different sizes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		obj1, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("abcdefgh"))
+		So(err, ShouldBeNil)
+
+		Convey("their owners should be the pools of their sizes", func() {
+			size, slab, ok := os.Owner(obj1)
+			So(ok, ShouldBeTrue)
+			So(size, ShouldEqual, 3)
+			So(slab, ShouldEqual, obj1-slabFromSlabAddr(slab).getDataOffset())
+
+			size, slab, ok = os.Owner(obj2 + 7)
+			So(ok, ShouldBeTrue)
+			So(size, ShouldEqual, 8)
+			So(slab, ShouldEqual, obj2-slabFromSlabAddr(slab).getDataOffset())
+		})
+
+		Convey("addresses outside of the slabs should have no owner", func() {
+			_, _, ok := os.Owner(1)
+			So(ok, ShouldBeFalse)
+			_, _, ok = os.Owner(obj2 + 16)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestContains(t *testing.T) {
+	Convey("When adding objects to the store", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		obj1, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("def"))
+		So(err, ShouldBeNil)
+
+		Convey("the store should contain them", func() {
+			So(os.Contains(obj1), ShouldBeTrue)
+			So(os.Contains(obj2), ShouldBeTrue)
+		})
+
+		Convey("the store shouldn't contain other addresses", func() {
+			So(os.Contains(0), ShouldBeFalse)
+			So(os.Contains(obj1+1), ShouldBeFalse)
+			So(os.Contains(obj2+3), ShouldBeFalse)
+		})
+
+		Convey("the store shouldn't contain deleted objects", func() {
+			So(os.Delete(obj1), ShouldBeNil)
+			So(os.Contains(obj1), ShouldBeFalse)
+			So(os.Contains(obj2), ShouldBeTrue)
+		})
+	})
+}
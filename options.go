@@ -0,0 +1,267 @@
+package gos
+
+import (
+	"crypto/cipher"
+	"sync/atomic"
+	"time"
+)
+
+// defaultObjsPerSlab is the number of objects per slab that is used
+// if WithObjsPerSlab is not given
+const defaultObjsPerSlab = 100
+
+// Option configures an ObjectStore, it is passed to NewObjectStore
+type Option func(*ObjectStore)
+
+// WithObjsPerSlab sets the number of objects that each slab can hold,
+// the default is 100
+func WithObjsPerSlab(objsPerSlab uint) Option {
+	return func(o *ObjectStore) {
+		o.objsPerSlab = objsPerSlab
+	}
+}
+
+// WithSlabSize sets the size of the slabs in bytes instead of their number
+// of objects, it overrides WithObjsPerSlab. The size gets rounded up to a
+// multiple of the page size, then each pool fits as many objects into its
+// slabs as possible, so the last page of a slab isn't mostly wasted
+func WithSlabSize(totalBytes int) Option {
+	return func(o *ObjectStore) {
+		pageSize := systemPageSize
+		o.slabBytes = (totalBytes + pageSize - 1) / pageSize * pageSize
+	}
+}
+
+// WithMaxMemory limits the total memory that is used by all slabs of the
+// store to the given number of bytes. Once the limit has been reached,
+// adding objects which require a new slab fails
+func WithMaxMemory(maxBytes uint64) Option {
+	return func(o *ObjectStore) {
+		o.mem = &memAccount{max: maxBytes}
+	}
+}
+
+// WithBackend sets the backend that is used to allocate the slab memory,
+// by default MmapBackend is used
+func WithBackend(backend Backend) Option {
+	return func(o *ObjectStore) {
+		o.backend = backend
+	}
+}
+
+// WithGuardPages makes the store use the GuardPageBackend, so buffer
+// overruns past the end of a slab fault immediately. It overrides the
+// backend given by WithBackend
+func WithGuardPages() Option {
+	return func(o *ObjectStore) {
+		o.backend = GuardPageBackend{}
+	}
+}
+
+// WithHugePages makes the store map slabs of at least one huge page, which
+// is 2MB on most systems, from huge pages on Linux. This reduces the TLB
+// misses when accessing stores with many objects. The slab size is defined
+// by WithSlabSize, or by the object size and WithObjsPerSlab.
+// Huge pages must have been reserved in /proc/sys/vm/nr_hugepages, if
+// there are none left the slabs get mapped from regular pages. It only
+// affects the default backend
+func WithHugePages() Option {
+	return func(o *ObjectStore) {
+		o.hugePages = true
+	}
+}
+
+// WithMlock locks the memory of all slabs into RAM, so the OS can't swap
+// out object data. It is only supported on Linux, on other platforms adding
+// objects fails. The locked memory is accounted against RLIMIT_MEMLOCK,
+// creating a slab which would exceed it fails with an error which wraps
+// ErrMemlockLimit, unless the limit is unlimited
+func WithMlock() Option {
+	return func(o *ObjectStore) {
+		o.mlock = true
+	}
+}
+
+// WithCanaries surrounds each slab with canary words, which get verified
+// by CheckIntegrity. It can be combined with any backend
+func WithCanaries() Option {
+	return func(o *ObjectStore) {
+		o.canaries = true
+	}
+}
+
+// WithMetrics sets a Metrics implementation which gets notified about
+// the operations of the store. If it also implements SearchMetrics, then
+// it gets notified about searches as well
+func WithMetrics(metrics Metrics) Option {
+	return func(o *ObjectStore) {
+		o.metrics = metrics
+		o.searchMetrics, _ = metrics.(SearchMetrics)
+	}
+}
+
+// WithReclaimEmptySlabs defines whether slabs get unmapped as soon as they
+// become empty, the default is true. See SetReclaimEmptySlabs
+func WithReclaimEmptySlabs(reclaim bool) Option {
+	return func(o *ObjectStore) {
+		o.reclaimEmptySlabs = reclaim
+	}
+}
+
+// WithSlabRecycling keeps up to the given number of empty slabs per pool
+// mapped when slabs get reclaimed, the next adds reuse them before any slab
+// gets created. Workloads which oscillate between high and low occupancy
+// then don't map and unmap slabs over and over. The kept slabs still count
+// as slabs of the store and towards the memory limit
+func WithSlabRecycling(n int) Option {
+	return func(o *ObjectStore) {
+		o.recycleSlabs = n
+	}
+}
+
+// WithBloomFilters enables a bloom filter per slab, which gets updated on
+// every add and delete. Searches consult the bloom filters to skip slabs
+// that can't contain the searched value. This speeds up searches for rare
+// values in stores with many slabs, at the cost of slower adds and deletes
+// and 10 bytes of heap memory per object slot
+func WithBloomFilters() Option {
+	return func(o *ObjectStore) {
+		o.bloomFilters = true
+	}
+}
+
+// WithHashIndex enables a hash index per pool, which maps the hashes of
+// the objects to their addresses and gets updated on every add and delete.
+// Searches and AddOrGet look the searched value up in the hash index
+// instead of comparing it with the objects of all slabs, at the cost of
+// slower adds and deletes and the heap memory of the index
+func WithHashIndex() Option {
+	return func(o *ObjectStore) {
+		o.hashIndex = true
+	}
+}
+
+// WithUseAfterFreeDetection enables a debug mode in which Get validates
+// every object address. Get panics with the slab address and the slot
+// index if the address refers to a slot whose object has been deleted,
+// for other invalid addresses it returns an *AddrError. This makes Get
+// considerably slower, so it is meant to be used in tests
+func WithUseAfterFreeDetection() Option {
+	return func(o *ObjectStore) {
+		o.detectUseAfterFree = true
+	}
+}
+
+// WithSecureErase makes the store zero the memory of an object when it
+// gets deleted and the memory of a slab before it gets unmapped. This is
+// meant for stores that hold sensitive data like credentials, which
+// shouldn't linger in memory that has been released
+func WithSecureErase() Option {
+	return func(o *ObjectStore) {
+		o.secureErase = true
+	}
+}
+
+// WithWriteAheadLog makes a store that gets opened by OpenFileStore record
+// every add and delete in a write-ahead log before it gets applied. If sync
+// is true the log gets flushed to disk after each record, this is slow but
+// protects against power failures, otherwise it only protects against
+// process crashes. The log gets truncated by FileBackend.Sync
+func WithWriteAheadLog(sync bool) Option {
+	return func(o *ObjectStore) {
+		o.writeAheadLog = true
+		o.syncWriteAheadLog = sync
+	}
+}
+
+// WithoutChecksumVerification makes Restore and ApplyIncremental skip the
+// verification of the slab checksums in snapshots. This makes restoring
+// faster, but corrupted slabs get loaded silently
+func WithoutChecksumVerification() Option {
+	return func(o *ObjectStore) {
+		o.skipChecksums = true
+	}
+}
+
+// WithSnapshotCipher makes WriteTo and WriteIncremental encrypt the
+// snapshots with the given AEAD, and Restore and ApplyIncremental decrypt
+// them. Unencrypted snapshots get refused by Restore and ApplyIncremental.
+// For AES-GCM the AEAD can be created with cipher.NewGCM from an AES block
+// cipher, any other AEAD with a nonce size of at least 8 bytes works too.
+// Encrypted snapshots can't be opened by OpenSnapshot
+func WithSnapshotCipher(aead cipher.AEAD) Option {
+	return func(o *ObjectStore) {
+		o.snapshotCipher = aead
+	}
+}
+
+// eventLogger is the subset of the methods of *slog.Logger that the store
+// uses to log events, see WithLogger
+type eventLogger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// Metrics receives notifications about the operations of an ObjectStore,
+// implementations must be safe for concurrent use
+type Metrics interface {
+	// OnAdd is called after an object of the given size has been added
+	OnAdd(objSize uint8)
+
+	// OnDelete is called after an object of the given size has been deleted
+	OnDelete(objSize uint8)
+
+	// OnSlabCreated is called after a slab has been created
+	OnSlabCreated(objSize uint8, slab SlabAddr)
+
+	// OnSlabDeleted is called after a slab has been deleted
+	OnSlabDeleted(objSize uint8, slab SlabAddr)
+}
+
+// SearchMetrics can be implemented in addition to Metrics to get notified
+// about searches, the store only measures the search latency if it does
+type SearchMetrics interface {
+	// OnSearch is called after a search for an object of the given size,
+	// found tells whether the object has been found
+	OnSearch(objSize uint8, found bool, latency time.Duration)
+}
+
+// Instrumentation is the complete set of hooks that the store calls, it
+// can be passed to WithMetrics. See the gosotel package for an adapter to
+// OpenTelemetry metrics and the gosprom package for Prometheus
+type Instrumentation interface {
+	Metrics
+	SearchMetrics
+}
+
+// memAccount keeps track of the memory used by slabs and enforces a limit,
+// a nil *memAccount accepts everything
+type memAccount struct {
+	used uint64
+	max  uint64
+}
+
+// reserve tries to account the given number of bytes
+// It returns false if that would exceed the limit
+func (m *memAccount) reserve(size uint64) bool {
+	if m == nil {
+		return true
+	}
+	for {
+		used := atomic.LoadUint64(&m.used)
+		if used+size > m.max {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&m.used, used, used+size) {
+			return true
+		}
+	}
+}
+
+// release gives the given number of bytes back to the account
+func (m *memAccount) release(size uint64) {
+	if m == nil {
+		return
+	}
+	atomic.AddUint64(&m.used, ^(size - 1))
+}
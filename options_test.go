@@ -0,0 +1,274 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// countingBackend wraps MmapBackend and counts the mapped memory areas
+type countingBackend struct {
+	MmapBackend
+	mapped int64
+}
+
+func (c *countingBackend) Map(size int) ([]byte, error) {
+	atomic.AddInt64(&c.mapped, 1)
+	return c.MmapBackend.Map(size)
+}
+
+func (c *countingBackend) Unmap(data []byte) error {
+	atomic.AddInt64(&c.mapped, -1)
+	return c.MmapBackend.Unmap(data)
+}
+
+// countingMetrics counts the notifications it receives
+type countingMetrics struct {
+	adds, deletes, slabsCreated, slabsDeleted int64
+}
+
+func (c *countingMetrics) OnAdd(objSize uint8)    { atomic.AddInt64(&c.adds, 1) }
+func (c *countingMetrics) OnDelete(objSize uint8) { atomic.AddInt64(&c.deletes, 1) }
+func (c *countingMetrics) OnSlabCreated(objSize uint8, _ SlabAddr) {
+	atomic.AddInt64(&c.slabsCreated, 1)
+}
+func (c *countingMetrics) OnSlabDeleted(objSize uint8, _ SlabAddr) {
+	atomic.AddInt64(&c.slabsDeleted, 1)
+}
+
+// copyingBackend wraps MmapBackend and keeps a copy of the content
+// of each memory area before it gets unmapped
+type copyingBackend struct {
+	MmapBackend
+	unmapped [][]byte
+}
+
+func (c *copyingBackend) Unmap(data []byte) error {
+	c.unmapped = append(c.unmapped, append([]byte(nil), data...))
+	return c.MmapBackend.Unmap(data)
+}
+
+// searchCountingMetrics additionally counts the searches
+type searchCountingMetrics struct {
+	countingMetrics
+	searches, found int64
+}
+
+func (c *searchCountingMetrics) OnSearch(objSize uint8, found bool, latency time.Duration) {
+	atomic.AddInt64(&c.searches, 1)
+	if found {
+		atomic.AddInt64(&c.found, 1)
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	Convey("When creating an object store without options", t, func() {
+		os := NewObjectStore()
+
+		Convey("it should use the default settings", func() {
+			So(os.objsPerSlab, ShouldEqual, defaultObjsPerSlab)
+			So(os.reclaimEmptySlabs, ShouldBeTrue)
+			So(os.backend, ShouldResemble, defaultBackend)
+			So(os.mem, ShouldBeNil)
+			So(os.metrics, ShouldBeNil)
+		})
+	})
+}
+
+func TestMaxMemoryOption(t *testing.T) {
+	objsPerSlab := uint(10)
+	objSize := uint8(5)
+	limit := uint64(2 * slabSize(objSize, objsPerSlab))
+
+	Convey("When limiting the memory to two slabs", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(objsPerSlab), WithMaxMemory(limit))
+
+		var objs []ObjAddr
+		for i := uint(0); i < 2*objsPerSlab; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+
+		Convey("adding an object that requires a third slab should fail", func() {
+			_, err := os.Add([]byte("abcde"))
+			So(err, ShouldNotBeNil)
+
+			Convey("after deleting a slab adding should succeed again", func() {
+				for _, obj := range objs[:objsPerSlab] {
+					So(os.Delete(obj), ShouldBeNil)
+				}
+				_, err := os.Add([]byte("abcde"))
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestSlabSizeOption(t *testing.T) {
+	Convey("When setting the slab size in bytes", t, func() {
+		pageSize := systemPageSize
+		os := NewObjectStore(WithSlabSize(3*pageSize - 100))
+		for _, value := range []string{"abc", "defghijk", string(make([]byte, 200))} {
+			_, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("the page size should be a power of two", func() {
+			So(PageSize(), ShouldBeGreaterThanOrEqualTo, 4096)
+			So(PageSize()&(PageSize()-1), ShouldEqual, 0)
+		})
+
+		Convey("the slabs of each pool should fill whole pages", func() {
+			for _, info := range os.Slabs() {
+				So(info.TotalBytes, ShouldBeLessThanOrEqualTo, 3*pageSize)
+				So(info.TotalBytes, ShouldBeGreaterThan, 3*pageSize-int(info.ObjSize)-8)
+				So(info.Capacity, ShouldEqual, objsPerSlabForSize(info.ObjSize, 3*pageSize))
+			}
+			So(os.MemStats().PaddingBytes, ShouldBeLessThan, 3*(200+8))
+		})
+	})
+}
+
+func TestSlabRecyclingOption(t *testing.T) {
+	Convey("When a store keeps empty slabs for recycling", t, func() {
+		backend := &countingBackend{}
+		os := NewObjectStore(WithObjsPerSlab(2), WithBackend(backend), WithSlabRecycling(2))
+		var objs []ObjAddr
+		for i := 0; i < 8; i++ {
+			obj, err := os.Add([]byte{byte(i), 1})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 4)
+
+		Convey("only the slabs beyond the limit should be unmapped", func() {
+			for _, obj := range objs {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 2)
+			So(len(os.Slabs()), ShouldEqual, 2)
+
+			Convey("and the kept slabs should be reused", func() {
+				for i := 0; i < 4; i++ {
+					_, err := os.Add([]byte{byte(i), 1})
+					So(err, ShouldBeNil)
+				}
+				So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 2)
+				So(os.CheckIntegrity(), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestBackendAndMetricsOptions(t *testing.T) {
+	Convey("When using a custom backend and metrics", t, func() {
+		backend := &countingBackend{}
+		metrics := &countingMetrics{}
+		os := NewObjectStore(WithObjsPerSlab(2), WithBackend(backend), WithMetrics(metrics))
+
+		var objs []ObjAddr
+		for i := 0; i < 4; i++ {
+			objAddr, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+
+		Convey("the slab memory should come from the backend", func() {
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 2)
+			So(atomic.LoadInt64(&metrics.adds), ShouldEqual, 4)
+			So(atomic.LoadInt64(&metrics.slabsCreated), ShouldEqual, 2)
+
+			Convey("and it should be given back to the backend on delete", func() {
+				for _, obj := range objs {
+					So(os.Delete(obj), ShouldBeNil)
+				}
+				So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 0)
+				So(atomic.LoadInt64(&metrics.deletes), ShouldEqual, 4)
+				So(atomic.LoadInt64(&metrics.slabsDeleted), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestUseAfterFreeDetectionOption(t *testing.T) {
+	Convey("When use after free detection is enabled", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4), WithReclaimEmptySlabs(false), WithUseAfterFreeDetection())
+		obj1, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("def"))
+		So(err, ShouldBeNil)
+
+		Convey("getting a live object should work", func() {
+			obj, err := os.Get(obj2)
+			So(err, ShouldBeNil)
+			So(string(obj), ShouldEqual, "def")
+		})
+
+		Convey("getting a deleted object should panic", func() {
+			So(os.Delete(obj1), ShouldBeNil)
+			So(func() { os.Get(obj1) }, ShouldPanic)
+		})
+
+		Convey("getting a slot that has never been used should return an error", func() {
+			_, err := os.Get(obj2 + 3)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSecureEraseOption(t *testing.T) {
+	Convey("When secure erase is enabled", t, func() {
+		backend := &copyingBackend{}
+		os := NewObjectStore(WithObjsPerSlab(2), WithBackend(backend), WithSecureErase())
+		obj1, err := os.Add([]byte("secret"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("hidden"))
+		So(err, ShouldBeNil)
+
+		Convey("deleting an object should zero its memory", func() {
+			So(os.Delete(obj1), ShouldBeNil)
+			// in debug builds the zeroed slot gets poisoned when it's freed
+			erased := make([]byte, 6)
+			if poisonFreedSlots {
+				erased = bytes.Repeat([]byte{poisonByte}, 6)
+			}
+			So(objFromObjAddr(obj1, 6), ShouldResemble, erased)
+			obj, err := os.Get(obj2)
+			So(err, ShouldBeNil)
+			So(string(obj), ShouldEqual, "hidden")
+
+			Convey("and unmapping the slab should zero its data", func() {
+				So(os.Delete(obj2), ShouldBeNil)
+				So(len(backend.unmapped), ShouldEqual, 1)
+				data := backend.unmapped[0]
+				dataOffset := len(data) - 2*6
+				So(data[0], ShouldEqual, 6)
+				So(data[dataOffset:], ShouldResemble, make([]byte, 2*6))
+			})
+		})
+	})
+}
+
+func TestSearchMetrics(t *testing.T) {
+	Convey("When the metrics implement SearchMetrics", t, func() {
+		metrics := &searchCountingMetrics{}
+		os := NewObjectStore(WithMetrics(metrics))
+		_, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		Convey("they should be notified about searches", func() {
+			_, found := os.Search([]byte("abc"))
+			So(found, ShouldBeTrue)
+			_, found = os.Search([]byte("def"))
+			So(found, ShouldBeFalse)
+			So(atomic.LoadInt64(&metrics.searches), ShouldEqual, 2)
+			So(atomic.LoadInt64(&metrics.found), ShouldEqual, 1)
+			So(atomic.LoadInt64(&metrics.adds), ShouldEqual, 1)
+		})
+	})
+}
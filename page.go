@@ -0,0 +1,23 @@
+package gos
+
+import (
+	"syscall"
+)
+
+// systemPageSize is the size of the memory pages of the system. The Go
+// runtime detects it when the process starts, so it is right even if the
+// kernel has been configured with larger pages than usual for the
+// architecture, like 16K or 64K pages on ARM64. Slab sizes, guard pages
+// and the ranges passed to madvise and mlock are aligned to it
+var systemPageSize = syscall.Getpagesize()
+
+// PageSize returns the size of the memory pages of the system, see
+// WithSlabSize
+func PageSize() int {
+	return systemPageSize
+}
+
+// pageRound rounds the given size up to a multiple of the page size
+func pageRound(size int) int {
+	return (size + systemPageSize - 1) / systemPageSize * systemPageSize
+}
@@ -0,0 +1,88 @@
+package gos
+
+import (
+	"fmt"
+)
+
+// Pin guarantees that the object at the given address doesn't get moved by
+// a compaction until it gets unpinned, see Compact. This is required for
+// objects whose memory has been handed to cgo or other consumers which
+// keep pointers into it. Pins are counted, each Pin must be matched by an
+// Unpin. Deleting an object removes all its pins. Slabs which contain a
+// pinned object don't get evacuated, but objects of other slabs still get
+// moved into their free slots
+// On success it returns nil
+// On failure it returns an *AddrError describing why the address is invalid
+func (o *ObjectStore) Pin(obj ObjAddr) error {
+	pool, sAddr, err := o.checkedPool(obj)
+	if err != nil {
+		return err
+	}
+
+	return pool.pin(obj, sAddr)
+}
+
+// Unpin removes a pin which has been added by Pin, once all pins of the
+// object have been removed compactions may move it again
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) Unpin(obj ObjAddr) error {
+	pool, sAddr, err := o.checkedPool(obj)
+	if err != nil {
+		return err
+	}
+
+	return pool.unpin(obj, sAddr)
+}
+
+// pin adds a pin to the object at the given address in the given slab
+// On success it returns nil, if the address doesn't refer to a used slot
+// of the slab it returns an *AddrError
+func (s *slabPool) pin(obj ObjAddr, slabAddr SlabAddr) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.checkObjAddr(obj, slabAddr); err != nil {
+		return err
+	}
+
+	if s.pins == nil {
+		s.pins = make(map[ObjAddr]uint32)
+	}
+	if s.pins[obj] == 0 {
+		s.states[slabFromSlabAddr(slabAddr)].pinned++
+	}
+	s.pins[obj]++
+
+	return nil
+}
+
+// unpin removes a pin from the object at the given address in the given
+// slab
+// On success it returns nil, if the object isn't pinned it returns an error
+// which wraps ErrNotFound
+func (s *slabPool) unpin(obj ObjAddr, slabAddr SlabAddr) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pins[obj] == 0 {
+		return fmt.Errorf("ObjectStore: Unpin failed because object %d is not pinned: %w", obj, ErrNotFound)
+	}
+
+	s.pins[obj]--
+	if s.pins[obj] == 0 {
+		delete(s.pins, obj)
+		s.states[slabFromSlabAddr(slabAddr)].pinned--
+	}
+
+	return nil
+}
+
+// dropPins removes all pins of the object at the given address in the
+// given slab state, it gets called when the object gets deleted
+// The caller must hold the pool lock for writing
+func (s *slabPool) dropPins(obj ObjAddr, st *slabState) {
+	if _, ok := s.pins[obj]; ok {
+		delete(s.pins, obj)
+		st.pinned--
+	}
+}
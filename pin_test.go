@@ -0,0 +1,77 @@
+package gos
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPin(t *testing.T) {
+	Convey("When objects in sparsely used slabs are pinned", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 12; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		// leave 1, 2 and 3 objects in the three slabs
+		for _, i := range []int{1, 2, 3, 6, 7, 11} {
+			So(os.Delete(objs[i]), ShouldBeNil)
+		}
+		So(os.Pin(objs[0]), ShouldBeNil)
+		So(os.Pin(objs[0]), ShouldBeNil)
+
+		Convey("compaction should not move them", func() {
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 2)
+			_, ok := moved[objs[0]]
+			So(ok, ShouldBeFalse)
+			newObj, ok := moved[objs[4]]
+			So(ok, ShouldBeTrue)
+			data, err := os.Get(newObj)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{4, 1, 2})
+			So(len(os.Slabs()), ShouldEqual, 2)
+		})
+
+		Convey("they should be moved again once every pin has been removed", func() {
+			pool, _ := os.getSlabPool(3)
+			So(os.Unpin(objs[0]), ShouldBeNil)
+			So(pool.pins[objs[0]], ShouldEqual, 1)
+			So(os.Unpin(objs[0]), ShouldBeNil)
+			So(len(pool.pins), ShouldEqual, 0)
+
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldEqual, 1)
+			newObj, ok := moved[objs[0]]
+			So(ok, ShouldBeTrue)
+			data, err := os.Get(newObj)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{0, 1, 2})
+			So(len(os.Slabs()), ShouldEqual, 2)
+		})
+
+		Convey("unpinning an object which isn't pinned should fail", func() {
+			So(errors.Is(os.Unpin(objs[4]), ErrNotFound), ShouldBeTrue)
+		})
+
+		Convey("deleting an object should remove its pins", func() {
+			So(os.Delete(objs[0]), ShouldBeNil)
+			pool, _ := os.getSlabPool(3)
+			So(len(pool.pins), ShouldEqual, 0)
+			for _, st := range pool.states {
+				So(st.pinned, ShouldEqual, 0)
+			}
+		})
+
+		Convey("pinning an invalid address should fail", func() {
+			var addrErr *AddrError
+			So(errors.As(os.Pin(objs[1]), &addrErr), ShouldBeTrue)
+		})
+	})
+}
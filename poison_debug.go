@@ -0,0 +1,8 @@
+//go:build gosdebug
+// +build gosdebug
+
+package gos
+
+// poisonFreedSlots defines whether freed object slots get poisoned,
+// it gets enabled by building with the gosdebug tag
+const poisonFreedSlots = true
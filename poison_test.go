@@ -0,0 +1,59 @@
+//go:build gosdebug
+// +build gosdebug
+
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPoisoningFreedSlots(t *testing.T) {
+	Convey("When deleting an object from a pool built with gosdebug", t, func() {
+		sp := NewSlabPool(4, 4)
+		obj1, _, err := sp.add([]byte("abcd"))
+		So(err, ShouldBeNil)
+		_, _, err = sp.add([]byte("efgh"))
+		So(err, ShouldBeNil)
+		_, err = sp.deleteObj(obj1)
+		So(err, ShouldBeNil)
+
+		Convey("the freed slot should be poisoned", func() {
+			So(sp.get(obj1), ShouldResemble, []byte{poisonByte, poisonByte, poisonByte, poisonByte})
+		})
+
+		Convey("reusing the slot should work if it hasn't been written to", func() {
+			obj, _, err := sp.add([]byte("ijkl"))
+			So(err, ShouldBeNil)
+			So(obj, ShouldEqual, obj1)
+			So(string(sp.get(obj)), ShouldEqual, "ijkl")
+		})
+
+		Convey("reusing the slot should panic if it has been written to", func() {
+			objFromObjAddr(obj1, 4)[2] = 'x'
+			So(func() { sp.add([]byte("ijkl")) }, ShouldPanic)
+		})
+	})
+}
+
+func TestPoisoningWithSecureErase(t *testing.T) {
+	Convey("When secure erase is enabled in a store built with gosdebug", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4), WithSecureErase())
+		obj1, err := os.Add([]byte("abcd"))
+		So(err, ShouldBeNil)
+		_, err = os.Add([]byte("efgh"))
+		So(err, ShouldBeNil)
+		So(os.Delete(obj1), ShouldBeNil)
+
+		Convey("the freed slot should be poisoned and reusable", func() {
+			So(objFromObjAddr(obj1, 4), ShouldResemble, []byte{poisonByte, poisonByte, poisonByte, poisonByte})
+			obj, err := os.Add([]byte("ijkl"))
+			So(err, ShouldBeNil)
+			So(obj, ShouldEqual, obj1)
+			data, err := os.Get(obj)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ijkl")
+		})
+	})
+}
@@ -0,0 +1,171 @@
+package gos
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Handle refers to an object of type T that is stored in a Pool[T], it
+// contains the id of the object's slab and the generation of its slot, so
+// a handle to a deleted object is detected even if its slot or the memory
+// of its slab has been reused
+// The zero value is an invalid handle
+type Handle[T any] struct {
+	addr ObjAddr
+	slab uint32
+	gen  uint32
+}
+
+// Addr returns the object address which this handle refers to
+func (h Handle[T]) Addr() ObjAddr {
+	return h.addr
+}
+
+// Pool is a typed wrapper around a slab pool, it stores values of type T
+// off of the Go heap. The object slots of the pool are large enough to
+// hold T at an address which is aligned for T, so T plus its alignment
+// padding must not be larger than 255 bytes.
+// Because the GC doesn't scan slab memory T must not contain any pointers,
+// this includes strings, slices, maps, channels, funcs and interfaces.
+type Pool[T any] struct {
+	pool *slabPool
+
+	// slabIDs maps the address of each slab to an id which is never
+	// reused, nextSlabID is the id of the next new slab. Both are
+	// protected by the pool lock
+	slabIDs    map[SlabAddr]uint32
+	nextSlabID uint32
+}
+
+// NewPool initializes a new typed pool with the given number of objects
+// per slab and returns a pointer to it
+// On failure, if T can't be stored in a pool, the second returned value
+// is an error
+func NewPool[T any](objsPerSlab uint) (*Pool[T], error) {
+	var zero T
+	size := unsafe.Sizeof(zero)
+
+	// the slots of a slab are not aligned, so each slot gets room to move
+	// the value to the next aligned address within it
+	slotSize := size + unsafe.Alignof(zero) - 1
+	if size == 0 || slotSize > 255 {
+		return nil, fmt.Errorf("Pool: Size of type (%d) plus alignment padding (%d) is outside limits (1-%d): %w", size, slotSize-size, 255, ErrInvalidSize)
+	}
+
+	typ := reflect.TypeOf(&zero).Elem()
+	if containsPointers(typ) {
+		return nil, fmt.Errorf("Pool: Type %s contains pointers", typ)
+	}
+
+	p := &Pool[T]{
+		pool:       NewSlabPool(uint8(slotSize), objsPerSlab),
+		slabIDs:    make(map[SlabAddr]uint32),
+		nextSlabID: 1,
+	}
+	p.pool.slabLinked = p.slabLinked
+	p.pool.slabUnlinked = p.slabUnlinked
+	return p, nil
+}
+
+// slabLinked assigns an id to a slab which has been added to the pool
+func (p *Pool[T]) slabLinked(slabAddr SlabAddr) {
+	p.slabIDs[slabAddr] = p.nextSlabID
+	p.nextSlabID++
+}
+
+// slabUnlinked forgets the id of a slab which is getting deleted
+func (p *Pool[T]) slabUnlinked(slabAddr SlabAddr) {
+	delete(p.slabIDs, slabAddr)
+}
+
+// slotOf returns the slab of the value that the given handle refers to
+// The second returned value is false if the value has been deleted
+// The caller must hold the pool lock
+func (p *Pool[T]) slotOf(h Handle[T]) (*slab, bool) {
+	sl, ok := p.pool.slotOf(h.addr, h.gen)
+	if !ok || p.slabIDs[sl.addr()] != h.slab {
+		return nil, false
+	}
+	return sl, true
+}
+
+// valuePtr returns a pointer to the value in the slot at the given address,
+// which is the first address within the slot that is aligned for T
+func (p *Pool[T]) valuePtr(slot ObjAddr) *T {
+	var zero T
+	align := unsafe.Alignof(zero)
+	return (*T)(unsafe.Pointer((slot + align - 1) &^ (align - 1)))
+}
+
+// Put copies the given value into the pool
+// On success it returns a handle which refers to the stored value
+// On failure the second returned value is the error
+func (p *Pool[T]) Put(value T) (Handle[T], error) {
+	p.pool.lock.Lock()
+	defer p.pool.lock.Unlock()
+
+	st, _, err := p.pool.slabWithFreeSlot()
+	if err != nil {
+		return Handle[T]{}, err
+	}
+	idx := st.popFree()
+	addr, _ := st.slab.reserveObj(idx)
+	st.dirty.Store(true)
+	p.pool.updateList(st)
+	p.pool.lastSlab = st.slab
+
+	*p.valuePtr(addr) = value
+	return Handle[T]{addr: addr, slab: p.slabIDs[st.slab.addr()], gen: st.gens[idx]}, nil
+}
+
+// Get returns a pointer to the value that the given handle refers to
+// The pointer points into slab memory, it must not be used after the
+// value has been deleted
+// It returns nil if the value has been deleted
+func (p *Pool[T]) Get(h Handle[T]) *T {
+	p.pool.lock.RLock()
+	defer p.pool.lock.RUnlock()
+
+	if _, ok := p.slotOf(h); !ok {
+		return nil
+	}
+	return p.valuePtr(h.addr)
+}
+
+// Delete deletes the value that the given handle refers to
+// On success it returns nil
+// On failure, if the value has been deleted already, it returns an error
+// which wraps ErrStaleHandle
+func (p *Pool[T]) Delete(h Handle[T]) error {
+	p.pool.lock.Lock()
+	defer p.pool.lock.Unlock()
+
+	sl, ok := p.slotOf(h)
+	if !ok {
+		return fmt.Errorf("Pool: Delete of object address %d failed: %w", h.addr, ErrStaleHandle)
+	}
+	_, err := p.pool.deleteFromSlab(h.addr, sl.addr())
+	return err
+}
+
+// containsPointers returns true if values of the given type contain
+// any pointers that the GC would need to know about
+func containsPointers(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Array:
+		return typ.Len() > 0 && containsPointers(typ.Elem())
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if containsPointers(typ.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Ptr, reflect.UnsafePointer, reflect.String, reflect.Slice,
+		reflect.Map, reflect.Chan, reflect.Func, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
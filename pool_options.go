@@ -0,0 +1,44 @@
+package gos
+
+// PoolOption configures the slab pool of one object size, it is passed to
+// WithPoolOptions
+type PoolOption func(*slabPool)
+
+// WithPoolOptions applies the given options to the slab pool of objects
+// with the given size, when it gets created
+func WithPoolOptions(objSize uint8, opts ...PoolOption) Option {
+	return func(o *ObjectStore) {
+		if o.poolOptions == nil {
+			o.poolOptions = make(map[uint8][]PoolOption)
+		}
+		o.poolOptions[objSize] = append(o.poolOptions[objSize], opts...)
+	}
+}
+
+// TransparentHugePages defines whether the kernel should back the memory
+// of slabs with transparent huge pages
+type TransparentHugePages int
+
+const (
+	// THPDefault leaves the decision to the system wide setting
+	THPDefault TransparentHugePages = iota
+
+	// THPEnable advises the kernel to use transparent huge pages, this
+	// reduces TLB misses if the objects of the pool are accessed randomly
+	THPEnable
+
+	// THPDisable advises the kernel not to use transparent huge pages,
+	// this avoids the memory bloat and the latency of compacting memory
+	// into huge pages if the slabs of the pool are sparsely used
+	THPDisable
+)
+
+// WithTransparentHugePages makes the pool advise the kernel with madvise
+// whether to back its slabs with transparent huge pages. It only has an
+// effect on Linux, if transparent huge pages are enabled in the madvise
+// mode or always
+func WithTransparentHugePages(mode TransparentHugePages) PoolOption {
+	return func(s *slabPool) {
+		s.thp = mode
+	}
+}
@@ -0,0 +1,46 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPoolOptions(t *testing.T) {
+	Convey("When configuring the pool of one object size", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(1000), WithPoolOptions(4, WithTransparentHugePages(THPEnable)))
+		for _, value := range []string{"abc", "abcd"} {
+			_, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+		}
+
+		Convey("only that pool should get the options", func() {
+			pool, ok := os.getSlabPool(4)
+			So(ok, ShouldBeTrue)
+			So(pool.thp, ShouldEqual, THPEnable)
+			pool, ok = os.getSlabPool(3)
+			So(ok, ShouldBeTrue)
+			So(pool.thp, ShouldEqual, THPDefault)
+		})
+
+		Convey("advising the kernel about the slab memory should succeed", func() {
+			pool, _ := os.getSlabPool(4)
+			So(adviseTHP(pool.slabs[0].memory(), THPDisable), ShouldBeNil)
+		})
+	})
+	Convey("When prefaulting the slabs of a pool", t, func() {
+		// the canaries move the slab header off the page boundary
+		os := NewObjectStore(WithObjsPerSlab(10000), WithCanaries(), WithPoolOptions(3, WithPrefault()))
+		obj, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		Convey("the slab should still be intact", func() {
+			So(os.CheckIntegrity(), ShouldBeNil)
+			data, err := os.Get(obj)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "abc")
+			pool, _ := os.getSlabPool(3)
+			So(pool.slabs[0].getObjByIdx(9999), ShouldResemble, []byte{0, 0, 0})
+		})
+	})
+}
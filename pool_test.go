@@ -0,0 +1,120 @@
+package gos
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testPoint struct {
+	X, Y int32
+	Tag  [3]byte
+}
+
+func TestTypedPool(t *testing.T) {
+	Convey("When creating a typed pool of a struct without pointers", t, func() {
+		pool, err := NewPool[testPoint](10)
+		So(err, ShouldBeNil)
+
+		Convey("we should be able to put values and get them back", func() {
+			var handles []Handle[testPoint]
+			for i := int32(0); i < 25; i++ {
+				h, err := pool.Put(testPoint{X: i, Y: -i, Tag: [3]byte{'a', byte(i), 'z'}})
+				So(err, ShouldBeNil)
+				handles = append(handles, h)
+			}
+
+			for i, h := range handles {
+				So(*pool.Get(h), ShouldResemble, testPoint{X: int32(i), Y: -int32(i), Tag: [3]byte{'a', byte(i), 'z'}})
+			}
+
+			Convey("and delete them again", func() {
+				for _, h := range handles {
+					So(pool.Delete(h), ShouldBeNil)
+				}
+				So(len(pool.pool.slabs), ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("When creating a typed pool of a type with 8 byte alignment", t, func() {
+		type aligned struct {
+			B byte
+			N uint64
+		}
+		pool, err := NewPool[aligned](10)
+		So(err, ShouldBeNil)
+
+		Convey("the returned pointers should be aligned and point into the slab", func() {
+			for i := 0; i < 10; i++ {
+				h, err := pool.Put(aligned{B: byte(i), N: uint64(i) << 40})
+				So(err, ShouldBeNil)
+				p := pool.Get(h)
+				So(uintptr(unsafe.Pointer(p))%unsafe.Alignof(p.N), ShouldEqual, 0)
+				So(uintptr(unsafe.Pointer(p)), ShouldBeGreaterThanOrEqualTo, h.Addr())
+				So(*p, ShouldResemble, aligned{B: byte(i), N: uint64(i) << 40})
+
+				p.N++
+				So(pool.Get(h).N, ShouldEqual, uint64(i)<<40+1)
+			}
+		})
+	})
+
+	Convey("When using a handle of a deleted value", t, func() {
+		pool, err := NewPool[testPoint](10)
+		So(err, ShouldBeNil)
+		h, err := pool.Put(testPoint{X: 1})
+		So(err, ShouldBeNil)
+		So(pool.Delete(h), ShouldBeNil)
+
+		Convey("Get should return nil and Delete should fail", func() {
+			So(pool.Get(h), ShouldBeNil)
+			So(errors.Is(pool.Delete(h), ErrStaleHandle), ShouldBeTrue)
+		})
+
+		Convey("it should stay stale after its slot has been reused", func() {
+			h2, err := pool.Put(testPoint{X: 2})
+			So(err, ShouldBeNil)
+			So(h2.Addr(), ShouldEqual, h.Addr())
+
+			So(pool.Get(h), ShouldBeNil)
+			So(errors.Is(pool.Delete(h), ErrStaleHandle), ShouldBeTrue)
+			So(pool.Get(h2).X, ShouldEqual, 2)
+		})
+
+		Convey("it should stay stale after its slot has been reused in the same slab", func() {
+			_, err := pool.Put(testPoint{X: 3})
+			So(err, ShouldBeNil)
+			h2, err := pool.Put(testPoint{X: 4})
+			So(err, ShouldBeNil)
+			So(pool.Delete(h2), ShouldBeNil)
+			h3, err := pool.Put(testPoint{X: 5})
+			So(err, ShouldBeNil)
+			So(h3.Addr(), ShouldEqual, h2.Addr())
+
+			So(pool.Get(h2), ShouldBeNil)
+			So(errors.Is(pool.Delete(h2), ErrStaleHandle), ShouldBeTrue)
+			So(pool.Get(h3).X, ShouldEqual, 5)
+		})
+
+		Convey("the zero handle should be rejected too", func() {
+			So(pool.Get(Handle[testPoint]{}), ShouldBeNil)
+			So(errors.Is(pool.Delete(Handle[testPoint]{}), ErrStaleHandle), ShouldBeTrue)
+		})
+	})
+
+	Convey("When creating typed pools of unsupported types", t, func() {
+		_, err := NewPool[*int](10)
+		So(err, ShouldNotBeNil)
+		_, err = NewPool[struct{ S string }](10)
+		So(err, ShouldNotBeNil)
+		_, err = NewPool[[]byte](10)
+		So(err, ShouldNotBeNil)
+		_, err = NewPool[struct{}](10)
+		So(err, ShouldNotBeNil)
+		_, err = NewPool[[256]byte](10)
+		So(err, ShouldNotBeNil)
+	})
+}
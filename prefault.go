@@ -0,0 +1,29 @@
+package gos
+
+// WithPrefault makes the pool fault in all the pages of a new slab when it
+// gets mapped, so the first writes into the slab don't have to wait for
+// the kernel to allocate its pages. This makes creating slabs slower, but
+// it reduces the tail latency of adds during an ingest
+func WithPrefault() PoolOption {
+	return func(s *slabPool) {
+		s.prefault = true
+	}
+}
+
+// prefaultSlab faults in all the pages of the memory of a new slab. On
+// Linux the kernel gets asked to populate the whole pages at once, then
+// one byte of each page gets written to fault in the rest. The slots and
+// the BitSet words of a new slab are all zero, so zeros get written after
+// the header
+func prefaultSlab(sl *slab) {
+	data := sl.memory()
+	populate(data)
+
+	pageSize := systemPageSize
+	header := 1 + int(sizeOfBitSet)
+	// the first page has already been faulted in by writing the header
+	off := header + (pageSize-int(sl.addr()+uintptr(header))%pageSize)%pageSize
+	for ; off < len(data); off += pageSize {
+		data[off] = 0
+	}
+}
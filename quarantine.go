@@ -0,0 +1,165 @@
+package gos
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WithQuarantine defers unmapping the memory of deleted slabs until all the
+// reads which have been begun with BeginRead before the slabs got deleted
+// have ended. Without it, the byte slices returned by Get refer to unmapped
+// memory as soon as the slab of their object gets deleted, accessing them
+// then crashes the process
+func WithQuarantine() Option {
+	return func(o *ObjectStore) {
+		o.quarantine = newQuarantine()
+	}
+}
+
+// ReadGuard is returned by BeginRead, the memory of the slabs which
+// existed when it has been created stays mapped until End gets called
+type ReadGuard struct {
+	q     *quarantine
+	epoch uint64
+}
+
+// BeginRead begins a read of objects, the byte slices which get returned
+// by Get and the other read methods stay valid until End gets called on
+// the returned ReadGuard, even if their slabs get deleted meanwhile. This
+// requires WithQuarantine, otherwise the ReadGuard does nothing
+func (o *ObjectStore) BeginRead() ReadGuard {
+	if o.quarantine == nil {
+		return ReadGuard{}
+	}
+	return ReadGuard{q: o.quarantine, epoch: o.quarantine.enter()}
+}
+
+// End ends the read, the slabs which have been deleted since it began get
+// unmapped once no earlier read is active anymore. It must be called
+// exactly once per ReadGuard
+func (g ReadGuard) End() {
+	if g.q != nil {
+		g.q.exit(g.epoch)
+	}
+}
+
+// quarantine implements epoch based reclamation, it keeps track of the
+// active reads and defers the release of the memory which has been retired
+// while they were active. Reads only count themselves in the global epoch,
+// so they don't take any lock. The epoch advances once all reads which
+// have begun in the previous epoch have ended, memory which has been
+// retired in an epoch gets released once the epoch has advanced twice
+// since, because then no read which might refer to it is active anymore.
+// The memory must have been made unreachable for new reads before it gets
+// retired
+type quarantine struct {
+	epoch atomic.Uint64
+
+	// readers counts the active reads by the parity of the epoch they
+	// have begun in, only the current and the previous epoch can have
+	// active reads
+	readers [2]atomic.Int64
+
+	// waiting is the number of retired memory areas
+	waiting atomic.Int64
+
+	// lock serializes advancing the epoch and releasing memory
+	lock sync.Mutex
+
+	// retired is the memory that waits to be released, sorted by epoch
+	retired []retiredMemory
+}
+
+// retiredMemory is a memory area which has been retired in epoch, release
+// releases it
+type retiredMemory struct {
+	epoch   uint64
+	release func()
+}
+
+// newQuarantine creates an empty quarantine
+func newQuarantine() *quarantine {
+	return &quarantine{}
+}
+
+// enter registers a read and returns the epoch it has begun in. If the
+// epoch advances while the read registers itself it registers again, so
+// it never gets counted in an epoch which has already ended
+func (q *quarantine) enter() uint64 {
+	for {
+		epoch := q.epoch.Load()
+		q.readers[epoch&1].Add(1)
+		if q.epoch.Load() == epoch {
+			return epoch
+		}
+		q.exit(epoch)
+	}
+}
+
+// exit unregisters a read which has begun in the given epoch and releases
+// the memory which no active read can refer to anymore
+func (q *quarantine) exit(epoch uint64) {
+	if q.readers[epoch&1].Add(-1) > 0 || q.waiting.Load() == 0 {
+		return
+	}
+
+	q.lock.Lock()
+	releasable := q.releasable()
+	q.lock.Unlock()
+
+	for _, r := range releasable {
+		r.release()
+	}
+}
+
+// retire calls release once no read which is active now is active
+// anymore, right away if there is none
+func (q *quarantine) retire(release func()) {
+	q.lock.Lock()
+	q.retired = append(q.retired, retiredMemory{epoch: q.epoch.Load(), release: release})
+	q.waiting.Add(1)
+	releasable := q.releasable()
+	q.lock.Unlock()
+
+	for _, r := range releasable {
+		r.release()
+	}
+}
+
+// releasable advances the epoch as far as the active reads allow it,
+// removes the retired memory which no active read can refer to anymore
+// from the quarantine and returns it
+// The caller must hold the lock
+func (q *quarantine) releasable() []retiredMemory {
+	for len(q.retired) > 0 && q.retired[len(q.retired)-1].epoch+2 > q.epoch.Load() {
+		// the counter of the previous epoch gets reused by the next one
+		epoch := q.epoch.Load()
+		if q.readers[(epoch+1)&1].Load() != 0 {
+			break
+		}
+		q.epoch.Store(epoch + 1)
+	}
+
+	epoch := q.epoch.Load()
+	var n int
+	for n < len(q.retired) && q.retired[n].epoch+2 <= epoch {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+
+	releasable := append([]retiredMemory(nil), q.retired[:n]...)
+	q.retired = append(q.retired[:0], q.retired[n:]...)
+	q.waiting.Add(int64(-n))
+	return releasable
+}
+
+// pending returns the number of retired memory areas which haven't been
+// released yet
+func (q *quarantine) pending() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.retired)
+}
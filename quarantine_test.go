@@ -0,0 +1,72 @@
+package gos
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQuarantine(t *testing.T) {
+	Convey("When deleting slabs of a store with a quarantine", t, func() {
+		backend := &countingBackend{}
+		os := NewObjectStore(WithObjsPerSlab(1), WithBackend(backend), WithQuarantine())
+		obj1, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		obj2, err := os.Add([]byte("def"))
+		So(err, ShouldBeNil)
+
+		Convey("slabs should be unmapped right away if there are no reads", func() {
+			So(os.Delete(obj1), ShouldBeNil)
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 1)
+			So(os.quarantine.pending(), ShouldEqual, 0)
+		})
+
+		Convey("slabs should stay mapped until the reads which began before have ended", func() {
+			first := os.BeginRead()
+			data, err := os.Get(obj1)
+			So(err, ShouldBeNil)
+			So(os.Delete(obj1), ShouldBeNil)
+			So(len(os.Slabs()), ShouldEqual, 1)
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 2)
+
+			// the memory of the deleted object can still be read, debug
+			// builds have poisoned its slot
+			expected := []byte("abc")
+			if poisonFreedSlots {
+				expected = bytes.Repeat([]byte{poisonByte}, 3)
+			}
+			So(data, ShouldResemble, expected)
+
+			second := os.BeginRead()
+			So(os.Delete(obj2), ShouldBeNil)
+			So(os.quarantine.pending(), ShouldEqual, 2)
+
+			first.End()
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 1)
+			second.End()
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 0)
+			So(os.quarantine.pending(), ShouldEqual, 0)
+		})
+
+		Convey("slabs deleted before a read began should not wait for it", func() {
+			first := os.BeginRead()
+			So(os.Delete(obj1), ShouldBeNil)
+			second := os.BeginRead()
+			first.End()
+			So(atomic.LoadInt64(&backend.mapped), ShouldEqual, 1)
+			second.End()
+		})
+	})
+
+	Convey("Without a quarantine read guards should do nothing", t, func() {
+		os := NewObjectStore()
+		obj, err := os.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		guard := os.BeginRead()
+		So(os.Delete(obj), ShouldBeNil)
+		So(len(os.Slabs()), ShouldEqual, 0)
+		guard.End()
+	})
+}
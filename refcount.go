@@ -0,0 +1,87 @@
+package gos
+
+// AddRef adds a reference to the object at the given address, so several
+// owners can share an object, for example one which has been returned by
+// AddOrGet. Every object starts with the reference of the owner which has
+// added it, so objects which never get an additional reference get deleted
+// by their first Release. Each AddRef must be matched by a Release, Delete
+// removes the object regardless of its references. Compaction keeps the
+// references of the objects which it moves
+// On success it returns the number of references of the object and nil
+// On failure it returns an *AddrError describing why the address is invalid
+func (o *ObjectStore) AddRef(obj ObjAddr) (uint32, error) {
+	pool, sAddr, err := o.checkedPool(obj)
+	if err != nil {
+		return 0, err
+	}
+
+	return pool.addRef(obj, sAddr)
+}
+
+// Release removes a reference from the object at the given address, the
+// object gets deleted like with Delete once its last reference has been
+// released, see AddRef
+// On success it returns true if the object has been deleted and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) Release(obj ObjAddr) (bool, error) {
+	return o.deleteWith(obj, func(pool *slabPool, slabAddr SlabAddr) (bool, bool, error) {
+		return pool.release(obj, slabAddr)
+	})
+}
+
+// addRef adds a reference to the object at the given address in the given
+// slab
+// On success it returns the number of references and nil, if the address
+// doesn't refer to a used slot of the slab the second value is an
+// *AddrError
+func (s *slabPool) addRef(obj ObjAddr, slabAddr SlabAddr) (uint32, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.checkObjAddr(obj, slabAddr); err != nil {
+		return 0, err
+	}
+
+	if s.refs == nil {
+		s.refs = make(map[ObjAddr]uint32)
+	}
+	s.refs[obj]++
+
+	return s.refs[obj] + 1, nil
+}
+
+// release removes a reference from the object at the given address in the
+// given slab and deletes the object if it was the last one
+// On success it returns whether the object has been deleted, whether its
+// slab has been deleted, and nil
+// On failure the third returned value is the error
+func (s *slabPool) release(obj ObjAddr, slabAddr SlabAddr) (bool, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.checkObjAddr(obj, slabAddr); err != nil {
+		return false, false, err
+	}
+
+	if refs := s.refs[obj]; refs > 0 {
+		if refs == 1 {
+			delete(s.refs, obj)
+		} else {
+			s.refs[obj] = refs - 1
+		}
+		return false, false, nil
+	}
+
+	deleted, err := s.deleteFromSlab(obj, slabAddr)
+	return err == nil, deleted, err
+}
+
+// moveRefs moves the references of an object which has been moved from the
+// old to the new address
+// The caller must hold the pool lock for writing
+func (s *slabPool) moveRefs(oldAddr, newAddr ObjAddr) {
+	if refs, ok := s.refs[oldAddr]; ok {
+		delete(s.refs, oldAddr)
+		s.refs[newAddr] = refs
+	}
+}
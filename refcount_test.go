@@ -0,0 +1,84 @@
+package gos
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRefCounts(t *testing.T) {
+	Convey("When an interned object is shared by several owners", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		obj, existed, err := os.AddOrGet([]byte("label"))
+		So(err, ShouldBeNil)
+		So(existed, ShouldBeFalse)
+		for i := 0; i < 2; i++ {
+			shared, existed, err := os.AddOrGet([]byte("label"))
+			So(err, ShouldBeNil)
+			So(existed, ShouldBeTrue)
+			refs, err := os.AddRef(shared)
+			So(err, ShouldBeNil)
+			So(refs, ShouldEqual, i+2)
+		}
+
+		Convey("it should only be deleted when the last reference gets released", func() {
+			for i := 0; i < 2; i++ {
+				deleted, err := os.Release(obj)
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeFalse)
+				So(os.Contains(obj), ShouldBeTrue)
+			}
+			deleted, err := os.Release(obj)
+			So(err, ShouldBeNil)
+			So(deleted, ShouldBeTrue)
+			So(os.Contains(obj), ShouldBeFalse)
+
+			_, err = os.Release(obj)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("deleting it should drop its references", func() {
+			_, err := os.Add([]byte("other"))
+			So(err, ShouldBeNil)
+			So(os.Delete(obj), ShouldBeNil)
+			pool, _ := os.getSlabPool(5)
+			So(len(pool.refs), ShouldEqual, 0)
+		})
+
+		Convey("adding a reference to an invalid address should fail", func() {
+			var addrErr *AddrError
+			_, err := os.AddRef(obj + 1)
+			So(errors.As(err, &addrErr), ShouldBeTrue)
+		})
+	})
+
+	Convey("When a compaction moves an object with references", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 8; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		for _, i := range []int{1, 2, 3, 4} {
+			So(os.Delete(objs[i]), ShouldBeNil)
+		}
+		_, err := os.AddRef(objs[0])
+		So(err, ShouldBeNil)
+
+		moved, err := os.Compact()
+		So(err, ShouldBeNil)
+		newObj, ok := moved[objs[0]]
+		So(ok, ShouldBeTrue)
+
+		Convey("the references should have moved with it", func() {
+			deleted, err := os.Release(newObj)
+			So(err, ShouldBeNil)
+			So(deleted, ShouldBeFalse)
+			deleted, err = os.Release(newObj)
+			So(err, ShouldBeNil)
+			So(deleted, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package gos
+
+import (
+	"fmt"
+	"math"
+)
+
+// RelAddr is a relative object address, it consists of the id of the slab
+// which contains the object and the object's offset within that slab.
+// Unlike an ObjAddr it doesn't depend on where the slab is mapped, so it
+// stays valid when a snapshot gets restored into an empty store, and when a
+// store that has been opened with OpenFileStore gets reopened
+type RelAddr uint64
+
+// newRelAddr combines a slab id and an offset into a RelAddr
+func newRelAddr(slabID uint32, offset uint32) RelAddr {
+	return RelAddr(uint64(slabID)<<32 | uint64(offset))
+}
+
+// SlabID returns the id of the slab that the address refers to
+func (r RelAddr) SlabID() uint32 {
+	return uint32(r >> 32)
+}
+
+// Offset returns the offset of the object within its slab
+func (r RelAddr) Offset() uint32 {
+	return uint32(r)
+}
+
+// Relative returns the relative address of the object at the given address
+// On success it returns the relative address and nil
+// On failure the second returned value is an *AddrError
+func (o *ObjectStore) Relative(obj ObjAddr) (RelAddr, error) {
+	var rel RelAddr
+	err := o.withCheckedObj(obj, func(pool *slabPool, slabAddr SlabAddr) error {
+		id, ok := o.slabID(slabAddr)
+		if !ok {
+			return &AddrError{Obj: obj, Slab: slabAddr, Reason: "slab has no id"}
+		}
+		if obj-slabAddr > math.MaxUint32 {
+			return &AddrError{Obj: obj, Slab: slabAddr, Reason: "offset doesn't fit into a relative address"}
+		}
+
+		rel = newRelAddr(id, uint32(obj-slabAddr))
+		return nil
+	})
+
+	return rel, err
+}
+
+// Absolute returns the current address of the object that the relative
+// address refers to
+// On success it returns the object address and nil
+// On failure it returns 0 and an error, which wraps ErrNotFound if there
+// is no slab with the address' slab id, otherwise it is an *AddrError
+func (o *ObjectStore) Absolute(rel RelAddr) (ObjAddr, error) {
+	o.lookupLock.RLock()
+	slabAddr, ok := o.slabsByID[rel.SlabID()]
+	o.lookupLock.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("ObjectStore: there is no slab with id %d: %w", rel.SlabID(), ErrNotFound)
+	}
+
+	obj := slabAddr + ObjAddr(rel.Offset())
+	err := o.withCheckedObj(obj, func(pool *slabPool, slabAddr SlabAddr) error {
+		// the slab might have been replaced by another one at the same
+		// address since the id has been looked up
+		if id, _ := o.slabID(slabAddr); id != rel.SlabID() {
+			return &AddrError{Obj: obj, Slab: slabAddr, Reason: "slab has been replaced"}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return obj, nil
+}
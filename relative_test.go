@@ -0,0 +1,85 @@
+package gos
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRelativeAddresses(t *testing.T) {
+	Convey("When converting an object address to a relative address", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		var objs []ObjAddr
+		for _, value := range []string{"ab", "cd", "ef"} {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		rel, err := os.Relative(objs[2])
+		So(err, ShouldBeNil)
+
+		Convey("it should contain the slab id and the offset", func() {
+			_, slabAddr, _ := os.Owner(objs[2])
+			id, _ := os.slabID(slabAddr)
+			So(rel.SlabID(), ShouldEqual, id)
+			So(rel.Offset(), ShouldEqual, objs[2]-slabAddr)
+		})
+
+		Convey("it should convert back to the object address", func() {
+			obj, err := os.Absolute(rel)
+			So(err, ShouldBeNil)
+			So(obj, ShouldEqual, objs[2])
+		})
+
+		Convey("it should be rejected after the object has been deleted", func() {
+			rel, err := os.Relative(objs[0])
+			So(err, ShouldBeNil)
+			So(os.Delete(objs[0]), ShouldBeNil)
+
+			_, err = os.Absolute(rel)
+			So(errors.Is(err, ErrInvalidAddr), ShouldBeTrue)
+
+			So(os.Delete(objs[1]), ShouldBeNil)
+			_, err = os.Absolute(rel)
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+
+		Convey("it should stay valid when the store gets restored from a snapshot", func() {
+			var buf bytes.Buffer
+			_, err := os.WriteTo(&buf)
+			So(err, ShouldBeNil)
+
+			restored := NewObjectStore()
+			table, err := restored.Restore(bytes.NewReader(buf.Bytes()))
+			So(err, ShouldBeNil)
+			newRel, ok := table.TranslateRel(rel)
+			So(ok, ShouldBeTrue)
+			So(newRel, ShouldEqual, rel)
+
+			obj, err := restored.Absolute(rel)
+			So(err, ShouldBeNil)
+			data, err := restored.Get(obj)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ef")
+
+			Convey("and get translated if the slab ids are in use already", func() {
+				_, err := restored.Restore(bytes.NewReader(buf.Bytes()))
+				So(err, ShouldBeNil)
+				table, err := restored.Restore(bytes.NewReader(buf.Bytes()))
+				So(err, ShouldBeNil)
+
+				newRel, ok := table.TranslateRel(rel)
+				So(ok, ShouldBeTrue)
+				So(newRel, ShouldNotEqual, rel)
+				obj, err := restored.Absolute(newRel)
+				So(err, ShouldBeNil)
+				data, err := restored.Get(obj)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "ef")
+			})
+		})
+	})
+}
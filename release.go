@@ -0,0 +1,85 @@
+package gos
+
+// WithFreePageRelease makes the store release the memory of the pages of
+// a slab which only contain free slots, once at most the given fraction of
+// the slab's slots is used. The pages stay mapped, so the resident memory
+// shrinks without giving up the address space, and they get faulted in
+// again when their slots get reused. This is done on Linux with
+// MADV_FREE, or MADV_DONTNEED if that's not supported by the memory
+func WithFreePageRelease(maxUsage float64) Option {
+	return func(o *ObjectStore) {
+		o.releaseFreePages = true
+		o.releaseMaxUsage = maxUsage
+	}
+}
+
+// releasePages releases the pages of the slab which only contain free
+// slots, after the slot at the given index has been freed. When the slab
+// becomes sparse all of its pages get checked, afterwards only the pages
+// of each freed slot
+// The caller must hold the pool lock for writing
+func (s *slabPool) releasePages(st *slabState, idx uint) {
+	if poisonFreedSlots || st.sealed {
+		// the poison of the freed slots must be preserved
+		return
+	}
+
+	used := st.capacity - st.freeCount()
+	maxUsed := uint(s.releaseMaxUsage * float64(st.capacity))
+	switch {
+	case used > maxUsed:
+		return
+	case used == maxUsed:
+		s.releaseFreeRange(st.slab, 0, st.capacity)
+	default:
+		s.releaseFreeRange(st.slab, idx, idx+1)
+	}
+}
+
+// releaseFreeRange releases the pages which overlap with the slots from
+// first to last, excluding last, and only contain free slots. Releasing is
+// best effort, it fails for locked memory
+// It returns the number of bytes in the pages which have been released
+// The caller must hold the pool lock for writing
+func (s *slabPool) releaseFreeRange(sl *slab, first, last uint) uintptr {
+	pageSize := uintptr(systemPageSize)
+	addr := sl.addr()
+	dataStart := addr + sl.getDataOffset()
+	dataEnd := addr + sl.getTotalLength()
+	objSize := uintptr(sl.objSize)
+	bitSet := sl.bitSet()
+
+	// the pages at the edges of the data may be shared with the BitSet or
+	// with other memory, so only whole pages inside the data are released.
+	// Adjacent free pages get released together
+	start := (dataStart + uintptr(first)*objSize) &^ (pageSize - 1)
+	end := dataStart + uintptr(last)*objSize
+	var run, runLen, released uintptr
+	for page := start; page < end; page += pageSize {
+		free := page >= dataStart && page+pageSize <= dataEnd
+		if free {
+			firstSlot := uint((page - dataStart) / objSize)
+			lastSlot := uint((page + pageSize - 1 - dataStart) / objSize)
+			for i := firstSlot; i <= lastSlot && free; i++ {
+				free = !bitSet.Test(i)
+			}
+		}
+		if free {
+			if runLen == 0 {
+				run = page
+			}
+			runLen += pageSize
+			continue
+		}
+		if runLen > 0 {
+			releaseMemory(sliceAt(run, int(runLen)))
+			released += runLen
+			runLen = 0
+		}
+	}
+	if runLen > 0 {
+		releaseMemory(sliceAt(run, int(runLen)))
+		released += runLen
+	}
+	return released
+}
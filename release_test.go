@@ -0,0 +1,52 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFreePageRelease(t *testing.T) {
+	Convey("When deleting most objects of a slab with free page release", t, func() {
+		pageSize := uint(systemPageSize)
+		objsPerSlab := 4 * pageSize / 8
+		os := NewObjectStore(WithObjsPerSlab(objsPerSlab), WithFreePageRelease(0.5))
+		var objs []ObjAddr
+		for i := uint(0); i < objsPerSlab; i++ {
+			obj, err := os.Add([]byte{1, 2, 3, 4, 5, 6, 7, byte(i)})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		pool, _ := os.getSlabPool(8)
+		sl := pool.slabs[0]
+
+		// keep the first and the last object of the slab
+		for _, obj := range objs[1 : len(objs)-1] {
+			So(os.Delete(obj), ShouldBeNil)
+		}
+
+		Convey("only the whole pages between them should be released", func() {
+			released := pool.releaseFreeRange(sl, 0, objsPerSlab)
+			So(released, ShouldBeGreaterThanOrEqualTo, uintptr(2*pageSize))
+			So(released, ShouldBeLessThan, uintptr(4*pageSize))
+
+			data, err := os.Get(objs[0])
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{1, 2, 3, 4, 5, 6, 7, 0})
+			data, err = os.Get(objs[len(objs)-1])
+			So(err, ShouldBeNil)
+			So(data[7], ShouldEqual, byte(objsPerSlab-1))
+		})
+
+		Convey("the released slots should be reusable", func() {
+			for i := 0; i < 10; i++ {
+				obj, err := os.Add([]byte("abcdefgh"))
+				So(err, ShouldBeNil)
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "abcdefgh")
+			}
+			So(os.CheckIntegrity(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,19 @@
+//go:build linux && !386 && !arm && !mips && !mipsle && !s390x
+// +build linux,!386,!arm,!mips,!mipsle,!s390x
+
+package gos
+
+import (
+	"syscall"
+)
+
+// mmapFixed maps the given memory file at the given address, replacing the
+// mapping which is there. syscall.Mmap can't be used because it doesn't
+// accept an address
+func mmapFixed(addr uintptr, length int, prot, flags, fd int) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_MMAP, addr, uintptr(length), uintptr(prot), uintptr(flags|syscall.MAP_FIXED), uintptr(fd), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build linux && (386 || arm || mips || mipsle)
+// +build linux
+// +build 386 arm mips mipsle
+
+package gos
+
+import (
+	"syscall"
+)
+
+// mmapFixed maps the given memory file at the given address, replacing the
+// mapping which is there. On 32-bit architectures SYS_MMAP is the old mmap
+// which takes a pointer to its arguments, so mmap2 gets used instead. Its
+// offset is in pages, which doesn't matter because it is always 0
+func mmapFixed(addr uintptr, length int, prot, flags, fd int) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_MMAP2, addr, uintptr(length), uintptr(prot), uintptr(flags|syscall.MAP_FIXED), uintptr(fd), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build linux && s390x
+// +build linux,s390x
+
+package gos
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// mmapFixed maps the given memory file at the given address, replacing the
+// mapping which is there. On s390x mmap takes a pointer to its arguments
+func mmapFixed(addr uintptr, length int, prot, flags, fd int) error {
+	args := [6]uintptr{addr, uintptr(length), uintptr(prot), uintptr(flags | syscall.MAP_FIXED), uintptr(fd), 0}
+	_, _, errno := syscall.Syscall(syscall.SYS_MMAP, uintptr(unsafe.Pointer(&args[0])), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package gos
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Ring is a fixed-capacity queue of fixed-size records whose memory is off
+// the Go heap, for passing records from multiple producers to a single
+// consumer without GC pressure. The records get packed into chunk objects
+// of an ObjectStore like the elements of a Vector, and the sequence number
+// of each slot lives in a directory which is mapped by the backend of the
+// store. Push may be called concurrently, Pop must only be called by one
+// goroutine at a time
+type Ring struct {
+	store *ObjectStore
+
+	recSize      int
+	recsPerChunk int
+	capacity     uint64
+	mask         uint64
+
+	// chunks contains the address of each chunk, it never changes after
+	// the ring has been created
+	chunks []ObjAddr
+
+	// seqs contains the sequence number of each slot. A slot whose
+	// sequence number equals the tail position can be written by the
+	// producer which claims that position, a slot whose sequence number
+	// is one more than the head position holds a record for the consumer
+	seqs []byte
+
+	head atomic.Uint64
+	tail atomic.Uint64
+}
+
+// NewRing creates an empty Ring of records of the given size, which must
+// be between 1 and 255 bytes. The capacity gets rounded up to the next
+// power of two. The chunks get stored in an ObjectStore created with the
+// given options
+// On success it returns the ring and nil
+// On failure the second returned value is the error
+func NewRing(recSize int, capacity int, opts ...Option) (*Ring, error) {
+	if recSize < 1 || recSize > 255 {
+		return nil, fmt.Errorf("Ring: size of records (%d) is outside limits (1-%d): %w", recSize, 255, ErrInvalidSize)
+	}
+	if capacity < 1 {
+		return nil, fmt.Errorf("Ring: capacity (%d) must be at least 1: %w", capacity, ErrInvalidSize)
+	}
+
+	size := uint64(1)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+
+	r := &Ring{
+		store:        NewObjectStore(opts...),
+		recSize:      recSize,
+		recsPerChunk: 255 / recSize,
+		capacity:     size,
+		mask:         size - 1,
+	}
+
+	seqs, err := r.store.mapDirectory(size)
+	if err != nil {
+		return nil, err
+	}
+	r.seqs = seqs
+	for pos := uint64(0); pos < size; pos++ {
+		r.seq(pos).Store(pos)
+	}
+
+	chunks := (int(size) + r.recsPerChunk - 1) / r.recsPerChunk
+	r.chunks = make([]ObjAddr, 0, chunks)
+	for i := 0; i < chunks; i++ {
+		addr, _, err := r.store.AddReserve(r.recSize * r.recsPerChunk)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.chunks = append(r.chunks, addr)
+	}
+
+	return r, nil
+}
+
+// seq returns the sequence number of the slot of the given position
+func (r *Ring) seq(pos uint64) *atomic.Uint64 {
+	return (*atomic.Uint64)(unsafe.Pointer(&r.seqs[(pos&r.mask)*8]))
+}
+
+// record returns the memory of the slot of the given position
+func (r *Ring) record(pos uint64) []byte {
+	slot := int(pos & r.mask)
+	offset := (slot % r.recsPerChunk) * r.recSize
+	return sliceAt(r.chunks[slot/r.recsPerChunk]+uintptr(offset), r.recSize)
+}
+
+// Push adds the given record to the ring, its size must be the record size
+// of the ring. It is safe to call Push from multiple goroutines
+// On success it returns nil, otherwise it returns an error which wraps
+// ErrRingFull if the ring has no free slot
+func (r *Ring) Push(rec []byte) error {
+	if len(rec) != r.recSize {
+		return fmt.Errorf("Ring: size of record (%d) doesn't match the size of the ring's records (%d): %w", len(rec), r.recSize, ErrInvalidSize)
+	}
+
+	pos := r.tail.Load()
+	for {
+		diff := int64(r.seq(pos).Load() - pos)
+		switch {
+		case diff == 0:
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				copy(r.record(pos), rec)
+				r.seq(pos).Store(pos + 1)
+				return nil
+			}
+			pos = r.tail.Load()
+		case diff < 0:
+			// the consumer hasn't popped the record of the previous round
+			return fmt.Errorf("Ring: Push failed because all %d slots are in use: %w", r.capacity, ErrRingFull)
+		default:
+			// another producer has claimed the position
+			pos = r.tail.Load()
+		}
+	}
+}
+
+// Pop copies the oldest record of the ring into dst, which must be large
+// enough to hold a record, and removes it from the ring. Pop must only be
+// called by one goroutine at a time
+// On success it returns true if a record has been popped or false if the
+// ring is empty, and nil
+// On failure the second returned value wraps ErrInvalidSize
+func (r *Ring) Pop(dst []byte) (bool, error) {
+	if len(dst) < r.recSize {
+		return false, fmt.Errorf("Ring: destination of %d bytes is too small for a record of %d bytes: %w", len(dst), r.recSize, ErrInvalidSize)
+	}
+
+	pos := r.head.Load()
+	if r.seq(pos).Load() != pos+1 {
+		return false, nil
+	}
+
+	copy(dst, r.record(pos))
+	r.seq(pos).Store(pos + r.capacity)
+	r.head.Store(pos + 1)
+
+	return true, nil
+}
+
+// Len returns the number of records in the ring, while records get pushed
+// or popped concurrently it is only an approximation
+func (r *Ring) Len() int {
+	head := r.head.Load()
+	tail := r.tail.Load()
+	if tail < head {
+		return 0
+	}
+	return int(tail - head)
+}
+
+// Cap returns the number of records that the ring can hold
+func (r *Ring) Cap() int {
+	return int(r.capacity)
+}
+
+// MemStats returns the memory usage statistics of the store of the ring,
+// the directory of sequence numbers is not included
+func (r *Ring) MemStats() StoreMemStats {
+	return r.store.MemStats()
+}
+
+// Close deletes the chunks of the ring and unmaps its directory, the ring
+// must not be used anymore afterwards
+// On success it returns nil, otherwise it returns an error
+func (r *Ring) Close() error {
+	for _, addr := range r.chunks {
+		if err := r.store.Delete(addr); err != nil {
+			return err
+		}
+	}
+	r.chunks = nil
+
+	return r.store.unmapDirectory(r.seqs)
+}
@@ -0,0 +1,112 @@
+package gos
+
+import (
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRing(t *testing.T) {
+	Convey("When creating a ring of 8 byte records", t, func() {
+		r, err := NewRing(8, 100)
+		So(err, ShouldBeNil)
+		So(r.Cap(), ShouldEqual, 128)
+
+		rec := make([]byte, 8)
+
+		Convey("records should be popped in the order they have been pushed", func() {
+			for i := uint64(0); i < 300; i++ {
+				binary.LittleEndian.PutUint64(rec, i)
+				So(r.Push(rec), ShouldBeNil)
+				if i%3 == 2 {
+					for j := i - 2; j <= i; j++ {
+						ok, err := r.Pop(rec)
+						So(err, ShouldBeNil)
+						So(ok, ShouldBeTrue)
+						So(binary.LittleEndian.Uint64(rec), ShouldEqual, j)
+					}
+				}
+			}
+			So(r.Len(), ShouldEqual, 0)
+			ok, err := r.Pop(rec)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("pushing into a full ring should fail", func() {
+			for i := 0; i < r.Cap(); i++ {
+				So(r.Push(rec), ShouldBeNil)
+			}
+			So(r.Len(), ShouldEqual, r.Cap())
+			So(errors.Is(r.Push(rec), ErrRingFull), ShouldBeTrue)
+
+			ok, err := r.Pop(rec)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(r.Push(rec), ShouldBeNil)
+		})
+
+		Convey("records and buffers of the wrong size should be rejected", func() {
+			So(errors.Is(r.Push(make([]byte, 4)), ErrInvalidSize), ShouldBeTrue)
+			_, err := r.Pop(make([]byte, 4))
+			So(errors.Is(err, ErrInvalidSize), ShouldBeTrue)
+		})
+
+		Convey("records of concurrent producers should all reach the consumer", func() {
+			const producers, perProducer = 4, 1000
+
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func(p int) {
+					defer wg.Done()
+					rec := make([]byte, 8)
+					for i := 0; i < perProducer; i++ {
+						binary.LittleEndian.PutUint64(rec, uint64(p*perProducer+i))
+						for errors.Is(r.Push(rec), ErrRingFull) {
+							runtime.Gosched()
+						}
+					}
+				}(p)
+			}
+
+			seen := make([]bool, producers*perProducer)
+			last := make([]int, producers)
+			for i := range last {
+				last[i] = -1
+			}
+			duplicates, reordered := 0, 0
+			for popped := 0; popped < producers*perProducer; {
+				if ok, _ := r.Pop(rec); !ok {
+					continue
+				}
+				v := int(binary.LittleEndian.Uint64(rec))
+				if seen[v] {
+					duplicates++
+				}
+				seen[v] = true
+
+				// the records of each producer should keep their order
+				p, i := v/perProducer, v%perProducer
+				if i <= last[p] {
+					reordered++
+				}
+				last[p] = i
+				popped++
+			}
+			wg.Wait()
+			So(duplicates, ShouldEqual, 0)
+			So(reordered, ShouldEqual, 0)
+			So(r.Len(), ShouldEqual, 0)
+		})
+
+		Convey("closing the ring should release its slabs", func() {
+			So(r.Close(), ShouldBeNil)
+			So(r.MemStats().Slabs, ShouldEqual, 0)
+		})
+	})
+}
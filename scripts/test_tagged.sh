@@ -0,0 +1,48 @@
+#!/bin/sh
+# test_tagged.sh vets and tests a package which is only built with a build
+# tag, because its dependencies aren't vendored. The tree gets copied into a
+# temporary module, which uses the vendored dependencies of the store and
+# fetches the dependencies of the tagged package with go get
+#
+# Usage: scripts/test_tagged.sh gosprom|gosotel
+set -eu
+
+tag=${1:-}
+case "$tag" in
+gosprom)
+	deps="github.com/prometheus/client_golang@v1.24.1"
+	;;
+gosotel)
+	deps="go.opentelemetry.io/otel@v1.44.0 go.opentelemetry.io/otel/metric@v1.44.0 go.opentelemetry.io/otel/sdk/metric@v1.44.0"
+	;;
+*)
+	echo "usage: $0 gosprom|gosotel" >&2
+	exit 2
+	;;
+esac
+
+root=$(cd "$(dirname "$0")/.." && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+(cd "$root" && tar -cf - --exclude=./vendor --exclude=./.git .) | (cd "$tmp" && tar -xf -)
+
+export GO111MODULE=on GOFLAGS=-mod=mod
+cd "$tmp"
+go mod init github.com/replay/go-generic-object-store >/dev/null 2>&1
+
+# the vendored packages don't have a go.mod, so they get copied into
+# modules of their own which replace the upstream ones
+for dir in $(cd "$root/vendor" && find . -name '*.go' -exec dirname {} \; | sort -u); do
+	pkg=${dir#./}
+	mod=$(echo "$pkg" | cut -d/ -f1-3)
+	[ -f "$tmp/.deps/$mod/go.mod" ] && continue
+	mkdir -p "$tmp/.deps/$mod"
+	cp -R "$root/vendor/$mod/." "$tmp/.deps/$mod/"
+	printf 'module %s\n' "$mod" >"$tmp/.deps/$mod/go.mod"
+	go mod edit -require="$mod@v0.0.0" -replace="$mod=./.deps/$mod"
+done
+
+go get $deps
+go vet -tags "$tag" "./$tag"
+go test -tags "$tag" "./$tag"
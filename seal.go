@@ -0,0 +1,61 @@
+package gos
+
+import (
+	"fmt"
+)
+
+// Sealer is implemented by backends which can make a memory area immutable,
+// see SealSlab
+type Sealer interface {
+	// Seal makes the memory area, which has been returned by Map,
+	// read-only and prevents it from ever being modified again
+	Seal(data []byte) error
+}
+
+// SealSlab makes the slab at the given address immutable, this requires a
+// backend which implements Sealer like MemfdBackend. The objects of a sealed
+// slab can still be read and searched, but deleting them fails with an error
+// which wraps ErrSealed and no objects get added to the slab anymore
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) SealSlab(slabAddr SlabAddr) error {
+	objSize, known := o.slabObjSize(slabAddr)
+	if !known {
+		return fmt.Errorf("ObjectStore: SealSlab failed to find slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	sl := slabFromSlabAddr(slabAddr)
+	pool, ok := o.getSlabPool(objSize)
+	if !ok {
+		return fmt.Errorf("ObjectStore: SealSlab failed to find pool of slab with address %d: %w", slabAddr, ErrNotFound)
+	}
+
+	return pool.sealSlab(sl)
+}
+
+// sealSlab seals the given slab and moves it to the list of sealed slabs
+// On success it returns nil, otherwise it returns an error
+func (s *slabPool) sealSlab(sl *slab) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	st, ok := s.states[sl]
+	if !ok {
+		return fmt.Errorf("ObjectStore: SealSlab failed to find slab with address %d: %w", sl.addr(), ErrNotFound)
+	}
+	if st.sealed {
+		return nil
+	}
+
+	sealer, ok := s.backend.(Sealer)
+	if !ok {
+		return fmt.Errorf("ObjectStore: SealSlab failed because the backend %T can't seal slabs", s.backend)
+	}
+	if err := sealer.Seal(sl.memory()); err != nil {
+		return fmt.Errorf("ObjectStore: SealSlab failed to seal slab with address %d: %w", sl.addr(), err)
+	}
+
+	st.sealed = true
+	s.updateList(st)
+
+	return nil
+}
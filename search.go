@@ -0,0 +1,272 @@
+package gos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// SearchFunc returns the addresses of all objects of the store for which
+// pred returns true. The slabs of each pool get searched in parallel, so
+// pred must be safe for concurrent use, and it must neither retain the data
+// nor modify the store. The addresses are grouped by pool in the order of
+// the object sizes, and ordered by slab and slot within each pool
+func (o *ObjectStore) SearchFunc(pred func(obj []byte) bool) []ObjAddr {
+	return o.searchFunc(0, pred)
+}
+
+// searchFunc implements SearchFunc, the pools whose objects are shorter
+// than minSize bytes are skipped
+func (o *ObjectStore) searchFunc(minSize int, pred func(obj []byte) bool) []ObjAddr {
+	var result []ObjAddr
+	for _, pool := range o.sortedPools() {
+		if int(pool.objSize) < minSize {
+			continue
+		}
+		result = append(result, pool.searchFunc(pred)...)
+	}
+
+	return result
+}
+
+// SearchPrefix returns the addresses of all objects which start with the
+// given prefix, so objects can be found by a leading key field without
+// knowing their trailing bytes. The objects of all pools whose objects are
+// at least as long as the prefix get searched, the addresses are ordered
+// like the result of SearchFunc
+func (o *ObjectStore) SearchPrefix(prefix []byte) []ObjAddr {
+	return o.searchFunc(len(prefix), func(obj []byte) bool { return bytes.HasPrefix(obj, prefix) })
+}
+
+// SearchMasked returns the addresses of all objects whose leading bytes
+// match the given value in the bits which are set in the given mask, so
+// objects can be found by parts of their leading bytes. The value and the
+// mask must have the same length, the addresses are ordered like the result
+// of SearchFunc
+// On success it returns the addresses and nil
+// On failure the second returned value wraps ErrInvalidSize if the lengths
+// of the value and the mask differ
+func (o *ObjectStore) SearchMasked(value, mask []byte) ([]ObjAddr, error) {
+	if len(value) != len(mask) {
+		return nil, fmt.Errorf("ObjectStore: SearchMasked got a value of %d bytes and a mask of %d bytes: %w", len(value), len(mask), ErrInvalidSize)
+	}
+
+	// the value gets masked once, so each object only needs to be masked
+	masked := make([]byte, len(value))
+	for i := range value {
+		masked[i] = value[i] & mask[i]
+	}
+
+	return o.searchFunc(len(masked), func(obj []byte) bool {
+		if len(obj) < len(masked) {
+			return false
+		}
+		for i, b := range masked {
+			if obj[i]&mask[i] != b {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// searchFunc returns the addresses of the objects of the pool for which
+// pred returns true, ordered by slab and slot
+func (s *slabPool) searchFunc(pred func(obj []byte) bool) []ObjAddr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.searchSlabsFunc(nil, pred)
+}
+
+// searchSlabsFunc implements searchFunc, the slabs for which skip returns
+// true don't get searched
+// The caller must hold the pool lock
+func (s *slabPool) searchSlabsFunc(skip func(sl *slab) bool, pred func(obj []byte) bool) []ObjAddr {
+	// every slab collects its matches separately, so the result doesn't
+	// depend on the order in which the slabs get searched
+	matches := make([][]ObjAddr, len(s.slabs))
+	s.visitSlabs(skip, func(slabIdx int, sl *slab) {
+		for idx, ok := sl.nextObj(0); ok; idx, ok = sl.nextObj(idx + 1) {
+			if pred(s.objByIdx(sl, idx)) {
+				matches[slabIdx] = append(matches[slabIdx], sl.getObjAddrByIdx(idx))
+			}
+		}
+	})
+
+	var result []ObjAddr
+	for _, objs := range matches {
+		result = append(result, objs...)
+	}
+
+	return result
+}
+
+// visitSlabs calls fn with the index and the slab of each slab of the pool
+// for which skip doesn't return true. The slabs get visited by up to
+// GOMAXPROCS goroutines in parallel
+// The caller must hold the pool lock
+func (s *slabPool) visitSlabs(skip func(sl *slab) bool, fn func(slabIdx int, sl *slab)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(s.slabs) {
+		workers = len(s.slabs)
+	}
+	slabIdxChan := make(chan int, len(s.slabs))
+	for idx := range s.slabs {
+		slabIdxChan <- idx
+	}
+	close(slabIdxChan)
+
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for slabIdx := range slabIdxChan {
+				sl := s.slabs[slabIdx]
+				if skip != nil && skip(sl) {
+					continue
+				}
+				fn(slabIdx, sl)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// SearchAll returns the addresses of all objects which are equal to the
+// given value in no particular order, unlike Search which stops at the
+// first one, for stores which hold duplicates intentionally
+func (o *ObjectStore) SearchAll(searching []byte) []ObjAddr {
+	pool, ok := o.getSlabPool(uint8(len(searching)))
+	if !ok {
+		return nil
+	}
+
+	return pool.searchAll(searching)
+}
+
+// searchAll returns the addresses of all objects of the pool which are
+// equal to the given value
+func (s *slabPool) searchAll(searching []byte) []ObjAddr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.hashIndex != nil {
+		var result []ObjAddr
+		for _, obj := range s.hashIndex[bloomHash(searching)] {
+			if bytes.Equal(s.get(obj), searching) {
+				result = append(result, obj)
+			}
+		}
+		return result
+	}
+
+	return s.searchSlabsFunc(s.skipForValue(searching), func(obj []byte) bool { return bytes.Equal(obj, searching) })
+}
+
+// skipForValue returns a function for visitSlabs which skips the slabs
+// that are empty or whose bloom filter says that they can't contain the
+// given value
+// The caller must hold the pool lock
+func (s *slabPool) skipForValue(searching []byte) func(sl *slab) bool {
+	var searchHash uint64
+	if s.bloomFilters != nil {
+		searchHash = bloomHash(searching)
+	}
+
+	return func(sl *slab) bool {
+		if s.states[sl].list == emptySlabs {
+			return true
+		}
+		filter, ok := s.bloomFilters[sl]
+		return ok && !filter.mayContain(searchHash)
+	}
+}
+
+// Count returns the number of objects which are equal to the given value,
+// like the length of the result of SearchAll but without collecting the
+// addresses
+func (o *ObjectStore) Count(obj []byte) int {
+	pool, ok := o.getSlabPool(uint8(len(obj)))
+	if !ok {
+		return 0
+	}
+
+	return pool.count(obj)
+}
+
+// count returns the number of objects of the pool which are equal to the
+// given value
+func (s *slabPool) count(searching []byte) int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.hashIndex != nil {
+		count := 0
+		for _, obj := range s.hashIndex[bloomHash(searching)] {
+			if bytes.Equal(s.get(obj), searching) {
+				count++
+			}
+		}
+		return count
+	}
+
+	var count atomic.Int64
+	s.visitSlabs(s.skipForValue(searching), func(_ int, sl *slab) {
+		var slabCount int64
+		for idx, ok := sl.nextObj(0); ok; idx, ok = sl.nextObj(idx + 1) {
+			if bytes.Equal(s.objByIdx(sl, idx), searching) {
+				slabCount++
+			}
+		}
+		count.Add(slabCount)
+	})
+
+	return int(count.Load())
+}
+
+// SearchBatchedContext searches for many values at once, the values of
+// each size get searched in one pass over the slabs of their pool. The
+// returned slice has the same length as the searched values, it contains
+// the lowest address of the objects which are equal to each value, or 0
+// if none has been found. The search stops promptly once the given
+// context gets canceled or its deadline gets exceeded
+// On success it returns the addresses and nil
+// On failure the addresses of the values which have been found before the
+// context has ended are set, and the second returned value is the error of
+// the context
+func (o *ObjectStore) SearchBatchedContext(ctx context.Context, searching [][]byte) ([]ObjAddr, error) {
+	result := make([]ObjAddr, len(searching))
+
+	// the indexes of the searched values by their size
+	bySize := make(map[uint8][]int)
+	for i, value := range searching {
+		bySize[uint8(len(value))] = append(bySize[uint8(len(value))], i)
+	}
+
+	for size, idxs := range bySize {
+		pool, ok := o.getSlabPool(size)
+		if !ok {
+			continue
+		}
+
+		values := make([][]byte, len(idxs))
+		for j, i := range idxs {
+			values[j] = searching[i]
+		}
+		found, err := pool.searchBatchedContext(ctx, values)
+		for j, i := range idxs {
+			result[i] = found[j]
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, ctx.Err()
+}
@@ -0,0 +1,191 @@
+package gos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSearchFunc(t *testing.T) {
+	Convey("When searching a store with a predicate", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var even []ObjAddr
+		for i := 0; i < 30; i++ {
+			obj, err := os.Add(bytes.Repeat([]byte{byte(i)}, 1+i%3))
+			So(err, ShouldBeNil)
+			if i%2 == 0 {
+				even = append(even, obj)
+			}
+		}
+		isEven := func(obj []byte) bool { return obj[0]%2 == 0 }
+
+		Convey("it should return all matching objects of all pools", func() {
+			found := os.SearchFunc(isEven)
+			So(found, ShouldHaveLength, len(even))
+			for _, obj := range even {
+				So(found, ShouldContain, obj)
+			}
+
+			// the pools are ordered by object size
+			var sizes []int
+			for _, obj := range found {
+				data, err := os.Get(obj)
+				So(err, ShouldBeNil)
+				sizes = append(sizes, len(data))
+			}
+			for i := 1; i < len(sizes); i++ {
+				So(sizes[i], ShouldBeGreaterThanOrEqualTo, sizes[i-1])
+			}
+		})
+
+		Convey("deleted objects should not be returned", func() {
+			So(os.Delete(even[0]), ShouldBeNil)
+			found := os.SearchFunc(isEven)
+			So(found, ShouldHaveLength, len(even)-1)
+			So(found, ShouldNotContain, even[0])
+		})
+
+		Convey("a predicate which matches nothing should return nothing", func() {
+			So(os.SearchFunc(func([]byte) bool { return false }), ShouldBeEmpty)
+			So(NewObjectStore().SearchFunc(isEven), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSearchAll(t *testing.T) {
+	Convey("When searching for a value which is stored several times", t, func() {
+		for _, opts := range [][]Option{nil, {WithBloomFilters()}, {WithHashIndex()}} {
+			os := NewObjectStore(append([]Option{WithObjsPerSlab(4)}, opts...)...)
+			var dups []ObjAddr
+			for i := 0; i < 20; i++ {
+				value := []byte("other")
+				if i%3 == 0 {
+					value = []byte("dup")
+				}
+				obj, err := os.Add(value)
+				So(err, ShouldBeNil)
+				if i%3 == 0 {
+					dups = append(dups, obj)
+				}
+			}
+
+			found := os.SearchAll([]byte("dup"))
+			So(found, ShouldHaveLength, len(dups))
+			for _, obj := range dups {
+				So(found, ShouldContain, obj)
+			}
+
+			So(os.Delete(dups[1]), ShouldBeNil)
+			found = os.SearchAll([]byte("dup"))
+			So(found, ShouldHaveLength, len(dups)-1)
+			So(found, ShouldNotContain, dups[1])
+
+			So(os.SearchAll([]byte("dux")), ShouldBeEmpty)
+			So(os.SearchAll([]byte("missing")), ShouldBeEmpty)
+		}
+	})
+}
+
+func TestCount(t *testing.T) {
+	Convey("When counting the occurrences of a value", t, func() {
+		for _, opts := range [][]Option{nil, {WithBloomFilters()}, {WithHashIndex()}} {
+			os := NewObjectStore(append([]Option{WithObjsPerSlab(4)}, opts...)...)
+			var dups []ObjAddr
+			for i := 0; i < 20; i++ {
+				obj, err := os.Add([]byte{'a' + byte(i%4)})
+				So(err, ShouldBeNil)
+				if i%4 == 0 {
+					dups = append(dups, obj)
+				}
+			}
+
+			So(os.Count([]byte("a")), ShouldEqual, len(dups))
+			So(os.Count([]byte("a")), ShouldEqual, len(os.SearchAll([]byte("a"))))
+			So(os.Delete(dups[0]), ShouldBeNil)
+			So(os.Count([]byte("a")), ShouldEqual, len(dups)-1)
+			So(os.Count([]byte("e")), ShouldEqual, 0)
+			So(os.Count([]byte("ab")), ShouldEqual, 0)
+		}
+	})
+}
+
+func TestSearchPrefixAndMasked(t *testing.T) {
+	Convey("When searching for objects by their leading bytes", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		add := func(value string) ObjAddr {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			return obj
+		}
+		user1 := add("user:1")
+		user22 := add("user:22")
+		userX := add("usex:3")
+		add("item:1")
+		add("us")
+
+		Convey("SearchPrefix should find the objects of all pools with the prefix", func() {
+			found := os.SearchPrefix([]byte("user:"))
+			So(found, ShouldResemble, []ObjAddr{user1, user22})
+			So(os.SearchPrefix([]byte("user:222")), ShouldBeEmpty)
+			So(os.SearchPrefix(nil), ShouldHaveLength, 5)
+		})
+
+		Convey("SearchMasked should only compare the masked bits", func() {
+			found, err := os.SearchMasked([]byte("use\x00:"), []byte{0xff, 0xff, 0xff, 0, 0xff})
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []ObjAddr{user1, userX, user22})
+
+			// upper and lower case letters differ in bit 0x20
+			found, err = os.SearchMasked([]byte("USER"), []byte{0xdf, 0xdf, 0xdf, 0xdf})
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []ObjAddr{user1, user22})
+
+			_, err = os.SearchMasked([]byte("user"), []byte{0xff})
+			So(errors.Is(err, ErrInvalidSize), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSearchContext(t *testing.T) {
+	Convey("When searching with a context", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 40; i++ {
+			obj, err := os.Add([]byte(fmt.Sprintf("%03d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Convey("SearchContext should find objects until the context ends", func() {
+			obj, found, err := os.SearchContext(context.Background(), []byte("017"))
+			So(err, ShouldBeNil)
+			So(found, ShouldBeTrue)
+			So(obj, ShouldEqual, objs[17])
+
+			_, found, err = os.SearchContext(context.Background(), []byte("999"))
+			So(err, ShouldBeNil)
+			So(found, ShouldBeFalse)
+
+			_, found, err = os.SearchContext(canceled, []byte("017"))
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("SearchBatchedContext should find objects until the context ends", func() {
+			searching := [][]byte{[]byte("003"), []byte("x"), []byte("039"), []byte("999")}
+			found, err := os.SearchBatchedContext(context.Background(), searching)
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []ObjAddr{objs[3], 0, objs[39], 0})
+
+			found, err = os.SearchBatchedContext(canceled, searching)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			So(found, ShouldHaveLength, len(searching))
+		})
+	})
+}
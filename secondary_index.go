@@ -0,0 +1,344 @@
+package gos
+
+import (
+	"fmt"
+	"sync"
+)
+
+// IndexFunc extracts the value by which a secondary index looks up an
+// object from the object's data. If ok is false the object doesn't get
+// indexed, for example because it lacks the field. It must always return
+// the same key for the same data and must not retain the data
+type IndexFunc func(obj []byte) (key []byte, ok bool)
+
+// secondaryIndex maps the keys which its IndexFunc extracts to the objects
+// they have been extracted from
+type secondaryIndex struct {
+	fn IndexFunc
+
+	lock sync.RWMutex
+
+	// objs contains the objects of each key, keys maps each indexed
+	// object back to its key
+	objs map[string][]ObjAddr
+	keys map[ObjAddr]string
+
+	// sorted orders the indexed objects by their keys if the index has
+	// been added by AddSortedIndex, otherwise it is nil
+	sorted *BTreeIndex
+}
+
+// add indexes the given object by the key of the given data, objects which
+// are indexed already are skipped
+// The caller must hold the index lock for writing
+func (x *secondaryIndex) add(obj ObjAddr, data []byte) {
+	if _, ok := x.keys[obj]; ok {
+		return
+	}
+	key, ok := x.fn(data)
+	if !ok {
+		return
+	}
+	x.insert(obj, string(key))
+}
+
+// insert adds the given object to the objects of the given key
+// The caller must hold the index lock for writing
+func (x *secondaryIndex) insert(obj ObjAddr, key string) {
+	x.objs[key] = append(x.objs[key], obj)
+	x.keys[obj] = key
+
+	if x.sorted != nil {
+		x.sorted.lock.Lock()
+		err := x.sorted.insert([]byte(key), obj)
+		x.sorted.lock.Unlock()
+		if err != nil && x.sorted.objects.logger != nil {
+			x.sorted.objects.logger.Warn("gos: failed to add object to sorted index", "obj", obj, "error", err)
+		}
+	}
+}
+
+// remove removes the given object from the index
+// It returns the key of the object and true if it was indexed
+// The caller must hold the index lock for writing
+func (x *secondaryIndex) remove(obj ObjAddr) (string, bool) {
+	key, ok := x.keys[obj]
+	if !ok {
+		return "", false
+	}
+	delete(x.keys, obj)
+
+	// the entry is found by the key, so the object doesn't get read
+	if x.sorted != nil {
+		x.sorted.lock.Lock()
+		x.sorted.delete([]byte(key), obj)
+		x.sorted.lock.Unlock()
+	}
+
+	objs := x.objs[key]
+	for i, o := range objs {
+		if o == obj {
+			objs[i] = objs[len(objs)-1]
+			objs = objs[:len(objs)-1]
+			break
+		}
+	}
+	if len(objs) == 0 {
+		delete(x.objs, key)
+	} else {
+		x.objs[key] = objs
+	}
+
+	return key, true
+}
+
+// AddIndex registers a secondary index of the given name, which looks up
+// objects by the keys that fn extracts from them. All objects in the store
+// get indexed, and the index gets maintained when objects get added,
+// deleted or moved by a compaction. Objects added by AddReserve and
+// objects whose data gets changed must be indexed again with Reindex, and
+// the objects of a restored snapshot only get indexed by indexes which are
+// added after the restore
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) AddIndex(name string, fn IndexFunc) error {
+	return o.addIndex(name, &secondaryIndex{
+		fn:   fn,
+		objs: make(map[string][]ObjAddr),
+		keys: make(map[ObjAddr]string),
+	})
+}
+
+// AddSortedIndex registers a secondary index like AddIndex, which also
+// keeps the indexed objects sorted by their keys, so RangeIndex can visit
+// them in order and query ranges of keys. A key can be the whole object or
+// a prefix of it. The keys must not be longer than 255 bytes, objects with
+// longer keys can be looked up but don't get visited by RangeIndex. The
+// sorted keys get copied into an ObjectStore created with the given options
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) AddSortedIndex(name string, fn IndexFunc, opts ...Option) error {
+	sorted, err := newBTreeIndex(o, func(obj []byte) []byte {
+		key, _ := fn(obj)
+		return key
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	err = o.addIndex(name, &secondaryIndex{
+		fn:     fn,
+		objs:   make(map[string][]ObjAddr),
+		keys:   make(map[ObjAddr]string),
+		sorted: sorted,
+	})
+	if err != nil {
+		sorted.Close()
+	}
+
+	return err
+}
+
+// addIndex implements AddIndex and AddSortedIndex
+// On success it returns nil, otherwise it returns an error
+func (o *ObjectStore) addIndex(name string, x *secondaryIndex) error {
+	// the index gets registered before the existing objects get indexed,
+	// so concurrently added objects don't get missed. They may get
+	// indexed twice, which add ignores
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	o.indexesLock.Lock()
+	if _, ok := o.indexes[name]; ok {
+		o.indexesLock.Unlock()
+		return fmt.Errorf("ObjectStore: adding index %q failed because there is an index of that name already", name)
+	}
+	if o.indexes == nil {
+		o.indexes = make(map[string]*secondaryIndex)
+	}
+	o.indexes[name] = x
+	o.indexesLock.Unlock()
+
+	o.Iter(func(obj ObjAddr, data []byte) bool {
+		x.add(obj, data)
+		return true
+	})
+
+	return nil
+}
+
+// RemoveIndex removes the secondary index of the given name
+// It returns true if the index has been removed, or false if there is no
+// index of that name
+func (o *ObjectStore) RemoveIndex(name string) bool {
+	o.indexesLock.Lock()
+	defer o.indexesLock.Unlock()
+
+	x, ok := o.indexes[name]
+	if !ok {
+		return false
+	}
+	delete(o.indexes, name)
+
+	if x.sorted != nil {
+		x.lock.Lock()
+		x.sorted.Close()
+		x.sorted = nil
+		x.lock.Unlock()
+	}
+
+	return true
+}
+
+// Lookup returns the addresses of the objects whose key in the secondary
+// index of the given name is the given key, in no particular order
+// On success it returns the addresses and nil
+// On failure the second returned value wraps ErrNotFound if there is no
+// index of the given name
+func (o *ObjectStore) Lookup(name string, key []byte) ([]ObjAddr, error) {
+	o.indexesLock.RLock()
+	x, ok := o.indexes[name]
+	o.indexesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("ObjectStore: Lookup failed to find index %q: %w", name, ErrNotFound)
+	}
+
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	objs := x.objs[string(key)]
+	result := make([]ObjAddr, len(objs))
+	copy(result, objs)
+
+	return result, nil
+}
+
+// RangeIndex calls fn with the key and the address of each object whose key
+// in the sorted index of the given name is at least from and less than to,
+// in ascending order of the keys, until fn returns false. Objects with
+// equal keys are ordered by their addresses. A nil from or to leaves the
+// range unbounded on that side. The key is only valid during the call and
+// fn must not add or delete objects
+// On success it returns nil
+// On failure it returns an error which wraps ErrNotFound if there is no
+// sorted index of the given name
+func (o *ObjectStore) RangeIndex(name string, from, to []byte, fn func(key []byte, obj ObjAddr) bool) error {
+	o.indexesLock.RLock()
+	x, ok := o.indexes[name]
+	o.indexesLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("ObjectStore: RangeIndex failed to find index %q: %w", name, ErrNotFound)
+	}
+
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+
+	if x.sorted == nil {
+		return fmt.Errorf("ObjectStore: RangeIndex failed because index %q isn't sorted: %w", name, ErrNotFound)
+	}
+	x.sorted.Range(from, to, fn)
+
+	return nil
+}
+
+// Reindex updates the entries of the object at the given address in all
+// secondary indexes, it must be called after the data of an indexed object
+// has been changed, or after the data of an object added by AddReserve has
+// been written
+// On success it returns nil, otherwise it returns an *AddrError
+func (o *ObjectStore) Reindex(obj ObjAddr) error {
+	data, err := o.GetChecked(obj)
+	if err != nil {
+		return err
+	}
+
+	for _, x := range o.secondaryIndexes() {
+		x.lock.Lock()
+		x.remove(obj)
+		x.add(obj, data)
+		x.lock.Unlock()
+	}
+
+	return nil
+}
+
+// secondaryIndexes returns the registered secondary indexes, or nil if
+// there are none
+func (o *ObjectStore) secondaryIndexes() []*secondaryIndex {
+	o.indexesLock.RLock()
+	defer o.indexesLock.RUnlock()
+
+	if len(o.indexes) == 0 {
+		return nil
+	}
+	indexes := make([]*secondaryIndex, 0, len(o.indexes))
+	for _, x := range o.indexes {
+		indexes = append(indexes, x)
+	}
+
+	return indexes
+}
+
+// indexAdded adds an object which has been added with the given data to
+// all secondary indexes
+func (o *ObjectStore) indexAdded(obj ObjAddr, data []byte) {
+	for _, x := range o.secondaryIndexes() {
+		x.lock.Lock()
+		x.add(obj, data)
+		x.lock.Unlock()
+	}
+}
+
+// unindex removes an object from all secondary indexes before it gets
+// deleted, so its address can't get reused by a concurrent add while it is
+// still indexed
+// It returns the keys of the object by index, which unindexRollback uses
+// to restore the entries if the object hasn't been deleted after all
+func (o *ObjectStore) unindex(obj ObjAddr) map[*secondaryIndex]string {
+	var removed map[*secondaryIndex]string
+	for _, x := range o.secondaryIndexes() {
+		x.lock.Lock()
+		if key, ok := x.remove(obj); ok {
+			if removed == nil {
+				removed = make(map[*secondaryIndex]string)
+			}
+			removed[x] = key
+		}
+		x.lock.Unlock()
+	}
+
+	return removed
+}
+
+// unindexRollback restores the entries which unindex has removed
+func (o *ObjectStore) unindexRollback(obj ObjAddr, removed map[*secondaryIndex]string) {
+	for x, key := range removed {
+		x.lock.Lock()
+		if _, ok := x.keys[obj]; !ok {
+			x.insert(obj, key)
+		}
+		x.lock.Unlock()
+	}
+}
+
+// indexesRelocated updates the addresses of the objects which have been
+// moved by a compaction in all secondary indexes
+func (o *ObjectStore) indexesRelocated(moved map[ObjAddr]ObjAddr) {
+	if len(moved) == 0 {
+		return
+	}
+
+	for _, x := range o.secondaryIndexes() {
+		x.lock.Lock()
+		// all entries get removed first, because the new address of an
+		// object may be the old address of another one
+		keys := make(map[ObjAddr]string, len(moved))
+		for oldAddr, newAddr := range moved {
+			if key, ok := x.remove(oldAddr); ok {
+				keys[newAddr] = key
+			}
+		}
+		for newAddr, key := range keys {
+			x.insert(newAddr, key)
+		}
+		x.lock.Unlock()
+	}
+}
@@ -0,0 +1,216 @@
+package gos
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sortedAddrs returns the given addresses in ascending order
+func sortedAddrs(objs []ObjAddr) []ObjAddr {
+	sort.Slice(objs, func(i, j int) bool { return objs[i] < objs[j] })
+	return objs
+}
+
+func TestSecondaryIndex(t *testing.T) {
+	Convey("When indexing objects by their first byte", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(8))
+		byKind := func(obj []byte) ([]byte, bool) {
+			return obj[:1], obj[0] != 'x'
+		}
+
+		// objects which exist before the index get indexed by AddIndex
+		var before []ObjAddr
+		for i := 0; i < 10; i++ {
+			obj, err := os.Add([]byte(fmt.Sprintf("a%02d", i)))
+			So(err, ShouldBeNil)
+			before = append(before, obj)
+		}
+		So(os.AddIndex("kind", byKind), ShouldBeNil)
+		So(os.AddIndex("kind", byKind), ShouldNotBeNil)
+
+		var after []ObjAddr
+		for i := 0; i < 10; i++ {
+			obj, err := os.Add([]byte(fmt.Sprintf("b%02d", i)))
+			So(err, ShouldBeNil)
+			after = append(after, obj)
+		}
+		batched, err := os.AddBatched([][]byte{[]byte("b10"), []byte("x00")})
+		So(err, ShouldBeNil)
+		after = append(after, batched[0])
+
+		Convey("Lookup should return the objects of a key", func() {
+			objs, err := os.Lookup("kind", []byte("a"))
+			So(err, ShouldBeNil)
+			So(sortedAddrs(objs), ShouldResemble, sortedAddrs(append([]ObjAddr(nil), before...)))
+
+			objs, err = os.Lookup("kind", []byte("b"))
+			So(err, ShouldBeNil)
+			So(sortedAddrs(objs), ShouldResemble, sortedAddrs(append([]ObjAddr(nil), after...)))
+
+			objs, err = os.Lookup("kind", []byte("x"))
+			So(err, ShouldBeNil)
+			So(len(objs), ShouldEqual, 0)
+
+			_, err = os.Lookup("missing", []byte("a"))
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+
+		Convey("deleted objects should be removed from the index", func() {
+			for _, obj := range before[:5] {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			objs, err := os.Lookup("kind", []byte("a"))
+			So(err, ShouldBeNil)
+			So(len(objs), ShouldEqual, 5)
+
+			Convey("and a failed delete should keep the object indexed", func() {
+				So(os.Delete(before[0]), ShouldNotBeNil)
+				obj := before[5]
+				_, err := os.AddRef(obj)
+				So(err, ShouldBeNil)
+				deleted, err := os.Release(obj)
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeFalse)
+				objs, err := os.Lookup("kind", []byte("a"))
+				So(err, ShouldBeNil)
+				So(len(objs), ShouldEqual, 5)
+			})
+
+			Convey("and objects moved by a compaction should be followed", func() {
+				// make room in the slabs of the b objects for the a objects
+				for _, obj := range after[:4] {
+					So(os.Delete(obj), ShouldBeNil)
+				}
+				moved, err := os.Compact()
+				So(err, ShouldBeNil)
+				So(len(moved), ShouldBeGreaterThan, 0)
+				objs, err := os.Lookup("kind", []byte("a"))
+				So(err, ShouldBeNil)
+				So(len(objs), ShouldEqual, 5)
+				for _, obj := range objs {
+					data, err := os.GetChecked(obj)
+					So(err, ShouldBeNil)
+					So(data[0], ShouldEqual, 'a')
+				}
+			})
+		})
+
+		Convey("objects added by AddReserve should be indexed by Reindex", func() {
+			obj, data, err := os.AddReserve(3)
+			So(err, ShouldBeNil)
+			copy(data, "c00")
+			objs, _ := os.Lookup("kind", []byte("c"))
+			So(len(objs), ShouldEqual, 0)
+
+			So(os.Reindex(obj), ShouldBeNil)
+			objs, _ = os.Lookup("kind", []byte("c"))
+			So(objs, ShouldResemble, []ObjAddr{obj})
+
+			copy(data, "d00")
+			So(os.Reindex(obj), ShouldBeNil)
+			objs, _ = os.Lookup("kind", []byte("c"))
+			So(len(objs), ShouldEqual, 0)
+			objs, _ = os.Lookup("kind", []byte("d"))
+			So(objs, ShouldResemble, []ObjAddr{obj})
+		})
+
+		Convey("a removed index should not be found anymore", func() {
+			So(os.RemoveIndex("kind"), ShouldBeTrue)
+			So(os.RemoveIndex("kind"), ShouldBeFalse)
+			_, err := os.Lookup("kind", []byte("a"))
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSortedIndex(t *testing.T) {
+	Convey("When a store has a sorted index on a key prefix", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		prefix := func(obj []byte) ([]byte, bool) {
+			if len(obj) < 2 {
+				return nil, false
+			}
+			return obj[:2], true
+		}
+
+		add := func(value string) ObjAddr {
+			obj, err := os.Add([]byte(value))
+			So(err, ShouldBeNil)
+			return obj
+		}
+		add("dd:1")
+		add("bb:22")
+		So(os.AddSortedIndex("prefix", prefix), ShouldBeNil)
+		add("aa:333")
+		add("cc:1")
+		add("x")
+		add("bb:4444")
+
+		keys := func(from, to string) []string {
+			var fromKey, toKey []byte
+			if from != "" {
+				fromKey = []byte(from)
+			}
+			if to != "" {
+				toKey = []byte(to)
+			}
+			var result []string
+			err := os.RangeIndex("prefix", fromKey, toKey, func(key []byte, obj ObjAddr) bool {
+				data, err := os.GetChecked(obj)
+				So(err, ShouldBeNil)
+				So(string(data[:2]), ShouldEqual, string(key))
+				result = append(result, string(data))
+				return true
+			})
+			So(err, ShouldBeNil)
+			return result
+		}
+
+		Convey("the objects should be visited in the order of their keys", func() {
+			all := keys("", "")
+			So(all, ShouldHaveLength, 5)
+			So(all[0], ShouldEqual, "aa:333")
+			So(all[1:3], ShouldContain, "bb:22")
+			So(all[1:3], ShouldContain, "bb:4444")
+			So(all[3:], ShouldResemble, []string{"cc:1", "dd:1"})
+
+			So(keys("bb", "cc"), ShouldHaveLength, 2)
+			So(keys("bc", ""), ShouldResemble, []string{"cc:1", "dd:1"})
+		})
+
+		Convey("deletes and compactions should be reflected", func() {
+			for _, obj := range os.SearchPrefix([]byte("bb")) {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(keys("", ""), ShouldResemble, []string{"aa:333", "cc:1", "dd:1"})
+
+			// fill a few slabs of the pool of cc:1 and dd:1, then empty
+			// them sparsely so the compaction moves objects
+			var filler []ObjAddr
+			for i := 0; i < 10; i++ {
+				filler = append(filler, add(fmt.Sprintf("ee:%d", i)))
+			}
+			for _, obj := range append(filler[:8], os.SearchPrefix([]byte("dd"))...) {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			moved, err := os.Compact()
+			So(err, ShouldBeNil)
+			So(len(moved), ShouldBeGreaterThan, 0)
+			So(keys("", ""), ShouldResemble, []string{"aa:333", "cc:1", "ee:8", "ee:9"})
+		})
+
+		Convey("indexes which aren't sorted can't be ranged over", func() {
+			So(os.AddIndex("unsorted", prefix), ShouldBeNil)
+			err := os.RangeIndex("unsorted", nil, nil, func([]byte, ObjAddr) bool { return true })
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+
+			So(os.RemoveIndex("prefix"), ShouldBeTrue)
+			err = os.RangeIndex("prefix", nil, nil, func([]byte, ObjAddr) bool { return true })
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+	})
+}
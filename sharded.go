@@ -0,0 +1,175 @@
+package gos
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedObjectStore spreads its objects over several ObjectStores, which
+// are called shards. Each processor adds objects to its own shard, so
+// goroutines which add objects concurrently don't contend on the locks of
+// the slab pools. Reads, deletes and searches find the shard that owns an
+// object, so they are slower than with a single ObjectStore
+type ShardedObjectStore struct {
+	shards []*ObjectStore
+
+	// local caches the index of a shard per processor, sync.Pool keeps
+	// its items per processor so each one mostly gets the same index
+	local sync.Pool
+
+	// next is the index of the shard that gets assigned next to a
+	// processor which has no cached index
+	next atomic.Uint32
+}
+
+// NewShardedObjectStore creates a ShardedObjectStore with the given number
+// of shards, if it is 0 there is one shard per processor as reported by
+// runtime.GOMAXPROCS. Each shard gets configured by the given options, the
+// memory limit of WithMaxMemory is shared by all shards
+func NewShardedObjectStore(shards int, opts ...Option) *ShardedObjectStore {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+
+	s := &ShardedObjectStore{shards: make([]*ObjectStore, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewObjectStore(opts...)
+		s.shards[i].mem = s.shards[0].mem
+	}
+	s.local.New = func() interface{} {
+		idx := int(s.next.Add(1)-1) % len(s.shards)
+		return &idx
+	}
+
+	return s
+}
+
+// Shards returns the shards of the store, they can be used for operations
+// which ShardedObjectStore doesn't provide. Objects must only be added
+// through the ShardedObjectStore
+func (s *ShardedObjectStore) Shards() []*ObjectStore {
+	return s.shards
+}
+
+// localShard returns the shard of the current processor
+func (s *ShardedObjectStore) localShard() *ObjectStore {
+	idx := s.local.Get().(*int)
+	shard := s.shards[*idx]
+	s.local.Put(idx)
+	return shard
+}
+
+// shardOf returns the shard which contains the slab of the given object
+// address
+// On success it returns the shard and true
+// On failure it returns nil and false
+func (s *ShardedObjectStore) shardOf(obj ObjAddr) (*ObjectStore, bool) {
+	for _, shard := range s.shards {
+		if _, _, ok := shard.Owner(obj); ok {
+			return shard, true
+		}
+	}
+	return nil, false
+}
+
+// Add adds the given object to the shard of the current processor
+// On success it returns the object address and nil
+// On failure the second returned value is the error
+func (s *ShardedObjectStore) Add(obj []byte) (ObjAddr, error) {
+	return s.localShard().Add(obj)
+}
+
+// AddBatched adds all the given objects to the shard of the current
+// processor, see ObjectStore.AddBatched
+func (s *ShardedObjectStore) AddBatched(objs [][]byte) ([]ObjAddr, error) {
+	return s.localShard().AddBatched(objs)
+}
+
+// Get retrieves the object at the given object address from the shard
+// which contains it
+// On success it returns the object data and nil
+// On failure the second returned value is the error
+func (s *ShardedObjectStore) Get(obj ObjAddr) ([]byte, error) {
+	shard, ok := s.shardOf(obj)
+	if !ok {
+		return nil, fmt.Errorf("ObjectStore: Get failed to find the shard of object address %d: %w", obj, ErrInvalidAddr)
+	}
+	return shard.Get(obj)
+}
+
+// Contains returns true if the given address refers to a live object in
+// one of the shards
+func (s *ShardedObjectStore) Contains(obj ObjAddr) bool {
+	shard, ok := s.shardOf(obj)
+	return ok && shard.Contains(obj)
+}
+
+// Delete deletes the object at the given object address from the shard
+// which contains it
+// On success it returns nil, otherwise it returns an error
+func (s *ShardedObjectStore) Delete(obj ObjAddr) error {
+	shard, ok := s.shardOf(obj)
+	if !ok {
+		return fmt.Errorf("ObjectStore: Delete failed to find the shard of object address %d: %w", obj, ErrInvalidAddr)
+	}
+	return shard.Delete(obj)
+}
+
+// Search searches all shards for the given value
+// On success it returns the object address and true
+// On failure it returns 0 and false
+func (s *ShardedObjectStore) Search(searching []byte) (ObjAddr, bool) {
+	for _, shard := range s.shards {
+		if obj, found := shard.Search(searching); found {
+			return obj, true
+		}
+	}
+	return 0, false
+}
+
+// Slabs returns the information about all slabs of all shards
+func (s *ShardedObjectStore) Slabs() []SlabInfo {
+	var infos []SlabInfo
+	for _, shard := range s.shards {
+		infos = append(infos, shard.Slabs()...)
+	}
+	return infos
+}
+
+// MemStats returns the memory usage statistics of all shards, the
+// statistics of the pools with the same object size are merged
+func (s *ShardedObjectStore) MemStats() StoreMemStats {
+	var stats StoreMemStats
+	pools := make(map[uint8]*PoolMemStats)
+	for _, shard := range s.shards {
+		shardStats := shard.MemStats()
+		stats.Slabs += shardStats.Slabs
+		stats.MappedBytes += shardStats.MappedBytes
+		stats.LiveObjects += shardStats.LiveObjects
+		stats.FreeSlots += shardStats.FreeSlots
+		stats.PaddingBytes += shardStats.PaddingBytes
+
+		for _, pool := range shardStats.Pools {
+			merged, ok := pools[pool.ObjSize]
+			if !ok {
+				merged = &PoolMemStats{ObjSize: pool.ObjSize}
+				pools[pool.ObjSize] = merged
+			}
+			merged.Slabs += pool.Slabs
+			merged.MappedBytes += pool.MappedBytes
+			merged.LiveObjects += pool.LiveObjects
+			merged.FreeSlots += pool.FreeSlots
+			merged.PaddingBytes += pool.PaddingBytes
+		}
+	}
+
+	for _, pool := range pools {
+		stats.Pools = append(stats.Pools, *pool)
+	}
+	sort.Slice(stats.Pools, func(i, j int) bool { return stats.Pools[i].ObjSize < stats.Pools[j].ObjSize })
+
+	return stats
+}
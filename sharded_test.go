@@ -0,0 +1,72 @@
+package gos
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShardedObjectStore(t *testing.T) {
+	Convey("When adding objects to a sharded store concurrently", t, func() {
+		s := NewShardedObjectStore(4, WithObjsPerSlab(8))
+		So(len(s.Shards()), ShouldEqual, 4)
+
+		var wg sync.WaitGroup
+		objs := make([][]ObjAddr, 8)
+		for g := range objs {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					obj, err := s.Add([]byte(fmt.Sprintf("%d-%03d", g, i)))
+					if err != nil {
+						panic(err)
+					}
+					objs[g] = append(objs[g], obj)
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		Convey("all objects should be retrievable and searchable", func() {
+			for g := range objs {
+				for i, obj := range objs[g] {
+					data, err := s.Get(obj)
+					So(err, ShouldBeNil)
+					So(string(data), ShouldEqual, fmt.Sprintf("%d-%03d", g, i))
+				}
+			}
+			obj, found := s.Search([]byte("3-042"))
+			So(found, ShouldBeTrue)
+			So(obj, ShouldEqual, objs[3][42])
+		})
+
+		Convey("the statistics should be merged over all shards", func() {
+			stats := s.MemStats()
+			So(stats.LiveObjects, ShouldEqual, 800)
+			So(len(stats.Pools), ShouldEqual, 1)
+			So(stats.Pools[0].LiveObjects, ShouldEqual, 800)
+			So(uint64(len(s.Slabs())), ShouldEqual, stats.Slabs)
+		})
+
+		Convey("deleting objects should find their shards", func() {
+			for _, obj := range objs[5] {
+				So(s.Delete(obj), ShouldBeNil)
+			}
+			So(s.Contains(objs[5][0]), ShouldBeFalse)
+			So(s.Contains(objs[6][0]), ShouldBeTrue)
+			So(s.MemStats().LiveObjects, ShouldEqual, 700)
+		})
+	})
+
+	Convey("When the shards share a memory limit", t, func() {
+		s := NewShardedObjectStore(2, WithObjsPerSlab(1), WithMaxMemory(uint64(slabSize(3, 1))))
+		_, err := s.Shards()[0].Add([]byte("abc"))
+		So(err, ShouldBeNil)
+		_, err = s.Shards()[1].Add([]byte("abc"))
+		So(errors.Is(err, ErrMemoryLimit), ShouldBeTrue)
+	})
+}
@@ -2,10 +2,9 @@ package gos
 
 import (
 	"fmt"
-	"math"
+	"math/bits"
 	"reflect"
 	"strings"
-	"syscall"
 	"unsafe"
 
 	"github.com/willf/bitset"
@@ -56,20 +55,79 @@ func (s *slab) String() string {
 // second value is nil
 // On failure the second returned value is an error
 func newSlab(objSize uint8, objsPerSlab uint) (*slab, error) {
-	bitSet := bitset.New(objsPerSlab)
+	return newSlabFromBackend(defaultBackend, objSize, objsPerSlab)
+}
 
-	bitSetDataLen := len(bitSet.Bytes()) * 8
+// slabSize returns the total size in bytes of a slab with the given parameters
+func slabSize(objSize uint8, objsPerSlab uint) int {
+	return growableSlabSize(objSize, objsPerSlab, objsPerSlab)
+}
 
+// growableSlabSize returns the total size in bytes of a slab with the given
+// parameters whose BitSet has room for up to maxObjsPerSlab objects
+func growableSlabSize(objSize uint8, objsPerSlab, maxObjsPerSlab uint) int {
 	// 1 byte for the objSize, that's a uint8
 	// sizeOfBitSet is the BitSet, excluding the data used by its data slice
-	// bitSetDataLen is the data used by the BitSets data slice
+	// the BitSets data slice uses 8 bytes per 64 objects
 	// the object slots take up (object size * object count) bytes
-	totalLen := 1 + int(sizeOfBitSet) + bitSetDataLen + int(objSize)*int(objsPerSlab)
-	data, err := syscall.Mmap(-1, 0, totalLen, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	bitSetDataLen := int((maxObjsPerSlab+63)/64) * 8
+	return 1 + int(sizeOfBitSet) + bitSetDataLen + int(objSize)*int(objsPerSlab)
+}
+
+// objsPerSlabForSize returns the largest number of objects per slab with
+// which a slab of objects of the given size fits into totalLen bytes,
+// but at least 1
+func objsPerSlabForSize(objSize uint8, totalLen int) uint {
+	// each object takes objSize bytes plus one bit of the BitSet, the
+	// estimate gets corrected for the rounding of the BitSet words
+	header := 1 + int(sizeOfBitSet)
+	if totalLen <= header {
+		return 1
+	}
+	objsPerSlab := uint((totalLen - header) * 8 / (8*int(objSize) + 1))
+	for objsPerSlab > 1 && slabSize(objSize, objsPerSlab) > totalLen {
+		objsPerSlab--
+	}
+	for slabSize(objSize, objsPerSlab+1) <= totalLen {
+		objsPerSlab++
+	}
+	if objsPerSlab == 0 {
+		return 1
+	}
+	return objsPerSlab
+}
+
+// newSlabFromBackend is like newSlab, but it obtains the slab memory
+// from the given backend
+func newSlabFromBackend(backend Backend, objSize uint8, objsPerSlab uint) (*slab, error) {
+	return newGrowableSlab(backend, objSize, objsPerSlab, objsPerSlab)
+}
+
+// newGrowableSlab is like newSlabFromBackend, but the BitSet of the slab
+// has room for up to maxObjsPerSlab objects, so it can be grown in place
+func newGrowableSlab(backend Backend, objSize uint8, objsPerSlab, maxObjsPerSlab uint) (*slab, error) {
+	totalLen := growableSlabSize(objSize, objsPerSlab, maxObjsPerSlab)
+	data, err := backend.Map(totalLen)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Add: Failed to map slab of %d bytes: %w: %w", totalLen, ErrMmapFailed, err)
 	}
 
+	return initGrowableSlab(data, objSize, objsPerSlab, maxObjsPerSlab), nil
+}
+
+// initSlab writes the header of a slab with the given parameters into
+// the given memory area, which must be at least as big as slabSize returns
+// It returns the memory area converted to a slab pointer
+func initSlab(data []byte, objSize uint8, objsPerSlab uint) *slab {
+	return initGrowableSlab(data, objSize, objsPerSlab, objsPerSlab)
+}
+
+// initGrowableSlab is like initSlab, but the capacity of the BitSet's data
+// slice is reserved for up to maxObjsPerSlab objects. The object slots
+// start after the reserved capacity
+func initGrowableSlab(data []byte, objSize uint8, objsPerSlab, maxObjsPerSlab uint) *slab {
+	bitSet := bitset.New(objsPerSlab)
+
 	// set the objSize property of the new slab
 	data[0] = byte(objSize)
 
@@ -89,9 +147,10 @@ func newSlab(objSize uint8, objsPerSlab uint) (*slab, error) {
 
 	// set the data pointer to point at the address right after the BitSet instance
 	bitSetDataSlice.Data = uintptr(unsafe.Pointer(&data[1+int(sizeOfBitSet)]))
+	bitSetDataSlice.Cap = int((maxObjsPerSlab + 63) / 64)
 
 	// return the data byte slice converted to a slab pointer
-	return (*slab)(unsafe.Pointer(&data[0])), nil
+	return (*slab)(unsafe.Pointer(&data[0]))
 }
 
 // addr returns this slabs' address as a SlabAddr type
@@ -104,11 +163,45 @@ func (s *slab) bitSet() *bitset.BitSet {
 	return (*bitset.BitSet)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + 1))
 }
 
+// checkHeader verifies that the slab header is consistent with the given
+// pool parameters and with the number of used slots
+// On success it returns nil, otherwise it returns an error
+func (s *slab) checkHeader(objSize uint8, objsPerSlab uint, used uint) error {
+	if s.objSize != objSize {
+		return fmt.Errorf("object size is %d instead of %d", s.objSize, objSize)
+	}
+
+	bitSetData := (*reflect.SliceHeader)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + 1 + offsetOfBitSetData))
+	if bitSetData.Data != uintptr(unsafe.Pointer(s))+1+sizeOfBitSet || bitSetData.Len != int((objsPerSlab+63)/64) {
+		return fmt.Errorf("BitSet data slice has been overwritten")
+	}
+
+	bitSet := s.bitSet()
+	if bitSet.Len() != objsPerSlab {
+		return fmt.Errorf("BitSet length is %d instead of %d", bitSet.Len(), objsPerSlab)
+	}
+	if bitSet.Count() != used {
+		return fmt.Errorf("BitSet has %d used slots instead of %d", bitSet.Count(), used)
+	}
+
+	return nil
+}
+
 // objsPerSlab returns the max number of objects each slab can contain
 func (s *slab) objsPerSlab() uint {
 	return s.bitSet().Len()
 }
 
+// memory returns a byte slice which refers to the whole memory of this slab
+func (s *slab) memory() []byte {
+	var mem []byte
+	memHeader := (*reflect.SliceHeader)(unsafe.Pointer(&mem))
+	memHeader.Data = uintptr(unsafe.Pointer(s))
+	memHeader.Len = int(s.getTotalLength())
+	memHeader.Cap = memHeader.Len
+	return mem
+}
+
 // getTotalLength returns the total size of this slab in bytes
 func (s *slab) getTotalLength() uintptr {
 	return s.getDataOffset() + uintptr(s.objSize)*uintptr(s.objsPerSlab())
@@ -116,8 +209,26 @@ func (s *slab) getTotalLength() uintptr {
 
 // getDataOffset returns the offset at which the stored objects start
 func (s *slab) getDataOffset() uintptr {
-	// multiply the BitSet bytes by 8 because it returns a slice of uint64
-	return uintptr(1) + sizeOfBitSet + uintptr(len(s.bitSet().Bytes())*8)
+	// multiply the BitSet bytes by 8 because it returns a slice of uint64,
+	// its capacity may be reserved for growing the slab
+	return uintptr(1) + sizeOfBitSet + uintptr(cap(s.bitSet().Bytes())*8)
+}
+
+// canGrow returns true if the BitSet of the slab has room for the given
+// number of objects per slab
+func (s *slab) canGrow(objsPerSlab uint) bool {
+	return objsPerSlab > s.objsPerSlab() && int((objsPerSlab+63)/64) <= cap(s.bitSet().Bytes())
+}
+
+// grow extends the BitSet of the slab to the given number of objects per
+// slab within its reserved capacity, the memory of the added object slots
+// must have been mapped already. The caller must check canGrow first
+func (s *slab) grow(objsPerSlab uint) {
+	// setting a bit beyond the length extends the BitSet without
+	// reallocating its data, because the capacity suffices
+	bitSet := s.bitSet()
+	bitSet.Set(objsPerSlab - 1)
+	bitSet.Clear(objsPerSlab - 1)
 }
 
 // getObjOffset returns the offset at which the object
@@ -136,7 +247,7 @@ func (s *slab) getObjOffset(idx uint) uintptr {
 // within this slice
 func (s *slab) getObjIdx(obj ObjAddr) uint {
 	// offset where the slices object data begins
-	dataOffset := uintptr(1) + sizeOfBitSet + uintptr(len(s.bitSet().Bytes())*8)
+	dataOffset := s.getDataOffset()
 
 	// offset where the object is within the data range
 	objectOffset := obj - dataOffset - uintptr(unsafe.Pointer(s))
@@ -167,9 +278,10 @@ func (s *slab) addObj(obj []byte, idx uint) (ObjAddr, bool, bool) {
 	}
 
 	// if the length is not divisible by 8 we need to copy the left over data
-	remainder := len % 8
-	if remainder != 0 {
-		*((*uint64)(unsafe.Pointer(objAddr + i))) |= (*((*uint64)(unsafe.Pointer(src + i))) & (math.MaxUint64 >> ((8 - remainder) * 8)))
+	// byte by byte, the bytes after the slot must not be accessed because
+	// they may be beyond the end of the slab's memory
+	for ; i < len; i++ {
+		*(*byte)(unsafe.Pointer(objAddr + i)) = *(*byte)(unsafe.Pointer(src + i))
 	}
 
 	// set the according object slot as used
@@ -179,6 +291,37 @@ func (s *slab) addObj(obj []byte, idx uint) (ObjAddr, bool, bool) {
 	return objAddr, bitSet.All(), true
 }
 
+// addVarLenObj is like addObj, but it prefixes the object data with
+// a one byte length. The slot size of the slab must be greater than
+// the length of the object
+func (s *slab) addVarLenObj(obj []byte, idx uint) (ObjAddr, bool, bool) {
+	objAddr := s.getObjAddrByIdx(idx)
+
+	slot := objFromObjAddr(objAddr, s.objSize)
+	slot[0] = uint8(len(obj))
+	copy(slot[1:], obj)
+
+	// set the according object slot as used
+	bitSet := s.bitSet()
+	bitSet.Set(idx)
+
+	return objAddr, bitSet.All(), true
+}
+
+// reserveObj marks the slot at the given index as used and returns its
+// address and a zeroed byte slice which refers to the slot's memory
+func (s *slab) reserveObj(idx uint) (ObjAddr, []byte) {
+	objAddr := s.getObjAddrByIdx(idx)
+
+	obj := objFromObjAddr(objAddr, s.objSize)
+	for i := range obj {
+		obj[i] = 0
+	}
+	s.bitSet().Set(idx)
+
+	return objAddr, obj
+}
+
 // delete deletes the object at the given object address
 // it returns a boolean which indicates if after this delete the slab is empty or not
 // on true it is empty, otherwise there is still some data in it
@@ -189,7 +332,81 @@ func (s *slab) delete(obj ObjAddr) bool {
 	return bitSet.None()
 }
 
+// contains returns true if the given address refers to the start
+// of a used object slot in this slab
+func (s *slab) contains(obj ObjAddr) bool {
+	dataStart := s.addr() + s.getDataOffset()
+	if obj < dataStart || obj >= s.addr()+s.getTotalLength() {
+		return false
+	}
+	if (obj-dataStart)%uintptr(s.objSize) != 0 {
+		return false
+	}
+	return s.bitSet().Test(s.getObjIdx(obj))
+}
+
+// nextObj returns the index of the next used object slot, starting at
+// the given index. Instead of testing the slots one by one it scans the
+// BitSet one 64bit word at a time, so unused ranges get skipped quickly
+// The second returned value is false if there is no further used slot
+func (s *slab) nextObj(idx uint) (uint, bool) {
+	words := s.bitSet().Bytes()
+	wordIdx := int(idx / 64)
+	if wordIdx >= len(words) {
+		return 0, false
+	}
+
+	// shift out the bits before idx in the first word
+	word := words[wordIdx] >> (idx % 64)
+	if word != 0 {
+		return idx + uint(bits.TrailingZeros64(word)), true
+	}
+
+	for wordIdx++; wordIdx < len(words); wordIdx++ {
+		if words[wordIdx] != 0 {
+			return uint(wordIdx)*64 + uint(bits.TrailingZeros64(words[wordIdx])), true
+		}
+	}
+
+	return 0, false
+}
+
+// zero overwrites the given memory with zeros
+func zero(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// poisonByte is the pattern that freed object slots get filled with
+// if poisonFreedSlots is enabled
+const poisonByte = 0xDE
+
+// poisonSlot fills the object slot at the given index with poisonByte
+func (s *slab) poisonSlot(idx uint) {
+	slot := s.getObjByIdx(idx)
+	for i := range slot {
+		slot[i] = poisonByte
+	}
+}
+
+// checkPoison verifies that the object slot at the given index is still
+// filled with poisonByte, if it isn't then something has written to the
+// slot after its object got deleted and checkPoison panics
+func (s *slab) checkPoison(idx uint) {
+	for _, b := range s.getObjByIdx(idx) {
+		if b != poisonByte {
+			panic(fmt.Sprintf("ObjectStore: slot %d in slab %d has been written to after its object got deleted", idx, s.addr()))
+		}
+	}
+}
+
 // getObjByIdx returns the object at the given index as a byte slice
 func (s *slab) getObjByIdx(idx uint) []byte {
-	return objFromObjAddr(uintptr(unsafe.Pointer(s))+s.getObjOffset(idx), s.objSize)
+	return objFromObjAddr(s.getObjAddrByIdx(idx), s.objSize)
+}
+
+// getObjAddrByIdx returns the address of the object at the given index
+func (s *slab) getObjAddrByIdx(idx uint) ObjAddr {
+	return uintptr(unsafe.Pointer(s)) + s.getObjOffset(idx)
 }
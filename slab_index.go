@@ -0,0 +1,150 @@
+package gos
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	// radixBits is the number of bits of a page number which select the
+	// child at each level of a slabIndex
+	radixBits   = 9
+	radixFanout = 1 << radixBits
+
+	// sharedPage marks a page in a slabIndex which contains parts of more
+	// than one slab, this only happens with backends like HeapBackend whose
+	// memory areas aren't page aligned
+	sharedPage = ^SlabAddr(0)
+)
+
+// slabIndex is a radix tree which maps the pages of all slabs of a store to
+// the address of the slab they belong to, so the slab of an object address
+// is found with one lookup per level instead of a binary search. Changes
+// must be serialized, but lookups can run concurrently with them because
+// the children and slabs get loaded and stored atomically
+type slabIndex struct {
+	pageShift uint
+	levels    int
+	root      *radixNode
+}
+
+// radixNode is a node of a slabIndex, the nodes at the last level have
+// slabs and all others have children. used is the number of children or
+// slabs which are set
+type radixNode struct {
+	children []atomic.Pointer[radixNode]
+	slabs    []atomic.Uintptr
+	used     int
+}
+
+// newSlabIndex creates an empty slabIndex for the page size of the system
+func newSlabIndex() *slabIndex {
+	pageShift := uint(bits.TrailingZeros(uint(systemPageSize)))
+	levels := (bits.UintSize - int(pageShift) + radixBits - 1) / radixBits
+	x := &slabIndex{pageShift: pageShift, levels: levels}
+	x.root = x.newNode(0)
+	return x
+}
+
+// newNode creates a node for the given level
+func (x *slabIndex) newNode(level int) *radixNode {
+	if level == x.levels-1 {
+		return &radixNode{slabs: make([]atomic.Uintptr, radixFanout)}
+	}
+	return &radixNode{children: make([]atomic.Pointer[radixNode], radixFanout)}
+}
+
+// key returns the index of the child or slab which the page belongs to at
+// the given level
+func (x *slabIndex) key(page uintptr, level int) uintptr {
+	return (page >> (uint(x.levels-1-level) * radixBits)) & (radixFanout - 1)
+}
+
+// insert adds the pages of the slab at the given address with the given
+// total length to the index
+func (x *slabIndex) insert(sAddr SlabAddr, length uintptr) {
+	for page := sAddr >> x.pageShift; page <= (sAddr+length-1)>>x.pageShift; page++ {
+		node := x.root
+		for level := 0; level < x.levels-1; level++ {
+			key := x.key(page, level)
+			child := node.children[key].Load()
+			if child == nil {
+				child = x.newNode(level + 1)
+				node.children[key].Store(child)
+				node.used++
+			}
+			node = child
+		}
+
+		key := x.key(page, x.levels-1)
+		switch node.slabs[key].Load() {
+		case 0:
+			node.slabs[key].Store(sAddr)
+			node.used++
+		case sAddr:
+		default:
+			node.slabs[key].Store(sharedPage)
+		}
+	}
+}
+
+// remove removes the pages of the slab at the given address from the
+// index, starting at the page of the slab address until reaching a page
+// which belongs to another slab. Pages which are shared with other slabs
+// stay marked as shared
+func (x *slabIndex) remove(sAddr SlabAddr) {
+	first := sAddr >> x.pageShift
+	for page := first; ; page++ {
+		switch x.lookupPage(page) {
+		case sAddr:
+			x.clear(x.root, page, 0)
+		case sharedPage:
+			if page != first {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// clear unsets the slab of the given page in the subtree of the node at
+// the given level, and removes nodes which become empty
+// It returns true if the node has become empty
+func (x *slabIndex) clear(node *radixNode, page uintptr, level int) bool {
+	key := x.key(page, level)
+	if level == x.levels-1 {
+		node.slabs[key].Store(0)
+	} else if x.clear(node.children[key].Load(), page, level+1) {
+		node.children[key].Store(nil)
+	} else {
+		return false
+	}
+	node.used--
+	return node.used == 0 && node != x.root
+}
+
+// lookupPage returns the slab address that the given page belongs to, 0 if
+// it doesn't belong to any slab or sharedPage if it belongs to several
+func (x *slabIndex) lookupPage(page uintptr) SlabAddr {
+	node := x.root
+	for level := 0; level < x.levels-1; level++ {
+		node = node.children[x.key(page, level)].Load()
+		if node == nil {
+			return 0
+		}
+	}
+	return node.slabs[x.key(page, x.levels-1)].Load()
+}
+
+// lookup returns the address of the slab which contains the given object
+// address
+// The second returned value is false if no slab or more than one slab
+// contains the page of the object address
+func (x *slabIndex) lookup(obj ObjAddr) (SlabAddr, bool) {
+	sAddr := x.lookupPage(obj >> x.pageShift)
+	if sAddr == 0 || sAddr == sharedPage {
+		return 0, false
+	}
+	return sAddr, true
+}
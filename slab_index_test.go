@@ -0,0 +1,87 @@
+package gos
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSlabIndex(t *testing.T) {
+	Convey("When adding slabs to a slab index", t, func() {
+		pageSize := uintptr(systemPageSize)
+		x := newSlabIndex()
+		first := 1000 * pageSize
+		second := first + 3*pageSize
+		third := second + 2*pageSize + pageSize/2
+		x.insert(first, 3*pageSize)
+		x.insert(second, 2*pageSize+pageSize/2)
+		x.insert(third, pageSize)
+
+		Convey("each address in a slab should be resolved to it", func() {
+			for _, obj := range []ObjAddr{first, first + pageSize + 5, second - 1} {
+				sAddr, ok := x.lookup(obj)
+				So(ok, ShouldBeTrue)
+				So(sAddr, ShouldEqual, first)
+			}
+			sAddr, ok := x.lookup(second + pageSize)
+			So(ok, ShouldBeTrue)
+			So(sAddr, ShouldEqual, second)
+			sAddr, ok = x.lookup(third + pageSize)
+			So(ok, ShouldBeTrue)
+			So(sAddr, ShouldEqual, third)
+		})
+
+		Convey("pages which are shared or not in a slab should not be resolved", func() {
+			for _, obj := range []ObjAddr{third, third - 1, first - 1, third + 2*pageSize, first + 1000*pageSize} {
+				_, ok := x.lookup(obj)
+				So(ok, ShouldBeFalse)
+			}
+		})
+
+		Convey("removing the slabs should remove all their pages and nodes", func() {
+			x.remove(second)
+			_, ok := x.lookup(second)
+			So(ok, ShouldBeFalse)
+			sAddr, ok := x.lookup(first)
+			So(ok, ShouldBeTrue)
+			So(sAddr, ShouldEqual, first)
+
+			x.remove(first)
+			x.remove(third)
+			_, ok = x.lookup(third + pageSize)
+			So(ok, ShouldBeFalse)
+
+			// only the page which has been shared stays marked
+			So(x.lookupPage(third>>x.pageShift), ShouldEqual, sharedPage)
+			x.clear(x.root, third>>x.pageShift, 0)
+			So(x.root.used, ShouldEqual, 0)
+		})
+	})
+
+	Convey("When looking up objects in a store with many slabs", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(4))
+		var objs []ObjAddr
+		for i := 0; i < 64; i++ {
+			obj, err := os.Add([]byte{byte(i), 1, 2, 3})
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("the slab index should resolve every object to its slab", func() {
+			for _, obj := range objs {
+				_, owner, ok := os.Owner(obj)
+				So(ok, ShouldBeTrue)
+				sAddr, ok := os.slabIndex.lookup(obj)
+				So(ok, ShouldBeTrue)
+				So(sAddr, ShouldEqual, owner)
+			}
+		})
+
+		Convey("deleting all objects should empty the slab index", func() {
+			for _, obj := range objs {
+				So(os.Delete(obj), ShouldBeNil)
+			}
+			So(os.slabIndex.root.used, ShouldEqual, 0)
+		})
+	})
+}
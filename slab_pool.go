@@ -1,37 +1,225 @@
 package gos
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"reflect"
 	"runtime"
 	"sort"
 	"sync"
 	"sync/atomic"
-	"syscall"
+	"time"
 	"unsafe"
-
-	"github.com/willf/bitset"
 )
 
 // slabPool is a struct that contains and manages multiple slabs of data
 // all objects in all the slabs must have the same size
+// all methods of slabPool are safe for concurrent use, the lock protects
+// the slab list, the slab states and the BitSets inside the slabs
 type slabPool struct {
+	lock        sync.RWMutex
 	slabs       []*slab
 	objSize     uint8
 	objsPerSlab uint
-	freeSlabs   bitset.BitSet
+
+	// maxObjsPerSlab is the capacity up to which the capacity of new slabs
+	// grows if it is greater than objsPerSlab, see WithAdaptiveObjsPerSlab
+	maxObjsPerSlab uint
+
+	// growObjsPerSlab is the capacity up to which full slabs get grown in
+	// place if it is not 0, see WithSlabGrowth. slabGrown gets called with
+	// the address of each slab that has grown, it may be nil
+	growObjsPerSlab uint
+	slabGrown       func(SlabAddr)
+
+	// capacities is the sorted set of the numbers of objects per slab that
+	// the slabs of the pool may have. Snapshots contain a pool section per
+	// capacity, so it may only change while the store's pools lock is
+	// held for writing
+	capacities []uint
+
+	// states contains the bookkeeping of each slab
+	states map[*slab]*slabState
+
+	// lists separates the slabs into empty, partial and full ones, so add
+	// can pick a slab with free slots without looking at full slabs
+	lists [slabListCount][]*slabState
+
+	// snapshotIters is the number of IterSnapshot calls which have
+	// captured the pool, compaction leaves the pool alone meanwhile
+	snapshotIters atomic.Int32
+
+	// reclaimEmptySlabs defines whether slabs get unmapped as soon as
+	// they become empty. If it is false, empty slabs are kept for reuse
+	reclaimEmptySlabs bool
+
+	// recycleSlabs is the number of empty slabs which are kept for reuse
+	// even if reclaimEmptySlabs is true
+	recycleSlabs int
+
+	// varLen indicates that this pool stores objects of variable length.
+	// In this mode objSize is the size of each slot, the first byte of a
+	// slot is the length of the stored object and the object data follows
+	varLen bool
+
+	// backend is used to allocate and release the slab memory
+	backend Backend
+
+	// quarantine defers unmapping the memory of deleted slabs while
+	// there are reads that might refer to it, it may be nil
+	quarantine *quarantine
+
+	// lockFreeSlabs is a copy of slabs which gets published on every
+	// change if lockFreeReads is set, see WithLockFreeReads. slabUnlinked
+	// gets called with the address of each deleted slab before its memory
+	// gets unmapped or retired, it may be nil
+	lockFreeReads bool
+	lockFreeSlabs atomic.Pointer[[]*slab]
+	slabUnlinked  func(SlabAddr)
+
+	// slabLinked gets called with the address of each slab which has been
+	// mapped by the pool, while the pool lock is still held, so lookups
+	// find the slab before any of its objects get handed out. It may be nil
+	slabLinked func(SlabAddr)
+
+	// objMoved gets called with the old and the new slot of each object
+	// which gets moved by a compaction, together with the generations of
+	// both slots. It gets called while the pool lock is held and before
+	// the old slot gets freed, it may be nil
+	objMoved func(from *slab, fromIdx uint, fromGen uint32, to *slab, toIdx uint, toGen uint32)
+
+	// pins counts the pins of each pinned object, see Pin
+	pins map[ObjAddr]uint32
+
+	// refs counts the references of each object beyond the one of the
+	// owner which has added it, see AddRef
+	refs map[ObjAddr]uint32
+
+	// mem accounts the slab memory against a limit, if it is nil
+	// then there is no limit
+	mem *memAccount
+
+	// lastSlab is the slab that has been used most recently by add or
+	// delete, it gets checked before searching through all slabs
+	lastSlab *slab
+
+	// alignedSlabs defines whether the slabs get looked up by masking
+	// object addresses, alignments has a bit set for each alignment of the
+	// slabs which are aligned to the power of two of their size
+	alignedSlabs bool
+	alignments   uintptr
+
+	// bloomFilters contains a bloom filter per slab, search uses them
+	// to skip slabs which can't contain the searched value
+	// if it is nil, then bloom filters are disabled
+	bloomFilters map[*slab]*bloomFilter
+
+	// hashIndex maps the hashes of the objects to their addresses, search
+	// and addOrGet use it to find objects by their value
+	// if it is nil, then the hash index is disabled
+	hashIndex hashIndex
+
+	// secureErase defines whether object memory gets zeroed when
+	// an object gets deleted and when a slab gets unmapped
+	secureErase bool
+
+	// logger receives events about slabs and failures, it may be nil
+	logger eventLogger
+
+	// wal records adds and deletes before they get applied, it may be nil
+	wal *writeAheadLog
+
+	// thp defines how the kernel gets advised about transparent huge
+	// pages for new slabs
+	thp TransparentHugePages
+
+	// prefault defines whether the pages of new slabs get faulted in
+	// right after they have been mapped
+	prefault bool
+
+	// releaseFreePages defines whether the pages of slabs with at most
+	// releaseMaxUsage of their slots used get released if they only
+	// contain free slots
+	releaseFreePages bool
+	releaseMaxUsage  float64
 }
 
 // NewSlabPool initializes a new slab pool and returns a pointer to it
 func NewSlabPool(objSize uint8, objsPerSlab uint) *slabPool {
 	return &slabPool{
-		objSize:     objSize,
-		objsPerSlab: objsPerSlab,
-		freeSlabs:   *bitset.New(0),
+		objSize:           objSize,
+		objsPerSlab:       objsPerSlab,
+		capacities:        []uint{objsPerSlab},
+		reclaimEmptySlabs: true,
+		backend:           defaultBackend,
+		states:            make(map[*slab]*slabState),
+	}
+}
+
+// NewVarLenSlabPool initializes a new slab pool which can store objects
+// of any length up to maxObjSize and returns a pointer to it.
+// Each object is stored with a one byte length prefix, so maxObjSize
+// can be at most 254
+func NewVarLenSlabPool(maxObjSize uint8, objsPerSlab uint) *slabPool {
+	if maxObjSize > 254 {
+		maxObjSize = 254
+	}
+	pool := NewSlabPool(maxObjSize+1, objsPerSlab)
+	pool.varLen = true
+	return pool
+}
+
+// maxObjSize returns the maximum size of the objects that can be stored
+func (s *slabPool) maxObjSize() int {
+	if s.varLen {
+		return int(s.objSize) - 1
+	}
+	return int(s.objSize)
+}
+
+// objByIdx returns the object stored in the given slot of the given
+// slab as a byte slice. In variable-length mode the length prefix is
+// not part of the returned slice
+func (s *slabPool) objByIdx(sl *slab, idx uint) []byte {
+	if s.varLen {
+		return s.get(sl.getObjAddrByIdx(idx))
+	}
+	return sl.getObjByIdx(idx)
+}
+
+// setReclaimEmptySlabs defines whether slabs which become empty due to
+// a delete get unmapped. Slabs which are already empty are not affected
+func (s *slabPool) setReclaimEmptySlabs(reclaim bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reclaimEmptySlabs = reclaim
+}
+
+// enableBloomFilters creates a bloom filter for each slab of the pool
+// and keeps them updated on every add and delete from then on
+func (s *slabPool) enableBloomFilters() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.bloomFilters != nil {
+		return
+	}
+
+	s.bloomFilters = make(map[*slab]*bloomFilter, len(s.slabs))
+	for _, sl := range s.slabs {
+		filter := newBloomFilter(s.states[sl].capacity)
+		for idx, ok := sl.nextObj(0); ok; idx, ok = sl.nextObj(idx + 1) {
+			filter.add(bloomHash(s.objByIdx(sl, idx)))
+		}
+		s.bloomFilters[sl] = filter
 	}
 }
 
 func (s *slabPool) fragStats() float32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
 	length := float32(len(s.slabs))
 
 	if length < 1 {
@@ -41,79 +229,370 @@ func (s *slabPool) fragStats() float32 {
 	var total float32
 
 	// iterate over all slabs in the pool
-	// get fragmentation percent
-	for _, sl := range s.slabs {
-		total += float32(sl.bitSet().Count()) / float32(sl.objsPerSlab())
+	// get fragmentation percent from the free slot counters
+	for _, st := range s.states {
+		total += float32(st.capacity-st.freeCount()) / float32(st.capacity)
 	}
 
 	return total / length
 }
 
 func (s *slabPool) memStats() uint64 {
-	length := uint64(len(s.slabs))
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 
-	if length < 1 {
-		return 0
+	// add MMapped slab usage for the pool
+	var total uint64
+	for _, sl := range s.slabs {
+		total += uint64(sl.getTotalLength())
 	}
+	return total
+}
 
-	// add MMapped slab usage for the pool
-	slabLength := uint64(s.slabs[0].getTotalLength())
-	return length * slabLength
+// fragmentation returns the fragmentation metrics of the pool, including
+// up to sparsest of the least used non-empty slabs
+func (s *slabPool) fragmentation(sparsest int) PoolFragmentation {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := PoolFragmentation{ObjSize: s.objSize}
+	if len(s.slabs) < 1 {
+		return result
+	}
+
+	var free, capacity uint
+	var nonEmpty []SlabInfo
+	for _, st := range s.states {
+		capacity += st.capacity
+		if st.list == emptySlabs {
+			continue
+		}
+		info := s.slabInfoOf(st)
+		free += info.FreeSlots
+		nonEmpty = append(nonEmpty, info)
+	}
+	result.Ratio = float64(free) / float64(capacity)
+
+	sort.Slice(nonEmpty, func(i, j int) bool {
+		if nonEmpty[i].LiveObjects != nonEmpty[j].LiveObjects {
+			return nonEmpty[i].LiveObjects < nonEmpty[j].LiveObjects
+		}
+		return nonEmpty[i].Addr < nonEmpty[j].Addr
+	})
+	if len(nonEmpty) > sparsest {
+		nonEmpty = nonEmpty[:sparsest]
+	}
+	result.SparsestSlabs = nonEmpty
+
+	return result
+}
+
+// detailedMemStats returns the PoolMemStats of the pool
+func (s *slabPool) detailedMemStats() PoolMemStats {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	stats := PoolMemStats{ObjSize: s.objSize, Slabs: uint64(len(s.slabs))}
+	pageSize := uint64(systemPageSize)
+	for _, st := range s.states {
+		size := uint64(st.slab.getTotalLength())
+		free := uint64(st.freeCount())
+		stats.MappedBytes += size
+		stats.LiveObjects += uint64(st.capacity) - free
+		stats.FreeSlots += free
+		stats.PaddingBytes += (size+pageSize-1)/pageSize*pageSize - size
+	}
+
+	return stats
 }
 
 // add adds an object to the pool
-// It will try to find a slab that has a free object slot to avoid
-// unnecessary allocations. If it can't find a free slot, it will add a
-// slab and then use that one
+// It prefers partially used slabs over empty ones, so the objects get
+// concentrated in as few slabs as possible. Only if there is neither a
+// partial nor an empty slab, it will add a slab and then use that one
 // The first return value is the ObjAddr of the added object
 // The second value is the slab address if the call created a new slab
 // If no new slab has been created, then the second value is 0
 // The third value is nil if there was no error, otherwise it is the error
 func (s *slabPool) add(obj []byte) (ObjAddr, SlabAddr, error) {
-	var currentSlab *slab
-	var objIdx uint
-
-	slabCount := uint(len(s.slabs))
-
-	found := false
-	exists := false
-	var slabIdx uint
-	if slabCount > 0 {
-		slabIdx, found = s.freeSlabs.NextClear(0)
-
-		if found {
-			currentSlab = s.slabs[slabIdx]
-			objIdx, exists = currentSlab.bitSet().NextClear(0)
-			if !exists {
-				return 0, 0, fmt.Errorf("Add: Failed to add object into slab")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.addLocked(obj)
+}
+
+// addBatched adds many objects to the pool at once. It first creates
+// all the slabs which are required to store the objects, then it adds
+// the objects while holding the pool lock only once
+// On success the first returned value contains the ObjAddrs of the
+// added objects in the same order as the given objects, the second
+// value contains the addresses of the slabs which have been created
+// On failure the third value is the error and no objects have been added
+func (s *slabPool) addBatched(objs [][]byte) ([]ObjAddr, []SlabAddr, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, obj := range objs {
+		if len(obj) > s.maxObjSize() {
+			return nil, nil, fmt.Errorf("Add: Size of object (%d) exceeds the maximum of the pool (%d): %w", len(obj), s.maxObjSize(), ErrInvalidSize)
+		}
+	}
+
+	// create the missing slabs in one go
+	newSlabs, err := s.ensureFreeSlots(uint(len(objs)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// take all the slots first, so all the objects can be recorded
+	// by a single record of the write-ahead log
+	states := make([]*slabState, len(objs))
+	slots := make([]uint, len(objs))
+	for i := range objs {
+		st, _, err := s.slabWithFreeSlot()
+		if err != nil {
+			// this shouldn't happen, because we have created enough slabs
+			return nil, nil, err
+		}
+		states[i], slots[i] = st, st.popFree()
+		s.updateList(st)
+	}
+
+	if s.wal != nil {
+		record := s.wal.newRecord()
+		for i, obj := range objs {
+			s.wal.add(record, states[i].slab, slots[i], obj, s.varLen)
+		}
+		if err := s.wal.commit(record); err != nil {
+			for i := range objs {
+				s.releaseSlot(states[i], slots[i])
+			}
+			for _, slabAddr := range newSlabs {
+				s.deleteSlab(slabAddr)
 			}
+			return nil, nil, err
 		}
+		defer s.wal.applied()
 	}
 
-	var newSlab SlabAddr
-	if !found {
+	objAddrs := make([]ObjAddr, len(objs))
+	for i, obj := range objs {
+		objAddr, err := s.storeObj(states[i], slots[i], obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		objAddrs[i] = objAddr
+	}
+
+	return objAddrs, newSlabs, nil
+}
+
+// reserve creates as many slabs as are required to add n more objects
+// without creating any further slabs, this avoids the latency of slab
+// creation during bursts of adds
+// On success it returns the addresses of the created slabs
+// On failure the second returned value is the error, in this case no
+// slabs have been created
+func (s *slabPool) reserve(n uint) ([]SlabAddr, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.ensureFreeSlots(n)
+}
+
+// ensureFreeSlots creates slabs until there are at least n free slots
+// On success it returns the addresses of the created slabs
+// On failure the created slabs are deleted again and the error is returned
+// The caller must hold the pool lock for writing
+func (s *slabPool) ensureFreeSlots(n uint) ([]SlabAddr, error) {
+	// count the free slots of all slabs that aren't full
+	var free uint
+	for _, st := range s.lists[partialSlabs] {
+		free += st.freeCount()
+	}
+	for _, st := range s.lists[emptySlabs] {
+		free += st.capacity
+	}
+
+	if n <= free || s.objsPerSlab == 0 {
+		return nil, nil
+	}
+
+	for free < n {
+		st := s.growSlab()
+		if st == nil {
+			break
+		}
+		free += st.freeCount()
+	}
+
+	var newSlabs []SlabAddr
+	if free < n {
+		newSlabs = s.addSlabsBulk(n - free)
+		for _, slabAddr := range newSlabs {
+			free += s.states[slabFromSlabAddr(slabAddr)].capacity
+		}
+	}
+
+	for free < n {
 		newIdx, err := s.addSlab()
 		if err != nil {
+			// remove the slabs that we have created so far
+			for _, slabAddr := range newSlabs {
+				s.deleteSlab(slabAddr)
+			}
+			return nil, err
+		}
+		newSlabs = append(newSlabs, s.slabs[newIdx].addr())
+		free += s.states[s.slabs[newIdx]].capacity
+	}
+
+	return newSlabs, nil
+}
+
+// addLocked adds an object to the pool, it is used by add
+// The caller must hold the pool lock for writing
+func (s *slabPool) addLocked(obj []byte) (ObjAddr, SlabAddr, error) {
+	if len(obj) > s.maxObjSize() {
+		return 0, 0, fmt.Errorf("Add: Size of object (%d) exceeds the maximum of the pool (%d): %w", len(obj), s.maxObjSize(), ErrInvalidSize)
+	}
+
+	st, newSlab, err := s.slabWithFreeSlot()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	idx := st.popFree()
+
+	if s.wal != nil {
+		record := s.wal.newRecord()
+		s.wal.add(record, st.slab, idx, obj, s.varLen)
+		if err := s.wal.commit(record); err != nil {
+			s.releaseSlot(st, idx)
+			if newSlab != 0 {
+				s.deleteSlab(newSlab)
+			}
 			return 0, 0, err
 		}
-		currentSlab = s.slabs[newIdx]
-		slabIdx = uint(newIdx)
-		newSlab = SlabAddr(unsafe.Pointer(currentSlab))
-		objIdx = 0
+		defer s.wal.applied()
+	}
+
+	objAddr, err := s.storeObj(st, idx, obj)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	objAddr, full, success := currentSlab.addObj(obj, objIdx)
+	return objAddr, newSlab, nil
+}
+
+// storeObj writes the object into the slot at the given index, which has
+// been taken with popFree, and marks it as used
+// The caller must hold the pool lock for writing
+func (s *slabPool) storeObj(st *slabState, idx uint, obj []byte) (ObjAddr, error) {
+	var objAddr ObjAddr
+	var success bool
+	if s.varLen {
+		objAddr, _, success = st.slab.addVarLenObj(obj, idx)
+	} else {
+		objAddr, _, success = st.slab.addObj(obj, idx)
+	}
 	if !success {
-		// this shouldn't happen, because we first checked via freeSlabs
-		// whether this slab has space or not
-		return 0, 0, fmt.Errorf("Add: Failed to add object into slab")
+		// this shouldn't happen, because slabs on the partial and
+		// empty lists always have a free slot
+		return 0, fmt.Errorf("Add: Failed to add object into slab: %w", ErrSlabFull)
 	}
-	if full {
-		// mark that slab as full so nothing more gets added
-		s.freeSlabs.Set(slabIdx)
+	st.dirty.Store(true)
+	s.updateList(st)
+
+	if s.bloomFilters != nil {
+		s.bloomFilters[st.slab].add(bloomHash(obj))
+	}
+	if s.hashIndex != nil {
+		s.hashIndex.add(bloomHash(obj), objAddr)
 	}
 
-	return objAddr, newSlab, nil
+	s.lastSlab = st.slab
+
+	return objAddr, nil
+}
+
+// releaseSlot returns a slot which has been taken with popFree, but
+// which hasn't been used
+// The caller must hold the pool lock for writing
+func (s *slabPool) releaseSlot(st *slabState, idx uint) {
+	st.pushFree(idx)
+	s.updateList(st)
+}
+
+// addReserve allocates an object slot and marks it as used, then it returns
+// a writable byte slice that refers to the slot's memory. This allows the
+// caller to write an object directly into the slab instead of building it
+// in a separate byte slice which then gets copied by add
+// The returned slice is zeroed and its length is the pool's object size
+// Variable-length pools and pools with bloom filters or a hash index don't
+// support this, because the pool needs to know the object before it gets
+// stored. If the pool has a write-ahead log, only the reservation of the
+// zeroed slot gets recorded, but not what gets written into it
+// The first return value is the ObjAddr of the reserved slot
+// The second value is the slab address if the call created a new slab
+// The fourth value is nil if there was no error, otherwise it is the error
+func (s *slabPool) addReserve() (ObjAddr, []byte, SlabAddr, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.varLen || s.bloomFilters != nil || s.hashIndex != nil {
+		return 0, nil, 0, fmt.Errorf("Add: Reserving slots is not supported by variable-length pools or pools with bloom filters or a hash index")
+	}
+
+	st, newSlab, err := s.slabWithFreeSlot()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	idx := st.popFree()
+
+	if s.wal != nil {
+		record := s.wal.newRecord()
+		s.wal.add(record, st.slab, idx, nil, false)
+		if err := s.wal.commit(record); err != nil {
+			s.releaseSlot(st, idx)
+			if newSlab != 0 {
+				s.deleteSlab(newSlab)
+			}
+			return 0, nil, 0, err
+		}
+		defer s.wal.applied()
+	}
+
+	objAddr, obj := st.slab.reserveObj(idx)
+	st.dirty.Store(true)
+	s.updateList(st)
+	s.lastSlab = st.slab
+
+	return objAddr, obj, newSlab, nil
+}
+
+// slabWithFreeSlot returns the state of a slab which has a free slot
+// It prefers partial slabs over empty ones and only creates a new slab
+// if there is no other choice, in which case the second returned value
+// is the address of the new slab
+// The caller must hold the pool lock for writing
+func (s *slabPool) slabWithFreeSlot() (*slabState, SlabAddr, error) {
+	if partial := s.lists[partialSlabs]; len(partial) > 0 {
+		return partial[len(partial)-1], 0, nil
+	}
+	if empty := s.lists[emptySlabs]; len(empty) > 0 {
+		return empty[len(empty)-1], 0, nil
+	}
+	if st := s.growSlab(); st != nil {
+		return st, 0, nil
+	}
+
+	newIdx, err := s.addSlab()
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.states[s.slabs[newIdx]], s.slabs[newIdx].addr(), nil
 }
 
 // delete takes an ObjAddr and a SlabAddr, it will delete the according
@@ -123,16 +602,95 @@ func (s *slabPool) add(obj []byte) (ObjAddr, SlabAddr, error) {
 // On success it returns false and nil if the slab was not also deleted.
 // On error it returns false and an error.
 func (s *slabPool) delete(obj ObjAddr, slabAddr SlabAddr) (bool, error) {
-	empty := slabFromSlabAddr(slabAddr).delete(obj)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.deleteFromSlab(obj, slabAddr)
+}
+
+// deleteObj takes an ObjAddr and deletes the according object from
+// the slab that contains it. Unlike delete it doesn't require the
+// caller to know the slab address.
+// If the slab becomes empty and reclaimEmptySlabs is enabled, then
+// the slab gets unmapped unless it gets kept for recycling.
+// On success it returns true and nil if the slab was also deleted.
+// On success it returns false and nil if the slab was not also deleted.
+// On error it returns false and an error.
+func (s *slabPool) deleteObj(obj ObjAddr) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	currentSlab := s.lastSlab
+	if currentSlab == nil || obj < currentSlab.addr() || obj >= currentSlab.addr()+currentSlab.getTotalLength() {
+		currentSlab = s.findSlab(obj)
+		if currentSlab == nil {
+			return false, fmt.Errorf("Delete: Failed to find slab of object address %d: %w", obj, ErrInvalidAddr)
+		}
+	}
+
+	if obj < currentSlab.addr()+currentSlab.getDataOffset() || obj >= currentSlab.addr()+currentSlab.getTotalLength() {
+		return false, fmt.Errorf("Delete: Object address %d is outside of the slab at %d: %w", obj, currentSlab.addr(), ErrInvalidAddr)
+	}
+
+	return s.deleteFromSlab(obj, currentSlab.addr())
+}
+
+// deleteFromSlab deletes the object from the slab at the given address
+// and updates the free slab accounting, it is used by delete and deleteObj
+// The caller must hold the pool lock for writing
+func (s *slabPool) deleteFromSlab(obj ObjAddr, slabAddr SlabAddr) (bool, error) {
+	if err := s.checkObjAddr(obj, slabAddr); err != nil {
+		if addrErr, ok := err.(*AddrError); ok && addrErr.unused {
+			return false, &DoubleFreeError{Slab: slabAddr, Slot: addrErr.slot}
+		}
+		return false, err
+	}
+
+	currentSlab := slabFromSlabAddr(slabAddr)
+	if s.states[currentSlab].sealed {
+		return false, fmt.Errorf("Delete: slab %d is sealed: %w", slabAddr, ErrSealed)
+	}
+	s.lastSlab = currentSlab
+
+	if s.wal != nil {
+		record := s.wal.newRecord()
+		s.wal.delete(record, currentSlab, currentSlab.getObjIdx(obj))
+		if err := s.wal.commit(record); err != nil {
+			return false, err
+		}
+		defer s.wal.applied()
+	}
+
+	if s.bloomFilters != nil {
+		s.bloomFilters[currentSlab].remove(bloomHash(s.get(obj)))
+	}
+	if s.hashIndex != nil {
+		s.hashIndex.remove(bloomHash(s.get(obj)), obj)
+	}
+
+	empty := currentSlab.delete(obj)
 
-	if empty {
+	// the freed slot can be reused by the next add
+	st := s.states[currentSlab]
+	idx := currentSlab.getObjIdx(obj)
+	st.gens[idx]++
+	st.dirty.Store(true)
+
+	// the slot gets zeroed before pushFree, because in debug builds
+	// pushFree poisons it and popFree expects the poison to be intact
+	if s.secureErase {
+		zero(currentSlab.getObjByIdx(idx))
+	}
+	st.pushFree(idx)
+	s.dropPins(obj, st)
+	delete(s.refs, obj)
+	s.updateList(st)
+
+	if empty && s.reclaimEmptySlabs && len(s.lists[emptySlabs]) > s.recycleSlabs {
 		return s.deleteSlab(slabAddr)
-	} else {
-		// the slab isn't empty, but since we've just deleted an object
-		// we know that there is at least one free slot, so we mark it
-		// accordingly
-		slabIdx := s.findSlabByAddr(slabAddr)
-		s.freeSlabs.Clear(uint(slabIdx))
+	}
+	if s.releaseFreePages {
+		s.releasePages(st, idx)
 	}
 
 	return false, nil
@@ -144,36 +702,182 @@ func (s *slabPool) delete(obj ObjAddr, slabAddr SlabAddr) (bool, error) {
 // It returns the slab index if the correct slab was found, otherwise
 // the return value is the number of known slabs.
 // For the lookup to succeed it relies on s.slabs to be sorted in descending order
+// The caller must hold the pool lock
 func (s *slabPool) findSlabByAddr(obj uintptr) int {
 	return sort.Search(len(s.slabs), func(i int) bool { return s.slabs[i].addr() <= obj })
 }
 
+// findSlab returns the slab which is likely to contain the given object
+// address like findSlabByAddr, or nil if there is none. Aligned slabs are
+// found by masking the object address instead of the binary search
+// The caller must hold the pool lock
+func (s *slabPool) findSlab(obj uintptr) *slab {
+	if sAddr, ok := maskedSlab(obj, s.alignments, s.hasSlab); ok {
+		return slabFromSlabAddr(sAddr)
+	}
+	slabIdx := s.findSlabByAddr(obj)
+	if slabIdx >= len(s.slabs) {
+		return nil
+	}
+	return s.slabs[slabIdx]
+}
+
+// hasSlab returns true if a slab of the pool starts at the given address
+// The caller must hold the pool lock
+func (s *slabPool) hasSlab(sAddr SlabAddr) bool {
+	_, ok := s.states[slabFromSlabAddr(sAddr)]
+	return ok
+}
+
 // addSlab adds another slab to the pool and initalizes the related structs
 // on success the first returned value is the index of the new slab
 // on failure the second returned value is the error message
+// The caller must hold the pool lock for writing
 func (s *slabPool) addSlab() (int, error) {
-	addedSlab, err := newSlab(s.objSize, s.objsPerSlab)
+	return s.addSlabWithCapacity(s.nextSlabCapacity())
+}
+
+// addSlabWithCapacity adds a slab with the given number of object slots
+// to the pool, see addSlab
+// The caller must hold the pool lock for writing
+func (s *slabPool) addSlabWithCapacity(capacity uint) (int, error) {
+	reserved := s.reservedCapacity(capacity)
+	size := uint64(growableSlabSize(s.objSize, capacity, reserved))
+	if !s.mem.reserve(size) {
+		if s.logger != nil {
+			s.logger.Warn("gos: slab rejected by memory limit", "objSize", s.objSize, "slabSize", size, "limit", s.mem.max)
+		}
+		return 0, fmt.Errorf("Add: Failed to add slab because it would exceed the memory limit of %d bytes: %w", s.mem.max, ErrMemoryLimit)
+	}
+
+	addedSlab, err := newGrowableSlab(s.backend, s.objSize, capacity, reserved)
 	if err != nil {
+		s.mem.release(size)
+		if s.logger != nil {
+			s.logger.Warn("gos: failed to map slab", "objSize", s.objSize, "slabSize", size, "error", err)
+		}
 		return 0, err
 	}
 
-	newSlabAddr := addedSlab.addr()
+	return s.setupSlab(addedSlab), nil
+}
+
+// setupSlab applies the memory advice of the pool to a slab which has just
+// been mapped and adds it to the slab list and the empty slabs
+// It returns the index of the slab in the slab list
+// The caller must hold the pool lock for writing
+func (s *slabPool) setupSlab(addedSlab *slab) int {
+	if s.thp != THPDefault {
+		if err := adviseTHP(addedSlab.memory(), s.thp); err != nil && s.logger != nil {
+			s.logger.Warn("gos: failed to advise transparent huge pages", "objSize", s.objSize, "slab", addedSlab.addr(), "error", err)
+		}
+	}
+	if s.prefault {
+		prefaultSlab(addedSlab)
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("gos: slab created", "objSize", s.objSize, "slab", addedSlab.addr())
+	}
+
+	insertAt, st := s.insertSlab(addedSlab)
+	s.addToList(st, emptySlabs)
+	if s.slabLinked != nil {
+		s.slabLinked(addedSlab.addr())
+	}
+
+	return insertAt
+}
 
+// insertSlab adds the given slab to the slab list and creates its state,
+// the caller must put the state on a slab list
+// It returns the index of the slab in the slab list and its state
+// The caller must hold the pool lock for writing
+func (s *slabPool) insertSlab(sl *slab) (int, *slabState) {
 	// find the right location to insert the new slab
 	// note that s.slabs must remain sorted
+	newSlabAddr := sl.addr()
+	if s.alignedSlabs {
+		s.alignments |= alignmentOf(newSlabAddr)
+	}
 	insertAt := sort.Search(len(s.slabs), func(i int) bool { return s.slabs[i].addr() < newSlabAddr })
 	s.slabs = append(s.slabs, &slab{})
 	copy(s.slabs[insertAt+1:], s.slabs[insertAt:])
-	s.slabs[insertAt] = addedSlab
+	s.slabs[insertAt] = sl
+
+	capacity := sl.objsPerSlab()
+	if s.bloomFilters != nil {
+		s.bloomFilters[sl] = newBloomFilter(capacity)
+	}
+
+	st := &slabState{slab: sl, capacity: capacity, gens: make([]uint32, capacity), created: time.Now()}
+	st.dirty.Store(true)
+	s.states[sl] = st
+	s.publishSlabs()
+
+	return insertAt, st
+}
+
+// rebuildState rebuilds the state of a slab whose BitSet has been filled
+// from outside of the pool, and moves the slab onto the right slab list.
+// Slots after the last used one are treated as never used, all the
+// others go on the free stack
+// On success it returns nil, if the BitSet has bits set beyond the end
+// of the slab it returns an error
+// The caller must hold the pool lock for writing
+func (s *slabPool) rebuildState(st *slabState) error {
+	sl := st.slab
+	st.next = 0
+	st.free = st.free[:0]
+	for objIdx, ok := sl.nextObj(0); ok; objIdx, ok = sl.nextObj(objIdx + 1) {
+		if objIdx >= st.capacity {
+			return fmt.Errorf("BitSet has slot %d set, but the slab only has %d slots", objIdx, st.capacity)
+		}
+		for free := st.next; free < objIdx; free++ {
+			st.free = append(st.free, free)
+		}
+		st.next = objIdx + 1
+		if s.bloomFilters != nil {
+			s.bloomFilters[sl].add(bloomHash(s.objByIdx(sl, objIdx)))
+		}
+	}
+	if s.hashIndex != nil {
+		s.indexSlab(sl)
+	}
+	s.updateList(st)
 
-	s.freeSlabs.InsertAt(uint(insertAt))
+	return nil
+}
 
-	return insertAt, nil
+// unmapSlab unmaps the memory of a deleted slab and releases it from the
+// memory account. If the pool has a quarantine, the memory only gets
+// unmapped once no read that might refer to it is active anymore, in that
+// case errors only get logged
+// On success it returns nil, otherwise it returns an error
+// The caller must hold the pool lock for writing
+func (s *slabPool) unmapSlab(slabAddr SlabAddr, data []byte) error {
+	unmap := func() error {
+		if err := s.backend.Unmap(data); err != nil {
+			if s.logger != nil {
+				s.logger.Warn("gos: failed to unmap slab", "objSize", s.objSize, "slab", slabAddr, "error", err)
+			}
+			return err
+		}
+		s.mem.release(uint64(len(data)))
+		return nil
+	}
+
+	if s.quarantine != nil {
+		s.quarantine.retire(func() { unmap() })
+		return nil
+	}
+	return unmap()
 }
 
 // deleteSlab deletes the slab at the given slab index
 // on failure it returns false and an error
 // on success it returns true and nil
+// The caller must hold the pool lock for writing
 func (s *slabPool) deleteSlab(slabAddr SlabAddr) (bool, error) {
 	slabIdx := s.findSlabByAddr(uintptr(slabAddr))
 
@@ -184,23 +888,44 @@ func (s *slabPool) deleteSlab(slabAddr SlabAddr) (bool, error) {
 	s.slabs[len(s.slabs)-1] = &slab{}
 	s.slabs = s.slabs[:len(s.slabs)-1]
 
-	totalLen := int(currentSlab.getTotalLength())
+	// remove the slab from its slab list, the memory of sealed slabs
+	// is read-only
+	sealed := s.states[currentSlab].sealed
+	s.removeFromList(s.states[currentSlab])
+	delete(s.states, currentSlab)
+
+	if s.bloomFilters != nil {
+		delete(s.bloomFilters, currentSlab)
+	}
+	if s.hashIndex != nil {
+		s.unindexSlab(currentSlab)
+	}
+
+	if s.lastSlab == currentSlab {
+		s.lastSlab = nil
+	}
+
+	// neither lookups nor lock-free reads must find the slab anymore
+	// once its memory gets unmapped or retired
+	s.publishSlabs()
+	if s.slabUnlinked != nil {
+		s.slabUnlinked(slabAddr)
+	}
 
 	// unmap the slab's memory
-	// to do so we need to built a byte slice that refers to the whole
-	// slab as its underlying memory area
-	var toDelete []byte
-	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&toDelete))
-	sliceHeader.Data = uintptr(unsafe.Pointer(currentSlab))
-	sliceHeader.Len = totalLen
-	sliceHeader.Cap = sliceHeader.Len
-
-	err := syscall.Munmap(toDelete)
-	if err != nil {
-		return false, err
+	toDelete := currentSlab.memory()
+
+	if s.secureErase && !sealed {
+		zero(toDelete[currentSlab.getDataOffset():])
 	}
 
-	s.freeSlabs.DeleteAt(uint(slabIdx))
+	if err := s.unmapSlab(slabAddr, toDelete); err != nil {
+		return false, fmt.Errorf("Delete: Failed to unmap slab at %d: %w: %w", slabAddr, ErrMmapFailed, err)
+	}
+
+	if s.logger != nil {
+		s.logger.Debug("gos: slab deleted", "objSize", s.objSize, "slab", slabAddr)
+	}
 
 	return true, nil
 }
@@ -210,18 +935,61 @@ func (s *slabPool) deleteSlab(slabAddr SlabAddr) (bool, error) {
 // When found it returns the object address and true,
 // otherwise the second returned value is false
 func (s *slabPool) search(searching []byte) (ObjAddr, bool) {
+	obj, found, _ := s.searchContext(context.Background(), searching)
+	return obj, found
+}
+
+// searchContext implements search, the search stops once the given
+// context has ended
+// On success it returns the object address, true if it has been found,
+// and nil
+// On failure the third returned value is the error of the context
+func (s *slabPool) searchContext(ctx context.Context, searching []byte) (ObjAddr, bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.hashIndex != nil {
+		obj, found := s.lookup(searching)
+		return obj, found, nil
+	}
+
+	return s.searchSlabs(ctx, s.slabs, searching, true)
+}
+
+// ended returns true if the context whose done channel is given has ended,
+// the done channel of a context which can't end is nil
+func ended(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// searchSlabs searches the given slabs of the pool for a byte slice like
+// searchContext does. If locked is true the caller holds the pool lock, so
+// the states and bloom filters of the slabs are used to skip slabs,
+// otherwise all slabs get searched
+func (s *slabPool) searchSlabs(ctx context.Context, slabs []*slab, searching []byte, locked bool) (ObjAddr, bool, error) {
+	done := ctx.Done()
+
 	wg := sync.WaitGroup{}
-	objSize := int(s.objSize)
 	var result uintptr
 
+	var searchHash uint64
+	if locked && s.bloomFilters != nil {
+		searchHash = bloomHash(searching)
+	}
+
 	goMaxProcs := runtime.GOMAXPROCS(0)
 	wg.Add(goMaxProcs)
-	slabCount := len(s.slabs)
+	slabCount := len(slabs)
 
 	slabIdxChan := make(chan uint, slabCount)
 
 	go func() {
-		for idx := range s.slabs {
+		for idx := range slabs {
 			slabIdxChan <- uint(idx)
 		}
 		close(slabIdxChan)
@@ -233,25 +1001,35 @@ func (s *slabPool) search(searching []byte) (ObjAddr, bool) {
 			defer wg.Done()
 
 			for slabIdx := range slabIdxChan {
-				currentSlab := s.slabs[slabIdx]
-
-			OBJECT:
-				for objID := uint(0); objID < s.objsPerSlab; objID++ {
-
-					if currentSlab.bitSet().Test(objID) {
-						obj := currentSlab.getObjByIdx(objID)
-						for j := 0; j < objSize; j++ {
-							if obj[j] != searching[j] {
-								continue OBJECT
-							}
-						}
-
-						// found it, store the result atomically
-						atomic.StoreUintptr(&result, objAddrFromObj(obj))
-						return
+				if ended(done) {
+					return
+				}
+				currentSlab := slabs[slabIdx]
+
+				if locked {
+					// skip the slab if it is empty
+					if s.states[currentSlab].list == emptySlabs {
+						continue
+					}
+
+					// skip the slab if its bloom filter says that it can't contain the value
+					if filter, ok := s.bloomFilters[currentSlab]; ok && !filter.mayContain(searchHash) {
+						continue
 					}
 				}
 
+				// only visit the used object slots, bytes.Equal compares
+				// them with an optimized memory comparison
+				for objID, ok := currentSlab.nextObj(0); ok; objID, ok = currentSlab.nextObj(objID + 1) {
+					if !bytes.Equal(s.objByIdx(currentSlab, objID), searching) {
+						continue
+					}
+
+					// found it, store the result atomically
+					atomic.StoreUintptr(&result, currentSlab.getObjAddrByIdx(objID))
+					return
+				}
+
 				// if result has been found by another thread we can exit this thread
 				if atomic.LoadUintptr(&result) > 0 {
 					//fmt.Println("exiting routine because result was found")
@@ -264,7 +1042,10 @@ func (s *slabPool) search(searching []byte) (ObjAddr, bool) {
 
 	wg.Wait()
 
-	return result, result > 0
+	if result == 0 {
+		return 0, false, ctx.Err()
+	}
+	return result, true, nil
 }
 
 // searchBatched searches for a batch of search objects.
@@ -275,61 +1056,302 @@ func (s *slabPool) search(searching []byte) (ObjAddr, bool) {
 // in the returned slice as it was in the search slice.
 // If a searched object has not been found, then the value in the returned
 // slice is 0 at the index of the searched object.
+// If several stored objects are equal to a searched object, the lowest of
+// their addresses is returned, and searched objects which occur several
+// times all get the same address, so the result doesn't depend on the
+// order in which the slabs get searched.
 func (s *slabPool) searchBatched(searching [][]byte) []ObjAddr {
-	wg := sync.WaitGroup{}
+	resultSet, _ := s.searchBatchedContext(context.Background(), searching)
+	return resultSet
+}
+
+// searchBatchedContext implements searchBatched, the search stops once the
+// given context has ended
+// On success it returns the result set and nil
+// On failure the result set contains the objects which have been found
+// before the context has ended, and the second returned value is the error
+// of the context
+func (s *slabPool) searchBatchedContext(ctx context.Context, searching [][]byte) ([]ObjAddr, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 
-	// preallocate the result set that will be returned
-	resultSet := make([]ObjAddr, len(searching))
-	resultsLeft := int32(len(searching))
-	objSize := int(s.objSize)
+	b := &batchSearch{
+		// preallocate the result set that will be returned
+		resultSet: make([]ObjAddr, len(searching)),
+		done:      ctx.Done(),
+	}
+
+	// index the searched objects by their value, so every stored object
+	// only needs a single map lookup instead of a comparison with each
+	// searched object. Duplicate search terms share one entry
+	b.queries = make(map[string][]int, len(searching))
+	for k, searchedObj := range searching {
+		b.queries[string(searchedObj)] = append(b.queries[string(searchedObj)], k)
+	}
+	b.resultsLeft = int32(len(b.queries))
+
+	if s.bloomFilters != nil {
+		b.searchHashes = make([]uint64, 0, len(b.queries))
+		for searchedObj := range b.queries {
+			b.searchHashes = append(b.searchHashes, bloomHash([]byte(searchedObj)))
+		}
+	}
 
-	wg.Add(len(s.slabs))
-	for i := range s.slabs {
+	// the slabs get searched in the order of their addresses, so once all
+	// search terms have been found the remaining slabs can only contain
+	// higher addresses and the workers stop
+	slabs := append([]*slab(nil), s.slabs...)
+	sort.Slice(slabs, func(i, j int) bool { return slabs[i].addr() < slabs[j].addr() })
+	var nextSlab int32
 
-		// every slab gets a go routine which searches for all searched objects
-		go func(currentSlab *slab) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(slabs) {
+		workers = len(slabs)
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
 			defer wg.Done()
 
-			// iterate over objects in slab
-			for j := uint(0); j < s.objsPerSlab; j++ {
+			for {
+				slabIdx := int(atomic.AddInt32(&nextSlab, 1)) - 1
+				if slabIdx >= len(slabs) || ended(b.done) || b.foundBelow(slabs[slabIdx].addr()) {
+					return
+				}
+				s.searchSlabBatched(b, slabs[slabIdx])
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// duplicate search terms get the address of their first index
+	for _, idxs := range b.queries {
+		for _, k := range idxs[1:] {
+			b.resultSet[k] = b.resultSet[idxs[0]]
+		}
+	}
 
-				// if the current object slot is in use, then we compare its
-				// value to the searched objects
-				if currentSlab.bitSet().Test(j) {
-					storedObj := currentSlab.getObjByIdx(j)
+	if b.resultsLeft > 0 {
+		return b.resultSet, ctx.Err()
+	}
+	return b.resultSet, nil
+}
 
-					// compare all searched objects to the stored object
-				SEARCH:
-					for k, searchedObj := range searching {
-						for l := 0; l < objSize; l++ {
-							if storedObj[l] != searchedObj[l] {
-								continue SEARCH
-							}
+// batchSearch is the state which the workers of searchBatchedContext share
+type batchSearch struct {
+	// queries maps each search term to its indexes in the result set,
+	// searchHashes contains their bloom filter hashes if the pool has
+	// bloom filters
+	queries      map[string][]int
+	searchHashes []uint64
 
-						}
+	// resultSet contains the lowest address found for each search term
+	// at the first index of the search term
+	resultSet []ObjAddr
 
-						// found one search term, store it in the right location atomically
-						atomic.StoreUintptr(&resultSet[k], objAddrFromObj(storedObj))
+	// resultsLeft is the number of search terms which haven't been found
+	// yet. maxFound is at least as high as the address found for every
+	// search term, objects above it can't replace any of them
+	resultsLeft int32
+	maxFound    uintptr
 
-						// decrease number of searches left by one
-						atomic.AddInt32(&resultsLeft, -1)
-					}
-				}
+	done <-chan struct{}
+}
 
-				if atomic.LoadInt32(&resultsLeft) == 0 {
-					// all search terms have been found, exit routine
-					return
-				}
+// foundBelow returns true if all search terms have been found at addresses
+// below the given one, so objects at or above it needn't be searched
+func (b *batchSearch) foundBelow(addr uintptr) bool {
+	return atomic.LoadInt32(&b.resultsLeft) == 0 && addr > atomic.LoadUintptr(&b.maxFound)
+}
+
+// found stores the given address for the search term whose indexes are
+// given, unless a lower address has been found for it already
+func (b *batchSearch) found(idxs []int, objAddr ObjAddr) {
+	for {
+		current := atomic.LoadUintptr(&b.resultSet[idxs[0]])
+		if current != 0 && current <= objAddr {
+			return
+		}
+		if atomic.CompareAndSwapUintptr(&b.resultSet[idxs[0]], current, objAddr) {
+			storeMax(&b.maxFound, objAddr)
+			if current == 0 {
+				// decrease number of searches left by one
+				atomic.AddInt32(&b.resultsLeft, -1)
 			}
-		}(s.slabs[i])
+			return
+		}
 	}
+}
 
-	wg.Wait()
+// searchSlabBatched searches the given slab for the search terms of the
+// given batch search
+// The caller must hold the pool lock
+func (s *slabPool) searchSlabBatched(b *batchSearch, currentSlab *slab) {
+	// if there is a bloom filter we can skip the slab if it can't
+	// contain any of the searched values
+	if filter, ok := s.bloomFilters[currentSlab]; ok {
+		mayContain := false
+		for _, hash := range b.searchHashes {
+			if filter.mayContain(hash) {
+				mayContain = true
+				break
+			}
+		}
+		if !mayContain {
+			return
+		}
+	}
 
-	return resultSet
+	// iterate over the used object slots in slab and look up
+	// their values in the searched objects
+	visited := 0
+	for j, ok := currentSlab.nextObj(0); ok; j, ok = currentSlab.nextObj(j + 1) {
+		objAddr := currentSlab.getObjAddrByIdx(j)
+		if b.foundBelow(objAddr) {
+			// the remaining objects of the slab are above the
+			// addresses of all search terms
+			return
+		}
+		// the context gets checked every 64 objects
+		if visited++; visited%64 == 0 && ended(b.done) {
+			return
+		}
+
+		if idxs, ok := b.queries[string(s.objByIdx(currentSlab, j))]; ok {
+			b.found(idxs, objAddr)
+		}
+	}
+}
+
+// storeMax atomically raises the value at the given address to the given
+// value if it is lower
+func storeMax(addr *uintptr, value uintptr) {
+	for {
+		current := atomic.LoadUintptr(addr)
+		if current >= value || atomic.CompareAndSwapUintptr(addr, current, value) {
+			return
+		}
+	}
+}
+
+// contains returns true if the given address refers to a live object
+// in one of the slabs of this pool
+func (s *slabPool) contains(obj ObjAddr) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	sl := s.findSlab(obj)
+	return sl != nil && sl.contains(obj)
+}
+
+// checkIntegrity verifies the header of each slab of the pool, and the
+// canaries around each slab if the pool's backend writes them
+// On success it returns nil, otherwise it returns an error about the
+// first corrupted slab
+func (s *slabPool) checkIntegrity() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, canaries := s.backend.(canaryBackend)
+	for _, sl := range s.slabs {
+		st := s.states[sl]
+		used := st.capacity - st.freeCount()
+		err := sl.checkHeader(s.objSize, st.capacity, used)
+		if err == nil && canaries {
+			err = checkCanaries(sl.addr(), uintptr(slabSize(s.objSize, st.capacity)))
+		}
+		if err != nil {
+			return fmt.Errorf("ObjectStore: Slab at %d of the pool with object size %d is corrupted: %s", sl.addr(), s.objSize, err)
+		}
+	}
+
+	return nil
+}
+
+// checkObjAddr verifies that the given object address refers to the start
+// of a used object slot in the slab at the given slab address
+// On success it returns nil, otherwise it returns an *AddrError that
+// describes why the address is invalid
+// The caller must hold the pool lock
+func (s *slabPool) checkObjAddr(obj ObjAddr, slabAddr SlabAddr) error {
+	sl := slabFromSlabAddr(slabAddr)
+	st, ok := s.states[sl]
+	if !ok {
+		return &AddrError{Obj: obj, Slab: slabAddr, Reason: "slab is not part of the pool"}
+	}
+
+	dataStart := slabAddr + sl.getDataOffset()
+	if obj < dataStart || obj >= slabAddr+sl.getTotalLength() {
+		return &AddrError{Obj: obj, Slab: slabAddr, Reason: "address is outside of the slab's data"}
+	}
+
+	if (obj-dataStart)%uintptr(s.objSize) != 0 {
+		return &AddrError{Obj: obj, Slab: slabAddr, Reason: "address is not aligned to an object slot"}
+	}
+
+	idx := sl.getObjIdx(obj)
+	if !sl.bitSet().Test(idx) {
+		// the generation of a slot only gets bumped when its object gets deleted
+		return &AddrError{Obj: obj, Slab: slabAddr, Reason: fmt.Sprintf("slot %d is not in use", idx), unused: true, freed: st.gens[idx] > 0, slot: idx}
+	}
+
+	return nil
+}
+
+// slotOf returns the slab of the given object address, if the address
+// refers to a used slot whose generation is the given one
+// The second returned value is false if the object has been deleted
+// The caller must hold the pool lock
+func (s *slabPool) slotOf(obj ObjAddr, gen uint32) (*slab, bool) {
+	sl := s.findSlab(obj)
+	if sl == nil || s.checkObjAddr(obj, sl.addr()) != nil {
+		return nil, false
+	}
+	return sl, s.states[sl].gens[sl.getObjIdx(obj)] == gen
+}
+
+// getBatched returns the objects of the given object addresses as byte
+// slices, in the same order as the given addresses. The addresses get
+// resolved in sorted order, so the objects of each slab are read together
+// and the slab list only gets traversed once. The slice of an address which
+// isn't inside the data of one of the slabs of the pool is nil
+// The caller must hold the pool lock
+func (s *slabPool) getBatched(objs []ObjAddr) [][]byte {
+	// sort the indexes by descending object address, like the slab list
+	order := make([]int, len(objs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return objs[order[i]] > objs[order[j]] })
+
+	result := make([][]byte, len(objs))
+	var slabIdx int
+	for _, i := range order {
+		obj := objs[i]
+		for slabIdx < len(s.slabs) && s.slabs[slabIdx].addr() > obj {
+			slabIdx++
+		}
+		if slabIdx == len(s.slabs) {
+			break
+		}
+
+		sl := s.slabs[slabIdx]
+		if obj < sl.addr()+sl.getDataOffset() || obj >= sl.addr()+sl.getTotalLength() {
+			continue
+		}
+		result[i] = s.get(obj)
+	}
+
+	return result
 }
 
 // get returns an object of the given object address as a byte slice
+// In variable-length mode the length is read from the slot's length prefix
 func (s *slabPool) get(obj ObjAddr) []byte {
+	if s.varLen {
+		return objFromObjAddr(obj+1, *(*uint8)(unsafe.Pointer(obj)))
+	}
 	return objFromObjAddr(obj, s.objSize)
 }
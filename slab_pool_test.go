@@ -1,9 +1,11 @@
 package gos
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -44,6 +46,256 @@ func TestAddingDeletingSlabs(t *testing.T) {
 	})
 }
 
+func TestDeletingObjectsByObjAddr(t *testing.T) {
+	objSize := uint8(10)
+	objsPerSlab := uint(2)
+
+	Convey("When adding objects to a pool which reclaims empty slabs", t, func() {
+		sp := NewSlabPool(objSize, objsPerSlab)
+		var objs []ObjAddr
+		for i := 0; i < int(objsPerSlab)*2; i++ {
+			objAddr, _, err := sp.add([]byte(fmt.Sprintf("%010d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+		So(len(sp.slabs), ShouldEqual, 2)
+
+		Convey("deleting them by object address should unmap the emptied slabs", func() {
+			for _, obj := range objs {
+				_, err := sp.deleteObj(obj)
+				So(err, ShouldBeNil)
+			}
+			So(len(sp.slabs), ShouldEqual, 0)
+
+			Convey("deleting an address which is not in the pool should fail", func() {
+				_, err := sp.deleteObj(objs[0])
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("deleting an object twice should return a DoubleFreeError", func() {
+			_, err := sp.deleteObj(objs[1])
+			So(err, ShouldBeNil)
+			_, err = sp.deleteObj(objs[1])
+			So(errors.Is(err, ErrDoubleFree), ShouldBeTrue)
+			So(err.(*DoubleFreeError).Slot, ShouldEqual, 1)
+			So(sp.freeSlotCount(), ShouldEqual, 1)
+
+			Convey("and the slot should only be reused once", func() {
+				obj1, _, err := sp.add([]byte("0123456789"))
+				So(err, ShouldBeNil)
+				obj2, _, err := sp.add([]byte("0123456789"))
+				So(err, ShouldBeNil)
+				So(obj1, ShouldEqual, objs[1])
+				So(obj2, ShouldNotEqual, objs[1])
+			})
+		})
+	})
+
+	Convey("When adding objects to a pool which keeps empty slabs", t, func() {
+		sp := NewSlabPool(objSize, objsPerSlab)
+		sp.setReclaimEmptySlabs(false)
+		var objs []ObjAddr
+		for i := 0; i < int(objsPerSlab)*2; i++ {
+			objAddr, _, err := sp.add([]byte(fmt.Sprintf("%010d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+
+		Convey("deleting them by object address should keep the slabs", func() {
+			for _, obj := range objs {
+				deleted, err := sp.deleteObj(obj)
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeFalse)
+			}
+			So(len(sp.slabs), ShouldEqual, 2)
+
+			Convey("and the slots should get reused by following adds", func() {
+				for i := 0; i < int(objsPerSlab)*2; i++ {
+					value := fmt.Sprintf("%010d", i+100)
+					objAddr, slabAddr, err := sp.add([]byte(value))
+					So(err, ShouldBeNil)
+					So(slabAddr, ShouldEqual, 0)
+					So(string(sp.get(objAddr)), ShouldEqual, value)
+				}
+				So(len(sp.slabs), ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestFreeSlotAccounting(t *testing.T) {
+	objSize := uint8(5)
+	objsPerSlab := uint(4)
+
+	Convey("When adding objects to a new pool", t, func() {
+		sp := NewSlabPool(objSize, objsPerSlab)
+		var objs []ObjAddr
+		for i := 0; i < 6; i++ {
+			objAddr, _, err := sp.add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+
+		Convey("the slab states should contain the remaining free slots", func() {
+			So(len(sp.slabs), ShouldEqual, 2)
+			So(sp.freeSlotCount(), ShouldEqual, 2)
+
+			Convey("a deleted slot should be reused by the next add", func() {
+				_, err := sp.deleteObj(objs[1])
+				So(err, ShouldBeNil)
+				So(sp.freeSlotCount(), ShouldEqual, 3)
+
+				objAddr, slabAddr, err := sp.add([]byte("abcde"))
+				So(err, ShouldBeNil)
+				So(slabAddr, ShouldEqual, 0)
+				So(objAddr, ShouldEqual, objs[1])
+				So(string(sp.get(objAddr)), ShouldEqual, "abcde")
+			})
+
+			Convey("deleting a slab should remove its slots from the stack", func() {
+				for _, obj := range objs[4:] {
+					_, err := sp.deleteObj(obj)
+					So(err, ShouldBeNil)
+				}
+				So(len(sp.slabs), ShouldEqual, 1)
+				So(sp.freeSlotCount(), ShouldEqual, 0)
+				So(len(sp.lists[fullSlabs]), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestSlabLists(t *testing.T) {
+	Convey("When adding 3 objects to a pool with 2 objects per slab", t, func() {
+		sp := NewSlabPool(5, 2)
+		sp.setReclaimEmptySlabs(false)
+		var objs []ObjAddr
+		for i := 0; i < 3; i++ {
+			objAddr, _, err := sp.add([]byte(fmt.Sprintf("%05d", i)))
+			So(err, ShouldBeNil)
+			objs = append(objs, objAddr)
+		}
+
+		Convey("there should be one full and one partial slab", func() {
+			So(len(sp.lists[fullSlabs]), ShouldEqual, 1)
+			So(len(sp.lists[partialSlabs]), ShouldEqual, 1)
+			So(len(sp.lists[emptySlabs]), ShouldEqual, 0)
+
+			Convey("after deleting from the full slab both should be partial", func() {
+				_, err := sp.deleteObj(objs[0])
+				So(err, ShouldBeNil)
+				So(len(sp.lists[fullSlabs]), ShouldEqual, 0)
+				So(len(sp.lists[partialSlabs]), ShouldEqual, 2)
+
+				Convey("after deleting everything both should be empty", func() {
+					for _, obj := range objs[1:] {
+						_, err := sp.deleteObj(obj)
+						So(err, ShouldBeNil)
+					}
+					So(len(sp.lists[partialSlabs]), ShouldEqual, 0)
+					So(len(sp.lists[emptySlabs]), ShouldEqual, 2)
+				})
+			})
+		})
+	})
+}
+
+func TestAddingObjectsBatchedToPool(t *testing.T) {
+	objSize := uint8(5)
+	objsPerSlab := uint(10)
+
+	Convey("When adding a batch of objects to a partially used pool", t, func() {
+		sp := NewSlabPool(objSize, objsPerSlab)
+		for i := 0; i < 5; i++ {
+			_, _, err := sp.add([]byte(fmt.Sprintf("a%04d", i)))
+			So(err, ShouldBeNil)
+		}
+
+		var batch [][]byte
+		for i := 0; i < 28; i++ {
+			batch = append(batch, []byte(fmt.Sprintf("b%04d", i)))
+		}
+		objAddrs, slabAddrs, err := sp.addBatched(batch)
+		So(err, ShouldBeNil)
+
+		Convey("only the required number of slabs should have been created", func() {
+			So(len(slabAddrs), ShouldEqual, 3)
+			So(len(sp.slabs), ShouldEqual, 4)
+			So(sp.freeSlotCount(), ShouldEqual, 7)
+		})
+
+		Convey("all the objects should be retrievable in the right order", func() {
+			So(len(objAddrs), ShouldEqual, len(batch))
+			for i, objAddr := range objAddrs {
+				So(string(sp.get(objAddr)), ShouldEqual, string(batch[i]))
+			}
+		})
+	})
+}
+
+func TestVarLenObjects(t *testing.T) {
+	maxObjSize := uint8(20)
+	objsPerSlab := uint(10)
+
+	Convey("When adding objects of varying lengths to a variable-length pool", t, func() {
+		sp := NewVarLenSlabPool(maxObjSize, objsPerSlab)
+		objects := make(map[string]ObjAddr)
+		for i := 0; i < int(objsPerSlab)*3; i++ {
+			value := strings.Repeat("x", i%int(maxObjSize)) + strconv.Itoa(i)
+			if len(value) > int(maxObjSize) {
+				value = value[:maxObjSize]
+			}
+			objAddr, _, err := sp.add([]byte(value))
+			So(err, ShouldBeNil)
+			objects[value] = objAddr
+		}
+
+		Convey("we should get each of them back with the correct length", func() {
+			for value, objAddr := range objects {
+				So(string(sp.get(objAddr)), ShouldEqual, value)
+			}
+		})
+
+		Convey("we should be able to search for them", func() {
+			for value, objAddr := range objects {
+				found, ok := sp.search([]byte(value))
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, objAddr)
+			}
+			_, ok := sp.search([]byte("xx"))
+			So(ok, ShouldBeFalse)
+			res := sp.searchBatched([][]byte{[]byte("0"), []byte("xx"), []byte("x1")})
+			So(res[0], ShouldEqual, objects["0"])
+			So(res[1], ShouldEqual, 0)
+			So(res[2], ShouldEqual, objects["x1"])
+		})
+
+		Convey("we should get all of them in one batch", func() {
+			var values []string
+			var objAddrs []ObjAddr
+			for value, objAddr := range objects {
+				values = append(values, value)
+				objAddrs = append(objAddrs, objAddr)
+			}
+			for i, obj := range sp.getBatched(objAddrs) {
+				So(string(obj), ShouldEqual, values[i])
+			}
+		})
+
+		Convey("addresses outside of the data of the slabs should be nil in a batch", func() {
+			sl := sp.slabs[0]
+			res := sp.getBatched([]ObjAddr{sl.addr(), sl.addr() + sl.getTotalLength(), sp.slabs[len(sp.slabs)-1].addr() - 1})
+			So(res, ShouldResemble, [][]byte{nil, nil, nil})
+		})
+
+		Convey("adding an object that exceeds the maximum size should fail", func() {
+			_, _, err := sp.add([]byte(strings.Repeat("x", int(maxObjSize)+1)))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func TestAddingGettingManyObjects8(t *testing.T) {
 	testAddingGettingManyObjects(t, 8, 10)
 }
@@ -192,9 +444,188 @@ func TestBatchSearchingObjects(t *testing.T) {
 			So(string(objFromObjAddr(searchResults[6], 5)), ShouldEqual, string(searchTerms[6]))
 			So(string(objFromObjAddr(searchResults[7], 5)), ShouldEqual, string(searchTerms[7]))
 		})
+
+		Convey("duplicate search terms should all be found", func() {
+			searchTerms := [][]byte{
+				[]byte("00100"),
+				[]byte("00100"),
+				[]byte("abcde"),
+				[]byte("abcde"),
+			}
+			searchResults := sp.searchBatched(searchTerms)
+			So(searchResults[0], ShouldBeGreaterThan, 0)
+			So(searchResults[1], ShouldEqual, searchResults[0])
+			So(searchResults[2], ShouldEqual, 0)
+			So(searchResults[3], ShouldEqual, 0)
+		})
+	})
+}
+
+func TestBatchSearchingDuplicateObjects(t *testing.T) {
+	Convey("When a pool holds equal objects in many slabs", t, func() {
+		sp := NewSlabPool(5, 4)
+		var dups, others []ObjAddr
+		for i := 0; i < 200; i++ {
+			value := []byte("other")
+			if i%3 == 0 {
+				value = []byte("dupes")
+			} else if i%7 == 0 {
+				value = []byte("seven")
+			}
+			obj, _, err := sp.add(value)
+			So(err, ShouldBeNil)
+			switch string(value) {
+			case "dupes":
+				dups = append(dups, obj)
+			case "other":
+				others = append(others, obj)
+			}
+		}
+		lowest := func(objs []ObjAddr) ObjAddr {
+			result := objs[0]
+			for _, obj := range objs {
+				if obj < result {
+					result = obj
+				}
+			}
+			return result
+		}
+
+		Convey("searchBatched should always return the lowest address of the matches", func() {
+			searchTerms := [][]byte{
+				[]byte("dupes"),
+				[]byte("other"),
+				[]byte("dupes"),
+				[]byte("abcde"),
+				[]byte("dupes"),
+			}
+			for i := 0; i < 20; i++ {
+				searchResults := sp.searchBatched(searchTerms)
+				So(searchResults, ShouldResemble, []ObjAddr{lowest(dups), lowest(others), lowest(dups), 0, lowest(dups)})
+			}
+
+			// once the lowest match is gone the next one takes its place
+			var remaining []ObjAddr
+			for _, obj := range dups {
+				if obj != lowest(dups) {
+					remaining = append(remaining, obj)
+				}
+			}
+			_, err := sp.deleteObj(lowest(dups))
+			So(err, ShouldBeNil)
+			searchResults := sp.searchBatched(searchTerms[:1])
+			So(searchResults[0], ShouldEqual, lowest(remaining))
+		})
+	})
+}
+
+func TestSearchingWithBloomFilters(t *testing.T) {
+	objSize := uint8(5)
+	objsPerSlab := uint(10)
+	expectedSlabs := uint(20)
+
+	Convey("When adding objects to a pool with bloom filters", t, func() {
+		sp := NewSlabPool(objSize, objsPerSlab)
+		objs := make(map[string]ObjAddr)
+		for i := uint(0); i < objsPerSlab*expectedSlabs/2; i++ {
+			value := fmt.Sprintf("%05d", i)
+			objs[value], _, _ = sp.add([]byte(value))
+		}
+		// enable them after some objects already exist
+		sp.enableBloomFilters()
+		for i := objsPerSlab * expectedSlabs / 2; i < objsPerSlab*expectedSlabs; i++ {
+			value := fmt.Sprintf("%05d", i)
+			objs[value], _, _ = sp.add([]byte(value))
+		}
+		So(len(sp.bloomFilters), ShouldEqual, expectedSlabs)
+
+		Convey("we should find all of them with search and searchBatched", func() {
+			var searchTerms [][]byte
+			for value, objAddr := range objs {
+				found, ok := sp.search([]byte(value))
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, objAddr)
+				searchTerms = append(searchTerms, []byte(value))
+			}
+			searchTerms = append(searchTerms, []byte("abcde"))
+			results := sp.searchBatched(searchTerms)
+			for i, searched := range searchTerms[:len(searchTerms)-1] {
+				So(results[i], ShouldEqual, objs[string(searched)])
+			}
+			So(results[len(results)-1], ShouldEqual, 0)
+		})
+
+		Convey("deleted objects should not be found anymore", func() {
+			_, err := sp.deleteObj(objs["00042"])
+			So(err, ShouldBeNil)
+			_, ok := sp.search([]byte("00042"))
+			So(ok, ShouldBeFalse)
+			found, ok := sp.search([]byte("00043"))
+			So(ok, ShouldBeTrue)
+			So(found, ShouldEqual, objs["00043"])
+		})
+	})
+}
+
+func TestBatchSearchTermination(t *testing.T) {
+	Convey("When a batch search finds its search terms", t, func() {
+		b := &batchSearch{
+			queries:   map[string][]int{"a": {0, 2}, "b": {1}},
+			resultSet: make([]ObjAddr, 3),
+		}
+		b.resultsLeft = int32(len(b.queries))
+
+		Convey("it should only stop once all of them have been found", func() {
+			b.found([]int{0, 2}, 200)
+			So(b.foundBelow(1000), ShouldBeFalse)
+
+			b.found([]int{1}, 300)
+			So(b.resultsLeft, ShouldEqual, 0)
+			So(b.foundBelow(1000), ShouldBeTrue)
+			So(b.foundBelow(300), ShouldBeFalse)
+		})
+
+		Convey("lower addresses should replace higher ones", func() {
+			b.found([]int{1}, 300)
+			b.found([]int{1}, 100)
+			b.found([]int{1}, 200)
+			So(b.resultSet[1], ShouldEqual, 100)
+			So(b.resultsLeft, ShouldEqual, 1)
+		})
 	})
 }
 
+func BenchmarkBatchSearchingExistingObjects(b *testing.B) {
+	objSize := uint8(20)
+	objsPerSlab := uint(100)
+	sp := NewSlabPool(objSize, objsPerSlab)
+	valueCount := 100000
+	testValues := make([][]byte, valueCount)
+	for i := 0; i < valueCount; i++ {
+		testValues[i] = []byte(fmt.Sprintf("%20d", i+valueCount))
+		if _, _, err := sp.add(testValues[i]); err != nil {
+			b.Fatalf("Got error on add: %s", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	// all searched values exist, so the search stops before it has
+	// visited all slabs
+	searching := make([][]byte, 10)
+	for i := 0; i < b.N; i++ {
+		for j := range searching {
+			searching[j] = testValues[rand.Int31n(int32(valueCount))]
+		}
+		for j, addr := range sp.searchBatched(searching) {
+			if addr == 0 {
+				b.Fatalf("Value %s has not been found", searching[j])
+			}
+		}
+	}
+}
+
 func BenchmarkAddingSearchingObjectInLargePool(b *testing.B) {
 	objSize := uint8(20)
 	objsPerSlab := uint(100)
@@ -222,7 +653,7 @@ func BenchmarkAddingSearchingObjectInLargePool(b *testing.B) {
 		searchFor := testValues[rand.Int31n(int32(valueCount))]
 		gotAddr, found := sp.search(searchFor.value)
 		if !found || gotAddr != searchFor.addr {
-			b.Fatalf(fmt.Sprintf("Got unexpected result:\nfound: %t\ngot addr: %d\nfound Addr: %d", found, gotAddr, searchFor.addr))
+			b.Fatalf("Got unexpected result:\nfound: %t\ngot addr: %d\nfound Addr: %d", found, gotAddr, searchFor.addr)
 		}
 	}
 }
@@ -274,3 +705,24 @@ func BenchmarkAddingSearchingObjectInLargePool(b *testing.B) {
 // 		}
 // 	}
 // }
+
+func TestPoolContains(t *testing.T) {
+	Convey("When adding objects to a pool with multiple slabs", t, func() {
+		sp := NewSlabPool(3, 2)
+		var objs []ObjAddr
+		for _, value := range []string{"abc", "def", "ghi"} {
+			obj, _, err := sp.add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+
+		Convey("the pool should contain all of them", func() {
+			for _, obj := range objs {
+				So(sp.contains(obj), ShouldBeTrue)
+			}
+			So(sp.contains(objs[2]+3), ShouldBeFalse)
+			So(sp.contains(objs[0]-1), ShouldBeFalse)
+			So(sp.contains(0), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,213 @@
+package gos
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// slabList identifies one of the slab lists of a slab pool
+type slabList int
+
+const (
+	// emptySlabs is the list of slabs without any objects
+	emptySlabs slabList = iota
+	// partialSlabs is the list of slabs that have used and free slots
+	partialSlabs
+	// fullSlabs is the list of slabs without free slots
+	fullSlabs
+	// sealedSlabs is the list of slabs which have been sealed, they are
+	// never changed again regardless of their free slots
+	sealedSlabs
+	// slabListCount is the number of slab lists
+	slabListCount
+)
+
+// slabState is the bookkeeping of a slab, which is kept on the Go heap.
+// Every slab of a pool is on exactly one of the pool's slab lists
+type slabState struct {
+	slab *slab
+
+	// capacity is the number of object slots in the slab, slabs of a pool
+	// with adaptive capacity don't all have the same number of slots
+	capacity uint
+
+	// free is a stack of the indexes of slots that have been freed
+	free []uint
+
+	// next is the index of the first slot that has never been used,
+	// all the slots from next to the end of the slab are free too
+	next uint
+
+	// list is the slab list that the slab is on, listIdx is its
+	// position in that list
+	list    slabList
+	listIdx int
+
+	// gens holds the generation of each slot, it gets bumped whenever
+	// the slot's object gets deleted to invalidate handles referring to it
+	gens []uint32
+
+	// created is the time at which the slab has been created
+	created time.Time
+
+	// dirty is true if the slab has been changed since it has been
+	// written by the last snapshot, see WriteIncremental. It gets set
+	// while holding the pool lock for writing and cleared while holding
+	// it for reading, so it needs to be accessed atomically
+	dirty atomic.Bool
+
+	// sealed is true if the slab's memory has been made immutable by
+	// SealSlab
+	sealed bool
+
+	// stuck is true if growing the slab in place has failed, because the
+	// address space after it is in use
+	stuck bool
+
+	// pinned is the number of pinned objects in the slab, see Pin
+	pinned int
+}
+
+// freeCount returns the number of free slots in the slab
+func (st *slabState) freeCount() uint {
+	return uint(len(st.free)) + st.capacity - st.next
+}
+
+// popFree takes a free slot and returns its index
+// the caller must ensure that there is a free slot
+func (st *slabState) popFree() uint {
+	if len(st.free) > 0 {
+		idx := st.free[len(st.free)-1]
+		st.free = st.free[:len(st.free)-1]
+		if poisonFreedSlots {
+			st.slab.checkPoison(idx)
+		}
+		return idx
+	}
+	st.next++
+	return st.next - 1
+}
+
+// pushFree marks the slot at the given index as free
+func (st *slabState) pushFree(idx uint) {
+	if poisonFreedSlots {
+		st.slab.poisonSlot(idx)
+	}
+	st.free = append(st.free, idx)
+}
+
+// listFor returns the slab list which the slab belongs on
+// based on its number of free slots, sealed slabs are on their own list
+func (s *slabPool) listFor(st *slabState) slabList {
+	if st.sealed {
+		return sealedSlabs
+	}
+	switch st.freeCount() {
+	case st.capacity:
+		return emptySlabs
+	case 0:
+		return fullSlabs
+	default:
+		return partialSlabs
+	}
+}
+
+// addToList appends the slab to the given list
+func (s *slabPool) addToList(st *slabState, list slabList) {
+	st.list = list
+	st.listIdx = len(s.lists[list])
+	s.lists[list] = append(s.lists[list], st)
+}
+
+// removeFromList removes the slab from the list it is on, the last
+// slab of the list takes its position
+func (s *slabPool) removeFromList(st *slabState) {
+	list := s.lists[st.list]
+	last := list[len(list)-1]
+	list[st.listIdx] = last
+	last.listIdx = st.listIdx
+	list[len(list)-1] = nil
+	s.lists[st.list] = list[:len(list)-1]
+}
+
+// updateList moves the slab to the list it belongs on, it needs to be
+// called whenever the number of free slots in a slab has changed
+func (s *slabPool) updateList(st *slabState) {
+	list := s.listFor(st)
+	if list == st.list {
+		return
+	}
+	s.removeFromList(st)
+	s.addToList(st, list)
+}
+
+// SlabInfo describes the state of a single slab
+type SlabInfo struct {
+	// Addr is the address of the slab
+	Addr SlabAddr
+	// ID is the id of the slab, see RelAddr
+	ID uint32
+	// ObjSize is the size of the object slots in the slab
+	ObjSize uint8
+	// Capacity is the number of object slots in the slab
+	Capacity uint
+	// FreeSlots is the number of unused object slots in the slab
+	FreeSlots uint
+	// LiveObjects is the number of objects in the slab
+	LiveObjects uint
+	// TotalBytes is the size of the slab's memory, including its header
+	TotalBytes uint64
+	// Created is the time at which the slab has been created
+	Created time.Time
+}
+
+// slabInfo returns the SlabInfo of the slab at the given address
+// the second returned value is false if the slab is not in this pool
+func (s *slabPool) slabInfo(slabAddr SlabAddr) (SlabInfo, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	st, ok := s.states[slabFromSlabAddr(slabAddr)]
+	if !ok {
+		return SlabInfo{}, false
+	}
+
+	return s.slabInfoOf(st), true
+}
+
+// slabInfos returns the SlabInfo of each slab in the pool
+func (s *slabPool) slabInfos() []SlabInfo {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	infos := make([]SlabInfo, 0, len(s.slabs))
+	for _, sl := range s.slabs {
+		infos = append(infos, s.slabInfoOf(s.states[sl]))
+	}
+	return infos
+}
+
+// slabInfoOf builds the SlabInfo of the slab with the given state
+// The caller must hold the pool lock
+func (s *slabPool) slabInfoOf(st *slabState) SlabInfo {
+	free := st.freeCount()
+	return SlabInfo{
+		Addr:        st.slab.addr(),
+		ObjSize:     s.objSize,
+		Capacity:    st.capacity,
+		FreeSlots:   free,
+		LiveObjects: st.capacity - free,
+		TotalBytes:  uint64(st.slab.getTotalLength()),
+		Created:     st.created,
+	}
+}
+
+// freeSlotCount returns the total number of free slots in the pool
+// The caller must hold the pool lock
+func (s *slabPool) freeSlotCount() uint {
+	var count uint
+	for _, st := range s.states {
+		count += st.freeCount()
+	}
+	return count
+}
@@ -91,3 +91,35 @@ func TestSettingGettingManyObjects(t *testing.T) {
 		})
 	})
 }
+
+func TestIteratingUsedSlots(t *testing.T) {
+	Convey("When creating a new slab spanning multiple BitSet words", t, func() {
+		slab, err := newSlab(5, 200)
+		So(err, ShouldBeNil)
+
+		Convey("there should be no used slots", func() {
+			_, ok := slab.nextObj(0)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("after using some slots we should iterate over exactly those", func() {
+			used := []uint{0, 3, 63, 64, 130, 199}
+			for _, idx := range used {
+				slab.bitSet().Set(idx)
+			}
+
+			var found []uint
+			for idx, ok := slab.nextObj(0); ok; idx, ok = slab.nextObj(idx + 1) {
+				found = append(found, idx)
+			}
+			So(found, ShouldResemble, used)
+
+			idx, ok := slab.nextObj(65)
+			So(ok, ShouldBeTrue)
+			So(idx, ShouldEqual, 130)
+
+			_, ok = slab.nextObj(200)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
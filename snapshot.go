@@ -0,0 +1,409 @@
+package gos
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// snapshotMagic is written at the start of every snapshot
+var snapshotMagic = [4]byte{'G', 'O', 'S', 'S'}
+
+// snapshotVersion is the version of the snapshot format written by WriteTo
+//
+// Version 5 is laid out like this:
+//
+//	magic        [4]byte "GOSS"
+//	version      uint16, always little endian
+//	byte order   uint8, 0 for little endian and 1 for big endian
+//	size width   uint8, the number of bytes of the objSize fields
+//	pool count   uint32
+//	for each pool, ordered by object size:
+//	  objSize      size width bytes
+//	  objsPerSlab  uint32
+//	  slab count   uint32
+//	  bitset cap   uint32, the number of bitset words that the slabs have
+//	               room for, see WithSlabGrowth
+//	  data offset  uint32, the offset of the objects in the slabs
+//	  for each slab, ordered by address:
+//	    address    uint64, the address of the slab when it was written
+//	    id         uint32, the id of the slab
+//	    bitset     (objsPerSlab+63)/64 uint64 words
+//	    objects    objSize*objsPerSlab bytes
+//	    checksum   uint32, CRC32 of the address, id, bitset and objects
+//
+// All the integers after the version and the bitset words have the
+// declared byte order. WriteTo always writes little endian integers and
+// one byte object sizes, the other encodings are accepted when reading.
+//
+// Version 4 is the same without the bitset cap and the data offset, its
+// slabs have room for the bitset words of objsPerSlab objects exactly.
+// Version 3 is the same as version 4 without the checksums. Version 2 is
+// the same as version 3 without the byte order and the size width, it is
+// always little endian with one byte object sizes. Version 1 is the same
+// as version 2, but without the slab ids. All of them are still accepted by Restore, see
+// MigrateSnapshot to convert them to the current version
+const snapshotVersion = 5
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteTo implements io.WriterTo, it writes a snapshot of all pools and
+// their slabs to w, so a warmed up store can be persisted and restored by
+// ReadFrom. Each pool is locked while it gets written, so the snapshot
+// contains a consistent state of each pool
+// On success it returns the number of written bytes and nil
+// On failure the second returned value is the error of w
+func (o *ObjectStore) WriteTo(w io.Writer) (int64, error) {
+	return o.writeSnapshot(w, snapshotMagic, false)
+}
+
+// writeSnapshot writes a snapshot with the given magic to w, it is used by
+// WriteTo and WriteIncremental
+func (o *ObjectStore) writeSnapshot(w io.Writer, magic [4]byte, incremental bool) (int64, error) {
+	cw := &countingWriter{w: w}
+	var out io.Writer = cw
+	var enc *encryptingWriter
+	if o.snapshotCipher != nil {
+		var err error
+		if enc, err = newEncryptingWriter(cw, o.snapshotCipher); err != nil {
+			return cw.n, err
+		}
+		out = enc
+	}
+	bw := bufio.NewWriter(out)
+
+	o.poolsLock.RLock()
+	pools := make([]*slabPool, 0, len(o.slabPools))
+	for _, pool := range o.slabPools {
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].objSize < pools[j].objSize })
+
+	// each pool gets written as one pool section per slab capacity
+	var sections int
+	for _, pool := range pools {
+		sections += len(pool.capacities)
+	}
+
+	writeSnapshotHeader(bw, magic, uint32(sections))
+	var written []*slabState
+	for _, pool := range pools {
+		written = pool.writeSnapshot(bw, o.slabID, incremental, written)
+	}
+	o.poolsLock.RUnlock()
+
+	err := bw.Flush()
+	if err == nil && enc != nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		markDirty(written)
+	}
+	return cw.n, err
+}
+
+// markDirty marks the given slabs as dirty again, because writing the
+// snapshot that contained them has failed
+func markDirty(states []*slabState) {
+	for _, st := range states {
+		st.dirty.Store(true)
+	}
+}
+
+// writeSnapshot writes the pool and its slabs to bw in the snapshot format,
+// or in the incremental snapshot format if incremental is true. There is a
+// pool section for each capacity of the pool's slabs. slabID gets called to
+// obtain the id of each slab. The dirty slabs get marked as clean and their
+// states are appended to written, which gets returned. Errors are reported
+// by bw when it gets flushed
+func (s *slabPool) writeSnapshot(bw *bufio.Writer, slabID func(SlabAddr) (uint32, bool), incremental bool, written []*slabState) []*slabState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, capacity := range s.capacities {
+		written = s.writeSnapshotSection(bw, capacity, slabID, incremental, written)
+	}
+
+	return written
+}
+
+// writeSnapshotSection writes the pool section with the slabs of the given
+// capacity, see writeSnapshot
+// The caller must hold the pool lock
+func (s *slabPool) writeSnapshotSection(bw *bufio.Writer, capacity uint, slabID func(SlabAddr) (uint32, bool), incremental bool, written []*slabState) []*slabState {
+	var count uint32
+	for _, st := range s.states {
+		if st.capacity == capacity {
+			count++
+		}
+	}
+
+	bw.WriteByte(s.objSize)
+	binary.Write(bw, binary.LittleEndian, uint32(capacity))
+	binary.Write(bw, binary.LittleEndian, count)
+	writePoolLayout(bw, layoutForCapacity(s.reservedCapacity(capacity)))
+
+	// everything but the checksum itself gets written to sw, so it also
+	// gets fed into crc
+	crc := crc32.NewIEEE()
+	sw := io.MultiWriter(bw, crc)
+
+	// s.slabs is sorted in descending order
+	for i := len(s.slabs) - 1; i >= 0; i-- {
+		sl := s.slabs[i]
+		if s.states[sl].capacity != capacity {
+			continue
+		}
+		id, _ := slabID(sl.addr())
+		crc.Reset()
+		binary.Write(sw, binary.LittleEndian, uint64(sl.addr()))
+		binary.Write(sw, binary.LittleEndian, id)
+
+		dirty := s.states[sl].dirty.Swap(false)
+		if dirty {
+			written = append(written, s.states[sl])
+		}
+		if incremental {
+			if !dirty {
+				sw.Write([]byte{0})
+				binary.Write(bw, binary.LittleEndian, crc.Sum32())
+				continue
+			}
+			sw.Write([]byte{1})
+		}
+		binary.Write(sw, binary.LittleEndian, sl.bitSet().Bytes())
+		sw.Write(sl.memory()[sl.getDataOffset():])
+		binary.Write(bw, binary.LittleEndian, crc.Sum32())
+	}
+
+	return written
+}
+
+// RelocationTable translates the object addresses of a snapshot into the
+// addresses that the objects have after they have been restored
+type RelocationTable struct {
+	// relocations is sorted by ascending oldStart
+	relocations []relocation
+}
+
+// relocation maps the address range and the id of a slab in a snapshot
+// to the address and the id of the slab it has been restored into
+type relocation struct {
+	oldStart, oldEnd uintptr
+	newStart         SlabAddr
+	oldID, newID     uint32
+}
+
+// Translate returns the current address of the object that had the given
+// address when the snapshot has been written
+// The second returned value is false if the address has not been in any
+// of the restored slabs
+func (t *RelocationTable) Translate(old ObjAddr) (ObjAddr, bool) {
+	idx := sort.Search(len(t.relocations), func(i int) bool { return t.relocations[i].oldEnd > old })
+	if idx >= len(t.relocations) || old < t.relocations[idx].oldStart {
+		return 0, false
+	}
+	return t.relocations[idx].newStart + (old - t.relocations[idx].oldStart), true
+}
+
+// TranslateSlab returns the current address of the slab that had the given
+// address when the snapshot has been written
+// The second returned value is false if there was no such slab
+func (t *RelocationTable) TranslateSlab(old SlabAddr) (SlabAddr, bool) {
+	newAddr, ok := t.Translate(old)
+	return newAddr, ok && newAddr != 0
+}
+
+// TranslateRel returns the current relative address of the object that
+// had the given relative address when the snapshot has been written. The
+// restored slabs keep their ids if they are not in use in the store, in
+// that case the relative address doesn't change
+// The second returned value is false if the address has not been in any
+// of the restored slabs
+func (t *RelocationTable) TranslateRel(old RelAddr) (RelAddr, bool) {
+	if old.SlabID() == 0 {
+		return 0, false
+	}
+	for _, reloc := range t.relocations {
+		if reloc.oldID == old.SlabID() {
+			return newRelAddr(reloc.newID, old.Offset()), true
+		}
+	}
+	return 0, false
+}
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom, it restores a snapshot which has been
+// written by WriteTo. It is like Restore, but it doesn't return the
+// relocation table, so it is only useful if the caller doesn't keep any
+// object addresses
+// On success it returns the number of read bytes and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	_, err := o.Restore(cr)
+	return cr.n, err
+}
+
+// Restore adds the pools and slabs of a snapshot which has been written by
+// WriteTo to the store. The slabs get mapped at new addresses, so the
+// returned RelocationTable must be used to translate the object addresses
+// which have been valid when the snapshot has been written. The slabs keep
+// their ids unless they are already in use, so relative addresses stay
+// valid when a snapshot gets restored into an empty store.
+// Pools which don't exist yet are created with the number of objects per
+// slab of the snapshot, pools which already exist must have the same one
+// On success it returns the relocation table and nil
+// On failure the second returned value is the error, none of the slabs of
+// the snapshot have been added to the store
+func (o *ObjectStore) Restore(r io.Reader) (*RelocationTable, error) {
+	br, err := o.snapshotReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+	table := &RelocationTable{}
+	var restored []SlabAddr
+
+	err = o.restore(br, table, &restored)
+	if err != nil {
+		for _, slabAddr := range restored {
+			o.deleteRestoredSlab(slabAddr)
+		}
+		return nil, err
+	}
+
+	sort.Slice(table.relocations, func(i, j int) bool { return table.relocations[i].oldStart < table.relocations[j].oldStart })
+	for _, slabAddr := range restored {
+		o.slabCreated(slabFromSlabAddr(slabAddr).objSize, slabAddr)
+	}
+
+	return table, nil
+}
+
+// restore reads the snapshot from br and restores its slabs, it appends
+// the address of each restored slab to restored
+func (o *ObjectStore) restore(br *bufio.Reader, table *RelocationTable, restored *[]SlabAddr) error {
+	d, err := newSnapshotDecoder(br, snapshotMagic)
+	if err != nil {
+		return fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+	d.verify = !o.skipChecksums
+
+	for i := uint32(0); i < d.poolCount; i++ {
+		objSize, objsPerSlab, slabCount, err := d.readPoolHeader()
+		if err != nil {
+			return fmt.Errorf("ObjectStore: Restore %w", err)
+		}
+		layout, err := d.readPoolLayout(objsPerSlab)
+		if err != nil {
+			return fmt.Errorf("ObjectStore: Restore %w", err)
+		}
+
+		// pools which don't exist yet get the layout of the snapshot
+		pool := o.acquireSlabPoolWithCapacity(objSize, objsPerSlab)
+		for j := uint32(0); j < slabCount; j++ {
+			reloc, err := pool.restoreSlab(d, objsPerSlab, layout)
+			if err != nil {
+				o.poolsLock.RUnlock()
+				return err
+			}
+			reloc.newID = o.assignSlabID(reloc.newStart, reloc.oldID)
+			*restored = append(*restored, reloc.newStart)
+			table.relocations = append(table.relocations, reloc)
+		}
+		o.poolsLock.RUnlock()
+	}
+
+	return nil
+}
+
+// restoreSlab reads a slab with the given capacity from the snapshot
+// decoder and adds it to the pool, the new slab must have the given layout
+// On success it returns the relocation of the slab, without the new id,
+// and nil
+// On failure the second returned value is the error
+func (s *slabPool) restoreSlab(d *snapshotDecoder, capacity uint, layout slabLayout) (relocation, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	oldAddr, oldID, err := d.readSlabHeader()
+	if err != nil {
+		return relocation{}, fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+
+	words := make([]uint64, (capacity+63)/64)
+	if err := d.readWords(words); err != nil {
+		return relocation{}, fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+
+	idx, err := s.addSlabWithCapacity(capacity)
+	if err != nil {
+		return relocation{}, err
+	}
+	sl := s.slabs[idx]
+
+	if err := layout.check(sl); err != nil {
+		s.deleteSlab(sl.addr())
+		return relocation{}, fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+	if err := d.readData(sl.memory()[sl.getDataOffset():]); err != nil {
+		s.deleteSlab(sl.addr())
+		return relocation{}, fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+	if err := d.verifySlab(oldAddr, oldID, s.objSize); err != nil {
+		s.deleteSlab(sl.addr())
+		return relocation{}, fmt.Errorf("ObjectStore: Restore %w", err)
+	}
+	copy(sl.bitSet().Bytes(), words)
+
+	if err := s.rebuildState(s.states[sl]); err != nil {
+		s.deleteSlab(sl.addr())
+		return relocation{}, fmt.Errorf("ObjectStore: Restore failed because slab %#x is invalid: %v", oldAddr, err)
+	}
+
+	return relocation{
+		oldStart: uintptr(oldAddr),
+		oldEnd:   uintptr(oldAddr) + sl.getTotalLength(),
+		newStart: sl.addr(),
+		oldID:    oldID,
+	}, nil
+}
+
+// deleteRestoredSlab removes a slab which has been restored by a failed Restore
+func (o *ObjectStore) deleteRestoredSlab(slabAddr SlabAddr) {
+	size := slabFromSlabAddr(slabAddr).objSize
+	pool, ok := o.getSlabPool(size)
+	if !ok {
+		return
+	}
+
+	pool.lock.Lock()
+	pool.deleteSlab(slabAddr)
+	pool.lock.Unlock()
+
+	o.deleteSlabPoolIfEmpty(size)
+}
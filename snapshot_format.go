@@ -0,0 +1,355 @@
+package gos
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// the byte orders which can be declared by the header of a snapshot
+const (
+	snapshotLittleEndian uint8 = 0
+	snapshotBigEndian    uint8 = 1
+)
+
+// snapshotChecksumSize is the size of the checksum after each slab
+const snapshotChecksumSize = 4
+
+// snapshotHeaderSize is the size of the header of the current version,
+// including the pool count
+const snapshotHeaderSize = 12
+
+// snapshotHeader describes how the integers in a snapshot are encoded.
+// Older versions of the format didn't declare it in the header, for them
+// it gets derived from the version, so they can be migrated transparently
+type snapshotHeader struct {
+	version uint16
+
+	// order is the byte order of all the integers after the version
+	order binary.ByteOrder
+
+	// objSizeWidth is the number of bytes of the objSize fields
+	objSizeWidth int
+
+	poolCount uint32
+}
+
+// hasSlabIDs returns true if the slabs in the snapshot have ids
+func (h snapshotHeader) hasSlabIDs() bool {
+	return h.version >= 2
+}
+
+// hasChecksums returns true if each slab in the snapshot is followed by
+// its checksum
+func (h snapshotHeader) hasChecksums() bool {
+	return h.version >= 4
+}
+
+// hasSlabLayouts returns true if the pool headers in the snapshot declare
+// the layout of their slabs
+func (h snapshotHeader) hasSlabLayouts() bool {
+	return h.version >= 5
+}
+
+// slabLayout describes where the objects of the slabs in a pool section
+// start, the object addresses in a snapshot are only valid for slabs
+// with the same layout
+type slabLayout struct {
+	// wordCap is the number of BitSet words that each slab has room for
+	wordCap uint32
+
+	// dataOffset is the offset of the first object in each slab
+	dataOffset uint32
+}
+
+// layoutForCapacity returns the layout of slabs whose BitSet has room for
+// the given number of objects
+func layoutForCapacity(capacity uint) slabLayout {
+	wordCap := uint32((capacity + 63) / 64)
+	return slabLayout{wordCap: wordCap, dataOffset: uint32(1+sizeOfBitSet) + wordCap*8}
+}
+
+// check compares the layout with the layout of the given slab
+// On success it returns nil, if they differ it returns an error which
+// wraps ErrLayoutMismatch
+func (l slabLayout) check(sl *slab) error {
+	wordCap, dataOffset := uint32(cap(sl.bitSet().Bytes())), uint32(sl.getDataOffset())
+	if wordCap != l.wordCap || dataOffset != l.dataOffset {
+		return fmt.Errorf("failed because the slabs of the snapshot have %d BitSet words and their objects at offset %d, but the slabs of the store have %d BitSet words and their objects at offset %d: %w", l.wordCap, l.dataOffset, wordCap, dataOffset, ErrLayoutMismatch)
+	}
+	return nil
+}
+
+// writeSnapshotHeader writes the header of the current version with the
+// given magic, the byte order is always little endian
+func writeSnapshotHeader(w io.Writer, magic [4]byte, poolCount uint32) {
+	w.Write(magic[:])
+	binary.Write(w, binary.LittleEndian, uint16(snapshotVersion))
+	w.Write([]byte{snapshotLittleEndian, 1})
+	binary.Write(w, binary.LittleEndian, poolCount)
+}
+
+// readSnapshotHeader reads the header of a snapshot with the given magic,
+// the version field is little endian in all the versions
+// On success it returns the header and nil
+// On failure the second returned value is the error
+func readSnapshotHeader(br *bufio.Reader, magic [4]byte) (snapshotHeader, error) {
+	var fixed struct {
+		Magic   [4]byte
+		Version uint16
+	}
+	if err := binary.Read(br, binary.LittleEndian, &fixed); err != nil {
+		return snapshotHeader{}, fmt.Errorf("failed to read the snapshot header: %w", err)
+	}
+	if fixed.Magic != magic {
+		return snapshotHeader{}, fmt.Errorf("failed because the data is not a snapshot of the expected kind")
+	}
+
+	h := snapshotHeader{version: fixed.Version, order: binary.LittleEndian, objSizeWidth: 1}
+	switch {
+	case h.version == 1 || h.version == 2:
+		// little endian with one byte object sizes
+	case h.version >= 3 && h.version <= snapshotVersion:
+		var encoding [2]byte
+		if _, err := io.ReadFull(br, encoding[:]); err != nil {
+			return snapshotHeader{}, fmt.Errorf("failed to read the snapshot header: %w", err)
+		}
+		switch encoding[0] {
+		case snapshotLittleEndian:
+		case snapshotBigEndian:
+			h.order = binary.BigEndian
+		default:
+			return snapshotHeader{}, fmt.Errorf("failed because byte order %d is not supported", encoding[0])
+		}
+		h.objSizeWidth = int(encoding[1])
+		if h.objSizeWidth != 1 && h.objSizeWidth != 2 && h.objSizeWidth != 4 {
+			return snapshotHeader{}, fmt.Errorf("failed because object size width %d is not supported", h.objSizeWidth)
+		}
+	default:
+		return snapshotHeader{}, fmt.Errorf("failed because snapshot version %d is not supported", h.version)
+	}
+
+	if err := binary.Read(br, h.order, &h.poolCount); err != nil {
+		return snapshotHeader{}, fmt.Errorf("failed to read the snapshot header: %w", err)
+	}
+	return h, nil
+}
+
+// snapshotDecoder reads the pools and slabs of a snapshot according to
+// its header
+type snapshotDecoder struct {
+	br *bufio.Reader
+	snapshotHeader
+
+	// r reads from br and feeds the read bytes into crc, which gets reset
+	// at the start of each slab
+	r   io.Reader
+	crc hash.Hash32
+
+	// verify defines whether the checksums get verified by verifySlab
+	verify bool
+}
+
+// newSnapshotDecoder reads the header of a snapshot with the given magic
+// from br and returns a decoder for the rest of it
+// On success it returns the decoder and nil
+// On failure the second returned value is the error
+func newSnapshotDecoder(br *bufio.Reader, magic [4]byte) (*snapshotDecoder, error) {
+	h, err := readSnapshotHeader(br, magic)
+	if err != nil {
+		return nil, err
+	}
+	d := &snapshotDecoder{br: br, snapshotHeader: h, r: br, crc: crc32.NewIEEE(), verify: true}
+	if h.hasChecksums() {
+		d.r = io.TeeReader(br, d.crc)
+	}
+	return d, nil
+}
+
+// readPoolHeader reads the header of the next pool
+// On success it returns the object size, the objects per slab, the number
+// of slabs and nil
+// On failure the fourth returned value is the error
+func (d *snapshotDecoder) readPoolHeader() (uint8, uint, uint32, error) {
+	sizeField := make([]byte, d.objSizeWidth)
+	if _, err := io.ReadFull(d.r, sizeField); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read pool header: %w", err)
+	}
+	var objSize uint32
+	switch d.objSizeWidth {
+	case 1:
+		objSize = uint32(sizeField[0])
+	case 2:
+		objSize = uint32(d.order.Uint16(sizeField))
+	case 4:
+		objSize = d.order.Uint32(sizeField)
+	}
+
+	var counts struct {
+		ObjsPerSlab uint32
+		SlabCount   uint32
+	}
+	if err := binary.Read(d.r, d.order, &counts); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read pool header: %w", err)
+	}
+	if objSize == 0 || objSize > 255 || counts.ObjsPerSlab == 0 {
+		return 0, 0, 0, fmt.Errorf("failed because of an invalid pool header with object size %d and %d objects per slab: %w", objSize, counts.ObjsPerSlab, ErrInvalidSize)
+	}
+
+	return uint8(objSize), uint(counts.ObjsPerSlab), counts.SlabCount, nil
+}
+
+// readPoolLayout reads the layout of the slabs of the pool whose header
+// has just been read. Snapshots without layouts have been written by
+// stores whose slabs have room for the given objects per slab exactly
+// On success it returns the layout and nil
+// On failure the second returned value is the error
+func (d *snapshotDecoder) readPoolLayout(objsPerSlab uint) (slabLayout, error) {
+	if !d.hasSlabLayouts() {
+		return layoutForCapacity(objsPerSlab), nil
+	}
+
+	var layout struct {
+		WordCap    uint32
+		DataOffset uint32
+	}
+	if err := binary.Read(d.r, d.order, &layout); err != nil {
+		return slabLayout{}, fmt.Errorf("failed to read pool header: %w", err)
+	}
+	return slabLayout{wordCap: layout.WordCap, dataOffset: layout.DataOffset}, nil
+}
+
+// writePoolLayout writes the layout of the slabs of a pool section
+func writePoolLayout(w io.Writer, layout slabLayout) {
+	binary.Write(w, binary.LittleEndian, layout.wordCap)
+	binary.Write(w, binary.LittleEndian, layout.dataOffset)
+}
+
+// readSlabHeader reads the address and the id of the next slab, the id is
+// 0 if the snapshot doesn't contain slab ids
+// On success the third returned value is nil, otherwise it is the error
+func (d *snapshotDecoder) readSlabHeader() (uint64, uint32, error) {
+	d.crc.Reset()
+
+	var addr uint64
+	var id uint32
+	if err := binary.Read(d.r, d.order, &addr); err != nil {
+		return 0, 0, fmt.Errorf("failed to read slab: %w", err)
+	}
+	if d.hasSlabIDs() {
+		if err := binary.Read(d.r, d.order, &id); err != nil {
+			return 0, 0, fmt.Errorf("failed to read slab: %w", err)
+		}
+	}
+	return addr, id, nil
+}
+
+// readWords reads the BitSet words of a slab
+// On success it returns nil, otherwise it returns the error
+func (d *snapshotDecoder) readWords(words []uint64) error {
+	if err := binary.Read(d.r, d.order, words); err != nil {
+		return fmt.Errorf("failed to read slab: %w", err)
+	}
+	return nil
+}
+
+// readData reads the object data of a slab
+// On success it returns nil, otherwise it returns the error
+func (d *snapshotDecoder) readData(data []byte) error {
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return fmt.Errorf("failed to read slab: %w", err)
+	}
+	return nil
+}
+
+// readDirty reads the dirty flag of a slab in an incremental snapshot
+// On success it returns the flag and nil
+// On failure the second returned value is the error
+func (d *snapshotDecoder) readDirty() (bool, error) {
+	var dirty [1]byte
+	if _, err := io.ReadFull(d.r, dirty[:]); err != nil {
+		return false, fmt.Errorf("failed to read slab: %w", err)
+	}
+	return dirty[0] != 0, nil
+}
+
+// verifySlab reads the checksum of the slab which has just been read and
+// compares it with the checksum of the read bytes. Snapshots without
+// checksums are always valid
+// On success it returns nil, if the checksum doesn't match the returned
+// error wraps a *ChecksumError which identifies the slab by the given fields
+func (d *snapshotDecoder) verifySlab(addr uint64, id uint32, objSize uint8) error {
+	if !d.hasChecksums() {
+		return nil
+	}
+
+	var expected uint32
+	if err := binary.Read(d.br, d.order, &expected); err != nil {
+		return fmt.Errorf("failed to read slab checksum: %w", err)
+	}
+	if actual := d.crc.Sum32(); d.verify && actual != expected {
+		return fmt.Errorf("failed because %w", &ChecksumError{Slab: addr, SlabID: id, ObjSize: objSize, Expected: expected, Actual: actual})
+	}
+	return nil
+}
+
+// MigrateSnapshot reads a snapshot which has been written by any version
+// of WriteTo and writes it to w in the current version of the format.
+// Slabs of snapshots without slab ids get the id 0, so they get new ids
+// when the migrated snapshot gets restored. The checksums of the slabs get
+// verified if the snapshot contains them, otherwise they get calculated
+// On success it returns nil, otherwise it returns an error
+func MigrateSnapshot(r io.Reader, w io.Writer) error {
+	d, err := newSnapshotDecoder(bufio.NewReader(r), snapshotMagic)
+	if err != nil {
+		return fmt.Errorf("ObjectStore: MigrateSnapshot %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	writeSnapshotHeader(bw, snapshotMagic, d.poolCount)
+	for i := uint32(0); i < d.poolCount; i++ {
+		objSize, objsPerSlab, slabCount, err := d.readPoolHeader()
+		if err != nil {
+			return fmt.Errorf("ObjectStore: MigrateSnapshot %w", err)
+		}
+		layout, err := d.readPoolLayout(objsPerSlab)
+		if err != nil {
+			return fmt.Errorf("ObjectStore: MigrateSnapshot %w", err)
+		}
+		bw.WriteByte(objSize)
+		binary.Write(bw, binary.LittleEndian, uint32(objsPerSlab))
+		binary.Write(bw, binary.LittleEndian, slabCount)
+		writePoolLayout(bw, layout)
+
+		words := make([]uint64, (objsPerSlab+63)/64)
+		data := make([]byte, int(objSize)*int(objsPerSlab))
+		for j := uint32(0); j < slabCount; j++ {
+			addr, id, err := d.readSlabHeader()
+			if err == nil {
+				err = d.readWords(words)
+			}
+			if err == nil {
+				err = d.readData(data)
+			}
+			if err == nil {
+				err = d.verifySlab(addr, id, objSize)
+			}
+			if err != nil {
+				return fmt.Errorf("ObjectStore: MigrateSnapshot %w", err)
+			}
+
+			crc := crc32.NewIEEE()
+			sw := io.MultiWriter(bw, crc)
+			binary.Write(sw, binary.LittleEndian, addr)
+			binary.Write(sw, binary.LittleEndian, id)
+			binary.Write(sw, binary.LittleEndian, words)
+			sw.Write(data)
+			binary.Write(bw, binary.LittleEndian, crc.Sum32())
+		}
+	}
+
+	return bw.Flush()
+}
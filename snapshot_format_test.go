@@ -0,0 +1,136 @@
+package gos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRestoreBigEndian(t *testing.T) {
+	Convey("When restoring a big endian version 3 snapshot with two byte object sizes", t, func() {
+		var buf bytes.Buffer
+		buf.WriteString("GOSS")
+		binary.Write(&buf, binary.LittleEndian, uint16(3))
+		buf.Write([]byte{snapshotBigEndian, 2})
+		binary.Write(&buf, binary.BigEndian, uint32(1))
+		binary.Write(&buf, binary.BigEndian, uint16(2))
+		binary.Write(&buf, binary.BigEndian, uint32(2))
+		binary.Write(&buf, binary.BigEndian, uint32(1))
+		binary.Write(&buf, binary.BigEndian, uint64(0x1000))
+		binary.Write(&buf, binary.BigEndian, uint32(7))
+		binary.Write(&buf, binary.BigEndian, uint64(2))
+		buf.WriteString("\x00\x00cd")
+
+		os := NewObjectStore()
+		table, err := os.Restore(&buf)
+		So(err, ShouldBeNil)
+
+		Convey("the integers should have been decoded in the declared byte order", func() {
+			obj, ok := table.TranslateRel(newRelAddr(7, uint32(1+sizeOfBitSet+8+2)))
+			So(ok, ShouldBeTrue)
+			abs, err := os.Absolute(obj)
+			So(err, ShouldBeNil)
+			data, err := os.Get(abs)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "cd")
+		})
+	})
+
+	Convey("When restoring a snapshot with an object size that doesn't fit into a byte", t, func() {
+		var buf bytes.Buffer
+		buf.WriteString("GOSS")
+		binary.Write(&buf, binary.LittleEndian, uint16(snapshotVersion))
+		buf.Write([]byte{snapshotLittleEndian, 2})
+		binary.Write(&buf, binary.LittleEndian, uint32(1))
+		binary.Write(&buf, binary.LittleEndian, uint16(256))
+		binary.Write(&buf, binary.LittleEndian, uint32(2))
+		binary.Write(&buf, binary.LittleEndian, uint32(0))
+
+		_, err := NewObjectStore().Restore(&buf)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestMigrateSnapshot(t *testing.T) {
+	Convey("When migrating a snapshot in the first version of the format", t, func() {
+		var v1 bytes.Buffer
+		v1.WriteString("GOSS")
+		binary.Write(&v1, binary.LittleEndian, uint16(1))
+		binary.Write(&v1, binary.LittleEndian, uint32(1))
+		v1.WriteByte(2)
+		binary.Write(&v1, binary.LittleEndian, uint32(2))
+		binary.Write(&v1, binary.LittleEndian, uint32(1))
+		binary.Write(&v1, binary.LittleEndian, uint64(0x1000))
+		binary.Write(&v1, binary.LittleEndian, uint64(1))
+		v1.WriteString("ab\x00\x00")
+
+		var migrated bytes.Buffer
+		So(MigrateSnapshot(&v1, &migrated), ShouldBeNil)
+
+		Convey("it should be written in the current version with slab id 0", func() {
+			data := migrated.Bytes()
+			So(binary.LittleEndian.Uint16(data[4:]), ShouldEqual, snapshotVersion)
+			So(len(data), ShouldEqual, snapshotHeaderSize+17+(8+4+8+4+4))
+			So(binary.LittleEndian.Uint32(data[snapshotHeaderSize+17+8:]), ShouldEqual, 0)
+		})
+
+		Convey("restoring it should assign a new slab id", func() {
+			os := NewObjectStore()
+			table, err := os.Restore(&migrated)
+			So(err, ShouldBeNil)
+
+			obj, ok := table.Translate(0x1000 + 1 + sizeOfBitSet + 8)
+			So(ok, ShouldBeTrue)
+			data, err := os.Get(obj)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ab")
+
+			rel, err := os.Relative(obj)
+			So(err, ShouldBeNil)
+			So(rel.SlabID(), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestSnapshotChecksums(t *testing.T) {
+	Convey("When restoring a snapshot with a corrupted slab", t, func() {
+		src := NewObjectStore(WithObjsPerSlab(2))
+		obj, err := src.Add([]byte("ab"))
+		So(err, ShouldBeNil)
+		_, slabAddr, _ := src.Owner(obj)
+		id, _ := src.slabID(slabAddr)
+
+		var buf bytes.Buffer
+		_, err = src.WriteTo(&buf)
+		So(err, ShouldBeNil)
+		data := buf.Bytes()
+		// the first object of the only slab
+		data[snapshotHeaderSize+17+8+4+8] = 'x'
+
+		Convey("the error should name the corrupted slab", func() {
+			os := NewObjectStore()
+			_, err := os.Restore(bytes.NewReader(data))
+			var checksumErr *ChecksumError
+			So(errors.As(err, &checksumErr), ShouldBeTrue)
+			So(errors.Is(err, ErrChecksumMismatch), ShouldBeTrue)
+			So(checksumErr.Slab, ShouldEqual, slabAddr)
+			So(checksumErr.SlabID, ShouldEqual, id)
+			So(checksumErr.ObjSize, ShouldEqual, 2)
+			So(len(os.Slabs()), ShouldEqual, 0)
+		})
+
+		Convey("the slab should get loaded if the verification is skipped", func() {
+			os := NewObjectStore(WithoutChecksumVerification())
+			table, err := os.Restore(bytes.NewReader(data))
+			So(err, ShouldBeNil)
+			restored, ok := table.Translate(obj)
+			So(ok, ShouldBeTrue)
+			value, err := os.Get(restored)
+			So(err, ShouldBeNil)
+			So(string(value), ShouldEqual, "xb")
+		})
+	})
+}
@@ -0,0 +1,234 @@
+package gos
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SnapshotSink is a destination that snapshots can be saved to by
+// SaveSnapshot and SaveIncremental
+type SnapshotSink interface {
+	// Create returns a writer for the snapshot with the given name. The
+	// snapshot is only complete once Close has returned nil, if writing it
+	// fails Close isn't called and the writer gets discarded
+	Create(name string) (io.WriteCloser, error)
+}
+
+// SnapshotSource is a location that snapshots can be loaded from by
+// LoadSnapshot and LoadIncremental
+type SnapshotSource interface {
+	// Open returns a reader for the snapshot with the given name
+	Open(name string) (io.ReadCloser, error)
+}
+
+// SaveSnapshot writes a snapshot like WriteTo to the given sink
+// On success it returns the number of written bytes and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) SaveSnapshot(sink SnapshotSink, name string) (int64, error) {
+	return o.saveSnapshot(sink, name, o.WriteTo)
+}
+
+// SaveIncremental writes an incremental snapshot like WriteIncremental to
+// the given sink
+// On success it returns the number of written bytes and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) SaveIncremental(sink SnapshotSink, name string) (int64, error) {
+	return o.saveSnapshot(sink, name, o.WriteIncremental)
+}
+
+// saveSnapshot creates the snapshot with the given name in the sink and
+// writes it with write
+func (o *ObjectStore) saveSnapshot(sink SnapshotSink, name string, write func(io.Writer) (int64, error)) (int64, error) {
+	w, err := sink.Create(name)
+	if err != nil {
+		return 0, fmt.Errorf("ObjectStore: Failed to create snapshot %q: %w", name, err)
+	}
+
+	n, err := write(w)
+	if err != nil {
+		if a, ok := w.(aborter); ok {
+			a.abort()
+		}
+		return n, err
+	}
+	if err := w.Close(); err != nil {
+		return n, fmt.Errorf("ObjectStore: Failed to save snapshot %q: %w", name, err)
+	}
+
+	return n, nil
+}
+
+// LoadSnapshot restores the snapshot with the given name from the source
+// like Restore
+// On success it returns the relocation table and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) LoadSnapshot(src SnapshotSource, name string) (*RelocationTable, error) {
+	return o.loadSnapshot(src, name, o.Restore)
+}
+
+// LoadIncremental applies the incremental snapshot with the given name from
+// the source like ApplyIncremental
+// On success it returns the relocation table and nil
+// On failure the second returned value is the error
+func (o *ObjectStore) LoadIncremental(src SnapshotSource, name string) (*RelocationTable, error) {
+	return o.loadSnapshot(src, name, o.ApplyIncremental)
+}
+
+// loadSnapshot opens the snapshot with the given name in the source and
+// reads it with read
+func (o *ObjectStore) loadSnapshot(src SnapshotSource, name string, read func(io.Reader) (*RelocationTable, error)) (*RelocationTable, error) {
+	r, err := src.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: Failed to open snapshot %q: %w", name, err)
+	}
+	defer r.Close()
+
+	return read(r)
+}
+
+// aborter is implemented by the writers of sinks which need to clean up if
+// writing a snapshot fails
+type aborter interface {
+	abort()
+}
+
+// DirSnapshots stores snapshots as files in a directory. A snapshot gets
+// written to a temporary file, which only replaces the file with the
+// snapshot's name once it is complete and has been flushed to disk
+type DirSnapshots struct {
+	Dir string
+}
+
+// Create implements SnapshotSink
+func (d DirSnapshots) Create(name string) (io.WriteCloser, error) {
+	file, err := os.CreateTemp(d.Dir, "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &dirSnapshotFile{File: file, path: filepath.Join(d.Dir, name)}, nil
+}
+
+// Open implements SnapshotSource
+func (d DirSnapshots) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.Dir, name))
+}
+
+// dirSnapshotFile is the temporary file of a snapshot that is being
+// written to a DirSnapshots
+type dirSnapshotFile struct {
+	*os.File
+	path string
+}
+
+// Close flushes the file to disk and renames it to the snapshot's name
+func (f *dirSnapshotFile) Close() error {
+	if err := f.File.Sync(); err != nil {
+		f.abort()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (f *dirSnapshotFile) abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// WriterSink writes every snapshot to W, regardless of its name
+type WriterSink struct {
+	W io.Writer
+}
+
+// Create implements SnapshotSink
+func (s WriterSink) Create(name string) (io.WriteCloser, error) {
+	return nopWriteCloser{s.W}, nil
+}
+
+// ReaderSource reads every snapshot from R, regardless of its name
+type ReaderSource struct {
+	R io.Reader
+}
+
+// Open implements SnapshotSource
+func (s ReaderSource) Open(name string) (io.ReadCloser, error) {
+	return io.NopCloser(s.R), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// ObjectStorageClient is the subset of an S3-style object storage API that
+// is needed to store snapshots, an adapter for the client of the storage
+// service in use needs to implement it
+type ObjectStorageClient interface {
+	// PutObject uploads the object with the given key, reading its content
+	// from r until io.EOF. If reading from r fails the upload must fail
+	PutObject(key string, r io.Reader) error
+
+	// GetObject returns a reader for the object with the given key
+	GetObject(key string) (io.ReadCloser, error)
+}
+
+// ObjectStorageSnapshots stores snapshots as objects in an object storage,
+// the key of each snapshot is Prefix followed by its name. The snapshots
+// get streamed to the client while they are written
+type ObjectStorageSnapshots struct {
+	Client ObjectStorageClient
+	Prefix string
+}
+
+// Create implements SnapshotSink
+func (s ObjectStorageSnapshots) Create(name string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	upload := &objectUpload{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := s.Client.PutObject(s.Prefix+name, pr)
+		// unblock the writer if the upload has stopped reading early
+		pr.CloseWithError(fmt.Errorf("upload has finished"))
+		upload.done <- err
+	}()
+	return upload, nil
+}
+
+// Open implements SnapshotSource
+func (s ObjectStorageSnapshots) Open(name string) (io.ReadCloser, error) {
+	return s.Client.GetObject(s.Prefix + name)
+}
+
+// objectUpload is the writer of a snapshot that is being uploaded to an
+// object storage
+type objectUpload struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (u *objectUpload) Write(p []byte) (int, error) {
+	return u.pw.Write(p)
+}
+
+// Close completes the upload and waits for its result
+func (u *objectUpload) Close() error {
+	u.pw.Close()
+	return <-u.done
+}
+
+// abort makes the upload fail, because the snapshot is incomplete
+func (u *objectUpload) abort() {
+	u.pw.CloseWithError(fmt.Errorf("ObjectStore: writing the snapshot has failed"))
+	<-u.done
+}
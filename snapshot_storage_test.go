@@ -0,0 +1,105 @@
+package gos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// memObjectStorage is an in-memory ObjectStorageClient
+type memObjectStorage struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memObjectStorage) PutObject(key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memObjectStorage) GetObject(key string) (io.ReadCloser, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("no such key %q", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestSnapshotStorage(t *testing.T) {
+	Convey("When saving snapshots to the different sinks", t, func() {
+		src := NewObjectStore()
+		obj, err := src.Add([]byte("abc"))
+		So(err, ShouldBeNil)
+
+		dir := DirSnapshots{Dir: t.TempDir()}
+		objectStorage := ObjectStorageSnapshots{Client: &memObjectStorage{objects: make(map[string][]byte)}, Prefix: "snapshots/"}
+		var buf bytes.Buffer
+
+		for _, storage := range []struct {
+			sink   SnapshotSink
+			source SnapshotSource
+		}{
+			{dir, dir},
+			{objectStorage, objectStorage},
+			{WriterSink{&buf}, ReaderSource{&buf}},
+		} {
+			n, err := src.SaveSnapshot(storage.sink, "base")
+			So(err, ShouldBeNil)
+			So(n, ShouldBeGreaterThan, 0)
+
+			dst := NewObjectStore()
+			table, err := dst.LoadSnapshot(storage.source, "base")
+			So(err, ShouldBeNil)
+			restored, ok := table.Translate(obj)
+			So(ok, ShouldBeTrue)
+			data, err := dst.Get(restored)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "abc")
+		}
+
+		Convey("a failed snapshot should not replace the previous one", func() {
+			_, err := src.SaveSnapshot(failingSink{dir}, "base")
+			So(err, ShouldNotBeNil)
+
+			entries, err := os.ReadDir(dir.Dir)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			_, err = NewObjectStore().LoadSnapshot(dir, "base")
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+// failingSink creates writers whose writes fail
+type failingSink struct {
+	DirSnapshots
+}
+
+func (s failingSink) Create(name string) (io.WriteCloser, error) {
+	w, err := s.DirSnapshots.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return failingFile{w.(*dirSnapshotFile)}, nil
+}
+
+type failingFile struct {
+	*dirSnapshotFile
+}
+
+func (f failingFile) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("write failed")
+}
@@ -0,0 +1,153 @@
+package gos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteTo(t *testing.T) {
+	Convey("When writing a snapshot of a store with two pools", t, func() {
+		os := NewObjectStore(WithObjsPerSlab(2))
+		obj, err := os.Add([]byte("ab"))
+		So(err, ShouldBeNil)
+		_, err = os.Add([]byte("cde"))
+		So(err, ShouldBeNil)
+
+		var buf bytes.Buffer
+		n, err := os.WriteTo(&buf)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, buf.Len())
+
+		Convey("the snapshot should contain the pools and their slabs", func() {
+			data := buf.Bytes()
+			So(string(data[:4]), ShouldEqual, "GOSS")
+			So(binary.LittleEndian.Uint16(data[4:]), ShouldEqual, snapshotVersion)
+			So(data[6], ShouldEqual, snapshotLittleEndian)
+			So(data[7], ShouldEqual, 1)
+			So(binary.LittleEndian.Uint32(data[8:]), ShouldEqual, 2)
+
+			// the first pool has object size 2
+			pool := data[12:]
+			So(pool[0], ShouldEqual, 2)
+			So(binary.LittleEndian.Uint32(pool[1:]), ShouldEqual, 2)
+			So(binary.LittleEndian.Uint32(pool[5:]), ShouldEqual, 1)
+			So(binary.LittleEndian.Uint32(pool[9:]), ShouldEqual, 1)
+			So(binary.LittleEndian.Uint32(pool[13:]), ShouldEqual, 1+sizeOfBitSet+8)
+			_, slabAddr, _ := os.Owner(obj)
+			So(binary.LittleEndian.Uint64(pool[17:]), ShouldEqual, slabAddr)
+			id, _ := os.slabID(slabAddr)
+			So(binary.LittleEndian.Uint32(pool[25:]), ShouldEqual, id)
+			So(binary.LittleEndian.Uint64(pool[29:]), ShouldEqual, 1)
+			So(string(pool[37:41]), ShouldEqual, "ab\x00\x00")
+
+			So(binary.LittleEndian.Uint32(pool[41:]), ShouldEqual, crc32.ChecksumIEEE(pool[17:41]))
+
+			// 4+2+2+4 bytes of header and two pools with one slab each
+			So(len(data), ShouldEqual, 12+(17+20+2*2+4)+(17+20+2*3+4))
+		})
+	})
+}
+
+func TestRestore(t *testing.T) {
+	Convey("When restoring a snapshot into a new store", t, func() {
+		src := NewObjectStore(WithObjsPerSlab(3))
+		var objs []ObjAddr
+		for _, value := range []string{"ab", "cd", "ef", "gh", "xyz"} {
+			obj, err := src.Add([]byte(value))
+			So(err, ShouldBeNil)
+			objs = append(objs, obj)
+		}
+		So(src.Delete(objs[1]), ShouldBeNil)
+
+		var buf bytes.Buffer
+		_, err := src.WriteTo(&buf)
+		So(err, ShouldBeNil)
+
+		dst := NewObjectStore()
+		table, err := dst.Restore(&buf)
+		So(err, ShouldBeNil)
+
+		Convey("the objects should be reachable through the relocation table", func() {
+			for i, value := range []string{"ab", "", "ef", "gh", "xyz"} {
+				obj, ok := table.Translate(objs[i])
+				So(ok, ShouldBeTrue)
+				So(dst.Contains(obj), ShouldEqual, value != "")
+				if value != "" {
+					data, err := dst.Get(obj)
+					So(err, ShouldBeNil)
+					So(string(data), ShouldEqual, value)
+				}
+			}
+			_, ok := table.Translate(0)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("the restored pools should keep the layout of the snapshot", func() {
+			pool, ok := dst.getSlabPool(2)
+			So(ok, ShouldBeTrue)
+			So(pool.objsPerSlab, ShouldEqual, 3)
+		})
+
+		Convey("freed slots should be reused", func() {
+			obj, err := dst.Add([]byte("ij"))
+			So(err, ShouldBeNil)
+			old, _ := table.Translate(objs[1])
+			So(obj, ShouldEqual, old)
+		})
+	})
+
+	Convey("When restoring invalid data", t, func() {
+		os := NewObjectStore()
+		_, err := os.Restore(bytes.NewReader([]byte("nope")))
+		So(err, ShouldNotBeNil)
+
+		Convey("a truncated snapshot should not leave any slabs behind", func() {
+			src := NewObjectStore()
+			_, err := src.Add([]byte("ab"))
+			So(err, ShouldBeNil)
+			var buf bytes.Buffer
+			_, err = src.WriteTo(&buf)
+			So(err, ShouldBeNil)
+
+			n, err := os.ReadFrom(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+			So(err, ShouldNotBeNil)
+			So(n, ShouldEqual, buf.Len()-1)
+			So(len(os.Slabs()), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestRestoreVersion1(t *testing.T) {
+	Convey("When restoring a snapshot in the first version of the format", t, func() {
+		var buf bytes.Buffer
+		buf.WriteString("GOSS")
+		binary.Write(&buf, binary.LittleEndian, uint16(1))
+		binary.Write(&buf, binary.LittleEndian, uint32(1))
+		buf.WriteByte(2)
+		binary.Write(&buf, binary.LittleEndian, uint32(2))
+		binary.Write(&buf, binary.LittleEndian, uint32(1))
+		binary.Write(&buf, binary.LittleEndian, uint64(0x1000))
+		binary.Write(&buf, binary.LittleEndian, uint64(1))
+		buf.WriteString("ab\x00\x00")
+
+		os := NewObjectStore()
+		table, err := os.Restore(&buf)
+		So(err, ShouldBeNil)
+
+		Convey("the slabs should get new ids", func() {
+			obj, ok := table.Translate(0x1000 + 1 + sizeOfBitSet + 8)
+			So(ok, ShouldBeTrue)
+			data, err := os.Get(obj)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ab")
+
+			rel, err := os.Relative(obj)
+			So(err, ShouldBeNil)
+			So(rel.SlabID(), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,284 @@
+package gos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"os"
+	"sync"
+)
+
+// SnapshotView gives read-only access to the objects in a snapshot file
+// which has been written by WriteTo, without restoring it. The file gets
+// mapped read-only, so opening it is fast regardless of its size, its pages
+// only get read when they are accessed and the page cache is shared by all
+// the processes that have opened the same snapshot.
+// Objects are identified by their relative addresses, see RelAddr
+type SnapshotView struct {
+	data  []byte
+	pools []viewPool
+
+	// slabs maps the slab ids to the slabs, it gets built on first use
+	// because it requires reading the header of every slab
+	slabsOnce sync.Once
+	slabs     map[uint32]viewSlab
+}
+
+// viewPool is a pool in a snapshot file. Its slabs have a fixed size, so
+// they can be located without reading them
+type viewPool struct {
+	objSize     uint8
+	objsPerSlab uint
+	slabCount   int
+	layout      slabLayout
+
+	// start is the offset of the first slab in the file, stride is the
+	// size of each slab in the file
+	start  int
+	stride int
+
+	// checksums is true if each slab is followed by its checksum
+	checksums bool
+}
+
+// viewSlab is a slab in a snapshot file
+type viewSlab struct {
+	pool *viewPool
+
+	// words and data refer to the BitSet words and the objects of the slab
+	words []byte
+	data  []byte
+}
+
+// wordCount returns the number of BitSet words of each slab in the pool
+func (p *viewPool) wordCount() int {
+	return int((p.objsPerSlab + 63) / 64)
+}
+
+// dataOffset returns the offset of the objects in the original slabs,
+// relative addresses are based on it
+func (p *viewPool) dataOffset() uint32 {
+	return p.layout.dataOffset
+}
+
+// slab returns the slab at the given index in the pool and its id
+func (p *viewPool) slab(data []byte, idx int) (uint32, viewSlab) {
+	pos := p.start + idx*p.stride
+	id := binary.LittleEndian.Uint32(data[pos+8:])
+	wordsEnd := pos + 12 + p.wordCount()*8
+	return id, viewSlab{pool: p, words: data[pos+12 : wordsEnd], data: data[wordsEnd : wordsEnd+int(p.objSize)*int(p.objsPerSlab)]}
+}
+
+// verifySlab compares the checksum of the slab at the given index in the
+// pool with its content
+// On success it returns nil, otherwise it returns a *ChecksumError
+func (p *viewPool) verifySlab(data []byte, idx int) error {
+	pos := p.start + idx*p.stride
+	end := pos + p.stride - snapshotChecksumSize
+	expected := binary.LittleEndian.Uint32(data[end:])
+	if actual := crc32.ChecksumIEEE(data[pos:end]); actual != expected {
+		return &ChecksumError{Slab: binary.LittleEndian.Uint64(data[pos:]), SlabID: binary.LittleEndian.Uint32(data[pos+8:]), ObjSize: p.objSize, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// OpenSnapshot maps the snapshot file at the given path read-only. Only
+// the headers of the pools get read, so this doesn't depend on the number
+// of slabs. The snapshot must contain slab ids and be little endian, older
+// snapshots can be converted by MigrateSnapshot
+// On success it returns the view and nil
+// On failure the second returned value is the error
+func OpenSnapshot(path string) (*SnapshotView, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: Failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: Failed to open snapshot: %w", err)
+	}
+	if info.Size() < 10 {
+		return nil, fmt.Errorf("ObjectStore: Failed to open snapshot because it is too small: %w", ErrInvalidSize)
+	}
+
+	data, err := mapFileReadOnly(file, int(info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: Failed to map snapshot: %w: %w", ErrMmapFailed, err)
+	}
+
+	v := &SnapshotView{data: data}
+	if err := v.readPools(); err != nil {
+		unmapFile(data)
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// readPools reads the snapshot header and the pool headers
+// On success it returns nil, otherwise it returns an error
+func (v *SnapshotView) readPools() error {
+	if !bytes.Equal(v.data[:4], snapshotMagic[:]) {
+		return fmt.Errorf("ObjectStore: Failed to open snapshot because the file is not a snapshot")
+	}
+
+	// the slabs get accessed in place, so the snapshot must have slab ids,
+	// little endian integers and one byte object sizes
+	pos := 10
+	version := binary.LittleEndian.Uint16(v.data[4:])
+	switch version {
+	case 2:
+	case 3, 4, snapshotVersion:
+		if len(v.data) < snapshotHeaderSize {
+			return fmt.Errorf("ObjectStore: Failed to open snapshot because it has been truncated: %w", ErrInvalidSize)
+		}
+		if v.data[6] != snapshotLittleEndian || v.data[7] != 1 {
+			return fmt.Errorf("ObjectStore: Failed to open snapshot because its encoding is not supported, see MigrateSnapshot")
+		}
+		pos = snapshotHeaderSize
+	default:
+		return fmt.Errorf("ObjectStore: Failed to open snapshot because snapshot version %d is not supported", version)
+	}
+	poolCount := binary.LittleEndian.Uint32(v.data[pos-4:])
+
+	header := snapshotHeader{version: version}
+	poolHeaderSize := 9
+	if header.hasSlabLayouts() {
+		poolHeaderSize += 8
+	}
+
+	for i := uint32(0); i < poolCount; i++ {
+		if pos+poolHeaderSize > len(v.data) {
+			return fmt.Errorf("ObjectStore: Failed to open snapshot because it has been truncated: %w", ErrInvalidSize)
+		}
+		p := viewPool{
+			objSize:     v.data[pos],
+			objsPerSlab: uint(binary.LittleEndian.Uint32(v.data[pos+1:])),
+			slabCount:   int(binary.LittleEndian.Uint32(v.data[pos+5:])),
+			start:       pos + poolHeaderSize,
+			checksums:   header.hasChecksums(),
+		}
+		if p.objSize == 0 || p.objsPerSlab == 0 {
+			return fmt.Errorf("ObjectStore: Failed to open snapshot because of an invalid pool header: %w", ErrInvalidSize)
+		}
+		p.layout = layoutForCapacity(p.objsPerSlab)
+		if header.hasSlabLayouts() {
+			p.layout = slabLayout{wordCap: binary.LittleEndian.Uint32(v.data[pos+9:]), dataOffset: binary.LittleEndian.Uint32(v.data[pos+13:])}
+		}
+		p.stride = 12 + p.wordCount()*8 + int(p.objSize)*int(p.objsPerSlab)
+		if p.checksums {
+			p.stride += snapshotChecksumSize
+		}
+
+		pos = p.start + p.slabCount*p.stride
+		if pos > len(v.data) {
+			return fmt.Errorf("ObjectStore: Failed to open snapshot because it has been truncated: %w", ErrInvalidSize)
+		}
+		v.pools = append(v.pools, p)
+	}
+
+	return nil
+}
+
+// Close unmaps the snapshot file, the byte slices returned by Get must not
+// be used anymore afterwards
+// On success it returns nil, otherwise it returns an error
+func (v *SnapshotView) Close() error {
+	return unmapFile(v.data)
+}
+
+// Verify compares the checksums of all the slabs in the snapshot with their
+// content, which requires reading the whole file. Snapshots which have been
+// written by versions without checksums are always valid
+// On success it returns nil, otherwise it returns an error which wraps the
+// *ChecksumError of the first corrupted slab
+func (v *SnapshotView) Verify() error {
+	for i := range v.pools {
+		p := &v.pools[i]
+		if !p.checksums {
+			continue
+		}
+		for j := 0; j < p.slabCount; j++ {
+			if err := p.verifySlab(v.data, j); err != nil {
+				return fmt.Errorf("ObjectStore: Verify failed because %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// indexSlabs builds the map of the slab ids to the slabs
+func (v *SnapshotView) indexSlabs() {
+	v.slabs = make(map[uint32]viewSlab)
+	for i := range v.pools {
+		for j := 0; j < v.pools[i].slabCount; j++ {
+			id, sl := v.pools[i].slab(v.data, j)
+			v.slabs[id] = sl
+		}
+	}
+}
+
+// Get returns the object at the given relative address, the returned byte
+// slice refers to the read-only mapping of the snapshot file
+// On success it returns the object and nil
+// On failure it returns nil and an error, which wraps ErrNotFound if there
+// is no slab with the address' slab id and ErrInvalidAddr if the offset
+// doesn't refer to an object
+func (v *SnapshotView) Get(rel RelAddr) ([]byte, error) {
+	v.slabsOnce.Do(v.indexSlabs)
+
+	sl, ok := v.slabs[rel.SlabID()]
+	if !ok {
+		return nil, fmt.Errorf("ObjectStore: there is no slab with id %d: %w", rel.SlabID(), ErrNotFound)
+	}
+
+	dataOffset := sl.pool.dataOffset()
+	objSize := uint32(sl.pool.objSize)
+	if rel.Offset() < dataOffset || (rel.Offset()-dataOffset)%objSize != 0 || (rel.Offset()-dataOffset)/objSize >= uint32(sl.pool.objsPerSlab) {
+		return nil, fmt.Errorf("ObjectStore: offset %d is not the offset of an object in slab %d: %w", rel.Offset(), rel.SlabID(), ErrInvalidAddr)
+	}
+
+	idx := (rel.Offset() - dataOffset) / objSize
+	word := binary.LittleEndian.Uint64(sl.words[idx/64*8:])
+	if word&(1<<(idx%64)) == 0 {
+		return nil, fmt.Errorf("ObjectStore: offset %d in slab %d refers to an unused slot: %w", rel.Offset(), rel.SlabID(), ErrInvalidAddr)
+	}
+
+	start := idx * objSize
+	return sl.data[start : start+objSize : start+objSize], nil
+}
+
+// Search searches for the given value in the snapshot
+// On success it returns the relative address of the object and true
+// On failure it returns 0 and false
+func (v *SnapshotView) Search(searching []byte) (RelAddr, bool) {
+	for i := range v.pools {
+		p := &v.pools[i]
+		if int(p.objSize) != len(searching) {
+			continue
+		}
+
+		for j := 0; j < p.slabCount; j++ {
+			id, sl := p.slab(v.data, j)
+			for w := 0; w < len(sl.words); w += 8 {
+				for word := binary.LittleEndian.Uint64(sl.words[w:]); word != 0; word &= word - 1 {
+					idx := uint(w/8*64 + bits.TrailingZeros64(word))
+					if idx >= p.objsPerSlab {
+						break
+					}
+					obj := sl.data[idx*uint(p.objSize) : (idx+1)*uint(p.objSize)]
+					if bytes.Equal(obj, searching) {
+						return newRelAddr(id, p.dataOffset()+uint32(idx)*uint32(p.objSize)), true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, false
+}
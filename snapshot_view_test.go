@@ -0,0 +1,81 @@
+package gos
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeSnapshotFile(o *ObjectStore, path string, incremental bool) {
+	file, err := os.Create(path)
+	So(err, ShouldBeNil)
+	if incremental {
+		_, err = o.WriteIncremental(file)
+	} else {
+		_, err = o.WriteTo(file)
+	}
+	So(err, ShouldBeNil)
+	So(file.Close(), ShouldBeNil)
+}
+
+func TestSnapshotView(t *testing.T) {
+	Convey("When opening a snapshot file read-only", t, func() {
+		src := NewObjectStore(WithObjsPerSlab(3))
+		rels := make(map[string]RelAddr)
+		objs := make(map[string]ObjAddr)
+		for _, value := range []string{"ab", "cd", "ef", "gh", "xyz"} {
+			obj, err := src.Add([]byte(value))
+			So(err, ShouldBeNil)
+			rels[value], err = src.Relative(obj)
+			So(err, ShouldBeNil)
+			objs[value] = obj
+		}
+		deleted := rels["cd"]
+		So(src.Delete(objs["cd"]), ShouldBeNil)
+		delete(rels, "cd")
+
+		path := filepath.Join(t.TempDir(), "snapshot")
+		writeSnapshotFile(src, path, false)
+
+		view, err := OpenSnapshot(path)
+		So(err, ShouldBeNil)
+		defer view.Close()
+
+		Convey("the objects should be accessible by their relative addresses", func() {
+			for value, rel := range rels {
+				data, err := view.Get(rel)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, value)
+			}
+
+			_, err := view.Get(deleted)
+			So(errors.Is(err, ErrInvalidAddr), ShouldBeTrue)
+			_, err = view.Get(newRelAddr(1000, 0))
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+
+		Convey("the objects should be searchable", func() {
+			rel, found := view.Search([]byte("gh"))
+			So(found, ShouldBeTrue)
+			So(rel, ShouldEqual, rels["gh"])
+
+			_, found = view.Search([]byte("cd"))
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("the checksums should be valid", func() {
+			So(view.Verify(), ShouldBeNil)
+		})
+	})
+
+	Convey("When opening a file that isn't a full snapshot", t, func() {
+		path := filepath.Join(t.TempDir(), "incremental")
+		writeSnapshotFile(NewObjectStore(), path, true)
+
+		_, err := OpenSnapshot(path)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,233 @@
+package gos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// vectorInitialChunks is the number of chunks that the directory of a new
+// Vector has room for
+const vectorInitialChunks = 16
+
+// Vector is a growable array of fixed-size elements whose memory is off
+// the Go heap. The elements get packed into chunk objects of an
+// ObjectStore, each chunk holds as many elements as fit into an object of
+// at most 255 bytes, and a directory which is mapped by the backend of the
+// store refers to the chunks. A Vector of many small structs doesn't add
+// to the work of the GC like a huge Go slice does. A Vector is safe for
+// concurrent use
+type Vector struct {
+	store *ObjectStore
+
+	elemSize      int
+	elemsPerChunk int
+
+	// lock protects the directory and the chunks
+	lock sync.RWMutex
+
+	// dir contains the address of each chunk, it has room for capacity
+	// chunks
+	dir      []byte
+	capacity int
+	length   int
+}
+
+// NewVector creates an empty Vector of elements of the given size, which
+// must be between 1 and 255 bytes. The chunks get stored in an ObjectStore
+// created with the given options
+// On success it returns the vector and nil
+// On failure the second returned value is the error
+func NewVector(elemSize int, opts ...Option) (*Vector, error) {
+	if elemSize < 1 || elemSize > 255 {
+		return nil, fmt.Errorf("Vector: size of elements (%d) is outside limits (1-%d): %w", elemSize, 255, ErrInvalidSize)
+	}
+
+	v := &Vector{
+		store:         NewObjectStore(opts...),
+		elemSize:      elemSize,
+		elemsPerChunk: 255 / elemSize,
+	}
+
+	dir, err := v.store.mapDirectory(vectorInitialChunks)
+	if err != nil {
+		return nil, err
+	}
+	v.dir = dir
+	v.capacity = vectorInitialChunks
+
+	return v, nil
+}
+
+// chunk returns the address of the chunk at the given index
+// The caller must hold the vector lock
+func (v *Vector) chunk(idx int) ObjAddr {
+	return ObjAddr(binary.LittleEndian.Uint64(v.dir[idx*8:]))
+}
+
+// elem returns the memory of the element at the given index
+// The caller must hold the vector lock and the index must be valid
+func (v *Vector) elem(i int) []byte {
+	offset := (i % v.elemsPerChunk) * v.elemSize
+	return sliceAt(v.chunk(i/v.elemsPerChunk)+uintptr(offset), v.elemSize)
+}
+
+// checkIndex returns an error which wraps ErrOutOfRange if the given index
+// doesn't refer to an element
+// The caller must hold the vector lock
+func (v *Vector) checkIndex(i int) error {
+	if i < 0 || i >= v.length {
+		return fmt.Errorf("Vector: index %d is out of range (0-%d): %w", i, v.length-1, ErrOutOfRange)
+	}
+	return nil
+}
+
+// Append adds the given element to the end of the vector, its size must
+// be the element size of the vector
+// On success it returns nil, otherwise it returns an error
+func (v *Vector) Append(elem []byte) error {
+	if len(elem) != v.elemSize {
+		return fmt.Errorf("Vector: size of element (%d) doesn't match the size of the vector's elements (%d): %w", len(elem), v.elemSize, ErrInvalidSize)
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.length%v.elemsPerChunk == 0 {
+		if err := v.addChunk(); err != nil {
+			return err
+		}
+	}
+
+	v.length++
+	copy(v.elem(v.length-1), elem)
+
+	return nil
+}
+
+// addChunk adds a chunk to the end of the vector, the directory gets
+// doubled if it is full
+// On success it returns nil, otherwise it returns an error
+// The caller must hold the vector lock for writing
+func (v *Vector) addChunk() error {
+	idx := v.length / v.elemsPerChunk
+	if idx == v.capacity {
+		dir, err := v.store.mapDirectory(uint64(v.capacity) * 2)
+		if err != nil {
+			return err
+		}
+		copy(dir, v.dir)
+		if err := v.store.unmapDirectory(v.dir); err != nil && v.store.logger != nil {
+			v.store.logger.Warn("gos: failed to unmap vector directory", "error", err)
+		}
+		v.dir = dir
+		v.capacity *= 2
+	}
+
+	addr, _, err := v.store.AddReserve(v.elemSize * v.elemsPerChunk)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(v.dir[idx*8:], uint64(addr))
+
+	return nil
+}
+
+// Get returns the element at the given index, the returned slice refers to
+// the slab memory, writing to it changes the element. It is only valid
+// until the vector gets truncated below the index
+// On success it returns the element and nil
+// On failure the second returned value wraps ErrOutOfRange
+func (v *Vector) Get(i int) ([]byte, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if err := v.checkIndex(i); err != nil {
+		return nil, err
+	}
+
+	return v.elem(i), nil
+}
+
+// Swap swaps the elements at the given indexes
+// On success it returns nil, otherwise it returns an error which wraps
+// ErrOutOfRange
+func (v *Vector) Swap(i, j int) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err := v.checkIndex(i); err != nil {
+		return err
+	}
+	if err := v.checkIndex(j); err != nil {
+		return err
+	}
+
+	var tmp [255]byte
+	a, b := v.elem(i), v.elem(j)
+	copy(tmp[:], a)
+	copy(a, b)
+	copy(b, tmp[:v.elemSize])
+
+	return nil
+}
+
+// Truncate shortens the vector to the given length, the chunks which
+// aren't used anymore get deleted
+// On success it returns nil, otherwise it returns an error which wraps
+// ErrOutOfRange if the vector is shorter than the given length
+func (v *Vector) Truncate(length int) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if length < 0 || length > v.length {
+		return fmt.Errorf("Vector: length %d is out of range (0-%d): %w", length, v.length, ErrOutOfRange)
+	}
+
+	return v.truncate(length)
+}
+
+// truncate implements Truncate
+// The caller must hold the vector lock for writing
+func (v *Vector) truncate(length int) error {
+	keep := (length + v.elemsPerChunk - 1) / v.elemsPerChunk
+	chunks := (v.length + v.elemsPerChunk - 1) / v.elemsPerChunk
+	for idx := chunks - 1; idx >= keep; idx-- {
+		if err := v.store.Delete(v.chunk(idx)); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(v.dir[idx*8:], 0)
+		v.length = idx * v.elemsPerChunk
+	}
+	v.length = length
+
+	return nil
+}
+
+// Len returns the number of elements of the vector
+func (v *Vector) Len() int {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return v.length
+}
+
+// MemStats returns the memory usage statistics of the store of the vector,
+// the directory is not included
+func (v *Vector) MemStats() StoreMemStats {
+	return v.store.MemStats()
+}
+
+// Close deletes all elements of the vector and unmaps its directory, the
+// vector must not be used anymore afterwards
+// On success it returns nil, otherwise it returns an error
+func (v *Vector) Close() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err := v.truncate(0); err != nil {
+		return err
+	}
+
+	return v.store.unmapDirectory(v.dir)
+}
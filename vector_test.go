@@ -0,0 +1,74 @@
+package gos
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVector(t *testing.T) {
+	Convey("When appending many elements to a vector", t, func() {
+		v, err := NewVector(8, WithObjsPerSlab(16))
+		So(err, ShouldBeNil)
+		elem := make([]byte, 8)
+		for i := 0; i < 1000; i++ {
+			binary.LittleEndian.PutUint64(elem, uint64(i))
+			So(v.Append(elem), ShouldBeNil)
+		}
+
+		Convey("all of them should be returned in order", func() {
+			So(v.Len(), ShouldEqual, 1000)
+			So(v.capacity, ShouldBeGreaterThan, vectorInitialChunks)
+			for i := 0; i < 1000; i++ {
+				elem, err := v.Get(i)
+				So(err, ShouldBeNil)
+				So(binary.LittleEndian.Uint64(elem), ShouldEqual, i)
+			}
+			_, err := v.Get(1000)
+			So(errors.Is(err, ErrOutOfRange), ShouldBeTrue)
+			_, err = v.Get(-1)
+			So(errors.Is(err, ErrOutOfRange), ShouldBeTrue)
+		})
+
+		Convey("swapping elements should exchange their values", func() {
+			So(v.Swap(3, 900), ShouldBeNil)
+			elem, _ := v.Get(3)
+			So(binary.LittleEndian.Uint64(elem), ShouldEqual, 900)
+			elem, _ = v.Get(900)
+			So(binary.LittleEndian.Uint64(elem), ShouldEqual, 3)
+			So(errors.Is(v.Swap(3, 1000), ErrOutOfRange), ShouldBeTrue)
+		})
+
+		Convey("truncating should delete the chunks behind the new end", func() {
+			objs := v.MemStats().LiveObjects
+			So(v.Truncate(100), ShouldBeNil)
+			So(v.Len(), ShouldEqual, 100)
+			So(v.MemStats().LiveObjects, ShouldBeLessThan, objs)
+			_, err := v.Get(100)
+			So(errors.Is(err, ErrOutOfRange), ShouldBeTrue)
+			So(errors.Is(v.Truncate(101), ErrOutOfRange), ShouldBeTrue)
+
+			binary.LittleEndian.PutUint64(elem, 12345)
+			So(v.Append(elem), ShouldBeNil)
+			got, err := v.Get(100)
+			So(err, ShouldBeNil)
+			So(binary.LittleEndian.Uint64(got), ShouldEqual, 12345)
+		})
+
+		Convey("closing the vector should release all of its slabs", func() {
+			So(v.Close(), ShouldBeNil)
+			So(v.MemStats().Slabs, ShouldEqual, 0)
+		})
+
+		Convey("elements of the wrong size should be rejected", func() {
+			So(errors.Is(v.Append(make([]byte, 7)), ErrInvalidSize), ShouldBeTrue)
+		})
+	})
+
+	Convey("When creating a vector with an invalid element size", t, func() {
+		_, err := NewVector(256)
+		So(errors.Is(err, ErrInvalidSize), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,341 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// walFileName is the name of the write-ahead log in the directory of a
+// file backed store
+const walFileName = "wal.log"
+
+// the kinds of operations in the write-ahead log
+const (
+	walAdd    byte = 1
+	walDelete byte = 2
+)
+
+// walOpHeaderSize is the size of an operation without the slot data
+const walOpHeaderSize = 14
+
+// writeAheadLog records the adds and deletes of a file backed store before
+// they get applied to the slabs. When the store gets opened again after a
+// crash the log gets replayed, so the BitSets and the object data of the
+// slabs can't be out of sync.
+//
+// The log consists of records, each one is written by a single write call.
+// A record is laid out like this, all integers are little endian:
+//
+//	length       uint32, the length of the operations
+//	operations   one or more of:
+//	  kind         uint8, walAdd or walDelete
+//	  slab id      uint32
+//	  objSize      uint8
+//	  objsPerSlab  uint32
+//	  slot         uint32
+//	  data         objSize bytes, only if kind is walAdd
+//	checksum     uint32, CRC32 of the operations
+//
+// The operations of a record are either all replayed or none of them is,
+// so AddBatched can't be replayed partially
+type writeAheadLog struct {
+	lock sync.Mutex
+	file *os.File
+
+	// applying is held for reading from the commit of a record until its
+	// operations have been applied, so a checkpoint can't truncate the
+	// log while there are operations which haven't been applied yet
+	applying sync.RWMutex
+
+	backend *FileBackend
+
+	// sync defines whether the log gets flushed to disk after each record,
+	// otherwise the records are only protected against process crashes
+	sync bool
+
+	// err is the error of the first failed write, after that the log
+	// refuses to record further operations, because they would be lost
+	// behind the incomplete record
+	err error
+}
+
+// walRecord is a record of the write-ahead log which is being built
+type walRecord struct {
+	buf []byte
+}
+
+// openWriteAheadLog replays the write-ahead log in the directory of the
+// backend and then truncates it
+// On success it returns the log and nil
+// On failure the second returned value is the error
+func openWriteAheadLog(backend *FileBackend, sync bool) (*writeAheadLog, error) {
+	path := filepath.Join(backend.dir, walFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("ObjectStore: Failed to open the write-ahead log: %w", err)
+	}
+
+	if err := replayWriteAheadLog(backend, file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("ObjectStore: Failed to replay the write-ahead log: %w", err)
+	}
+
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("ObjectStore: Failed to truncate the write-ahead log: %w", err)
+	}
+
+	return &writeAheadLog{file: file, backend: backend, sync: sync}, nil
+}
+
+// newRecord returns an empty record
+func (w *writeAheadLog) newRecord() *walRecord {
+	return &walRecord{buf: make([]byte, 4, 64)}
+}
+
+// add appends the operation which adds obj to the slot at the given index
+// to the record. The data of variable-length objects gets prefixed with
+// their length, like it is stored in the slot
+func (w *writeAheadLog) add(r *walRecord, sl *slab, idx uint, obj []byte, varLen bool) {
+	w.appendOp(r, walAdd, sl, idx)
+	start := len(r.buf)
+	r.buf = append(r.buf, make([]byte, sl.objSize)...)
+	if varLen {
+		r.buf[start] = uint8(len(obj))
+		copy(r.buf[start+1:], obj)
+	} else {
+		copy(r.buf[start:], obj)
+	}
+}
+
+// delete appends the operation which deletes the object in the slot at the
+// given index to the record
+func (w *writeAheadLog) delete(r *walRecord, sl *slab, idx uint) {
+	w.appendOp(r, walDelete, sl, idx)
+}
+
+// appendOp appends the header of an operation to the record
+func (w *writeAheadLog) appendOp(r *walRecord, kind byte, sl *slab, idx uint) {
+	id, _ := w.backend.slabID(sl.addr())
+
+	r.buf = append(r.buf, kind)
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, id)
+	r.buf = append(r.buf, sl.objSize)
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, uint32(sl.objsPerSlab()))
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, uint32(idx))
+}
+
+// commit writes the record to the log, the operations in it must only be
+// applied if it succeeds, and then applied must be called
+// On success it returns nil, otherwise the error of the log file
+func (w *writeAheadLog) commit(r *walRecord) error {
+	w.applying.RLock()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.write(r); err != nil {
+		w.applying.RUnlock()
+		return err
+	}
+	return nil
+}
+
+// applied must be called after the operations of a committed record have
+// been applied
+func (w *writeAheadLog) applied() {
+	w.applying.RUnlock()
+}
+
+// write writes the record to the log file
+// The caller must hold the lock
+func (w *writeAheadLog) write(r *walRecord) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	binary.LittleEndian.PutUint32(r.buf, uint32(len(r.buf)-4))
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, crc32.ChecksumIEEE(r.buf[4:]))
+	if _, err := w.file.Write(r.buf); err != nil {
+		w.err = fmt.Errorf("ObjectStore: Failed to write to the write-ahead log: %w", err)
+		return w.err
+	}
+	if w.sync {
+		if err := w.file.Sync(); err != nil {
+			w.err = fmt.Errorf("ObjectStore: Failed to sync the write-ahead log: %w", err)
+			return w.err
+		}
+	}
+	return nil
+}
+
+// checkpoint calls flush and then truncates the log, because all the
+// recorded operations have been persisted by the slab files
+// Adds and deletes are blocked while the checkpoint is in progress
+func (w *writeAheadLog) checkpoint(flush func() error) error {
+	w.applying.Lock()
+	defer w.applying.Unlock()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("ObjectStore: Failed to truncate the write-ahead log: %w", err)
+	}
+
+	// the incomplete record of a failed write is gone now
+	w.err = nil
+	return nil
+}
+
+// close closes the log file
+func (w *writeAheadLog) close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.file.Close()
+}
+
+// replayWriteAheadLog applies all the records of the given log to the
+// slab files of the backend and flushes them to disk. The operations on
+// slab files which don't exist anymore are skipped. The replay stops at
+// the first incomplete or corrupted record, because the process has
+// crashed while it was writing it
+func replayWriteAheadLog(backend *FileBackend, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(file)
+
+	// the slab files are only mapped once, no matter how many operations
+	// refer to them. Files which don't exist are nil
+	slabs := make(map[uint32]*slab)
+	defer func() {
+		for _, sl := range slabs {
+			if sl != nil {
+				syscall.Munmap(sl.memory())
+			}
+		}
+	}()
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			break
+		}
+		length := binary.LittleEndian.Uint32(header[:])
+		if int64(length) > info.Size() {
+			break
+		}
+		ops := make([]byte, length)
+		if _, err := io.ReadFull(r, ops); err != nil {
+			break
+		}
+		var checksum [4]byte
+		if _, err := io.ReadFull(r, checksum[:]); err != nil || binary.LittleEndian.Uint32(checksum[:]) != crc32.ChecksumIEEE(ops) {
+			break
+		}
+
+		if err := replayOps(backend, slabs, ops); err != nil {
+			return err
+		}
+	}
+
+	for _, sl := range slabs {
+		if sl == nil {
+			continue
+		}
+		if err := msyncMemory(sl.memory()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replayOps applies the operations of a record to the slabs, which get
+// mapped and added to the given map when they are needed
+func replayOps(backend *FileBackend, slabs map[uint32]*slab, ops []byte) error {
+	for len(ops) >= walOpHeaderSize {
+		kind := ops[0]
+		id := binary.LittleEndian.Uint32(ops[1:])
+		objSize := ops[5]
+		objsPerSlab := uint(binary.LittleEndian.Uint32(ops[6:]))
+		idx := uint(binary.LittleEndian.Uint32(ops[10:]))
+		ops = ops[walOpHeaderSize:]
+
+		var data []byte
+		if kind == walAdd {
+			if len(ops) < int(objSize) {
+				return fmt.Errorf("record has been truncated")
+			}
+			data, ops = ops[:objSize], ops[objSize:]
+		}
+		if (kind != walAdd && kind != walDelete) || id == 0 || objSize == 0 || idx >= objsPerSlab {
+			return fmt.Errorf("record contains an invalid operation")
+		}
+
+		sl, ok := slabs[id]
+		if !ok {
+			var err error
+			sl, err = mapLoggedSlab(backend.slabFilePath(id-1), objSize, objsPerSlab)
+			if err != nil {
+				return err
+			}
+			slabs[id] = sl
+		}
+		if sl == nil || sl.objSize != objSize || sl.objsPerSlab() != objsPerSlab {
+			continue
+		}
+
+		if kind == walAdd {
+			copy(sl.getObjByIdx(idx), data)
+			sl.bitSet().Set(idx)
+		} else {
+			sl.bitSet().Clear(idx)
+		}
+	}
+
+	return nil
+}
+
+// mapLoggedSlab maps the slab file at the given path for the replay of the
+// write-ahead log. If the header of the slab hasn't been written before the
+// crash, it gets initialized with the given parameters
+// On success it returns the slab, or nil if the file doesn't exist
+// On failure the second returned value is the error
+func mapLoggedSlab(path string, objSize uint8, objsPerSlab uint) (*slab, error) {
+	data, err := mapFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sl, err := relocateSlab(data)
+	if err == errUninitializedSlab && len(data) == slabSize(objSize, objsPerSlab) {
+		return initSlab(data, objSize, objsPerSlab), nil
+	}
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+	return sl, nil
+}
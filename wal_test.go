@@ -0,0 +1,77 @@
+//go:build unix
+// +build unix
+
+package gos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func walSize(dir string) int64 {
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	So(err, ShouldBeNil)
+	return info.Size()
+}
+
+func TestWriteAheadLog(t *testing.T) {
+	Convey("When using a file backed store with a write-ahead log", t, func() {
+		dir := t.TempDir()
+		os1, backend, err := OpenFileStore(dir, WithObjsPerSlab(4), WithWriteAheadLog(false))
+		So(err, ShouldBeNil)
+
+		obj, err := os1.Add([]byte("ab"))
+		So(err, ShouldBeNil)
+		_, err = os1.AddBatched([][]byte{[]byte("cd"), []byte("ef")})
+		So(err, ShouldBeNil)
+		So(os1.Delete(obj), ShouldBeNil)
+
+		Convey("the operations should be recorded in the log", func() {
+			// an add record, a batch record with two adds and a delete record
+			So(walSize(dir), ShouldEqual, (4+14+2+4)+(4+2*(14+2)+4)+(4+14+4))
+
+			Convey("and the log should be truncated by Sync", func() {
+				So(backend.Sync(), ShouldBeNil)
+				So(walSize(dir), ShouldEqual, 0)
+				So(backend.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("the log should be replayed if the slabs haven't been written", func() {
+			So(backend.Close(), ShouldBeNil)
+
+			// simulate that none of the slab's pages had been written
+			// back to the file when the system crashed
+			path := filepath.Join(dir, "slab-0.gos")
+			info, err := os.Stat(path)
+			So(err, ShouldBeNil)
+			So(os.WriteFile(path, make([]byte, info.Size()), 0o600), ShouldBeNil)
+
+			// a record that has been torn by the crash gets ignored
+			wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0)
+			So(err, ShouldBeNil)
+			_, err = wal.Write([]byte{30, 0, 0, 0, walAdd, 1})
+			So(err, ShouldBeNil)
+			So(wal.Close(), ShouldBeNil)
+
+			os2, backend2, err := OpenFileStore(dir, WithWriteAheadLog(false))
+			So(err, ShouldBeNil)
+			defer backend2.Close()
+			So(walSize(dir), ShouldEqual, 0)
+			So(os2.CheckIntegrity(), ShouldBeNil)
+
+			_, found := os2.Search([]byte("ab"))
+			So(found, ShouldBeFalse)
+			for _, value := range []string{"cd", "ef"} {
+				obj, found := os2.Search([]byte(value))
+				So(found, ShouldBeTrue)
+				data, err := os2.Get(obj)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, value)
+			}
+		})
+	})
+}